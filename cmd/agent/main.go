@@ -1,19 +1,25 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 
 	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/agent/collector"
 	"github.com/moonicy/gometrics/internal/agent/workerpool"
 	metricsClient "github.com/moonicy/gometrics/internal/client"
 	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/pkg/breaker"
+	"github.com/moonicy/gometrics/pkg/certs"
 )
 
 var (
@@ -41,17 +47,45 @@ func main() {
 
 	cfg.Host = config.ParseURI(cfg.Host)
 
+	var tlsConfig *tls.Config
+	if cfg.TLSCA != "" || cfg.TLSCert != "" {
+		var err error
+		tlsConfig, err = certs.ClientTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.Host = strings.Replace(cfg.Host, "http://", "https://", 1)
+		if u, err := url.Parse(cfg.Host); err == nil {
+			tlsConfig.ServerName = u.Hostname()
+		}
+	}
+
 	mem := agent.NewReport()
 	var client workerpool.Client
-	client = metricsClient.NewClient(cfg.Host, cfg.HashKey, cfg.CryptoKey)
+	httpClient := metricsClient.NewClient(cfg.Host, cfg.HashKey, cfg.CryptoKey)
+	httpClient.SetLabels(cfg.Labels)
+	if tlsConfig != nil {
+		httpClient.SetTLSConfig(tlsConfig)
+	}
+	client = httpClient
 	if cfg.Grpc {
-		grpcClient, err := metricsClient.NewGRPCClient()
+		grpcClient, err := metricsClient.NewGRPCClient(metricsClient.DefaultGRPCAddress, cfg.HashKey, tlsConfig)
 		if err != nil {
 			log.Fatal(err)
 		}
+		grpcClient.SetLabels(cfg.Labels)
 		client = grpcClient
 	}
-	reader := agent.NewMetricsReader()
+	client = workerpool.NewBreakerClient(client, breaker.New(breaker.Settings{
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		Cooldown:         cfg.BreakerCooldown,
+		MaxCooldown:      cfg.BreakerMaxCooldown,
+	}))
+	reader, err := collector.NewReader(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Collectors:", strings.Join(reader.Enabled(), ", "))
 	var wg sync.WaitGroup
 	wg.Add(2)
 
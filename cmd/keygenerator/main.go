@@ -6,20 +6,44 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"flag"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moonicy/gometrics/pkg/certs"
 )
 
 func main() {
+	dir := flag.String("dir", "keys", "output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "comma-separated server host names and IP addresses")
+	validFor := flag.Duration("valid", 365*24*time.Hour, "certificate validity period")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := generateRSAKeys(*dir); err != nil {
+		log.Fatal(err)
+	}
+	if err := generateCertificates(*dir, strings.Split(*hosts, ","), *validFor); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generateRSAKeys создаёт пару ключей RSA для шифрования тела запросов агента.
+func generateRSAKeys(dir string) error {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	publicKey := &privateKey.PublicKey
 	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	var publicKeyPEM bytes.Buffer
@@ -28,7 +52,7 @@ func main() {
 		Bytes: publicKeyBytes,
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	var privateKeyPEM bytes.Buffer
@@ -37,39 +61,37 @@ func main() {
 		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	filePrivate, err := os.Create("keys/private.pem")
-	if err != nil {
-		log.Fatal(err)
+	if err = os.WriteFile(filepath.Join(dir, "private.pem"), privateKeyPEM.Bytes(), 0o600); err != nil {
+		return err
 	}
-	_, err = filePrivate.Write(privateKeyPEM.Bytes())
+	return os.WriteFile(filepath.Join(dir, "public.pem"), publicKeyPEM.Bytes(), 0o644)
+}
+
+// generateCertificates выпускает локальный удостоверяющий центр, сертификат сервера для hosts
+// и клиентский сертификат агента для TLS и mTLS.
+func generateCertificates(dir string, hosts []string, validFor time.Duration) error {
+	ca, err := certs.NewAuthority("gometrics CA", validFor)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err = certs.WriteFiles(ca.Cert, ca.Key, filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")); err != nil {
+		return err
 	}
 
-	defer func(filePrivate *os.File) {
-		err = filePrivate.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(filePrivate)
-
-	filePublic, err := os.Create("keys/public.pem")
+	server, err := ca.IssueServer("gometrics server", hosts, validFor)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	_, err = filePublic.Write(publicKeyPEM.Bytes())
-	if err != nil {
-		log.Fatal(err)
+	if err = certs.WriteFiles(server.Cert, server.Key, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")); err != nil {
+		return err
 	}
 
-	defer func(filePublic *os.File) {
-		err = filePublic.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
-	}(filePublic)
-
+	agent, err := ca.IssueClient("gometrics agent", validFor)
+	if err != nil {
+		return err
+	}
+	return certs.WriteFiles(agent.Cert, agent.Key, filepath.Join(dir, "agent.pem"), filepath.Join(dir, "agent-key.pem"))
 }
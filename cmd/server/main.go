@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
 	"net"
 	"net/http"
@@ -19,11 +20,17 @@ import (
 	"github.com/go-chi/chi/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
+	"github.com/moonicy/gometrics/internal/alerting"
 	"github.com/moonicy/gometrics/internal/config"
 	"github.com/moonicy/gometrics/internal/file"
 	"github.com/moonicy/gometrics/internal/handlers"
+	"github.com/moonicy/gometrics/internal/migrations"
 	grpcserver "github.com/moonicy/gometrics/internal/server"
+	"github.com/moonicy/gometrics/internal/storage"
+	"github.com/moonicy/gometrics/pkg/certs"
+	"github.com/moonicy/gometrics/pkg/crypt"
 	database2 "github.com/moonicy/gometrics/pkg/database"
+	"github.com/moonicy/gometrics/pkg/interceptors"
 	"github.com/moonicy/gometrics/pkg/logger"
 	pb "github.com/moonicy/gometrics/proto"
 )
@@ -71,9 +78,24 @@ func main() {
 		}
 	}()
 
+	if cfg.Migrate != "" {
+		if cfg.DatabaseDsn == "" {
+			sugar.Fatalw("database dsn is required", "event", "migrate")
+		}
+		migrator, err := migrations.NewMigrator(database)
+		if err == nil {
+			err = migrator.Run(ctx, cfg.Migrate, os.Stdout)
+		}
+		cancel()
+		if err != nil {
+			sugar.Fatalw(err.Error(), "event", "migrate")
+		}
+		return
+	}
+
 	cr := file.NewConsumer(cfg.FileStoragePath)
 	pr := file.NewProducer(cfg.FileStoragePath)
-	storage := handlers.NewStorage(cfg, database, cr, pr)
+	storage := handlers.NewStorage(cfg, database, cr, pr, newWAL(cfg.FileStoragePath))
 	err = storage.Init(ctx)
 	if err != nil {
 		sugar.Error(err)
@@ -81,7 +103,37 @@ func main() {
 
 	metricsHandler := handlers.NewMetricsHandler(storage, database, sugar)
 
-	route := handlers.NewRoute(metricsHandler, sugar, cfg)
+	if len(cfg.AlertRules) > 0 {
+		rules, err := alerting.ParseRules(cfg.AlertRules)
+		if err != nil {
+			sugar.Fatalw(err.Error(), "event", "parse alert rules")
+		}
+		engine := alerting.NewEngine(rules, storage)
+		if len(cfg.AlertWebhooks) > 0 {
+			engine.SetNotifier(alerting.NewWebhookNotifier(cfg.AlertWebhooks, cfg.HashKey, cfg.AlertRepeatInterval))
+		}
+		metricsHandler.SetAlerts(engine)
+		go engine.Run(ctx, cfg.AlertInterval)
+	}
+
+	keyring := crypt.NewKeyring(cfg.CryptoKeys()...)
+	if err = keyring.Reload(); err != nil {
+		sugar.Error(err)
+	}
+	sugar.Infow("Loaded crypto keys", "ids", keyring.KeyIDs())
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := keyring.Reload(); err != nil {
+				sugar.Errorw("Crypto keys reload failed, keeping loaded keys", "error", err)
+				continue
+			}
+			sugar.Infow("Reloaded crypto keys", "ids", keyring.KeyIDs())
+		}
+	}()
+
+	route := handlers.NewRoute(metricsHandler, sugar, cfg, keyring)
 
 	gserver := grpcserver.NewGRPCServer(storage)
 
@@ -96,13 +148,40 @@ func main() {
 		Addr:    cfg.Host,
 		Handler: route,
 	}
+	grpcOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			interceptors.RecoveryUnaryInterceptor(sugar),
+			interceptors.LoggingUnaryInterceptor(sugar),
+			// Как и в HTTP, доверенная подсеть проверяется только для записи метрик.
+			interceptors.IPCheckUnaryInterceptor(cfg.TrustedSubnet, pb.Metrics_UpdateMetrics_FullMethodName),
+			interceptors.SignCheckUnaryInterceptor(cfg.HashKey),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptors.RecoveryStreamInterceptor(sugar),
+			interceptors.LoggingStreamInterceptor(sugar),
+			interceptors.IPCheckStreamInterceptor(cfg.TrustedSubnet, pb.Metrics_StreamMetrics_FullMethodName),
+			interceptors.SignCheckStreamInterceptor(cfg.HashKey),
+		),
+	}
+	if cfg.TLSCert != "" {
+		tlsConfig, err := certs.ServerTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA)
+		if err != nil {
+			sugar.Fatalw(err.Error(), "event", "load tls config")
+		}
+		server.TLSConfig = tlsConfig
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
 
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		err = server.ListenAndServe()
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			sugar.Fatalw(err.Error(), "event", "start server")
 		}
@@ -115,8 +194,8 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		// создаём gRPC-сервер без зарегистрированной службы
-		s := grpc.NewServer()
+		// создаём gRPC-сервер с той же цепочкой проверок и настройками TLS, что и у HTTP-сервера
+		s := grpc.NewServer(grpcOpts...)
 		// регистрируем сервис
 		pb.RegisterMetricsServer(s, gserver)
 
@@ -144,6 +223,14 @@ func main() {
 	time.Sleep(1 * time.Second)
 }
 
+// newWAL возвращает журнал изменений рядом с файлом хранилища path или nil, если путь не задан.
+func newWAL(path string) storage.WAL {
+	if path == "" {
+		return nil
+	}
+	return file.NewWAL(path + ".wal")
+}
+
 func AttachProfiler(router *chi.Mux) {
 	router.HandleFunc("/debug/pprof/", pprof.Index)
 	router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
@@ -0,0 +1,296 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+const (
+	// cgroupRoot - точка монтирования иерархии cgroup.
+	cgroupRoot = "/sys/fs/cgroup"
+	// selfCgroup - файл со списком cgroup процесса агента.
+	selfCgroup = "/proc/self/cgroup"
+)
+
+// containerMarkers - файлы, которые создают среды выполнения контейнеров: Docker и Podman.
+var containerMarkers = []string{"/.dockerenv", "/run/.containerenv"}
+
+func init() {
+	Register("cgroup", EnabledInContainer, func(config.CollectorConfig) (Collector, error) {
+		dir, err := cgroupDir(cgroupRoot, selfCgroup)
+		if err != nil {
+			return nil, err
+		}
+		if dir == "" {
+			return nil, errors.New("agent is not running in a cgroup v2")
+		}
+		return newCgroupCollector(dir), nil
+	})
+}
+
+// detectContainer сообщает, работает ли агент в контейнере с доступной собственной cgroup v2.
+// Без cgroup v2 сборщик cgroup работать не может, поэтому агент ведёт себя как на хосте.
+var detectContainer = func() bool {
+	if !inContainer(os.Getenv("container"), containerMarkers, cgroupRoot, selfCgroup) {
+		return false
+	}
+	dir, err := cgroupDir(cgroupRoot, selfCgroup)
+	return err == nil && dir != ""
+}
+
+// inContainer определяет контейнер по явным признакам: переменной окружения container, которую задают
+// systemd-nspawn, LXC и Podman (env), файлам сред выполнения markers или собственному пространству имён cgroup.
+// В пространстве имён cgroup процесс видит свою cgroup как корневую "0::/", но смонтированная в root
+// иерархия не является корневой cgroup хоста. Служба systemd на хосте находится в некорневой cgroup,
+// но контейнером не считается.
+func inContainer(env string, markers []string, root, self string) bool {
+	if env != "" {
+		return true
+	}
+	for _, m := range markers {
+		if _, err := os.Stat(m); err == nil {
+			return true
+		}
+	}
+	data, err := os.ReadFile(self)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "0::/" {
+			_, err := os.Stat(filepath.Join(root, "cgroup.type"))
+			return err == nil
+		}
+	}
+	return false
+}
+
+// cgroupDir возвращает каталог cgroup v2 процесса, описанного файлом self, в иерархии root.
+// Возвращает пустую строку, если иерархия cgroup v2 недоступна или процесс находится в корневой cgroup.
+func cgroupDir(root, self string) (string, error) {
+	data, err := os.ReadFile(self)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// В cgroup v2 строка имеет вид "0::/path".
+		p, ok := strings.CutPrefix(line, "0::")
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(root, p)
+		// Файл cgroup.type есть во всех cgroup v2, кроме корневой.
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.type")); err != nil {
+			return "", nil
+		}
+		return dir, nil
+	}
+	return "", nil
+}
+
+// cgroupCollector собирает показатели cgroup v2: память, CPU и его ограничение, ввод-вывод и число процессов.
+type cgroupCollector struct {
+	dir    string
+	now    func() time.Time
+	deltas deltas
+
+	// prevUsage и prevTime - потреблённое время CPU в микросекундах и время предыдущего сбора.
+	prevUsage uint64
+	prevTime  time.Time
+}
+
+func newCgroupCollector(dir string) *cgroupCollector {
+	return &cgroupCollector{dir: dir, now: time.Now}
+}
+
+// Collect сохраняет в report показатели cgroup. Файлы контроллеров, которые не включены для cgroup,
+// пропускаются.
+func (cc *cgroupCollector) Collect(_ context.Context, report *agent.Report) error {
+	now := cc.now()
+	var errs []error
+
+	if v, ok, err := cc.readValue("memory.current"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		report.SetGauge(agent.CgroupMemoryUsage, float64(v))
+	}
+	if v, ok, err := cc.readValue("memory.max"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		report.SetGauge(agent.CgroupMemoryLimit, float64(v))
+	}
+	if v, ok, err := cc.readValue("pids.current"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		report.SetGauge(agent.CgroupPids, float64(v))
+	}
+	if v, ok, err := cc.readValue("pids.max"); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		report.SetGauge(agent.CgroupPidsLimit, float64(v))
+	}
+	if err := cc.collectCPULimit(report); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cc.collectCPUStat(report, now); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cc.collectIOStat(report); err != nil {
+		errs = append(errs, err)
+	}
+	cc.deltas.commit()
+	return errors.Join(errs...)
+}
+
+// collectCPULimit сохраняет ограничение CPU из cpu.max в ядрах. Без ограничения метрика не передаётся.
+func (cc *cgroupCollector) collectCPULimit(report *agent.Report) error {
+	data, ok, err := cc.readFile("cpu.max")
+	if err != nil || !ok {
+		return err
+	}
+	// Формат файла: "$MAX $PERIOD", где $MAX может быть "max".
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return fmt.Errorf("cpu.max: %w", err)
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period == 0 {
+		return fmt.Errorf("cpu.max: invalid period %q", fields[1])
+	}
+	report.SetGauge(agent.CgroupCPULimit, quota/period)
+	return nil
+}
+
+// collectCPUStat сохраняет загрузку CPU с предыдущего сбора в процентах от одного ядра
+// и приращения счётчиков троттлинга из cpu.stat.
+func (cc *cgroupCollector) collectCPUStat(report *agent.Report, now time.Time) error {
+	data, ok, err := cc.readFile("cpu.stat")
+	if err != nil || !ok {
+		return err
+	}
+	stat, err := parseKeyValues(data)
+	if err != nil {
+		return fmt.Errorf("cpu.stat: %w", err)
+	}
+	if usage, ok := stat["usage_usec"]; ok {
+		if !cc.prevTime.IsZero() && usage >= cc.prevUsage && now.After(cc.prevTime) {
+			elapsed := float64(now.Sub(cc.prevTime).Microseconds())
+			report.SetGauge(agent.CgroupCPUUsage, float64(usage-cc.prevUsage)/elapsed*100)
+		}
+		cc.prevUsage, cc.prevTime = usage, now
+	}
+	// Счётчики троттлинга есть, только если для cgroup включён контроллер cpu.
+	if v, ok := stat["nr_periods"]; ok {
+		cc.deltas.add(report, agent.CgroupCPUPeriods, v)
+	}
+	if v, ok := stat["nr_throttled"]; ok {
+		cc.deltas.add(report, agent.CgroupCPUThrottledPeriods, v)
+	}
+	if v, ok := stat["throttled_usec"]; ok {
+		cc.deltas.add(report, agent.CgroupCPUThrottledTime, v)
+	}
+	return nil
+}
+
+// collectIOStat сохраняет приращения счётчиков ввода-вывода из io.stat с меткой device,
+// равной номеру устройства "major:minor".
+func (cc *cgroupCollector) collectIOStat(report *agent.Report) error {
+	data, ok, err := cc.readFile("io.stat")
+	if err != nil || !ok {
+		return err
+	}
+	keys := map[string]string{
+		"rbytes": agent.CgroupIOReadBytes,
+		"wbytes": agent.CgroupIOWriteBytes,
+		"rios":   agent.CgroupIOReads,
+		"wios":   agent.CgroupIOWrites,
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// Формат строки: "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		labels := map[string]string{"device": fields[0]}
+		for _, field := range fields[1:] {
+			k, v, ok := strings.Cut(field, "=")
+			id, known := keys[k]
+			if !ok || !known {
+				continue
+			}
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("io.stat: %w", err)
+			}
+			cc.deltas.add(report, metrics.SeriesKey(id, labels), n)
+		}
+	}
+	return scanner.Err()
+}
+
+// readFile читает файл cgroup. Отсутствие файла не считается ошибкой: ok равно false.
+func (cc *cgroupCollector) readFile(name string) (data []byte, ok bool, err error) {
+	data, err = os.ReadFile(filepath.Join(cc.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// readValue читает файл cgroup с одним числом. Значение "max" (нет ограничения) возвращается с ok, равным false.
+func (cc *cgroupCollector) readValue(name string) (uint64, bool, error) {
+	data, ok, err := cc.readFile(name)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, true, nil
+}
+
+// parseKeyValues разбирает файлы вида "key value" по одной паре в строке.
+func parseKeyValues(data []byte) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fields[0], err)
+		}
+		values[fields[0]] = v
+	}
+	return values, nil
+}
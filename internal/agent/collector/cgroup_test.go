@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/agent"
+)
+
+const (
+	fixtureRoot = "testdata/cgroup/sys/fs/cgroup"
+	fixtureDir  = fixtureRoot + "/system.slice/app.service"
+)
+
+func TestCgroupDir(t *testing.T) {
+	tests := []struct {
+		name string
+		self string
+		want string
+	}{
+		{name: "own cgroup", self: "testdata/cgroup/proc/self.cgroup", want: fixtureDir},
+		{name: "root cgroup", self: "testdata/cgroup/proc/root.cgroup"},
+		{name: "cgroup v1", self: "testdata/cgroup/proc/v1.cgroup"},
+		{name: "no procfs", self: "testdata/cgroup/proc/missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := cgroupDir(fixtureRoot, tt.self)
+			require.NoError(t, err)
+			assert.Equal(t, filepath.FromSlash(tt.want), dir)
+		})
+	}
+}
+
+func TestInContainer(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), ".dockerenv")
+	writeFile(t, marker, "")
+	// Корень иерархии в пространстве имён cgroup - некорневая cgroup хоста, в ней есть cgroup.type.
+	nsRoot := t.TempDir()
+	writeFile(t, filepath.Join(nsRoot, "cgroup.type"), "domain\n")
+
+	tests := []struct {
+		name    string
+		env     string
+		markers []string
+		root    string
+		self    string
+		want    bool
+	}{
+		{name: "systemd service on host", root: fixtureRoot, self: "testdata/cgroup/proc/self.cgroup"},
+		{name: "root cgroup on host", root: fixtureRoot, self: "testdata/cgroup/proc/root.cgroup"},
+		{name: "no procfs", root: fixtureRoot, self: "testdata/cgroup/proc/missing"},
+		{name: "container env", env: "podman", root: fixtureRoot, self: "testdata/cgroup/proc/self.cgroup", want: true},
+		{name: "runtime marker", markers: []string{"/nonexistent/.containerenv", marker}, root: fixtureRoot, self: "testdata/cgroup/proc/self.cgroup", want: true},
+		{name: "cgroup namespace", root: nsRoot, self: "testdata/cgroup/proc/root.cgroup", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, inContainer(tt.env, tt.markers, tt.root, tt.self))
+		})
+	}
+}
+
+// copyFixture копирует файлы cgroup из testdata во временный каталог, чтобы тест мог их изменять.
+func copyFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir(fixtureDir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(fixtureDir, e.Name()))
+		require.NoError(t, err)
+		writeFile(t, filepath.Join(dir, e.Name()), string(data))
+	}
+	return dir
+}
+
+func TestCgroupCollector_Collect(t *testing.T) {
+	dir := copyFixture(t)
+	cc := newCgroupCollector(dir)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cc.now = func() time.Time { return now }
+
+	report := agent.NewReport()
+	require.NoError(t, cc.Collect(context.Background(), report))
+	assert.Equal(t, map[string]float64{
+		agent.CgroupMemoryUsage: 268435456,
+		agent.CgroupMemoryLimit: 536870912,
+		agent.CgroupCPULimit:    0.5,
+		agent.CgroupPids:        12,
+	}, report.GetGauge(), "cpu usage needs two samples, pids.max is unlimited")
+	assert.Equal(t, int64(0), report.GetCounter()[agent.CgroupCPUThrottledPeriods])
+
+	writeFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 2500000\nnr_periods 120\nnr_throttled 15\nthrottled_usec 400000\n")
+	writeFile(t, filepath.Join(dir, "io.stat"), "8:0 rbytes=1049600 wbytes=2097152 rios=17 wios=32 dbytes=0 dios=0\n")
+	writeFile(t, filepath.Join(dir, "cpu.max"), "max 100000\n")
+	now = now.Add(2 * time.Second)
+
+	report = agent.NewReport()
+	require.NoError(t, cc.Collect(context.Background(), report))
+	gauges := report.GetGauge()
+	assert.InDelta(t, 25.0, gauges[agent.CgroupCPUUsage], 1e-9, "0.5s of CPU time in 2s")
+	assert.NotContains(t, gauges, agent.CgroupCPULimit)
+	assert.Equal(t, map[string]int64{
+		agent.CgroupCPUPeriods:             20,
+		agent.CgroupCPUThrottledPeriods:    5,
+		agent.CgroupCPUThrottledTime:       150000,
+		`CgroupIOReadBytes{device="8:0"}`:  1024,
+		`CgroupIOWriteBytes{device="8:0"}`: 0,
+		`CgroupIOReads{device="8:0"}`:      1,
+		`CgroupIOWrites{device="8:0"}`:     0,
+	}, report.GetCounter())
+}
+
+func TestCgroupCollector_MissingControllers(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 100\nuser_usec 60\nsystem_usec 40\n")
+
+	report := agent.NewReport()
+	require.NoError(t, newCgroupCollector(dir).Collect(context.Background(), report))
+	assert.Empty(t, report.GetGauge())
+	assert.Empty(t, report.GetCounter())
+}
+
+func TestCgroupCollector_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "memory.current"), "a lot\n")
+	writeFile(t, filepath.Join(dir, "pids.current"), "3\n")
+
+	report := agent.NewReport()
+	err := newCgroupCollector(dir).Collect(context.Background(), report)
+	assert.ErrorContains(t, err, "memory.current")
+	assert.Equal(t, 3.0, report.GetGauge()[agent.CgroupPids], "other files are still read")
+}
@@ -0,0 +1,105 @@
+// Package collector содержит подключаемые сборщики метрик агента и средство их запуска.
+//
+// Сборщик регистрируется функцией Register в init своего файла и включается или выключается
+// настройками AgentConfig.Collectors; если сборщик в настройках не упомянут, его включение определяет Default. Reader опрашивает включённые сборщики, каждый со своей частотой,
+// и изолирует их друг от друга: ошибка или паника одного сборщика не мешает остальным.
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+// Collector собирает группу метрик и сохраняет их в отчёт.
+type Collector interface {
+	// Collect собирает метрики в report. Метрики, собранные до ошибки, остаются в отчёте.
+	Collect(ctx context.Context, report *agent.Report) error
+}
+
+// CollectorFunc позволяет использовать функцию как Collector.
+type CollectorFunc func(ctx context.Context, report *agent.Report) error
+
+// Collect вызывает f(ctx, report).
+func (f CollectorFunc) Collect(ctx context.Context, report *agent.Report) error {
+	return f(ctx, report)
+}
+
+// Factory создаёт сборщик по его настройкам.
+type Factory func(cfg config.CollectorConfig) (Collector, error)
+
+// Default определяет, включён ли сборщик, если это не задано в настройках.
+type Default int
+
+// Варианты включения сборщика по умолчанию.
+const (
+	DisabledByDefault  Default = iota // DisabledByDefault - сборщик нужно включить явно.
+	EnabledByDefault                  // EnabledByDefault - сборщик включён всегда.
+	EnabledOnHost                     // EnabledOnHost - сборщик включён, если агент работает не в контейнере.
+	EnabledInContainer                // EnabledInContainer - сборщик включён, если агент работает в контейнере.
+)
+
+// enabled сообщает, включён ли сборщик по умолчанию; inContainer - работает ли агент в контейнере.
+func (d Default) enabled(inContainer bool) bool {
+	switch d {
+	case EnabledByDefault:
+		return true
+	case EnabledOnHost:
+		return !inContainer
+	case EnabledInContainer:
+		return inContainer
+	default:
+		return false
+	}
+}
+
+type registration struct {
+	def     Default
+	factory Factory
+}
+
+var (
+	registryMx sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register регистрирует сборщик с именем name. Сборщики, включённые по умолчанию, работают,
+// пока не выключены в настройках; остальные нужно включить явно.
+// Повторная регистрация имени вызывает панику.
+func Register(name string, def Default, factory Factory) {
+	registryMx.Lock()
+	defer registryMx.Unlock()
+	if factory == nil {
+		panic("collector: Register factory is nil for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic("collector: Register called twice for " + name)
+	}
+	registry[name] = registration{def: def, factory: factory}
+}
+
+// Names возвращает отсортированные имена зарегистрированных сборщиков.
+func Names() []string {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(name string) (registration, error) {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	reg, ok := registry[name]
+	if !ok {
+		return registration{}, fmt.Errorf("unknown collector %q", name)
+	}
+	return reg, nil
+}
@@ -0,0 +1,30 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func init() {
+	Register("cpu", EnabledOnHost, func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(collectCPU), nil
+	})
+}
+
+// collectCPU сохраняет в report загрузку каждого ядра процессора с момента предыдущего сбора
+// в метриках CPUutilization1, CPUutilization2 и т.д.
+func collectCPU(ctx context.Context, mem *agent.Report) error {
+	cpuAll, err := cpu.PercentWithContext(ctx, 0, true)
+	if err != nil {
+		return err
+	}
+	for i, cpuVal := range cpuAll {
+		mem.SetGauge(agent.CPUutilization+strconv.Itoa(i+1), cpuVal)
+	}
+	return nil
+}
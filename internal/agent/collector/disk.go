@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shirou/gopsutil/v4/disk"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+func init() {
+	Register("disk", EnabledByDefault, func(cfg config.CollectorConfig) (Collector, error) {
+		f, err := newFilter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &diskCollector{filter: f, partitions: disk.PartitionsWithContext, usage: disk.UsageWithContext}, nil
+	})
+	Register("diskio", EnabledByDefault, func(cfg config.CollectorConfig) (Collector, error) {
+		f, err := newFilter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &diskIOCollector{filter: f, ioCounters: disk.IOCountersWithContext}, nil
+	})
+}
+
+// diskCollector собирает заполненность файловых систем по точкам монтирования.
+// Фильтры сравниваются с точкой монтирования и именем устройства.
+type diskCollector struct {
+	filter     filter
+	partitions func(ctx context.Context, all bool) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+}
+
+// Collect сохраняет в report метрики DiskTotal, DiskUsed, DiskFree и DiskUsedPercent с меткой mountpoint.
+// Ошибка чтения одной файловой системы не мешает сбору остальных.
+func (dc *diskCollector) Collect(ctx context.Context, report *agent.Report) error {
+	partitions, err := dc.partitions(ctx, false)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range partitions {
+		if !dc.filter.match(p.Mountpoint, p.Device) {
+			continue
+		}
+		usage, err := dc.usage(ctx, p.Mountpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Mountpoint, err))
+			continue
+		}
+		labels := map[string]string{"mountpoint": p.Mountpoint}
+		report.SetGauge(metrics.SeriesKey(agent.DiskTotal, labels), float64(usage.Total))
+		report.SetGauge(metrics.SeriesKey(agent.DiskUsed, labels), float64(usage.Used))
+		report.SetGauge(metrics.SeriesKey(agent.DiskFree, labels), float64(usage.Free))
+		report.SetGauge(metrics.SeriesKey(agent.DiskUsedPercent, labels), usage.UsedPercent)
+	}
+	return errors.Join(errs...)
+}
+
+// diskIOCollector собирает счётчики операций ввода-вывода по дисковым устройствам.
+type diskIOCollector struct {
+	filter     filter
+	ioCounters func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error)
+	deltas     deltas
+}
+
+// Collect добавляет в report приращения DiskReadBytes, DiskWriteBytes, DiskReads и DiskWrites с меткой device.
+func (dc *diskIOCollector) Collect(ctx context.Context, report *agent.Report) error {
+	counters, err := dc.ioCounters(ctx)
+	if err != nil {
+		return err
+	}
+	for name, c := range counters {
+		if !dc.filter.match(name) {
+			continue
+		}
+		labels := map[string]string{"device": name}
+		dc.deltas.add(report, metrics.SeriesKey(agent.DiskReadBytes, labels), c.ReadBytes)
+		dc.deltas.add(report, metrics.SeriesKey(agent.DiskWriteBytes, labels), c.WriteBytes)
+		dc.deltas.add(report, metrics.SeriesKey(agent.DiskReads, labels), c.ReadCount)
+		dc.deltas.add(report, metrics.SeriesKey(agent.DiskWrites, labels), c.WriteCount)
+	}
+	dc.deltas.commit()
+	return nil
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+// filter отбирает устройства и интерфейсы по шаблонам path.Match из настроек сборщика.
+type filter struct {
+	include []string
+	exclude []string
+}
+
+// newFilter создаёт фильтр из cfg.Include и cfg.Exclude, проверяя синтаксис шаблонов.
+func newFilter(cfg config.CollectorConfig) (filter, error) {
+	for _, pattern := range append(append([]string(nil), cfg.Include...), cfg.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return filter{include: cfg.Include, exclude: cfg.Exclude}, nil
+}
+
+// match сообщает, нужно ли собирать метрики объекта с именами names, например точки монтирования и устройства.
+// Объект исключается, если любое из имён подходит под шаблон Exclude, и включается, если Include пуст
+// или любое из имён подходит под шаблон Include.
+func (f filter) match(names ...string) bool {
+	if matchAny(f.exclude, names) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, names)
+}
+
+func matchAny(patterns []string, names []string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deltas преобразует накопленные с момента загрузки системы значения в приращения counter-метрик.
+// Первое значение ряда только запоминается; если значение уменьшилось (счётчик сброшен),
+// приращением считается новое значение.
+type deltas struct {
+	prev map[string]uint64
+	next map[string]uint64
+}
+
+// add добавляет в report приращение counter-метрики key.
+func (d *deltas) add(report *agent.Report, key string, value uint64) {
+	if d.next == nil {
+		d.next = make(map[string]uint64)
+	}
+	d.next[key] = value
+	prev, ok := d.prev[key]
+	switch {
+	case !ok:
+		report.AddCounter(key, 0)
+	case value < prev:
+		report.AddCounter(key, int64(value))
+	default:
+		report.AddCounter(key, int64(value-prev))
+	}
+}
+
+// commit завершает сбор: ряды, которые не встретились при сборе, забываются.
+func (d *deltas) commit() {
+	d.prev, d.next = d.next, nil
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func TestFilter_Match(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.CollectorConfig
+		names []string
+		want  bool
+	}{
+		{name: "no patterns", names: []string{"eth0"}, want: true},
+		{name: "included", cfg: config.CollectorConfig{Include: []string{"eth*"}}, names: []string{"eth0"}, want: true},
+		{name: "not included", cfg: config.CollectorConfig{Include: []string{"eth*"}}, names: []string{"lo"}, want: false},
+		{name: "excluded", cfg: config.CollectorConfig{Exclude: []string{"lo"}}, names: []string{"lo"}, want: false},
+		{
+			name:  "exclude wins",
+			cfg:   config.CollectorConfig{Include: []string{"/*"}, Exclude: []string{"/dev/loop*"}},
+			names: []string{"/snap", "/dev/loop3"},
+			want:  false,
+		},
+		{name: "any name included", cfg: config.CollectorConfig{Include: []string{"/dev/sda*"}}, names: []string{"/", "/dev/sda1"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newFilter(tt.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f.match(tt.names...))
+		})
+	}
+}
+
+func TestNewFilter_InvalidPattern(t *testing.T) {
+	_, err := newFilter(config.CollectorConfig{Exclude: []string{"eth["}})
+	assert.ErrorContains(t, err, `invalid pattern "eth["`)
+}
+
+func TestDeltas(t *testing.T) {
+	var d deltas
+	collect := func(values map[string]uint64) map[string]int64 {
+		report := agent.NewReport()
+		for key, value := range values {
+			d.add(report, key, value)
+		}
+		d.commit()
+		return report.GetCounter()
+	}
+
+	assert.Equal(t, map[string]int64{"a": 0, "b": 0}, collect(map[string]uint64{"a": 100, "b": 5}), "first sample is remembered")
+	assert.Equal(t, map[string]int64{"a": 20, "b": 3}, collect(map[string]uint64{"a": 120, "b": 8}))
+	assert.Equal(t, map[string]int64{"a": 7}, collect(map[string]uint64{"a": 7}), "counter reset")
+	assert.Equal(t, map[string]int64{"a": 1, "b": 0}, collect(map[string]uint64{"a": 8, "b": 50}), "forgotten series starts over")
+}
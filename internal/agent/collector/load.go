@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/process"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func init() {
+	Register("load", EnabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(collectLoad), nil
+	})
+	Register("processes", EnabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return &processesCollector{misc: load.MiscWithContext, threads: countThreads}, nil
+	})
+}
+
+// collectLoad сохраняет в report средние значения загрузки системы в метриках Load1, Load5 и Load15.
+func collectLoad(ctx context.Context, report *agent.Report) error {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	report.SetGauge(agent.Load1, avg.Load1)
+	report.SetGauge(agent.Load5, avg.Load5)
+	report.SetGauge(agent.Load15, avg.Load15)
+	return nil
+}
+
+// processesCollector собирает число процессов и потоков в системе.
+type processesCollector struct {
+	misc    func(ctx context.Context) (*load.MiscStat, error)
+	threads func(ctx context.Context) (int, error)
+}
+
+// Collect сохраняет в report метрики ProcessCount, ProcessesRunning, ProcessesBlocked и ThreadCount.
+func (pc *processesCollector) Collect(ctx context.Context, report *agent.Report) error {
+	misc, err := pc.misc(ctx)
+	if err != nil {
+		return err
+	}
+	report.SetGauge(agent.ProcessCount, float64(misc.ProcsTotal))
+	report.SetGauge(agent.ProcessesRunning, float64(misc.ProcsRunning))
+	report.SetGauge(agent.ProcessesBlocked, float64(misc.ProcsBlocked))
+
+	threads, err := pc.threads(ctx)
+	if err != nil {
+		return err
+	}
+	report.SetGauge(agent.ThreadCount, float64(threads))
+	return nil
+}
+
+// countThreads возвращает суммарное число потоков всех процессов.
+// Процессы, завершившиеся во время подсчёта, пропускаются.
+func countThreads(ctx context.Context) (int, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, p := range procs {
+		if n, err := p.NumThreadsWithContext(ctx); err == nil {
+			total += int(n)
+		}
+	}
+	return total, ctx.Err()
+}
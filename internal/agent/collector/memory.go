@@ -0,0 +1,27 @@
+package collector
+
+import (
+	"context"
+
+	gopsutil "github.com/shirou/gopsutil/v4/mem"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func init() {
+	Register("memory", EnabledOnHost, func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(collectMemory), nil
+	})
+}
+
+// collectMemory сохраняет в report общий и свободный объём оперативной памяти системы.
+func collectMemory(ctx context.Context, mem *agent.Report) error {
+	v, err := gopsutil.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	mem.SetGauge(agent.TotalMemory, float64(v.Total))
+	mem.SetGauge(agent.FreeMemory, float64(v.Free))
+	return nil
+}
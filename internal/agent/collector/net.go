@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v4/net"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+func init() {
+	Register("net", EnabledByDefault, func(cfg config.CollectorConfig) (Collector, error) {
+		f, err := newFilter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &netCollector{filter: f, ioCounters: net.IOCountersWithContext}, nil
+	})
+}
+
+// netCollector собирает счётчики трафика, пакетов и ошибок по сетевым интерфейсам.
+type netCollector struct {
+	filter     filter
+	ioCounters func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error)
+	deltas     deltas
+}
+
+// Collect добавляет в report приращения NetBytesSent, NetBytesRecv, NetPacketsSent, NetPacketsRecv,
+// NetErrorsIn, NetErrorsOut, NetDropsIn и NetDropsOut с меткой interface.
+func (nc *netCollector) Collect(ctx context.Context, report *agent.Report) error {
+	counters, err := nc.ioCounters(ctx, true)
+	if err != nil {
+		return err
+	}
+	for _, c := range counters {
+		if !nc.filter.match(c.Name) {
+			continue
+		}
+		labels := map[string]string{"interface": c.Name}
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetBytesSent, labels), c.BytesSent)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetBytesRecv, labels), c.BytesRecv)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetPacketsSent, labels), c.PacketsSent)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetPacketsRecv, labels), c.PacketsRecv)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetErrorsIn, labels), c.Errin)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetErrorsOut, labels), c.Errout)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetDropsIn, labels), c.Dropin)
+		nc.deltas.add(report, metrics.SeriesKey(agent.NetDropsOut, labels), c.Dropout)
+	}
+	nc.deltas.commit()
+	return nil
+}
@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v4/process"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+func init() {
+	Register("process", DisabledByDefault, func(cfg config.CollectorConfig) (Collector, error) {
+		return newProcessCollector(cfg.Processes, newProcessTable(), cgroupRoot)
+	})
+}
+
+// procStat - показатели одного процесса.
+type procStat struct {
+	rss        uint64
+	cpuPercent float64
+	fds        int32
+	threads    int32
+	// createTime - время запуска процесса в миллисекундах с начала эпохи.
+	createTime int64
+}
+
+// procSource предоставляет сведения о процессах системы.
+type procSource interface {
+	// names возвращает имена всех процессов по их PID.
+	names(ctx context.Context) (map[int32]string, error)
+	// stat возвращает показатели процесса pid.
+	stat(ctx context.Context, pid int32) (procStat, error)
+	// sweep вызывается после сбора и освобождает сведения о процессах, которые в нём не участвовали.
+	sweep()
+}
+
+// processCollector собирает метрики отслеживаемых процессов.
+type processCollector struct {
+	targets    []config.ProcessConfig
+	source     procSource
+	cgroupRoot string
+	// mainPIDs хранит PID основного процесса каждой цели при предыдущем сборе.
+	mainPIDs map[string]int32
+}
+
+func newProcessCollector(targets []config.ProcessConfig, source procSource, cgroupRoot string) (*processCollector, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no processes to watch")
+	}
+	names := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		if t.Name == "" {
+			return nil, errors.New("process name is empty")
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("duplicate process %q", t.Name)
+		}
+		names[t.Name] = true
+		selectors := 0
+		for _, s := range []string{t.PIDFile, t.Pattern, t.Cgroup} {
+			if s != "" {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			return nil, fmt.Errorf("process %s: exactly one of pid_file, pattern and cgroup must be set", t.Name)
+		}
+		if _, err := path.Match(t.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("process %s: invalid pattern %q: %w", t.Name, t.Pattern, err)
+		}
+	}
+	return &processCollector{
+		targets:    targets,
+		source:     source,
+		cgroupRoot: cgroupRoot,
+		mainPIDs:   make(map[string]int32),
+	}, nil
+}
+
+// Collect сохраняет в report метрики ProcessInstances, ProcessRSS, ProcessCPUPercent, ProcessOpenFDs
+// и ProcessThreads, просуммированные по всем процессам цели, и counter-метрику ProcessRestarts.
+// Метрики помечаются меткой process с именем цели.
+//
+// Перезапуском считается смена PID основного процесса цели - самого раннего по времени запуска.
+// Процент CPU считается с предыдущего сбора, поэтому при первом сборе процесса он равен 0.
+func (pc *processCollector) Collect(ctx context.Context, report *agent.Report) error {
+	defer pc.source.sweep()
+	var (
+		names map[int32]string
+		errs  []error
+	)
+	for _, t := range pc.targets {
+		var (
+			pids []int32
+			err  error
+		)
+		switch {
+		case t.PIDFile != "":
+			pids, err = readPIDs(t.PIDFile)
+		case t.Cgroup != "":
+			pids, err = readPIDs(filepath.Join(pc.cgroupRoot, t.Cgroup, "cgroup.procs"))
+		default:
+			if names == nil {
+				names, err = pc.source.names(ctx)
+			}
+			pids = matchPIDs(names, t.Pattern)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("process %s: %w", t.Name, err))
+			continue
+		}
+		if err = pc.collectTarget(ctx, report, t.Name, pids); err != nil {
+			errs = append(errs, fmt.Errorf("process %s: %w", t.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (pc *processCollector) collectTarget(ctx context.Context, report *agent.Report, name string, pids []int32) error {
+	var (
+		total     procStat
+		instances int
+		mainPID   int32
+		oldest    int64
+		errs      []error
+	)
+	for _, pid := range pids {
+		st, err := pc.source.stat(ctx, pid)
+		if errors.Is(err, process.ErrorProcessNotRunning) || errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			// Показатели, которые удалось прочитать, например без открытых файлов чужого процесса, учитываются.
+			errs = append(errs, fmt.Errorf("pid %d: %w", pid, err))
+		}
+		instances++
+		total.rss += st.rss
+		total.cpuPercent += st.cpuPercent
+		total.fds += st.fds
+		total.threads += st.threads
+		if mainPID == 0 || st.createTime < oldest || st.createTime == oldest && pid < mainPID {
+			mainPID, oldest = pid, st.createTime
+		}
+	}
+
+	labels := map[string]string{"process": name}
+	report.SetGauge(metrics.SeriesKey(agent.ProcessInstances, labels), float64(instances))
+	report.SetGauge(metrics.SeriesKey(agent.ProcessRSS, labels), float64(total.rss))
+	report.SetGauge(metrics.SeriesKey(agent.ProcessCPUPercent, labels), total.cpuPercent)
+	report.SetGauge(metrics.SeriesKey(agent.ProcessOpenFDs, labels), float64(total.fds))
+	report.SetGauge(metrics.SeriesKey(agent.ProcessThreads, labels), float64(total.threads))
+
+	var restarts int64
+	if mainPID != 0 {
+		if prev, ok := pc.mainPIDs[name]; ok && prev != mainPID {
+			restarts = 1
+		}
+		pc.mainPIDs[name] = mainPID
+	}
+	report.AddCounter(metrics.SeriesKey(agent.ProcessRestarts, labels), restarts)
+	return errors.Join(errs...)
+}
+
+// readPIDs читает PID из файла, по одному в строке. Отсутствующий файл означает, что процессов нет.
+func readPIDs(name string) ([]int32, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pids []int32
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		pid, err := strconv.ParseInt(string(line), 10, 32)
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("invalid pid %q in %s", line, name)
+		}
+		pids = append(pids, int32(pid))
+	}
+	return pids, scanner.Err()
+}
+
+func matchPIDs(names map[int32]string, pattern string) []int32 {
+	var pids []int32
+	for pid, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// processTable получает сведения о процессах через gopsutil. Объекты процессов сохраняются между
+// сборами, чтобы считать процент CPU с предыдущего сбора.
+type processTable struct {
+	procs map[int32]*process.Process
+	used  map[int32]bool
+}
+
+func newProcessTable() *processTable {
+	return &processTable{procs: make(map[int32]*process.Process), used: make(map[int32]bool)}
+}
+
+func (pt *processTable) names(ctx context.Context) (map[int32]string, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int32]string, len(procs))
+	for _, p := range procs {
+		if name, err := p.NameWithContext(ctx); err == nil {
+			names[p.Pid] = name
+		}
+	}
+	return names, nil
+}
+
+func (pt *processTable) stat(ctx context.Context, pid int32) (procStat, error) {
+	p, ok := pt.procs[pid]
+	if !ok {
+		var err error
+		if p, err = process.NewProcessWithContext(ctx, pid); err != nil {
+			return procStat{}, err
+		}
+		pt.procs[pid] = p
+	}
+	pt.used[pid] = true
+
+	var (
+		st   procStat
+		errs []error
+	)
+	if mem, err := p.MemoryInfoWithContext(ctx); err == nil {
+		st.rss = mem.RSS
+	} else {
+		errs = append(errs, err)
+	}
+	var err error
+	if st.cpuPercent, err = p.PercentWithContext(ctx, 0); err != nil {
+		errs = append(errs, err)
+	}
+	if st.fds, err = p.NumFDsWithContext(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if st.threads, err = p.NumThreadsWithContext(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if st.createTime, err = p.CreateTimeWithContext(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		if running, _ := p.IsRunningWithContext(ctx); !running {
+			return procStat{}, process.ErrorProcessNotRunning
+		}
+	}
+	return st, errors.Join(errs...)
+}
+
+func (pt *processTable) sweep() {
+	for pid := range pt.procs {
+		if !pt.used[pid] {
+			delete(pt.procs, pid)
+		}
+	}
+	clear(pt.used)
+}
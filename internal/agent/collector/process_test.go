@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+type fakeProcs struct {
+	procs map[int32]string
+	stats map[int32]procStat
+	swept int
+}
+
+func (f *fakeProcs) names(context.Context) (map[int32]string, error) {
+	return f.procs, nil
+}
+
+func (f *fakeProcs) stat(_ context.Context, pid int32) (procStat, error) {
+	st, ok := f.stats[pid]
+	if !ok {
+		return procStat{}, process.ErrorProcessNotRunning
+	}
+	if pid == 666 {
+		return st, errors.New("permission denied")
+	}
+	return st, nil
+}
+
+func (f *fakeProcs) sweep() { f.swept++ }
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	require.NoError(t, os.WriteFile(name, []byte(data), 0o600))
+}
+
+func TestNewProcessCollector_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []config.ProcessConfig
+		want    string
+	}{
+		{name: "empty", want: "no processes to watch"},
+		{name: "no name", targets: []config.ProcessConfig{{Pattern: "nginx"}}, want: "process name is empty"},
+		{
+			name:    "duplicate",
+			targets: []config.ProcessConfig{{Name: "web", Pattern: "nginx"}, {Name: "web", Pattern: "httpd"}},
+			want:    `duplicate process "web"`,
+		},
+		{name: "no selector", targets: []config.ProcessConfig{{Name: "web"}}, want: "exactly one of"},
+		{
+			name:    "two selectors",
+			targets: []config.ProcessConfig{{Name: "web", Pattern: "nginx", PIDFile: "/run/nginx.pid"}},
+			want:    "exactly one of",
+		},
+		{name: "bad pattern", targets: []config.ProcessConfig{{Name: "web", Pattern: "ngin["}}, want: "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newProcessCollector(tt.targets, &fakeProcs{}, "")
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestProcessCollector_Collect(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "api.pid")
+	writeFile(t, pidFile, "100\n")
+	writeFile(t, filepath.Join(dir, "cgroup", "system.slice", "db.service", "cgroup.procs"), "300\n301\n")
+
+	source := &fakeProcs{
+		procs: map[int32]string{100: "api", 200: "nginx", 201: "nginx", 202: "nginx-exporter", 300: "postgres", 301: "postgres"},
+		stats: map[int32]procStat{
+			100: {rss: 1024, cpuPercent: 12.5, fds: 10, threads: 8, createTime: 1},
+			200: {rss: 100, cpuPercent: 1, fds: 5, threads: 1, createTime: 10},
+			201: {rss: 200, cpuPercent: 2, fds: 7, threads: 1, createTime: 20},
+			300: {rss: 4096, fds: 30, threads: 4, createTime: 5},
+		},
+	}
+	pc, err := newProcessCollector([]config.ProcessConfig{
+		{Name: "api", PIDFile: pidFile},
+		{Name: "web", Pattern: "nginx"},
+		{Name: "db", Cgroup: "/system.slice/db.service"},
+		{Name: "worker", PIDFile: filepath.Join(dir, "missing.pid")},
+	}, source, filepath.Join(dir, "cgroup"))
+	require.NoError(t, err)
+
+	report := agent.NewReport()
+	require.NoError(t, pc.Collect(context.Background(), report))
+	assert.Equal(t, 1, source.swept)
+
+	gauges := report.GetGauge()
+	assert.Equal(t, 1.0, gauges[`ProcessInstances{process="api"}`])
+	assert.Equal(t, 1024.0, gauges[`ProcessRSS{process="api"}`])
+	assert.Equal(t, 12.5, gauges[`ProcessCPUPercent{process="api"}`])
+	assert.Equal(t, 2.0, gauges[`ProcessInstances{process="web"}`])
+	assert.Equal(t, 300.0, gauges[`ProcessRSS{process="web"}`])
+	assert.Equal(t, 12.0, gauges[`ProcessOpenFDs{process="web"}`])
+	assert.Equal(t, 1.0, gauges[`ProcessInstances{process="db"}`], "exited pid 301 is skipped")
+	assert.Equal(t, 4.0, gauges[`ProcessThreads{process="db"}`])
+	assert.Equal(t, 0.0, gauges[`ProcessInstances{process="worker"}`])
+	assert.Equal(t, int64(0), report.GetCounter()[`ProcessRestarts{process="api"}`])
+}
+
+func TestProcessCollector_Restarts(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "api.pid")
+	source := &fakeProcs{stats: map[int32]procStat{100: {createTime: 1}, 101: {createTime: 2}, 200: {createTime: 3}}}
+	pc, err := newProcessCollector([]config.ProcessConfig{{Name: "api", PIDFile: pidFile}}, source, "")
+	require.NoError(t, err)
+
+	restarts := func(pid string) int64 {
+		writeFile(t, pidFile, pid)
+		report := agent.NewReport()
+		require.NoError(t, pc.Collect(context.Background(), report))
+		return report.GetCounter()[`ProcessRestarts{process="api"}`]
+	}
+	assert.Equal(t, int64(0), restarts("100"), "first observation")
+	assert.Equal(t, int64(0), restarts("100"))
+	assert.Equal(t, int64(1), restarts("101"))
+	assert.Equal(t, int64(0), restarts("999"), "process is down")
+	assert.Equal(t, int64(1), restarts("200"), "process is back with a new pid")
+}
+
+func TestProcessCollector_PartialStat(t *testing.T) {
+	source := &fakeProcs{
+		procs: map[int32]string{666: "agent"},
+		stats: map[int32]procStat{666: {rss: 512, threads: 3}},
+	}
+	pc, err := newProcessCollector([]config.ProcessConfig{{Name: "agent", Pattern: "agent"}}, source, "")
+	require.NoError(t, err)
+
+	report := agent.NewReport()
+	assert.ErrorContains(t, pc.Collect(context.Background(), report), "process agent: pid 666: permission denied")
+	assert.Equal(t, 512.0, report.GetGauge()[`ProcessRSS{process="agent"}`])
+}
+
+func TestReadPIDs_Invalid(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "bad.pid")
+	writeFile(t, name, "not-a-pid")
+	_, err := readPIDs(name)
+	assert.ErrorContains(t, err, `invalid pid "not-a-pid"`)
+}
+
+func TestProcessTable_Self(t *testing.T) {
+	pt := newProcessTable()
+	st, err := pt.stat(context.Background(), int32(os.Getpid()))
+	require.NoError(t, err)
+	assert.NotZero(t, st.rss)
+	assert.NotZero(t, st.threads)
+	pt.sweep()
+	assert.Len(t, pt.procs, 1)
+	pt.sweep()
+	assert.Empty(t, pt.procs)
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+// collectTimeout - время, которое отводится одному сборщику на сбор метрик.
+const collectTimeout = 5 * time.Second
+
+type scheduled struct {
+	name      string
+	collector Collector
+	interval  time.Duration
+	next      time.Time
+}
+
+// Reader опрашивает включённые сборщики и сохраняет собранные метрики в отчёт.
+type Reader struct {
+	mx         sync.Mutex
+	collectors []*scheduled
+	now        func() time.Time
+}
+
+// NewReader создаёт Reader со сборщиками, включёнными в cfg.Collectors или включёнными по умолчанию.
+// Если агент работает в cgroup v2, например в контейнере, по умолчанию вместо memory и cpu,
+// которые сообщают показатели всего хоста, включается сборщик cgroup.
+// Возвращает ошибку, если в настройках указан незарегистрированный сборщик или сборщик не удалось создать.
+func NewReader(cfg config.AgentConfig) (*Reader, error) {
+	for name := range cfg.Collectors {
+		if _, err := lookup(name); err != nil {
+			return nil, err
+		}
+	}
+	r := &Reader{now: time.Now}
+	inContainer := detectContainer()
+	for _, name := range Names() {
+		reg, err := lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		cc := cfg.Collectors[name]
+		enabled := reg.def.enabled(inContainer)
+		if cc.Enabled != nil {
+			enabled = *cc.Enabled
+		}
+		if !enabled {
+			continue
+		}
+		c, err := reg.factory(cc)
+		if err != nil {
+			return nil, fmt.Errorf("collector %s: %w", name, err)
+		}
+		r.collectors = append(r.collectors, &scheduled{name: name, collector: c, interval: cc.Interval})
+	}
+	return r, nil
+}
+
+// Enabled возвращает имена включённых сборщиков.
+func (r *Reader) Enabled() []string {
+	names := make([]string, 0, len(r.collectors))
+	for _, s := range r.collectors {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Read опрашивает сборщики, для которых наступило время сбора, и сохраняет метрики в report.
+// Ошибки и паники сборщиков выводятся в лог и не прерывают опрос остальных.
+func (r *Reader) Read(report *agent.Report) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	now := r.now()
+	for _, s := range r.collectors {
+		if now.Before(s.next) {
+			continue
+		}
+		s.next = now.Add(s.interval)
+		if err := collect(s.collector, report); err != nil {
+			log.Printf("Collector %s: %v", s.name, err)
+		}
+	}
+}
+
+func collect(c Collector, report *agent.Report) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	return c.Collect(ctx, report)
+}
@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+var calls = make(map[string]int)
+
+func init() {
+	Register("test-failing", DisabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(func(_ context.Context, report *agent.Report) error {
+			calls["test-failing"]++
+			report.SetGauge("Partial", 1)
+			return errors.New("source is unavailable")
+		}), nil
+	})
+	Register("test-panicking", DisabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(func(context.Context, *agent.Report) error {
+			calls["test-panicking"]++
+			panic("boom")
+		}), nil
+	})
+	Register("test-ok", DisabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(func(_ context.Context, report *agent.Report) error {
+			calls["test-ok"]++
+			report.AddCounter("TestOK", 1)
+			return nil
+		}), nil
+	})
+	Register("test-broken", DisabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return nil, errors.New("bad config")
+	})
+}
+
+func enabled(v bool) *bool {
+	return &v
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		Register("runtime", EnabledByDefault, func(config.CollectorConfig) (Collector, error) { return nil, nil })
+	})
+}
+
+func TestNames(t *testing.T) {
+	names := Names()
+	assert.Subset(t, names, []string{"cgroup", "cpu", "disk", "diskio", "load", "memory", "net", "processes", "runtime"})
+	assert.IsNonDecreasing(t, names)
+}
+
+// setInContainer подменяет результат определения контейнера на время теста.
+func setInContainer(t *testing.T, inContainer bool) {
+	orig := detectContainer
+	detectContainer = func() bool { return inContainer }
+	t.Cleanup(func() { detectContainer = orig })
+}
+
+func TestNewReader_Defaults(t *testing.T) {
+	setInContainer(t, false)
+	r, err := NewReader(config.AgentConfig{
+		Collectors: map[string]config.CollectorConfig{"memory": {Enabled: enabled(false)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "disk", "diskio", "load", "net", "processes", "runtime"}, r.Enabled())
+}
+
+func TestNewReader_DefaultsInContainer(t *testing.T) {
+	setInContainer(t, true)
+	registryMx.Lock()
+	registry["test-cgroup"] = registration{def: EnabledInContainer, factory: func(config.CollectorConfig) (Collector, error) {
+		return CollectorFunc(func(context.Context, *agent.Report) error { return nil }), nil
+	}}
+	registryMx.Unlock()
+	t.Cleanup(func() {
+		registryMx.Lock()
+		delete(registry, "test-cgroup")
+		registryMx.Unlock()
+	})
+
+	r, err := NewReader(config.AgentConfig{Collectors: map[string]config.CollectorConfig{
+		"cgroup": {Enabled: enabled(false)},
+		"cpu":    {Enabled: enabled(true)},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "disk", "diskio", "load", "net", "processes", "runtime", "test-cgroup"}, r.Enabled(),
+		"memory is replaced by cgroup metrics, explicit settings win")
+}
+
+func TestDefault_Enabled(t *testing.T) {
+	assert.False(t, DisabledByDefault.enabled(false))
+	assert.True(t, EnabledByDefault.enabled(true))
+	assert.True(t, EnabledOnHost.enabled(false))
+	assert.False(t, EnabledOnHost.enabled(true))
+	assert.True(t, EnabledInContainer.enabled(true))
+	assert.False(t, EnabledInContainer.enabled(false))
+}
+
+func TestNewReader_Errors(t *testing.T) {
+	_, err := NewReader(config.AgentConfig{
+		Collectors: map[string]config.CollectorConfig{"disk-typo": {Enabled: enabled(true)}},
+	})
+	assert.ErrorContains(t, err, `unknown collector "disk-typo"`)
+
+	_, err = NewReader(config.AgentConfig{
+		Collectors: map[string]config.CollectorConfig{"test-broken": {Enabled: enabled(true)}},
+	})
+	assert.ErrorContains(t, err, "collector test-broken: bad config")
+
+	_, err = NewReader(config.AgentConfig{
+		Collectors: map[string]config.CollectorConfig{"net": {Include: []string{"eth["}}},
+	})
+	assert.ErrorContains(t, err, `collector net: invalid pattern "eth["`)
+}
+
+func TestReader_Isolation(t *testing.T) {
+	clear(calls)
+	r, err := NewReader(config.AgentConfig{Collectors: map[string]config.CollectorConfig{
+		"cpu":            {Enabled: enabled(false)},
+		"memory":         {Enabled: enabled(false)},
+		"test-failing":   {Enabled: enabled(true)},
+		"test-panicking": {Enabled: enabled(true)},
+		"test-ok":        {Enabled: enabled(true)},
+	}})
+	require.NoError(t, err)
+
+	report := agent.NewReport()
+	r.Read(report)
+
+	assert.Equal(t, map[string]int{"test-failing": 1, "test-panicking": 1, "test-ok": 1}, calls)
+	assert.Equal(t, int64(1), report.GetCounter()["TestOK"])
+	assert.Equal(t, int64(1), report.GetCounter()[agent.PollCount], "other collectors run despite failures")
+	assert.Equal(t, 1.0, report.GetGauge()["Partial"], "metrics collected before an error are kept")
+}
+
+func TestReader_Intervals(t *testing.T) {
+	clear(calls)
+	r, err := NewReader(config.AgentConfig{Collectors: map[string]config.CollectorConfig{
+		"cpu":     {Enabled: enabled(false)},
+		"memory":  {Enabled: enabled(false)},
+		"runtime": {Enabled: enabled(false)},
+		"test-ok": {Enabled: enabled(true), Interval: 10 * time.Second},
+	}})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	report := agent.NewReport()
+	for i := 0; i < 6; i++ {
+		r.Read(report)
+		now = now.Add(4 * time.Second)
+	}
+	// Опросы в 0, 4, 8, 12, 16 и 20 секунд: сбор выполняется в 0 и 12 секунд.
+	assert.Equal(t, 2, calls["test-ok"])
+}
+
+func TestRuntimeCollector_Collect(t *testing.T) {
+	report := agent.NewReport()
+	require.NoError(t, NewRuntimeCollector().Collect(context.Background(), report))
+	assert.Contains(t, report.GetGauge(), agent.Alloc)
+	assert.Contains(t, report.GetGauge(), agent.RandomValue)
+	assert.Equal(t, int64(1), report.GetCounter()[agent.PollCount])
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func init() {
+	Register("runtime", EnabledByDefault, func(config.CollectorConfig) (Collector, error) {
+		return NewRuntimeCollector(), nil
+	})
+}
+
+// RuntimeCollector собирает статистику памяти Go runtime, а также метрики RandomValue и PollCount.
+type RuntimeCollector struct {
+	rtm runtime.MemStats // Структура для хранения статистики памяти Go runtime.
+}
+
+// NewRuntimeCollector создаёт и возвращает новый RuntimeCollector.
+func NewRuntimeCollector() *RuntimeCollector {
+	return &RuntimeCollector{}
+}
+
+// Collect сохраняет в report статистику памяти Go runtime.
+func (rc *RuntimeCollector) Collect(_ context.Context, mem *agent.Report) error {
+	runtime.ReadMemStats(&rc.rtm)
+	mem.SetGauge(agent.Alloc, float64(rc.rtm.Alloc))
+	mem.SetGauge(agent.BuckHashSys, float64(rc.rtm.BuckHashSys))
+	mem.SetGauge(agent.Frees, float64(rc.rtm.Frees))
+	mem.SetGauge(agent.GCCPUFraction, rc.rtm.GCCPUFraction)
+	mem.SetGauge(agent.GCSys, float64(rc.rtm.GCSys))
+	mem.SetGauge(agent.HeapAlloc, float64(rc.rtm.HeapAlloc))
+	mem.SetGauge(agent.HeapIdle, float64(rc.rtm.HeapIdle))
+	mem.SetGauge(agent.HeapInuse, float64(rc.rtm.HeapInuse))
+	mem.SetGauge(agent.HeapObjects, float64(rc.rtm.HeapObjects))
+	mem.SetGauge(agent.HeapReleased, float64(rc.rtm.HeapReleased))
+	mem.SetGauge(agent.HeapSys, float64(rc.rtm.HeapSys))
+	mem.SetGauge(agent.LastGC, float64(rc.rtm.LastGC))
+	mem.SetGauge(agent.Lookups, float64(rc.rtm.Lookups))
+	mem.SetGauge(agent.MCacheInuse, float64(rc.rtm.MCacheInuse))
+	mem.SetGauge(agent.MCacheSys, float64(rc.rtm.MCacheSys))
+	mem.SetGauge(agent.MSpanInuse, float64(rc.rtm.MSpanInuse))
+	mem.SetGauge(agent.MSpanSys, float64(rc.rtm.MSpanSys))
+	mem.SetGauge(agent.Mallocs, float64(rc.rtm.Mallocs))
+	mem.SetGauge(agent.NextGC, float64(rc.rtm.NextGC))
+	mem.SetGauge(agent.NumForcedGC, float64(rc.rtm.NumForcedGC))
+	mem.SetGauge(agent.NumGC, float64(rc.rtm.NumGC))
+	mem.SetGauge(agent.OtherSys, float64(rc.rtm.OtherSys))
+	mem.SetGauge(agent.PauseTotalNs, float64(rc.rtm.PauseTotalNs))
+	mem.SetGauge(agent.StackInuse, float64(rc.rtm.StackInuse))
+	mem.SetGauge(agent.StackSys, float64(rc.rtm.StackSys))
+	mem.SetGauge(agent.Sys, float64(rc.rtm.Sys))
+	mem.SetGauge(agent.TotalAlloc, float64(rc.rtm.TotalAlloc))
+	mem.SetGauge(agent.RandomValue, rand.Float64())
+
+	mem.AddCounter(agent.PollCount, 1)
+	return nil
+}
@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func TestDiskCollector_Collect(t *testing.T) {
+	f, err := newFilter(config.CollectorConfig{Exclude: []string{"/dev/loop*"}})
+	require.NoError(t, err)
+	dc := &diskCollector{
+		filter: f,
+		partitions: func(context.Context, bool) ([]disk.PartitionStat, error) {
+			return []disk.PartitionStat{
+				{Device: "/dev/sda1", Mountpoint: "/"},
+				{Device: "/dev/loop0", Mountpoint: "/snap/core"},
+				{Device: "/dev/sdb1", Mountpoint: "/data"},
+			}, nil
+		},
+		usage: func(_ context.Context, path string) (*disk.UsageStat, error) {
+			if path == "/data" {
+				return nil, errors.New("permission denied")
+			}
+			return &disk.UsageStat{Path: path, Total: 100, Used: 25, Free: 75, UsedPercent: 25}, nil
+		},
+	}
+
+	report := agent.NewReport()
+	err = dc.Collect(context.Background(), report)
+	assert.ErrorContains(t, err, "/data: permission denied")
+	assert.Equal(t, map[string]float64{
+		`DiskTotal{mountpoint="/"}`:       100,
+		`DiskUsed{mountpoint="/"}`:        25,
+		`DiskFree{mountpoint="/"}`:        75,
+		`DiskUsedPercent{mountpoint="/"}`: 25,
+	}, report.GetGauge())
+}
+
+func TestDiskIOCollector_Collect(t *testing.T) {
+	readBytes := uint64(1000)
+	dc := &diskIOCollector{
+		ioCounters: func(context.Context, ...string) (map[string]disk.IOCountersStat, error) {
+			return map[string]disk.IOCountersStat{
+				"sda": {Name: "sda", ReadBytes: readBytes, WriteBytes: 10, ReadCount: 1, WriteCount: 1},
+			}, nil
+		},
+	}
+
+	require.NoError(t, dc.Collect(context.Background(), agent.NewReport()))
+	readBytes = 1500
+	report := agent.NewReport()
+	require.NoError(t, dc.Collect(context.Background(), report))
+	assert.Equal(t, map[string]int64{
+		`DiskReadBytes{device="sda"}`:  500,
+		`DiskWriteBytes{device="sda"}`: 0,
+		`DiskReads{device="sda"}`:      0,
+		`DiskWrites{device="sda"}`:     0,
+	}, report.GetCounter())
+}
+
+func TestNetCollector_Collect(t *testing.T) {
+	f, err := newFilter(config.CollectorConfig{Include: []string{"eth*"}})
+	require.NoError(t, err)
+	sent := uint64(100)
+	nc := &netCollector{
+		filter: f,
+		ioCounters: func(context.Context, bool) ([]net.IOCountersStat, error) {
+			return []net.IOCountersStat{
+				{Name: "lo", BytesSent: sent},
+				{Name: "eth0", BytesSent: sent, Errin: 2},
+			}, nil
+		},
+	}
+
+	require.NoError(t, nc.Collect(context.Background(), agent.NewReport()))
+	sent = 40
+	report := agent.NewReport()
+	require.NoError(t, nc.Collect(context.Background(), report))
+	counters := report.GetCounter()
+	assert.Len(t, counters, 8)
+	assert.Equal(t, int64(40), counters[`NetBytesSent{interface="eth0"}`], "counter reset")
+	assert.Equal(t, int64(0), counters[`NetErrorsIn{interface="eth0"}`])
+	assert.NotContains(t, counters, `NetBytesSent{interface="lo"}`)
+}
+
+func TestNetCollector_Error(t *testing.T) {
+	nc := &netCollector{
+		ioCounters: func(context.Context, bool) ([]net.IOCountersStat, error) {
+			return nil, errors.New("no /proc/net/dev")
+		},
+	}
+	assert.Error(t, nc.Collect(context.Background(), agent.NewReport()))
+}
+
+func TestProcessesCollector_Collect(t *testing.T) {
+	pc := &processesCollector{
+		misc: func(context.Context) (*load.MiscStat, error) {
+			return &load.MiscStat{ProcsTotal: 120, ProcsRunning: 3, ProcsBlocked: 1}, nil
+		},
+		threads: func(context.Context) (int, error) { return 480, nil },
+	}
+
+	report := agent.NewReport()
+	require.NoError(t, pc.Collect(context.Background(), report))
+	assert.Equal(t, map[string]float64{
+		agent.ProcessCount:     120,
+		agent.ProcessesRunning: 3,
+		agent.ProcessesBlocked: 1,
+		agent.ThreadCount:      480,
+	}, report.GetGauge())
+}
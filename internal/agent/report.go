@@ -40,6 +40,65 @@ const (
 	TotalMemory    = "TotalMemory"
 	FreeMemory     = "FreeMemory"
 	CPUutilization = "CPUutilization"
+	// CircuitBreakerState - состояние выключателя отправки: 0 - замкнут, 1 - разомкнут, 2 - пробная отправка
+	// после размыкания. Так как разомкнутый выключатель не отправляет отчёты, сервер получает 2 с первым
+	// отчётом после восстановления связи.
+	CircuitBreakerState = "CircuitBreakerState"
+)
+
+// Константы, представляющие названия системных метрик. Метрики дисков и сетевых интерфейсов
+// передаются с метками mountpoint, device или interface.
+const (
+	DiskTotal        = "DiskTotal"
+	DiskUsed         = "DiskUsed"
+	DiskFree         = "DiskFree"
+	DiskUsedPercent  = "DiskUsedPercent"
+	DiskReadBytes    = "DiskReadBytes"
+	DiskWriteBytes   = "DiskWriteBytes"
+	DiskReads        = "DiskReads"
+	DiskWrites       = "DiskWrites"
+	NetBytesSent     = "NetBytesSent"
+	NetBytesRecv     = "NetBytesRecv"
+	NetPacketsSent   = "NetPacketsSent"
+	NetPacketsRecv   = "NetPacketsRecv"
+	NetErrorsIn      = "NetErrorsIn"
+	NetErrorsOut     = "NetErrorsOut"
+	NetDropsIn       = "NetDropsIn"
+	NetDropsOut      = "NetDropsOut"
+	Load1            = "Load1"
+	Load5            = "Load5"
+	Load15           = "Load15"
+	ProcessCount     = "ProcessCount"
+	ProcessesRunning = "ProcessesRunning"
+	ProcessesBlocked = "ProcessesBlocked"
+	ThreadCount      = "ThreadCount"
+)
+
+// Константы, представляющие названия метрик отслеживаемых процессов. Метрики передаются с меткой process.
+const (
+	ProcessInstances  = "ProcessInstances"
+	ProcessRSS        = "ProcessRSS"
+	ProcessCPUPercent = "ProcessCPUPercent"
+	ProcessOpenFDs    = "ProcessOpenFDs"
+	ProcessThreads    = "ProcessThreads"
+	ProcessRestarts   = "ProcessRestarts"
+)
+
+// Константы, представляющие названия метрик cgroup, в которой работает агент.
+const (
+	CgroupMemoryUsage         = "CgroupMemoryUsage"
+	CgroupMemoryLimit         = "CgroupMemoryLimit"
+	CgroupCPUUsage            = "CgroupCPUUsage"
+	CgroupCPULimit            = "CgroupCPULimit"
+	CgroupCPUPeriods          = "CgroupCPUPeriods"
+	CgroupCPUThrottledPeriods = "CgroupCPUThrottledPeriods"
+	CgroupCPUThrottledTime    = "CgroupCPUThrottledTime"
+	CgroupIOReadBytes         = "CgroupIOReadBytes"
+	CgroupIOWriteBytes        = "CgroupIOWriteBytes"
+	CgroupIOReads             = "CgroupIOReads"
+	CgroupIOWrites            = "CgroupIOWrites"
+	CgroupPids                = "CgroupPids"
+	CgroupPidsLimit           = "CgroupPidsLimit"
 )
 
 // Report хранит собранные метрики типа gauge и counter.
@@ -77,6 +136,33 @@ func (r *Report) AddCounter(name string, value int64) {
 	r.counter[name] += value
 }
 
+// Take забирает из отчёта все метрики для отправки и очищает отчёт, поэтому одновременные отправки
+// не доставляют одни и те же приращения counter-метрик дважды. Если отправить метрики не удалось,
+// их нужно вернуть в отчёт методом Restore.
+func (r *Report) Take() *Report {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	taken := &Report{gauge: r.gauge, counter: r.counter}
+	r.gauge = make(map[string]float64)
+	r.counter = make(map[string]int64)
+	return taken
+}
+
+// Restore возвращает в отчёт неотправленные метрики unsent: приращения counter-метрик прибавляются,
+// а gauge-метрики восстанавливаются, только если после Take им не было задано новое значение.
+func (r *Report) Restore(unsent *Report) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for k, v := range unsent.counter {
+		r.counter[k] += v
+	}
+	for k, v := range unsent.gauge {
+		if _, ok := r.gauge[k]; !ok {
+			r.gauge[k] = v
+		}
+	}
+}
+
 func (r *Report) Clean() {
 	r.mx.Lock()
 	defer r.mx.Unlock()
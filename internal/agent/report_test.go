@@ -103,6 +103,35 @@ func TestReportClean(t *testing.T) {
 	}
 }
 
+func TestReportTakeRestore(t *testing.T) {
+	report := &Report{
+		gauge:   map[string]float64{"metric1": 1.23, "metric2": 4.56},
+		counter: map[string]int64{"count1": 10, "count2": 20},
+	}
+
+	taken := report.Take()
+	if report.GetCommonCount() != 0 {
+		t.Errorf("expected report to be empty after Take(), got %d metrics", report.GetCommonCount())
+	}
+	if second := report.Take(); second.GetCommonCount() != 0 {
+		t.Errorf("expected second Take() not to return taken metrics, got %v", second.GetCounter())
+	}
+
+	report.SetGauge("metric2", 7.89)
+	report.AddCounter("count1", 5)
+	report.AddCounter("count3", 1)
+	report.Restore(taken)
+
+	gauge := report.GetGauge()
+	if len(gauge) != 2 || gauge["metric1"] != 1.23 || gauge["metric2"] != 7.89 {
+		t.Errorf("expected gauge set after Take() to win, got %v", gauge)
+	}
+	counter := report.GetCounter()
+	if len(counter) != 3 || counter["count1"] != 15 || counter["count2"] != 20 || counter["count3"] != 1 {
+		t.Errorf("expected restored increments to be added, got %v", counter)
+	}
+}
+
 func TestReportGetGauge(t *testing.T) {
 	report := &Report{
 		gauge: map[string]float64{"metric1": 1.23, "metric2": 4.56},
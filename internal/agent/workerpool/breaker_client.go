@@ -0,0 +1,40 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/pkg/breaker"
+)
+
+// BreakerClient отправляет отчёты через вложенный Client под защитой автоматического выключателя.
+// Пока сервер недоступен, отправка не выполняется, а метрики накапливаются в отчёте до восстановления.
+// Состояние выключателя передаётся в отчёте метрикой agent.CircuitBreakerState.
+type BreakerClient struct {
+	client  Client
+	breaker *breaker.Breaker
+}
+
+// NewBreakerClient создаёт и возвращает BreakerClient поверх client.
+// Смены состояния выключателя выводятся в лог.
+func NewBreakerClient(client Client, b *breaker.Breaker) *BreakerClient {
+	b.SetOnStateChange(func(from, to breaker.State) {
+		log.Printf("Circuit breaker: %s -> %s", from, to)
+	})
+	return &BreakerClient{client: client, breaker: b}
+}
+
+// SendReport отправляет отчёт, если выключатель замкнут или разрешает пробную отправку.
+// Если выключатель разомкнут, возвращает breaker.ErrOpen; накопленные метрики остаются в отчёте.
+func (bc *BreakerClient) SendReport(ctx context.Context, report *agent.Report) error {
+	err := bc.breaker.Execute(func() error {
+		report.SetGauge(agent.CircuitBreakerState, float64(bc.breaker.State()))
+		return bc.client.SendReport(ctx, report)
+	})
+	if errors.Is(err, breaker.ErrOpen) {
+		report.SetGauge(agent.CircuitBreakerState, float64(breaker.Open))
+	}
+	return err
+}
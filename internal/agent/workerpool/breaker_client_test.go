@@ -0,0 +1,111 @@
+package workerpool
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/client"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/pkg/breaker"
+	"github.com/moonicy/gometrics/pkg/retry"
+)
+
+type failingClient struct {
+	err   error
+	calls int
+	state []float64
+}
+
+func (c *failingClient) SendReport(_ context.Context, report *agent.Report) error {
+	c.calls++
+	c.state = append(c.state, report.GetGauge()[agent.CircuitBreakerState])
+	if c.err != nil {
+		return c.err
+	}
+	report.Clean()
+	return nil
+}
+
+func TestBreakerClient_SendReport(t *testing.T) {
+	inner := &failingClient{err: errors.New("server is not available")}
+	client := NewBreakerClient(inner, breaker.New(breaker.Settings{FailureThreshold: 2, Cooldown: 50 * time.Millisecond}))
+	report := agent.NewReport()
+	ctx := context.Background()
+
+	report.AddCounter(agent.PollCount, 1)
+	assert.Error(t, client.SendReport(ctx, report))
+	assert.Error(t, client.SendReport(ctx, report))
+	assert.Equal(t, 2, inner.calls)
+
+	// Выключатель разомкнут: отправка не выполняется, метрики остаются в отчёте.
+	report.AddCounter(agent.PollCount, 1)
+	err := client.SendReport(ctx, report)
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+	assert.Equal(t, 2, inner.calls)
+	assert.Equal(t, int64(2), report.GetCounter()[agent.PollCount])
+	assert.Equal(t, float64(breaker.Open), report.GetGauge()[agent.CircuitBreakerState])
+
+	time.Sleep(50 * time.Millisecond)
+	inner.err = nil
+	require.NoError(t, client.SendReport(ctx, report))
+	require.NoError(t, client.SendReport(ctx, report))
+	assert.Equal(t, 4, inner.calls)
+	assert.Equal(t, []float64{0, 0, float64(breaker.HalfOpen), float64(breaker.Closed)}, inner.state)
+}
+
+func TestBreakerClient_SendReport_ProbeFails(t *testing.T) {
+	var (
+		available atomic.Bool
+		received  atomic.Int64
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var batch []metrics.Metric
+		require.NoError(t, json.NewDecoder(body).Decode(&batch))
+		for _, m := range batch {
+			if m.ID == agent.PollCount {
+				received.Add(*m.Delta)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	inner := client.NewClient(server.URL, "", "")
+	inner.SetRetryPolicy(retry.Policy{MaxAttempts: 1})
+	bc := NewBreakerClient(inner, breaker.New(breaker.Settings{FailureThreshold: 1, Cooldown: 20 * time.Millisecond}))
+	report := agent.NewReport()
+	ctx := context.Background()
+
+	report.AddCounter(agent.PollCount, 1)
+	assert.Error(t, bc.SendReport(ctx, report))
+
+	// Пробная отправка не удалась: накопленные метрики не должны потеряться.
+	time.Sleep(20 * time.Millisecond)
+	report.AddCounter(agent.PollCount, 1)
+	assert.Error(t, bc.SendReport(ctx, report))
+	assert.Equal(t, int64(2), report.GetCounter()[agent.PollCount])
+
+	time.Sleep(20 * time.Millisecond)
+	available.Store(true)
+	report.AddCounter(agent.PollCount, 1)
+	require.NoError(t, bc.SendReport(ctx, report))
+	assert.Equal(t, int64(3), received.Load())
+	assert.NotContains(t, report.GetCounter(), agent.PollCount)
+}
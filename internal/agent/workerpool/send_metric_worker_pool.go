@@ -5,23 +5,27 @@ import (
 	"github.com/moonicy/gometrics/internal/agent"
 	"github.com/moonicy/gometrics/internal/config"
 	"github.com/moonicy/gometrics/pkg/workerpool"
+	"log"
 	"time"
 )
 
+// Client определяет интерфейс клиента, отправляющего отчёт с метриками на сервер.
 type Client interface {
-	SendReport(ctx context.Context, report *agent.Report)
+	SendReport(ctx context.Context, report *agent.Report) error
 }
 
 // RunSendReport запускает горутину для периодической отправки отчета с метриками на сервер.
 // Она использует пул воркеров для управления количеством одновременных задач и ограничивает скорость отправки.
-// При завершении возвращает функцию, которую можно вызвать для корректного закрытия пула воркеров.
+// При завершении возвращает функцию, которую можно вызвать для корректного закрытия пула воркеров:
+// она прерывает повторные попытки текущих отправок и отправляет оставшиеся метрики не дольше flushTimeout.
 func RunSendReport(cfg config.AgentConfig, client Client, mem *agent.Report, callback func()) func() {
-	queryTimeout := 1 * time.Second
+	flushTimeout := 1 * time.Second
 
 	cwp := workerpool.NewWorkerPool(5, cfg.RateLimit)
 	cwp.Run()
 
 	stop := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
 
@@ -32,9 +36,9 @@ func RunSendReport(cfg config.AgentConfig, client Client, mem *agent.Report, cal
 				return
 			default:
 				cwp.AddJob(func() error {
-					ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
-					defer cancel()
-					client.SendReport(ctx, mem)
+					if err := client.SendReport(ctx, mem); err != nil {
+						log.Print(err)
+					}
 					return nil
 				})
 				time.Sleep(cfg.ReportInterval)
@@ -44,11 +48,14 @@ func RunSendReport(cfg config.AgentConfig, client Client, mem *agent.Report, cal
 
 	return func() {
 		close(stop)
+		cancel()
 		ch := make(chan struct{})
 		cwp.AddJob(func() error {
-			ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+			ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
 			defer cancel()
-			client.SendReport(ctx, mem)
+			if err := client.SendReport(ctx, mem); err != nil {
+				log.Print(err)
+			}
 			ch <- struct{}{}
 			return nil
 		})
@@ -13,8 +13,9 @@ type MockClient struct {
 	SendReportCount int
 }
 
-func (m *MockClient) SendReport(_ context.Context, _ *agent.Report) {
+func (m *MockClient) SendReport(_ context.Context, _ *agent.Report) error {
 	m.SendReportCount++
+	return nil
 }
 
 func TestRunSendReport(t *testing.T) {
@@ -0,0 +1,304 @@
+package alerting
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// Параметры вычисления правил по умолчанию.
+const (
+	DefaultInterval   = 15 * time.Second // интервал вычисления правил
+	ResolvedRetention = 15 * time.Minute // время, в течение которого отображается завершившееся оповещение
+)
+
+// State - состояние оповещения.
+type State string
+
+// Состояния оповещения.
+const (
+	StatePending  State = "pending"  // условие выполняется, но ещё не дольше for
+	StateFiring   State = "firing"   // условие выполняется дольше for
+	StateResolved State = "resolved" // условие перестало выполняться после срабатывания
+)
+
+// Alert представляет оповещение по одному временному ряду правила.
+type Alert struct {
+	Rule        string            `json:"rule"`
+	Expr        string            `json:"expr"`
+	Type        string            `json:"type,omitempty"`   // тип метрики: gauge или counter
+	Metric      string            `json:"metric,omitempty"` // ключ временного ряда, пустой для absent
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	State       State             `json:"state"`
+	Value       float64           `json:"value"`
+	ActiveAt    time.Time         `json:"active_at"`
+	FiredAt     *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`
+}
+
+// Fingerprint возвращает ключ, однозначно определяющий оповещение среди оповещений движка.
+func (a Alert) Fingerprint() string {
+	return alertKey(a.Rule, a.Type, a.Metric)
+}
+
+func alertKey(rule, mType, metric string) string {
+	return rule + "\x00" + mType + "\x00" + metric
+}
+
+// Notifier определяет интерфейс доставки оповещений после каждого вычисления правил.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert)
+}
+
+// Storage определяет интерфейс хранилища, по значениям которого вычисляются правила.
+type Storage interface {
+	GetMetrics(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
+}
+
+// Engine периодически вычисляет правила оповещений и отслеживает состояние оповещений.
+type Engine struct {
+	rules    []*Rule
+	storage  Storage
+	notifier Notifier
+	now      func() time.Time
+
+	mx      sync.Mutex
+	alerts  map[string]*Alert
+	samples map[string][]metrics.Sample // наблюдения counter для правил rate
+}
+
+// NewEngine создаёт и возвращает новый Engine для правил rules.
+func NewEngine(rules []*Rule, storage Storage) *Engine {
+	return &Engine{
+		rules:   rules,
+		storage: storage,
+		now:     time.Now,
+		alerts:  make(map[string]*Alert),
+		samples: make(map[string][]metrics.Sample),
+	}
+}
+
+// SetNotifier задаёт получателя оповещений, вызываемого после каждого вычисления правил в Run.
+func (e *Engine) SetNotifier(notifier Notifier) {
+	e.notifier = notifier
+}
+
+// Run вычисляет правила с интервалом interval до завершения ctx.
+// Доставка оповещений ограничена тем же интервалом, чтобы недоступный получатель не задерживал вычисление правил.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Evaluate(ctx); err != nil {
+				log.Println("Error evaluating alert rules:", err)
+				continue
+			}
+			if e.notifier != nil {
+				notifyCtx, cancel := context.WithTimeout(ctx, interval)
+				e.notifier.Notify(notifyCtx, e.Alerts())
+				cancel()
+			}
+		}
+	}
+}
+
+// result - выполнившееся условие правила по одному временному ряду.
+type result struct {
+	mType  string
+	metric string
+	labels map[string]string
+	value  float64
+}
+
+// Evaluate однократно вычисляет все правила и обновляет состояние оповещений.
+// При ошибке чтения хранилища состояние оповещений не меняется.
+func (e *Engine) Evaluate(ctx context.Context) error {
+	counter, gauge, err := e.storage.GetMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	now := e.now().UTC()
+
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.observe(counter, now)
+	active := make(map[string]struct{})
+	for _, rule := range e.rules {
+		for _, res := range e.evalRule(rule, counter, gauge) {
+			key := alertKey(rule.Name, res.mType, res.metric)
+			active[key] = struct{}{}
+			e.activate(key, rule, res, now)
+		}
+	}
+	for key, alert := range e.alerts {
+		if _, ok := active[key]; ok {
+			continue
+		}
+		switch alert.State {
+		case StatePending:
+			delete(e.alerts, key)
+		case StateFiring:
+			alert.State = StateResolved
+			alert.ResolvedAt = &now
+		case StateResolved:
+			if now.Sub(*alert.ResolvedAt) >= ResolvedRetention {
+				delete(e.alerts, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Alerts возвращает копию всех оповещений, упорядоченных по правилу и временному ряду.
+func (e *Engine) Alerts() []Alert {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		if alerts[i].Type != alerts[j].Type {
+			return alerts[i].Type < alerts[j].Type
+		}
+		return alerts[i].Metric < alerts[j].Metric
+	})
+	return alerts
+}
+
+// activate переводит оповещение в pending или firing для выполнившегося условия.
+func (e *Engine) activate(key string, rule *Rule, res result, now time.Time) {
+	alert, ok := e.alerts[key]
+	if !ok || alert.State == StateResolved {
+		alert = &Alert{
+			Rule:        rule.Name,
+			Expr:        rule.Expr,
+			Type:        res.mType,
+			Metric:      res.metric,
+			Labels:      mergeLabels(res.labels, rule.Labels),
+			Annotations: rule.Annotations,
+			State:       StatePending,
+			ActiveAt:    now,
+		}
+		e.alerts[key] = alert
+	}
+	alert.Value = res.value
+	if alert.State == StatePending && now.Sub(alert.ActiveAt) >= rule.For {
+		alert.State = StateFiring
+		alert.FiredAt = &now
+	}
+}
+
+// evalRule возвращает временные ряды, для которых выполняется условие правила.
+func (e *Engine) evalRule(rule *Rule, counter map[string]int64, gauge map[string]float64) []result {
+	var results []result
+	switch rule.Kind {
+	case KindThreshold:
+		for key, value := range gauge {
+			if labels, ok := rule.match(key); ok && rule.compare(value) {
+				results = append(results, result{metrics.Gauge, key, labels, value})
+			}
+		}
+		for key, value := range counter {
+			if labels, ok := rule.match(key); ok && rule.compare(float64(value)) {
+				results = append(results, result{metrics.Counter, key, labels, float64(value)})
+			}
+		}
+	case KindRate:
+		for key := range counter {
+			labels, ok := rule.match(key)
+			if !ok {
+				continue
+			}
+			if value, ok := rate(e.samples[key], rule.window); ok && rule.compare(value) {
+				results = append(results, result{metrics.Counter, key, labels, value})
+			}
+		}
+	case KindAbsent:
+		for key := range gauge {
+			if _, ok := rule.match(key); ok {
+				return nil
+			}
+		}
+		for key := range counter {
+			if _, ok := rule.match(key); ok {
+				return nil
+			}
+		}
+		results = append(results, result{labels: rule.selectorLabels()})
+	}
+	return results
+}
+
+// observe запоминает значения counter для правил rate и удаляет наблюдения вне окна.
+func (e *Engine) observe(counter map[string]int64, now time.Time) {
+	var window time.Duration
+	for _, rule := range e.rules {
+		if rule.Kind == KindRate {
+			window = max(window, rule.window)
+		}
+	}
+	if window == 0 {
+		return
+	}
+	for key := range e.samples {
+		if _, ok := counter[key]; !ok {
+			delete(e.samples, key)
+		}
+	}
+	for key, value := range counter {
+		samples := append(e.samples[key], metrics.Sample{Timestamp: now, Value: float64(value)})
+		i := sort.Search(len(samples), func(i int) bool { return !samples[i].Timestamp.Before(now.Add(-window)) })
+		e.samples[key] = samples[i:]
+	}
+}
+
+// match проверяет, относится ли ключ временного ряда к метрике правила, и возвращает его метки.
+func (r *Rule) match(key string) (map[string]string, bool) {
+	id, labels, err := metrics.ParseSeriesKey(key)
+	if err != nil || id != r.metric || !metrics.MatchAll(r.matchers, labels) {
+		return nil, false
+	}
+	return labels, true
+}
+
+// rate вычисляет скорость роста counter в секунду по наблюдениям за последнее окно window.
+func rate(samples []metrics.Sample, window time.Duration) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	last := samples[len(samples)-1].Timestamp
+	first := sort.Search(len(samples)-2, func(i int) bool { return last.Sub(samples[i].Timestamp) <= window })
+	return metrics.Rate(samples[first:])
+}
+
+// mergeLabels объединяет метки временного ряда и метки правила; метки правила имеют приоритет.
+func mergeLabels(series, rule map[string]string) map[string]string {
+	if len(series) == 0 && len(rule) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(series)+len(rule))
+	for k, v := range series {
+		labels[k] = v
+	}
+	for k, v := range rule {
+		labels[k] = v
+	}
+	return labels
+}
@@ -0,0 +1,168 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
+)
+
+func newTestEngine(t *testing.T, st Storage, rules ...config.AlertRule) (*Engine, *time.Time) {
+	t.Helper()
+	parsed, err := ParseRules(rules)
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewEngine(parsed, st)
+	e.now = func() time.Time { return now }
+	return e, &now
+}
+
+func TestEngine_Threshold(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	e, now := newTestEngine(t, st, config.AlertRule{
+		Name:   "HighHeap",
+		Expr:   "HeapAlloc > 100 for 2m",
+		Labels: map[string]string{"severity": "page"},
+	})
+
+	require.NoError(t, st.SetGauge(ctx, "HeapAlloc", 200))
+	require.NoError(t, e.Evaluate(ctx))
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StatePending, alerts[0].State)
+	assert.Equal(t, map[string]string{"severity": "page"}, alerts[0].Labels)
+
+	*now = now.Add(2 * time.Minute)
+	require.NoError(t, e.Evaluate(ctx))
+	alerts = e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Equal(t, 200.0, alerts[0].Value)
+	require.NotNil(t, alerts[0].FiredAt)
+
+	require.NoError(t, st.SetGauge(ctx, "HeapAlloc", 50))
+	*now = now.Add(time.Minute)
+	require.NoError(t, e.Evaluate(ctx))
+	alerts = e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateResolved, alerts[0].State)
+	require.NotNil(t, alerts[0].ResolvedAt)
+
+	*now = now.Add(ResolvedRetention)
+	require.NoError(t, e.Evaluate(ctx))
+	assert.Empty(t, e.Alerts())
+}
+
+func TestEngine_PendingCleared(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	e, now := newTestEngine(t, st, config.AlertRule{Name: "HighHeap", Expr: "HeapAlloc > 100 for 2m"})
+
+	require.NoError(t, st.SetGauge(ctx, "HeapAlloc", 200))
+	require.NoError(t, e.Evaluate(ctx))
+	require.NoError(t, st.SetGauge(ctx, "HeapAlloc", 10))
+	*now = now.Add(time.Minute)
+	require.NoError(t, e.Evaluate(ctx))
+
+	assert.Empty(t, e.Alerts())
+}
+
+func TestEngine_Selector(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	e, _ := newTestEngine(t, st, config.AlertRule{Name: "HighCPU", Expr: `CPU{env="prod"} > 0.9`})
+
+	require.NoError(t, st.SetGauge(ctx, metrics.SeriesKey("CPU", map[string]string{"env": "prod", "host": "a"}), 0.95))
+	require.NoError(t, st.SetGauge(ctx, metrics.SeriesKey("CPU", map[string]string{"env": "dev", "host": "b"}), 0.99))
+	require.NoError(t, e.Evaluate(ctx))
+
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Equal(t, metrics.Gauge, alerts[0].Type)
+	assert.Equal(t, map[string]string{"env": "prod", "host": "a"}, alerts[0].Labels)
+}
+
+func TestEngine_Rate(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	e, now := newTestEngine(t, st, config.AlertRule{Name: "FastRequests", Expr: "rate(Requests[1m]) > 1"})
+
+	require.NoError(t, st.AddCounter(ctx, "Requests", 10))
+	require.NoError(t, e.Evaluate(ctx))
+	assert.Empty(t, e.Alerts(), "rate needs at least two observations")
+
+	*now = now.Add(10 * time.Second)
+	require.NoError(t, st.AddCounter(ctx, "Requests", 30))
+	require.NoError(t, e.Evaluate(ctx))
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 3.0, alerts[0].Value)
+
+	*now = now.Add(10 * time.Second)
+	require.NoError(t, e.Evaluate(ctx))
+	alerts = e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 1.5, alerts[0].Value)
+
+	*now = now.Add(2 * time.Minute)
+	require.NoError(t, e.Evaluate(ctx))
+	alerts = e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateResolved, alerts[0].State)
+}
+
+func TestRate_Window(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []metrics.Sample{
+		{Timestamp: base, Value: 0},
+		{Timestamp: base.Add(time.Minute), Value: 100},
+		{Timestamp: base.Add(70 * time.Second), Value: 105},
+	}
+
+	value, ok := rate(samples, 30*time.Second)
+
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, value)
+}
+
+func TestEngine_Absent(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	e, now := newTestEngine(t, st, config.AlertRule{Name: "NoAlloc", Expr: `absent(Alloc{host="a"}) for 1m`})
+
+	require.NoError(t, e.Evaluate(ctx))
+	*now = now.Add(time.Minute)
+	require.NoError(t, e.Evaluate(ctx))
+	alerts := e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Equal(t, map[string]string{"host": "a"}, alerts[0].Labels)
+
+	require.NoError(t, st.SetGauge(ctx, metrics.SeriesKey("Alloc", map[string]string{"host": "a"}), 1))
+	require.NoError(t, e.Evaluate(ctx))
+	alerts = e.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateResolved, alerts[0].State)
+}
+
+type failingStorage struct{}
+
+func (failingStorage) GetMetrics(context.Context) (map[string]int64, map[string]float64, error) {
+	return nil, nil, errors.New("storage is down")
+}
+
+func TestEngine_StorageError(t *testing.T) {
+	e, _ := newTestEngine(t, failingStorage{}, config.AlertRule{Name: "NoAlloc", Expr: "absent(Alloc)"})
+
+	assert.Error(t, e.Evaluate(context.Background()))
+	assert.Empty(t, e.Alerts())
+}
@@ -0,0 +1,194 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/config"
+	sign "github.com/moonicy/gometrics/pkg/hash"
+	"github.com/moonicy/gometrics/pkg/retry"
+)
+
+// DefaultWebhookTimeout - время ожидания ответа на один запрос к webhook.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Notification - тело запроса к webhook: группа оповещений одного правила.
+type Notification struct {
+	Rule   string  `json:"rule"`
+	Status State   `json:"status"` // firing, если в группе есть срабатывающие оповещения, иначе resolved
+	Alerts []Alert `json:"alerts"`
+}
+
+// sentAlert - последнее доставленное состояние оповещения.
+type sentAlert struct {
+	state State
+	at    time.Time
+}
+
+// WebhookNotifier отправляет оповещения методом POST на заданные адреса.
+// Оповещения группируются по правилу, повторные уведомления о продолжающемся
+// оповещении отправляются не чаще repeatInterval, о завершении - однократно.
+// Неудачная доставка повторяется при следующем вызове Notify.
+type WebhookNotifier struct {
+	webhooks       []config.AlertWebhook
+	repeatInterval time.Duration
+	httpClient     *http.Client
+	now            func() time.Time
+
+	mx   sync.Mutex
+	sent []map[string]sentAlert // для каждого webhook: отпечаток оповещения -> доставленное состояние
+}
+
+// NewWebhookNotifier создаёт и возвращает новый WebhookNotifier.
+// Если у webhook не задан ключ подписи, используется hashKey.
+func NewWebhookNotifier(webhooks []config.AlertWebhook, hashKey string, repeatInterval time.Duration) *WebhookNotifier {
+	hooks := make([]config.AlertWebhook, 0, len(webhooks))
+	sent := make([]map[string]sentAlert, 0, len(webhooks))
+	for _, wh := range webhooks {
+		if wh.Key == "" {
+			wh.Key = hashKey
+		}
+		hooks = append(hooks, wh)
+		sent = append(sent, make(map[string]sentAlert))
+	}
+	return &WebhookNotifier{
+		webhooks:       hooks,
+		repeatInterval: repeatInterval,
+		httpClient:     &http.Client{Timeout: DefaultWebhookTimeout},
+		now:            time.Now,
+		sent:           sent,
+	}
+}
+
+// Notify отправляет на каждый webhook новые, повторяемые и завершившиеся оповещения.
+// Webhook обслуживаются параллельно, поэтому зависший получатель не задерживает остальных;
+// время доставки ограничивается ctx.
+func (wn *WebhookNotifier) Notify(ctx context.Context, alerts []Alert) {
+	wn.mx.Lock()
+	defer wn.mx.Unlock()
+	now := wn.now()
+	var wg sync.WaitGroup
+	for i, wh := range wn.webhooks {
+		wg.Add(1)
+		go func(wh config.AlertWebhook, sent map[string]sentAlert) {
+			defer wg.Done()
+			wn.notify(ctx, wh, sent, alerts, now)
+		}(wh, wn.sent[i])
+	}
+	wg.Wait()
+}
+
+// notify отправляет оповещения на один webhook и запоминает доставленные в sent.
+func (wn *WebhookNotifier) notify(ctx context.Context, wh config.AlertWebhook, sent map[string]sentAlert, alerts []Alert, now time.Time) {
+	for _, n := range wn.pending(sent, alerts, now) {
+		if err := wn.send(ctx, wh, n); err != nil {
+			log.Printf("Error sending alert %s to %s: %v", n.Rule, wh.URL, err)
+			continue
+		}
+		for _, alert := range n.Alerts {
+			if alert.State == StateResolved {
+				delete(sent, alert.Fingerprint())
+				continue
+			}
+			sent[alert.Fingerprint()] = sentAlert{state: alert.State, at: now}
+		}
+	}
+	wn.forget(sent, alerts)
+}
+
+// pending возвращает сгруппированные по правилу оповещения, которые нужно отправить на webhook.
+func (wn *WebhookNotifier) pending(sent map[string]sentAlert, alerts []Alert, now time.Time) []Notification {
+	groups := make(map[string]*Notification)
+	for _, alert := range alerts {
+		last, ok := sent[alert.Fingerprint()]
+		switch alert.State {
+		case StateFiring:
+			if ok && (wn.repeatInterval <= 0 || now.Sub(last.at) < wn.repeatInterval) {
+				continue
+			}
+		case StateResolved:
+			if !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		n, ok := groups[alert.Rule]
+		if !ok {
+			n = &Notification{Rule: alert.Rule, Status: StateResolved}
+			groups[alert.Rule] = n
+		}
+		if alert.State == StateFiring {
+			n.Status = StateFiring
+		}
+		n.Alerts = append(n.Alerts, alert)
+	}
+
+	result := make([]Notification, 0, len(groups))
+	for _, n := range groups {
+		result = append(result, *n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Rule < result[j].Rule })
+	return result
+}
+
+// forget удаляет сведения о доставке оповещений, которых больше нет у движка.
+func (wn *WebhookNotifier) forget(sent map[string]sentAlert, alerts []Alert) {
+	current := make(map[string]struct{}, len(alerts))
+	for _, alert := range alerts {
+		current[alert.Fingerprint()] = struct{}{}
+	}
+	for fp := range sent {
+		if _, ok := current[fp]; !ok {
+			delete(sent, fp)
+		}
+	}
+}
+
+// send отправляет уведомление на webhook, повторяя попытки при сетевых ошибках и ответах 5xx и 429, пока не завершится ctx.
+// Если задан ключ, тело подписывается в заголовке HashSHA256.
+func (wn *WebhookNotifier) send(ctx context.Context, wh config.AlertWebhook, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return retry.DefaultPolicy().Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if wh.Key != "" {
+			req.Header.Set("HashSHA256", sign.CalcHash(body, wh.Key))
+		}
+		resp, err := wn.httpClient.Do(req)
+		if err != nil {
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) && ctx.Err() == nil {
+				return retry.NewRetryableError(urlErr.Error())
+			}
+			return err
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				log.Print(err)
+			}
+		}()
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return retry.NewRetryableError("webhook is not available: " + resp.Status)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,222 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/config"
+	sign "github.com/moonicy/gometrics/pkg/hash"
+)
+
+// receiver - тестовый webhook, запоминающий полученные уведомления.
+type receiver struct {
+	mx       sync.Mutex
+	received []Notification
+	hashes   []string
+	bodies   [][]byte
+	failures int // количество первых запросов, на которые возвращается 503
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var n Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.received = append(rc.received, n)
+	rc.hashes = append(rc.hashes, r.Header.Get("HashSHA256"))
+	rc.bodies = append(rc.bodies, body)
+}
+
+func newTestNotifier(url, key string, repeat time.Duration) (*WebhookNotifier, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wn := NewWebhookNotifier([]config.AlertWebhook{{URL: url}}, key, repeat)
+	wn.now = func() time.Time { return now }
+	return wn, &now
+}
+
+func TestWebhookNotifier_Dedup(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	ctx := context.Background()
+	wn, now := newTestNotifier(srv.URL, "secret", 10*time.Minute)
+
+	firing := []Alert{
+		{Rule: "HighHeap", Type: "gauge", Metric: `HeapAlloc{host="a"}`, State: StateFiring, Value: 2e9},
+		{Rule: "HighHeap", Type: "gauge", Metric: `HeapAlloc{host="b"}`, State: StateFiring, Value: 3e9},
+		{Rule: "NoAlloc", State: StatePending},
+	}
+	wn.Notify(ctx, firing)
+	wn.Notify(ctx, firing)
+
+	require.Len(t, rc.received, 1, "repeated alerts must be deduplicated")
+	assert.Equal(t, "HighHeap", rc.received[0].Rule)
+	assert.Equal(t, StateFiring, rc.received[0].Status)
+	assert.Len(t, rc.received[0].Alerts, 2, "alerts of one rule must be grouped")
+	assert.Equal(t, sign.CalcHash(rc.bodies[0], "secret"), rc.hashes[0])
+
+	*now = now.Add(10 * time.Minute)
+	wn.Notify(ctx, firing)
+	require.Len(t, rc.received, 2, "firing alerts must be repeated after repeat interval")
+
+	resolved := []Alert{
+		{Rule: "HighHeap", Type: "gauge", Metric: `HeapAlloc{host="a"}`, State: StateResolved},
+		firing[1],
+	}
+	wn.Notify(ctx, resolved)
+	wn.Notify(ctx, resolved)
+	require.Len(t, rc.received, 3, "resolve must be sent once")
+	assert.Equal(t, StateResolved, rc.received[2].Status)
+	require.Len(t, rc.received[2].Alerts, 1)
+	assert.Equal(t, `HeapAlloc{host="a"}`, rc.received[2].Alerts[0].Metric)
+}
+
+func TestWebhookNotifier_ResolvedWithoutFiring(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	wn, _ := newTestNotifier(srv.URL, "", 0)
+
+	wn.Notify(context.Background(), []Alert{{Rule: "HighHeap", State: StateResolved}})
+
+	assert.Empty(t, rc.received)
+}
+
+func TestWebhookNotifier_Retry(t *testing.T) {
+	rc := &receiver{failures: 1}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	wn, _ := newTestNotifier(srv.URL, "", 0)
+
+	wn.Notify(context.Background(), []Alert{{Rule: "HighHeap", State: StateFiring}})
+
+	require.Len(t, rc.received, 1)
+	assert.Empty(t, rc.hashes[0], "body must not be signed without a key")
+}
+
+func TestWebhookNotifier_RedeliverAfterError(t *testing.T) {
+	var fail atomic.Bool
+	rc := &receiver{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rc.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	wn, _ := newTestNotifier(srv.URL, "", 0)
+	alerts := []Alert{{Rule: "HighHeap", State: StateFiring}}
+
+	fail.Store(true)
+	wn.Notify(context.Background(), alerts)
+	assert.Empty(t, rc.received)
+
+	fail.Store(false)
+	wn.Notify(context.Background(), alerts)
+	assert.Len(t, rc.received, 1)
+}
+
+func TestEngine_RunNotifies(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	rules, err := ParseRules([]config.AlertRule{{Name: "NoAlloc", Expr: "absent(Alloc)"}})
+	require.NoError(t, err)
+	e := NewEngine(rules, emptyStorage{})
+	e.SetNotifier(NewWebhookNotifier([]config.AlertWebhook{{URL: srv.URL}}, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx, time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		rc.mx.Lock()
+		defer rc.mx.Unlock()
+		return len(rc.received) == 1
+	}, time.Second, time.Millisecond)
+}
+
+type emptyStorage struct{}
+
+func (emptyStorage) GetMetrics(context.Context) (map[string]int64, map[string]float64, error) {
+	return map[string]int64{}, map[string]float64{}, nil
+}
+
+// hangingWebhook не отвечает до завершения теста и считает полученные запросы.
+type hangingWebhook struct {
+	requests atomic.Int32
+	release  chan struct{}
+}
+
+func newHangingWebhook(t *testing.T) (*hangingWebhook, *httptest.Server) {
+	hw := &hangingWebhook{release: make(chan struct{})}
+	srv := httptest.NewServer(hw)
+	t.Cleanup(func() {
+		close(hw.release)
+		srv.Close()
+	})
+	return hw, srv
+}
+
+func (hw *hangingWebhook) ServeHTTP(_ http.ResponseWriter, r *http.Request) {
+	hw.requests.Add(1)
+	select {
+	case <-r.Context().Done():
+	case <-hw.release:
+	}
+}
+
+func TestWebhookNotifier_HangingWebhook(t *testing.T) {
+	_, hanging := newHangingWebhook(t)
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	wn := NewWebhookNotifier([]config.AlertWebhook{{URL: hanging.URL}, {URL: srv.URL}}, "", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	wn.Notify(ctx, []Alert{{Rule: "HighHeap", State: StateFiring}})
+
+	assert.Less(t, time.Since(start), time.Second, "delivery must be limited by ctx")
+	assert.Len(t, rc.received, 1, "hanging webhook must not block other webhooks")
+}
+
+func TestEngine_RunHangingWebhook(t *testing.T) {
+	hw, srv := newHangingWebhook(t)
+
+	rules, err := ParseRules([]config.AlertRule{{Name: "NoAlloc", Expr: "absent(Alloc)"}})
+	require.NoError(t, err)
+	e := NewEngine(rules, emptyStorage{})
+	e.SetNotifier(NewWebhookNotifier([]config.AlertWebhook{{URL: srv.URL}}, "", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx, 20*time.Millisecond)
+
+	// Недоставленное оповещение отправляется снова после каждого вычисления правил.
+	assert.Eventually(t, func() bool {
+		return hw.requests.Load() >= 3
+	}, 2*time.Second, time.Millisecond, "rules must be evaluated while webhook hangs")
+}
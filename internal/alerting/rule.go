@@ -0,0 +1,216 @@
+package alerting
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// ErrWrongRule возвращается, если правило оповещения задано некорректно.
+var ErrWrongRule = errors.New("wrong alert rule")
+
+// Операторы сравнения в условии правила.
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// Виды условий правила.
+const (
+	KindThreshold = "threshold" // сравнение значения gauge или counter с порогом
+	KindRate      = "rate"      // сравнение скорости роста counter в секунду с порогом
+	KindAbsent    = "absent"    // отсутствие данных по метрике
+)
+
+// Rule представляет разобранное правило оповещения.
+// Условие записывается в одном из видов:
+//
+//	HeapAlloc > 1e9 for 2m
+//	rate(PollCount{host="a"}[1m]) < 0.1 for 5m
+//	absent(Alloc) for 1m
+//
+// Часть "for" необязательна: без неё оповещение срабатывает при первом выполнении условия.
+type Rule struct {
+	Name        string
+	Expr        string
+	Kind        string
+	For         time.Duration
+	Labels      map[string]string
+	Annotations map[string]string
+
+	metric    string
+	matchers  []metrics.LabelMatcher
+	op        string
+	threshold float64
+	window    time.Duration
+}
+
+// ParseRules разбирает правила оповещений из конфигурации сервера.
+func ParseRules(cfg []config.AlertRule) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(cfg))
+	names := make(map[string]struct{}, len(cfg))
+	for _, rc := range cfg {
+		if _, ok := names[rc.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrWrongRule, rc.Name)
+		}
+		names[rc.Name] = struct{}{}
+		rule, err := ParseRule(rc)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseRule разбирает одно правило оповещения.
+func ParseRule(rc config.AlertRule) (*Rule, error) {
+	if strings.TrimSpace(rc.Name) == "" {
+		return nil, fmt.Errorf("%w: empty name", ErrWrongRule)
+	}
+	if err := metrics.ValidateLabels(rc.Labels); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrWrongRule, rc.Name, err)
+	}
+	rule := &Rule{Name: rc.Name, Expr: rc.Expr, Labels: rc.Labels, Annotations: rc.Annotations}
+	if err := rule.parseExpr(rc.Expr); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrWrongRule, rc.Name, err)
+	}
+	return rule, nil
+}
+
+func (r *Rule) parseExpr(expr string) error {
+	expr = strings.TrimSpace(expr)
+	if i := strings.LastIndex(expr, " for "); i >= 0 {
+		d, err := time.ParseDuration(strings.TrimSpace(expr[i+len(" for "):]))
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid for duration: %s", expr[i+len(" for "):])
+		}
+		r.For = d
+		expr = strings.TrimSpace(expr[:i])
+	}
+
+	if inner, ok := call(expr, KindAbsent); ok {
+		r.Kind = KindAbsent
+		return r.parseSelector(inner)
+	}
+
+	left, op, right, err := splitComparison(expr)
+	if err != nil {
+		return err
+	}
+	r.op = op
+	if r.threshold, err = strconv.ParseFloat(right, 64); err != nil {
+		return fmt.Errorf("invalid threshold: %s", right)
+	}
+	if inner, ok := call(left, KindRate); ok {
+		r.Kind = KindRate
+		open := strings.LastIndexByte(inner, '[')
+		if open < 0 || !strings.HasSuffix(inner, "]") {
+			return errors.New("rate requires a window, e.g. rate(PollCount[1m])")
+		}
+		if r.window, err = time.ParseDuration(inner[open+1 : len(inner)-1]); err != nil || r.window <= 0 {
+			return fmt.Errorf("invalid rate window: %s", inner[open+1:len(inner)-1])
+		}
+		return r.parseSelector(inner[:open])
+	}
+	r.Kind = KindThreshold
+	return r.parseSelector(left)
+}
+
+func (r *Rule) parseSelector(selector string) error {
+	metric, matchers, err := metrics.ParseSelector(selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector %s: %v", selector, err)
+	}
+	r.metric = metric
+	r.matchers = matchers
+	return nil
+}
+
+// compare проверяет условие сравнения значения с порогом правила.
+func (r *Rule) compare(value float64) bool {
+	switch r.op {
+	case OpGreater:
+		return value > r.threshold
+	case OpGreaterEqual:
+		return value >= r.threshold
+	case OpLess:
+		return value < r.threshold
+	case OpLessEqual:
+		return value <= r.threshold
+	case OpEqual:
+		return value == r.threshold
+	case OpNotEqual:
+		return value != r.threshold
+	}
+	return false
+}
+
+// selectorLabels возвращает метки, заданные в селекторе через равенство.
+func (r *Rule) selectorLabels() map[string]string {
+	labels := make(map[string]string)
+	for _, m := range r.matchers {
+		if m.Op == metrics.MatchEqual {
+			labels[m.Name] = m.Value
+		}
+	}
+	return labels
+}
+
+// call возвращает аргумент вызова функции name(...), если выражение является таким вызовом.
+func call(expr, name string) (string, bool) {
+	if !strings.HasPrefix(expr, name+"(") || !strings.HasSuffix(expr, ")") {
+		return "", false
+	}
+	return strings.TrimSpace(expr[len(name)+1 : len(expr)-1]), true
+}
+
+// splitComparison разделяет выражение на левую часть, оператор сравнения и порог.
+// Операторы внутри фигурных скобок и строк селектора не учитываются.
+func splitComparison(expr string) (string, string, string, error) {
+	depth := 0
+	inQuotes := false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case inQuotes:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuotes = false
+			}
+			continue
+		case c == '"':
+			inQuotes = true
+			continue
+		case c == '{' || c == '(' || c == '[':
+			depth++
+			continue
+		case c == '}' || c == ')' || c == ']':
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+		for _, op := range []string{OpGreaterEqual, OpLessEqual, OpEqual, OpNotEqual, OpGreater, OpLess} {
+			if strings.HasPrefix(expr[i:], op) {
+				left := strings.TrimSpace(expr[:i])
+				right := strings.TrimSpace(expr[i+len(op):])
+				if left == "" || right == "" {
+					break
+				}
+				return left, op, right, nil
+			}
+		}
+	}
+	return "", "", "", errors.New("expected comparison, e.g. HeapAlloc > 1e9")
+}
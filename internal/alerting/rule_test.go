@@ -0,0 +1,67 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/config"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		expr      string
+		kind      string
+		metric    string
+		op        string
+		threshold float64
+		window    time.Duration
+		forDur    time.Duration
+	}{
+		{name: "threshold", expr: "HeapAlloc > 1e9 for 2m", kind: KindThreshold, metric: "HeapAlloc", op: OpGreater, threshold: 1e9, forDur: 2 * time.Minute},
+		{name: "without for", expr: "Alloc<=10", kind: KindThreshold, metric: "Alloc", op: OpLessEqual, threshold: 10},
+		{name: "selector", expr: `CPU{host=~"a|b", env!="dev"} >= 0.9`, kind: KindThreshold, metric: "CPU", op: OpGreaterEqual, threshold: 0.9},
+		{name: "rate", expr: "rate(PollCount[1m]) < 0.5 for 5m", kind: KindRate, metric: "PollCount", op: OpLess, threshold: 0.5, window: time.Minute, forDur: 5 * time.Minute},
+		{name: "absent", expr: `absent(Alloc{host="a"}) for 1m`, kind: KindAbsent, metric: "Alloc", forDur: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(config.AlertRule{Name: tt.name, Expr: tt.expr})
+			require.NoError(t, err)
+			assert.Equal(t, tt.kind, rule.Kind)
+			assert.Equal(t, tt.metric, rule.metric)
+			assert.Equal(t, tt.op, rule.op)
+			assert.Equal(t, tt.threshold, rule.threshold)
+			assert.Equal(t, tt.window, rule.window)
+			assert.Equal(t, tt.forDur, rule.For)
+		})
+	}
+}
+
+func TestParseRule_Errors(t *testing.T) {
+	for _, rc := range []config.AlertRule{
+		{Name: "", Expr: "Alloc > 1"},
+		{Name: "no op", Expr: "Alloc"},
+		{Name: "bad threshold", Expr: "Alloc > many"},
+		{Name: "bad for", Expr: "Alloc > 1 for ever"},
+		{Name: "rate without window", Expr: "rate(PollCount) > 1"},
+		{Name: "bad selector", Expr: `Alloc{host=} > 1`},
+		{Name: "bad labels", Expr: "Alloc > 1", Labels: map[string]string{"bad-name": "x"}},
+	} {
+		t.Run(rc.Name, func(t *testing.T) {
+			_, err := ParseRule(rc)
+			assert.ErrorIs(t, err, ErrWrongRule)
+		})
+	}
+}
+
+func TestParseRules_Duplicate(t *testing.T) {
+	_, err := ParseRules([]config.AlertRule{
+		{Name: "HighHeap", Expr: "HeapAlloc > 1"},
+		{Name: "HighHeap", Expr: "HeapAlloc > 2"},
+	})
+	assert.ErrorIs(t, err, ErrWrongRule)
+}
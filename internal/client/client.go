@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/moonicy/gometrics/pkg/crypt"
@@ -10,7 +11,7 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"net/url"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 
@@ -21,82 +22,128 @@ import (
 	"github.com/moonicy/gometrics/pkg/retry"
 )
 
+// attemptTimeout - время на одну попытку отправки отчёта.
+const attemptTimeout = time.Second
+
 // Client представляет клиента для отправки метрик на сервер.
 type Client struct {
 	httpClient *http.Client
 	host       string
 	hashKey    string
-	cryptoKey  string
+	publicKey  *crypt.PublicKeyFile
+	labels     map[string]string
+	retry      retry.Policy
 }
 
 // NewClient создаёт и возвращает новый экземпляр Client с заданным хостом и ключом хеширования.
 func NewClient(host string, key string, cryptoKey string) *Client {
-	return &Client{
+	cl := &Client{
 		httpClient: &http.Client{},
 		host:       host,
 		hashKey:    key,
-		cryptoKey:  cryptoKey,
+		retry:      defaultRetryPolicy(),
+	}
+	if cryptoKey != "" {
+		cl.publicKey = crypt.NewPublicKeyFile(cryptoKey)
 	}
+	return cl
+}
+
+// SetTLSConfig задаёт настройки TLS для соединений с сервером.
+func (cl *Client) SetTLSConfig(tlsConfig *tls.Config) {
+	cl.httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+}
+
+// SetRetryPolicy задаёт политику повторных попыток отправки отчёта.
+func (cl *Client) SetRetryPolicy(policy retry.Policy) {
+	cl.retry = policy
+}
+
+// SetLabels задаёт метки, которые добавляются ко всем отправляемым метрикам.
+func (cl *Client) SetLabels(labels map[string]string) {
+	cl.labels = labels
 }
 
 // SendReport отправляет отчет с метриками на сервер.
 // Он собирает данные метрик, сжимает их, добавляет необходимые заголовки и отправляет HTTP-запрос.
-// В случае ошибок выполняет повторные попытки с помощью механизма retry.
-func (cl *Client) SendReport(_ context.Context, report *agent.Report) {
-	out, err := cl.makeRequestData(report)
+// Если соединение не установлено или сервер ответил 503, выполняет повторные попытки по политике повторов,
+// пока не завершится ctx; остальные ошибки не повторяются, чтобы сервер не применил приращения counter дважды.
+// Возвращает ошибку, если отчёт не доставлен; в этом случае метрики остаются в отчёте до следующей отправки.
+// Ответ сервера с кодом 4xx выводится в лог и ошибкой не считается.
+func (cl *Client) SendReport(ctx context.Context, report *agent.Report) error {
+	taken := report.Take()
+	out, err := cl.makeRequestData(taken)
+	if err == nil {
+		err = cl.send(ctx, out)
+	}
 	if err != nil {
-		log.Print(err)
-		return
+		report.Restore(taken)
 	}
+	return err
+}
 
+// SendMetrics отправляет метрики на сервер так же, как SendReport: со сжатием, подписью, шифрованием
+// и повторными попытками. Метрики отправляются как есть, общие метки клиента к ним не добавляются.
+func (cl *Client) SendMetrics(ctx context.Context, metrics []m.Metric) error {
+	out, err := jsoniter.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	return cl.send(ctx, out)
+}
+
+func (cl *Client) send(ctx context.Context, out []byte) error {
 	buf, err := gzip.Compress(out)
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 
 	compressedData, err := io.ReadAll(buf)
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 
-	if cl.cryptoKey != "" {
-		compressedData, err = crypt.Encrypt(cl.cryptoKey, compressedData)
+	var keyID string
+	if cl.publicKey != nil {
+		compressedData, keyID, err = cl.publicKey.Encrypt(compressedData)
 		if err != nil {
-			log.Print(err)
-			return
+			return err
 		}
 	}
 
 	ip, err := cl.externalIP()
 	if err != nil {
-		log.Print(err)
-		return
-	}
-
-	uri := fmt.Sprintf("%s/updates/", cl.host)
-	req, err := http.NewRequest("POST", uri, bytes.NewReader(compressedData))
-	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Content-Encoding", "gzip")
-	req.Header.Add("X-Real-IP", ip)
 
+	var hash string
 	if cl.hashKey != "" {
-		hash := sign.CalcHash(out, cl.hashKey)
-		req.Header.Add("HashSHA256", hash)
+		hash = sign.CalcHash(out, cl.hashKey)
 	}
 
-	var resp *http.Response
-	err = retry.RetryHandle(func() error {
-		resp, err = cl.httpClient.Do(req)
+	uri := fmt.Sprintf("%s/updates/", cl.host)
+	var status string
+	err = cl.retry.Do(ctx, func(ctx context.Context) error {
+		// Запрос создаётся для каждой попытки заново, так как тело читается при отправке.
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(compressedData))
+		if err != nil {
+			return err
+		}
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Content-Encoding", "gzip")
+		req.Header.Add("X-Real-IP", ip)
+		if cl.publicKey != nil {
+			req.Header.Add(crypt.EncryptionHeader, crypt.EncryptionEnvelope)
+			req.Header.Add(crypt.KeyIDHeader, keyID)
+		}
+		if hash != "" {
+			req.Header.Add("HashSHA256", hash)
+		}
+
+		resp, err := cl.httpClient.Do(req)
 		if err != nil {
-			var urlErr *url.Error
-			if errors.As(err, &urlErr) {
-				return retry.NewRetryableError(urlErr.Error())
+			if isDialError(err) && ctx.Err() == nil {
+				return retry.NewRetryableError(err.Error())
 			}
 			return err
 		}
@@ -106,18 +153,32 @@ func (cl *Client) SendReport(_ context.Context, report *agent.Report) {
 				log.Print(err)
 			}
 		}()
-		if resp.StatusCode >= http.StatusInternalServerError {
+		if resp.StatusCode == http.StatusServiceUnavailable {
 			return retry.NewRetryableError("Server is not available")
 		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("server error: %s", resp.Status)
+		}
+		if resp.StatusCode != http.StatusOK {
+			status = resp.Status
+		}
 		return nil
 	})
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		log.Print("Wrong status code", resp.Status)
+	if status != "" {
+		log.Print("Wrong status code", status)
 	}
+	return nil
+}
+
+// isDialError сообщает, что соединение с сервером не установлено и запрос до него не дошёл.
+// Только такие ошибки повторяются: приращения counter-метрик не идемпотентны, и запрос,
+// прерванный по таймауту, мог быть уже применён сервером.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
 func (cl *Client) makeRequestData(report *agent.Report) ([]byte, error) {
@@ -125,28 +186,20 @@ func (cl *Client) makeRequestData(report *agent.Report) ([]byte, error) {
 	counter := report.GetCounter()
 	for k, v := range counter {
 		metrics = append(metrics, m.Metric{
-			MetricName: m.MetricName{
-				ID:    k,
-				MType: m.Counter,
-			},
-			Delta: &v,
-			Value: nil,
+			MetricName: metricName(k, m.Counter, cl.labels),
+			Delta:      &v,
+			Value:      nil,
 		})
 	}
 	gauges := report.GetGauge()
 	for k, v := range gauges {
 		metrics = append(metrics, m.Metric{
-			MetricName: m.MetricName{
-				ID:    k,
-				MType: m.Gauge,
-			},
-			Delta: nil,
-			Value: &v,
+			MetricName: metricName(k, m.Gauge, cl.labels),
+			Delta:      nil,
+			Value:      &v,
 		})
 	}
 
-	report.Clean()
-
 	out, err := jsoniter.Marshal(metrics)
 	if err != nil {
 		log.Print(err)
@@ -155,7 +208,25 @@ func (cl *Client) makeRequestData(report *agent.Report) ([]byte, error) {
 	return out, nil
 }
 
+// defaultRetryPolicy возвращает политику повторов отправки по умолчанию.
+// Каждая попытка ограничена attemptTimeout, повторы выводятся в лог.
+func defaultRetryPolicy() retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.AttemptTimeout = attemptTimeout
+	policy.OnAttempt = func(a retry.Attempt) {
+		if a.Err != nil && a.Delay > 0 {
+			log.Printf("Send attempt %d failed, retrying in %v: %v", a.Number, a.Delay.Round(time.Millisecond), a.Err)
+		}
+	}
+	return policy
+}
+
 func (cl *Client) externalIP() (string, error) {
+	return externalIP()
+}
+
+// externalIP возвращает IPv4-адрес первого активного сетевого интерфейса, кроме loopback.
+func externalIP() (string, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return "", err
@@ -2,15 +2,32 @@ package client
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	jsoniter "github.com/json-iterator/go"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/agent/collector"
+	m "github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/pkg/crypt"
+	"github.com/moonicy/gometrics/pkg/gzip"
+	sign "github.com/moonicy/gometrics/pkg/hash"
+	"github.com/moonicy/gometrics/pkg/retry"
 )
 
 func TestClient_SendReport(t *testing.T) {
@@ -46,11 +63,163 @@ func TestClient_SendReport(t *testing.T) {
 	cl.SendReport(context.TODO(), report)
 }
 
+func TestClient_SendReport_Retry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.NotEmpty(t, data, "every attempt must send the full body")
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	report := agent.NewReport()
+	report.SetGauge(agent.Alloc, 11)
+
+	var attempts []retry.Attempt
+	cl := NewClient(server.URL, "", "")
+	cl.SetRetryPolicy(retry.Policy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		OnAttempt:       func(a retry.Attempt) { attempts = append(attempts, a) },
+	})
+	assert.NoError(t, cl.SendReport(context.Background(), report))
+
+	assert.Equal(t, int32(2), calls.Load())
+	require.Len(t, attempts, 2)
+	assert.Error(t, attempts[0].Err)
+	assert.NoError(t, attempts[1].Err)
+}
+
+func TestClient_SendReport_NoRetryAfterDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name:    "internal server error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+		},
+		{
+			name:    "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) { time.Sleep(50 * time.Millisecond) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				tt.handler(w, r)
+			}))
+			defer server.Close()
+
+			report := agent.NewReport()
+			report.AddCounter(agent.PollCount, 1)
+
+			cl := NewClient(server.URL, "", "")
+			cl.SetRetryPolicy(retry.Policy{
+				MaxAttempts:     3,
+				InitialInterval: time.Millisecond,
+				AttemptTimeout:  10 * time.Millisecond,
+			})
+			assert.Error(t, cl.SendReport(context.Background(), report))
+
+			// Сервер мог применить запрос, поэтому он не отправляется повторно, а приращение остаётся в отчёте.
+			assert.Equal(t, int32(1), calls.Load())
+			assert.Equal(t, int64(1), report.GetCounter()[agent.PollCount])
+		})
+	}
+}
+
+func TestClient_SendReport_RetryConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	report := agent.NewReport()
+	report.AddCounter(agent.PollCount, 1)
+
+	var attempts int
+	cl := NewClient(server.URL, "", "")
+	cl.SetRetryPolicy(retry.Policy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		OnAttempt:       func(retry.Attempt) { attempts++ },
+	})
+	assert.Error(t, cl.SendReport(context.Background(), report))
+	assert.Equal(t, 3, attempts)
+}
+
+func TestClient_SendMetrics(t *testing.T) {
+	var got []m.Metric
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := gzip.NewCompressReader(r.Body)
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, sign.CalcHash(data, "secret"), r.Header.Get("HashSHA256"))
+		require.NoError(t, jsoniter.Unmarshal(data, &got))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hist := m.NewHistogramValue([]float64{0.1, 1})
+	hist.Observe(0.5)
+	batch := []m.Metric{{
+		MetricName: m.MetricName{ID: "latency", MType: m.Histogram, Labels: map[string]string{"handler": "/"}},
+		Histogram:  &hist,
+	}}
+
+	cl := NewClient(server.URL, "secret", "")
+	cl.SetLabels(map[string]string{"host": "a"})
+	require.NoError(t, cl.SendMetrics(context.Background(), batch))
+	assert.Equal(t, batch, got, "metrics are sent as is")
+}
+
+func TestClient_SendReport_Encrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	publicPath := filepath.Join(dir, "public.pem")
+	privatePath := filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}), 0o644))
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), 0o600))
+
+	var received atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, crypt.EncryptionEnvelope, r.Header.Get(crypt.EncryptionHeader))
+		assert.Equal(t, crypt.KeyID(&privateKey.PublicKey), r.Header.Get(crypt.KeyIDHeader))
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		compressed, err := crypt.DecryptEnvelope(privatePath, data)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, compressed)
+		received.Store(true)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	report := agent.NewReport()
+	report.SetGauge(agent.Alloc, 11)
+
+	cl := NewClient(server.URL, "", publicPath)
+	cl.SendReport(context.TODO(), report)
+	assert.True(t, received.Load())
+}
+
 func BenchmarkClient_makeResponseData(b *testing.B) {
 	client := &Client{}
 	report := agent.NewReport()
-	reader := agent.NewMetricsReader()
-	reader.Read(report)
+	_ = collector.NewRuntimeCollector().Collect(context.Background(), report)
 	for i := 0; i < b.N; i++ {
 		_, _ = client.makeRequestData(report)
 	}
@@ -69,8 +238,8 @@ func TestNewClient(t *testing.T) {
 	if client.hashKey != key {
 		t.Errorf("Expected hashKey to be %v, got %v", key, client.hashKey)
 	}
-	if client.cryptoKey != cryptoKey {
-		t.Errorf("Expected cryptoKey to be %v, got %v", cryptoKey, client.cryptoKey)
+	if client.publicKey == nil {
+		t.Error("Expected publicKey to be initialized, but it was nil")
 	}
 	if client.httpClient == nil {
 		t.Error("Expected httpClient to be initialized, but it was nil")
@@ -101,6 +270,29 @@ func TestMakeRequestData(t *testing.T) {
 	}
 }
 
+func TestMakeRequestData_Labels(t *testing.T) {
+	client := &Client{}
+	client.SetLabels(map[string]string{"host": "a"})
+
+	report := agent.NewReport()
+	report.AddCounter(`requests{code="200"}`, 3)
+
+	data, err := client.makeRequestData(report)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var metrics []m.Metric
+	err = jsoniter.Unmarshal(data, &metrics)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal request data: %v", err)
+	}
+
+	if len(metrics) != 1 || metrics[0].ID != "requests" || metrics[0].Labels["code"] != "200" || metrics[0].Labels["host"] != "a" {
+		t.Errorf("Unexpected metrics: %s", data)
+	}
+}
+
 func TestExternalIP(t *testing.T) {
 	client := &Client{}
 
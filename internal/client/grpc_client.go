@@ -2,80 +2,184 @@ package client
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
 	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"log"
-	"net/url"
 
 	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/pkg/interceptors"
 	"github.com/moonicy/gometrics/pkg/retry"
 	pb "github.com/moonicy/gometrics/proto"
 )
 
+// DefaultGRPCAddress - адрес gRPC-сервера метрик по умолчанию.
+const DefaultGRPCAddress = ":3200"
+
 // GRPCClient представляет клиента для отправки метрик на сервер.
 type GRPCClient struct {
+	conn          *grpc.ClientConn
 	metricsClient pb.MetricsClient
+	labels        map[string]string
+	retry         retry.Policy
 }
 
-// NewGRPCClient создаёт и возвращает новый экземпляр Client с заданным хостом и ключом хеширования.
-func NewGRPCClient() (*GRPCClient, error) {
-	conn, err := grpc.NewClient(":3200", grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewGRPCClient создаёт и возвращает новый экземпляр GRPCClient для сервера с адресом address.
+// Запросы подписываются ключом key, в метаданных x-real-ip передаётся внешний IP-адрес агента.
+// Если задан tlsConfig, соединение с сервером устанавливается по TLS.
+func NewGRPCClient(address string, key string, tlsConfig *tls.Config) (*GRPCClient, error) {
+	ip, err := externalIP()
+	if err != nil {
+		log.Print(err)
+	}
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(interceptors.ClientUnaryInterceptor(key, ip)),
+		grpc.WithStreamInterceptor(interceptors.ClientStreamInterceptor(key, ip)),
+	)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	c := pb.NewMetricsClient(conn)
 	return &GRPCClient{
+		conn:          conn,
 		metricsClient: c,
+		retry:         defaultRetryPolicy(),
 	}, nil
 }
 
+// Close закрывает соединение с сервером.
+func (cl *GRPCClient) Close() error {
+	if cl.conn == nil {
+		return nil
+	}
+	return cl.conn.Close()
+}
+
+// SetRetryPolicy задаёт политику повторных попыток отправки отчёта.
+func (cl *GRPCClient) SetRetryPolicy(policy retry.Policy) {
+	cl.retry = policy
+}
+
+// SetLabels задаёт метки, которые добавляются ко всем отправляемым метрикам.
+func (cl *GRPCClient) SetLabels(labels map[string]string) {
+	cl.labels = labels
+}
+
 // SendReport отправляет отчет с метриками на сервер.
 // Он собирает данные метрик, сжимает их, добавляет необходимые заголовки и отправляет HTTP-запрос.
-// В случае ошибок выполняет повторные попытки с помощью механизма retry.
-func (cl *GRPCClient) SendReport(ctx context.Context, report *agent.Report) {
-	out := cl.makeRequestData(report)
+// В случае временной недоступности сервера выполняет повторные попытки по политике повторов, пока не завершится ctx.
+// Возвращает ошибку, если отчёт не доставлен; в этом случае метрики остаются в отчёте до следующей отправки.
+// Отчёт, отклонённый сервером со статусом InvalidArgument, не отправляется повторно.
+func (cl *GRPCClient) SendReport(ctx context.Context, report *agent.Report) error {
+	taken := report.Take()
+	err := cl.send(ctx, cl.makeRequestData(taken))
+	if err != nil && status.Code(err) != codes.InvalidArgument {
+		report.Restore(taken)
+	}
+	return err
+}
 
-	err := retry.RetryHandle(func() error {
-		resp, err := cl.metricsClient.UpdateMetrics(ctx, out)
-		if err != nil {
-			var urlErr *url.Error
-			if errors.As(err, &urlErr) {
-				return retry.NewRetryableError(urlErr.Error())
+// SendMetrics отправляет метрики на сервер с повторными попытками, как SendReport.
+// Метрики отправляются как есть, общие метки клиента к ним не добавляются.
+func (cl *GRPCClient) SendMetrics(ctx context.Context, batch []metrics.Metric) error {
+	req := &pb.UpdateMetricsRequest{}
+	for _, m := range batch {
+		switch {
+		case m.MType == metrics.Counter && m.Delta != nil:
+			req.Counters = append(req.Counters, &pb.Counter{Id: m.ID, Delta: *m.Delta, Labels: m.Labels})
+		case m.MType == metrics.Gauge && m.Value != nil:
+			req.Gauges = append(req.Gauges, &pb.Gauge{Id: m.ID, Value: *m.Value, Labels: m.Labels})
+		case m.MType == metrics.Histogram && m.Histogram != nil:
+			req.Histograms = append(req.Histograms, &pb.Histogram{
+				Id:     m.ID,
+				Bounds: m.Histogram.Bounds,
+				Counts: m.Histogram.Counts,
+				Sum:    m.Histogram.Sum,
+				Count:  m.Histogram.Count,
+				Labels: m.Labels,
+			})
+		case m.MType == metrics.Summary && m.Summary != nil:
+			quantiles := make([]*pb.Quantile, 0, len(m.Summary.Quantiles))
+			for _, q := range m.Summary.Quantiles {
+				quantiles = append(quantiles, &pb.Quantile{Quantile: q.Quantile, Value: q.Value})
 			}
-			return err
+			req.Summaries = append(req.Summaries, &pb.Summary{
+				Id:        m.ID,
+				Quantiles: quantiles,
+				Sum:       m.Summary.Sum,
+				Count:     m.Summary.Count,
+				Labels:    m.Labels,
+			})
+		default:
+			return fmt.Errorf("metric %s: %w", m.ID, metrics.ErrWrongValue)
 		}
-		if resp.Error != "" {
-			return fmt.Errorf("grpc error: %s", resp.Error)
-		}
-		return nil
-	})
-	if err != nil {
-		log.Print(err)
-		return
 	}
-	fmt.Println("Sent report")
+	return cl.send(ctx, req)
+}
+
+func (cl *GRPCClient) send(ctx context.Context, req *pb.UpdateMetricsRequest) error {
+	return cl.retry.Do(ctx, func(ctx context.Context) error {
+		_, err := cl.metricsClient.UpdateMetrics(ctx, req)
+		return classifyError(err)
+	})
 }
 
 func (cl *GRPCClient) makeRequestData(report *agent.Report) *pb.UpdateMetricsRequest {
 	req := &pb.UpdateMetricsRequest{}
 	counter := report.GetCounter()
 	for k, v := range counter {
+		name := metricName(k, metrics.Counter, cl.labels)
 		req.Counters = append(req.Counters, &pb.Counter{
-			Id:    k,
-			Delta: v,
+			Id:     name.ID,
+			Delta:  v,
+			Labels: name.Labels,
 		})
 	}
 	gauges := report.GetGauge()
 	for k, v := range gauges {
+		name := metricName(k, metrics.Gauge, cl.labels)
 		req.Gauges = append(req.Gauges, &pb.Gauge{
-			Id:    k,
-			Value: v,
+			Id:     name.ID,
+			Value:  v,
+			Labels: name.Labels,
 		})
 	}
 
-	report.Clean()
-
 	return req
 }
+
+// classifyError помечает ошибки со статусом Unavailable как повторяемые: с этим статусом клиент завершает
+// запрос, который не удалось отправить, а сервер - запрос, который не сохранён из-за ошибки хранилища.
+// DeadlineExceeded и Aborted не повторяются: приращения counter-метрик не идемпотентны,
+// и такой запрос мог быть уже применён сервером.
+// Для отклонённых сервером метрик выводит в лог причины из google.rpc.BadRequest.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unavailable:
+		return retry.NewRetryableError(err.Error())
+	case codes.InvalidArgument:
+		for _, detail := range st.Details() {
+			if br, ok := detail.(*errdetails.BadRequest); ok {
+				for _, v := range br.GetFieldViolations() {
+					log.Printf("Metric rejected: %s: %s", v.GetField(), v.GetDescription())
+				}
+			}
+		}
+	}
+	return fmt.Errorf("grpc error: %w", err)
+}
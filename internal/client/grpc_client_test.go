@@ -4,26 +4,35 @@ import (
 	"context"
 	"errors"
 	"github.com/moonicy/gometrics/internal/agent"
+	"reflect"
+	"sync"
 	"testing"
 
+	"github.com/moonicy/gometrics/internal/metrics"
 	"github.com/moonicy/gometrics/pkg/retry"
 	pb "github.com/moonicy/gometrics/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type MockMetricsClient struct {
+	pb.MetricsClient
 	updateMetricsCount int
+	req                *pb.UpdateMetricsRequest
 	resp               *pb.UpdateMetricsResponse
 	err                error
 }
 
-func (m *MockMetricsClient) UpdateMetrics(_ context.Context, _ *pb.UpdateMetricsRequest, _ ...grpc.CallOption) (*pb.UpdateMetricsResponse, error) {
+func (m *MockMetricsClient) UpdateMetrics(_ context.Context, req *pb.UpdateMetricsRequest, _ ...grpc.CallOption) (*pb.UpdateMetricsResponse, error) {
 	m.updateMetricsCount++
+	m.req = req
 	return m.resp, m.err
 }
 
 func TestNewGRPCClient(t *testing.T) {
-	client, err := NewGRPCClient()
+	client, err := NewGRPCClient(DefaultGRPCAddress, "key", nil)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -49,14 +58,133 @@ func TestSendReport(t *testing.T) {
 		t.Errorf("Expected UpdateMetrics to be called once, got %d", mockMetricsClient.updateMetricsCount)
 	}
 
-	mockMetricsClient.err = errors.New("network error")
-	err := retry.RetryHandle(func() error {
+	mockMetricsClient.err = status.Error(codes.InvalidArgument, "1 metrics rejected")
+	err := retry.DefaultPolicy().Do(ctx, func(context.Context) error {
 		client.SendReport(ctx, report)
 		return mockMetricsClient.err
 	})
 
 	if err == nil {
-		t.Error("Expected error due to network issue, but got none")
+		t.Error("Expected error due to rejected metrics, but got none")
+	}
+}
+
+func TestSendReport_KeepsMetricsOnError(t *testing.T) {
+	ctx := context.Background()
+	mockMetricsClient := &MockMetricsClient{err: status.Error(codes.Unavailable, "storage is not available")}
+	client := &GRPCClient{metricsClient: mockMetricsClient}
+
+	report := agent.NewReport()
+	report.SetGauge("gauge1", 10.5)
+	report.AddCounter("counter1", 100)
+
+	if err := client.SendReport(ctx, report); err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if report.GetCounter()["counter1"] != 100 || report.GetGauge()["gauge1"] != 10.5 {
+		t.Errorf("Expected metrics to remain in report, got %v %v", report.GetCounter(), report.GetGauge())
+	}
+
+	mockMetricsClient.err = nil
+	mockMetricsClient.resp = &pb.UpdateMetricsResponse{}
+	if err := client.SendReport(ctx, report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.GetCommonCount() != 0 {
+		t.Errorf("Expected report to be empty after successful send, got %d metrics", report.GetCommonCount())
+	}
+}
+
+// slowMetricsClient задерживает первый запрос до закрытия release и суммирует доставленные counter-метрики.
+type slowMetricsClient struct {
+	pb.MetricsClient
+	started chan struct{}
+	release chan struct{}
+
+	mx        sync.Mutex
+	calls     int
+	delivered map[string]int64
+}
+
+func (m *slowMetricsClient) UpdateMetrics(_ context.Context, req *pb.UpdateMetricsRequest, _ ...grpc.CallOption) (*pb.UpdateMetricsResponse, error) {
+	m.mx.Lock()
+	m.calls++
+	first := m.calls == 1
+	for _, c := range req.GetCounters() {
+		m.delivered[c.GetId()] += c.GetDelta()
+	}
+	m.mx.Unlock()
+	if first {
+		close(m.started)
+		<-m.release
+	}
+	return &pb.UpdateMetricsResponse{}, nil
+}
+
+func TestSendReport_ConcurrentSends(t *testing.T) {
+	ctx := context.Background()
+	mockMetricsClient := &slowMetricsClient{
+		started:   make(chan struct{}),
+		release:   make(chan struct{}),
+		delivered: make(map[string]int64),
+	}
+	client := &GRPCClient{metricsClient: mockMetricsClient}
+
+	report := agent.NewReport()
+	report.AddCounter("counter1", 100)
+
+	done := make(chan error)
+	go func() { done <- client.SendReport(ctx, report) }()
+	<-mockMetricsClient.started
+
+	// Вторая отправка, начатая до завершения первой, не должна повторно доставить те же приращения.
+	report.AddCounter("counter1", 5)
+	if err := client.SendReport(ctx, report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	close(mockMetricsClient.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := mockMetricsClient.delivered["counter1"]; got != 105 {
+		t.Errorf("Expected counter1 to be delivered once in total 105, got %d", got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "1 metrics rejected").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "gauges[0]", Description: "invalid"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantErr   bool
+		retryable bool
+	}{
+		{name: "ok", err: nil},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "storage error"), wantErr: true, retryable: true},
+		{name: "deadline exceeded", err: status.Error(codes.DeadlineExceeded, "timeout"), wantErr: true},
+		{name: "aborted", err: status.Error(codes.Aborted, "aborted"), wantErr: true},
+		{name: "resource exhausted", err: status.Error(codes.ResourceExhausted, "limit"), wantErr: true},
+		{name: "invalid argument", err: st.Err(), wantErr: true},
+		{name: "not a status", err: errors.New("network error"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("classifyError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var re *retry.RetryableError
+			if errors.As(err, &re) != tt.retryable {
+				t.Errorf("classifyError() retryable = %v, want %v", !tt.retryable, tt.retryable)
+			}
+		})
 	}
 }
 
@@ -76,3 +204,45 @@ func TestMakeRequestDataGrpc(t *testing.T) {
 		t.Errorf("Unexpected gauge data: %+v", data.Gauges)
 	}
 }
+
+func TestMakeRequestDataGrpc_Labels(t *testing.T) {
+	client := &GRPCClient{}
+	client.SetLabels(map[string]string{"host": "a", "env": "prod"})
+	report := agent.NewReport()
+	report.SetGauge(`cpu{core="0",env="dev"}`, 0.5)
+
+	data := client.makeRequestData(report)
+
+	want := map[string]string{"host": "a", "env": "dev", "core": "0"}
+	if len(data.Gauges) != 1 || data.Gauges[0].Id != "cpu" || !reflect.DeepEqual(data.Gauges[0].Labels, want) {
+		t.Errorf("Unexpected gauge data: %+v", data.Gauges)
+	}
+}
+
+func TestGRPCClient_SendMetrics(t *testing.T) {
+	mockMetricsClient := &MockMetricsClient{resp: &pb.UpdateMetricsResponse{}}
+	client := &GRPCClient{metricsClient: mockMetricsClient}
+	delta := int64(3)
+	hist := metrics.NewHistogramValue([]float64{1})
+	hist.Observe(2)
+
+	err := client.SendMetrics(context.Background(), []metrics.Metric{
+		{MetricName: metrics.MetricName{ID: "requests", MType: metrics.Counter, Labels: map[string]string{"code": "200"}}, Delta: &delta},
+		{MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram}, Histogram: &hist},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	req := mockMetricsClient.req
+	if len(req.Counters) != 1 || req.Counters[0].Delta != 3 || req.Counters[0].Labels["code"] != "200" {
+		t.Errorf("Unexpected counter data: %+v", req.Counters)
+	}
+	if len(req.Histograms) != 1 || req.Histograms[0].Count != 1 || !reflect.DeepEqual(req.Histograms[0].Counts, []uint64{0, 1}) {
+		t.Errorf("Unexpected histogram data: %+v", req.Histograms)
+	}
+
+	err = client.SendMetrics(context.Background(), []metrics.Metric{{MetricName: metrics.MetricName{ID: "g", MType: metrics.Gauge}}})
+	if !errors.Is(err, metrics.ErrWrongValue) {
+		t.Errorf("Expected ErrWrongValue for gauge without value, got %v", err)
+	}
+}
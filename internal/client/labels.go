@@ -0,0 +1,25 @@
+package client
+
+import (
+	m "github.com/moonicy/gometrics/internal/metrics"
+)
+
+// metricName формирует имя метрики по ключу из отчёта, добавляя к нему общие метки агента.
+// Метки, заданные в самом ключе, имеют приоритет над общими.
+func metricName(key string, mType string, common map[string]string) m.MetricName {
+	id, labels, err := m.ParseSeriesKey(key)
+	if err != nil {
+		return m.MetricName{ID: key, MType: mType, Labels: common}
+	}
+	if len(common) == 0 {
+		return m.MetricName{ID: id, MType: mType, Labels: labels}
+	}
+	merged := make(map[string]string, len(common)+len(labels))
+	for k, v := range common {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return m.MetricName{ID: id, MType: mType, Labels: merged}
+}
@@ -27,6 +27,66 @@ type AgentConfig struct {
 	// Config - путь до файла конфигурации.
 	Config string
 	Grpc   bool
+	// Labels - метки, которые агент добавляет ко всем отправляемым метрикам.
+	Labels map[string]string `json:"labels"`
+	// TLSCA - путь до сертификата удостоверяющего центра; если задан, агент подключается к серверу по TLS.
+	TLSCA string `json:"tls_ca"`
+	// TLSCert - путь до сертификата агента, предъявляемого серверу при mTLS.
+	TLSCert string `json:"tls_cert"`
+	// TLSKey - путь до закрытого ключа сертификата агента.
+	TLSKey string `json:"tls_key"`
+	// BreakerFailureThreshold - число неудачных отправок подряд, после которого агент прекращает отправку.
+	BreakerFailureThreshold int `json:"breaker_failure_threshold"`
+	// BreakerCooldown - пауза перед пробной отправкой после прекращения отправки.
+	BreakerCooldown time.Duration `json:"breaker_cooldown"`
+	// BreakerMaxCooldown - предел паузы, которая удваивается после каждой неудачной пробной отправки.
+	BreakerMaxCooldown time.Duration `json:"breaker_max_cooldown"`
+	// Collectors - настройки сборщиков метрик по имени сборщика.
+	Collectors map[string]CollectorConfig `json:"collectors"`
+}
+
+// CollectorConfig описывает настройки сборщика метрик агента.
+type CollectorConfig struct {
+	// Enabled включает или выключает сборщик; если не задано, используется значение по умолчанию сборщика.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Interval - частота сбора; если не задана или меньше PollInterval, сборщик опрашивается с частотой PollInterval.
+	Interval time.Duration `json:"interval,omitempty"`
+	// Include - шаблоны имён устройств, точек монтирования или сетевых интерфейсов, метрики которых собираются;
+	// если не заданы, собираются все, кроме исключённых.
+	Include []string `json:"include,omitempty"`
+	// Exclude - шаблоны имён, метрики которых не собираются.
+	Exclude []string `json:"exclude,omitempty"`
+	// Processes - процессы, за которыми следит сборщик process.
+	Processes []ProcessConfig `json:"processes,omitempty"`
+}
+
+// ProcessConfig описывает отслеживаемый процесс. Процесс выбирается ровно одним из способов:
+// по PID-файлу, по шаблону имени или по cgroup.
+type ProcessConfig struct {
+	// Name - имя процесса, которым помечаются его метрики.
+	Name string `json:"name"`
+	// PIDFile - путь до файла с PID процесса.
+	PIDFile string `json:"pid_file,omitempty"`
+	// Pattern - шаблон имени процесса (синтаксис path.Match); под него могут подходить несколько процессов.
+	Pattern string `json:"pattern,omitempty"`
+	// Cgroup - путь cgroup относительно /sys/fs/cgroup, например "/system.slice/nginx.service".
+	Cgroup string `json:"cgroup,omitempty"`
+}
+
+// enableCollectors включает или выключает перечисленные через запятую сборщики.
+func (ac *AgentConfig) enableCollectors(names string, enabled bool) {
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if ac.Collectors == nil {
+			ac.Collectors = make(map[string]CollectorConfig)
+		}
+		cc := ac.Collectors[name]
+		cc.Enabled = &enabled
+		ac.Collectors[name] = cc
+	}
 }
 
 // NewAgentConfig создаёт и возвращает новый экземпляр AgentConfig, инициализированный с помощью флагов.
@@ -39,6 +99,8 @@ func NewAgentConfig() AgentConfig {
 func (ac *AgentConfig) parseFlag() {
 	var scFlags AgentConfig
 	var err error
+	var labels string
+	var collectors, disableCollectors string
 
 	flag.StringVar(&scFlags.Host, "a", DefaultHost, "address and port to run server")
 	flag.DurationVar(&scFlags.ReportInterval, "r", DefaultReportInterval*time.Second, "report interval")
@@ -49,6 +111,15 @@ func (ac *AgentConfig) parseFlag() {
 	flag.StringVar(&scFlags.Config, "c", "", "file config")
 	flag.StringVar(&ac.Config, "config", "", "file config")
 	flag.BoolVar(&ac.Grpc, "g", false, "grpc server")
+	flag.StringVar(&labels, "labels", "", "labels added to all metrics, e.g. host=a,env=prod")
+	flag.StringVar(&scFlags.TLSCA, "tls-ca", "", "CA certificate file to verify the server")
+	flag.StringVar(&scFlags.TLSCert, "tls-cert", "", "TLS client certificate file")
+	flag.StringVar(&scFlags.TLSKey, "tls-key", "", "TLS client private key file")
+	flag.StringVar(&collectors, "collectors", "", "collectors to enable, e.g. runtime,cpu")
+	flag.StringVar(&disableCollectors, "disable-collectors", "", "collectors to disable, e.g. memory")
+	flag.IntVar(&scFlags.BreakerFailureThreshold, "breaker-failure-threshold", DefaultBreakerFailureThreshold, "failed reports in a row before sending is paused")
+	flag.DurationVar(&scFlags.BreakerCooldown, "breaker-cooldown", DefaultBreakerCooldown*time.Second, "pause before a trial report after sending is paused")
+	flag.DurationVar(&scFlags.BreakerMaxCooldown, "breaker-max-cooldown", DefaultBreakerMaxCooldown*time.Second, "max pause, doubled after each failed trial report")
 	flag.Parse()
 
 	if scFlags.Config != "" {
@@ -86,6 +157,32 @@ func (ac *AgentConfig) parseFlag() {
 	if scFlags.CryptoKey != "" {
 		ac.CryptoKey = scFlags.CryptoKey
 	}
+	if scFlags.TLSCA != "" {
+		ac.TLSCA = scFlags.TLSCA
+	}
+	if scFlags.TLSCert != "" {
+		ac.TLSCert = scFlags.TLSCert
+	}
+	if scFlags.TLSKey != "" {
+		ac.TLSKey = scFlags.TLSKey
+	}
+	if scFlags.BreakerFailureThreshold > 0 {
+		ac.BreakerFailureThreshold = scFlags.BreakerFailureThreshold
+	}
+	if scFlags.BreakerCooldown > 0 {
+		ac.BreakerCooldown = scFlags.BreakerCooldown
+	}
+	if scFlags.BreakerMaxCooldown > 0 {
+		ac.BreakerMaxCooldown = scFlags.BreakerMaxCooldown
+	}
+	ac.enableCollectors(collectors, true)
+	ac.enableCollectors(disableCollectors, false)
+	if labels != "" {
+		ac.Labels, err = ParseLabels(labels)
+		if err != nil {
+			log.Fatal("Invalid labels")
+		}
+	}
 
 	if envRunAddr := os.Getenv("ADDRESS"); envRunAddr != "" {
 		ac.Host = envRunAddr
@@ -122,9 +219,48 @@ func (ac *AgentConfig) parseFlag() {
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		ac.CryptoKey = envCryptoKey
 	}
+	if envLabels := os.Getenv("LABELS"); envLabels != "" {
+		ac.Labels, err = ParseLabels(envLabels)
+		if err != nil {
+			log.Fatal("Invalid LABELS")
+		}
+	}
 	if envConfig := os.Getenv("CONFIG"); envConfig != "" {
 		ac.Config = envConfig
 	}
+	if envTLSCA := os.Getenv("TLS_CA"); envTLSCA != "" {
+		ac.TLSCA = envTLSCA
+	}
+	if envTLSCert := os.Getenv("TLS_CERT"); envTLSCert != "" {
+		ac.TLSCert = envTLSCert
+	}
+	if envTLSKey := os.Getenv("TLS_KEY"); envTLSKey != "" {
+		ac.TLSKey = envTLSKey
+	}
+	if envCollectors := os.Getenv("COLLECTORS"); envCollectors != "" {
+		ac.enableCollectors(envCollectors, true)
+	}
+	if envDisableCollectors := os.Getenv("DISABLE_COLLECTORS"); envDisableCollectors != "" {
+		ac.enableCollectors(envDisableCollectors, false)
+	}
+	if envThreshold := os.Getenv("BREAKER_FAILURE_THRESHOLD"); envThreshold != "" {
+		ac.BreakerFailureThreshold, err = strconv.Atoi(envThreshold)
+		if err != nil {
+			log.Fatal("Invalid BREAKER_FAILURE_THRESHOLD")
+		}
+	}
+	if envCooldown := os.Getenv("BREAKER_COOLDOWN"); envCooldown != "" {
+		ac.BreakerCooldown, err = time.ParseDuration(strings.Trim(envCooldown, "\""))
+		if err != nil {
+			log.Fatal("Invalid BREAKER_COOLDOWN")
+		}
+	}
+	if envMaxCooldown := os.Getenv("BREAKER_MAX_COOLDOWN"); envMaxCooldown != "" {
+		ac.BreakerMaxCooldown, err = time.ParseDuration(strings.Trim(envMaxCooldown, "\""))
+		if err != nil {
+			log.Fatal("Invalid BREAKER_MAX_COOLDOWN")
+		}
+	}
 	if envGrpcServer := os.Getenv("GRPC_SERVER"); envGrpcServer == "true" || envGrpcServer == "1" {
 		ac.Grpc = true
 	} else if envGrpcServer == "false" || envGrpcServer == "0" {
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -51,6 +53,7 @@ func TestNewAgentConfig_Flags(t *testing.T) {
 		"-k", "secretkey",
 		"-l", "5",
 		"-crypto-key", "/path/to/crypto.key",
+		"-labels", "host=a, env=prod",
 	}
 
 	ac := NewAgentConfig()
@@ -73,6 +76,9 @@ func TestNewAgentConfig_Flags(t *testing.T) {
 	if ac.CryptoKey != "/path/to/crypto.key" {
 		t.Errorf("Expected CryptoKey to be '/path/to/crypto.key', got '%s'", ac.CryptoKey)
 	}
+	if len(ac.Labels) != 2 || ac.Labels["host"] != "a" || ac.Labels["env"] != "prod" {
+		t.Errorf("Expected Labels to be map[env:prod host:a], got %v", ac.Labels)
+	}
 }
 
 func TestNewAgentConfig_EnvVars(t *testing.T) {
@@ -133,3 +139,92 @@ func TestNewAgentConfig_EnvVars(t *testing.T) {
 		t.Errorf("Expected Config to be '', got '%s'", ac.Config)
 	}
 }
+
+func TestNewAgentConfig_LabelsEnv(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	resetFlags()
+
+	os.Args = []string{"cmd", "-labels", "host=a"}
+	t.Setenv("LABELS", "host=b,dc=eu")
+
+	ac := NewAgentConfig()
+
+	if len(ac.Labels) != 2 || ac.Labels["host"] != "b" || ac.Labels["dc"] != "eu" {
+		t.Errorf("Expected Labels to be map[dc:eu host:b], got %v", ac.Labels)
+	}
+}
+
+func TestNewAgentConfig_TLS(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	resetFlags()
+
+	os.Args = []string{"cmd", "-tls-ca", "certs/ca.pem", "-tls-cert", "certs/agent.pem"}
+	t.Setenv("TLS_KEY", "/env/agent-key.pem")
+
+	ac := NewAgentConfig()
+
+	if ac.TLSCA != "certs/ca.pem" || ac.TLSCert != "certs/agent.pem" || ac.TLSKey != "/env/agent-key.pem" {
+		t.Errorf("Unexpected TLS config: ca=%q cert=%q key=%q", ac.TLSCA, ac.TLSCert, ac.TLSKey)
+	}
+}
+
+func TestNewAgentConfig_Breaker(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	resetFlags()
+
+	os.Args = []string{"cmd"}
+	ac := NewAgentConfig()
+	if ac.BreakerFailureThreshold != DefaultBreakerFailureThreshold {
+		t.Errorf("Expected BreakerFailureThreshold to be %d, got %d", DefaultBreakerFailureThreshold, ac.BreakerFailureThreshold)
+	}
+	if ac.BreakerCooldown != DefaultBreakerCooldown*time.Second {
+		t.Errorf("Expected BreakerCooldown to be %v, got %v", DefaultBreakerCooldown*time.Second, ac.BreakerCooldown)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-breaker-failure-threshold", "5", "-breaker-cooldown", "30s"}
+	t.Setenv("BREAKER_MAX_COOLDOWN", "5m")
+	ac = NewAgentConfig()
+	if ac.BreakerFailureThreshold != 5 || ac.BreakerCooldown != 30*time.Second || ac.BreakerMaxCooldown != 5*time.Minute {
+		t.Errorf("Unexpected breaker config: threshold=%d cooldown=%v max=%v", ac.BreakerFailureThreshold, ac.BreakerCooldown, ac.BreakerMaxCooldown)
+	}
+}
+
+func TestNewAgentConfig_Collectors(t *testing.T) {
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	resetFlags()
+
+	path := filepath.Join(t.TempDir(), "agent.json")
+	data := `{"collectors": {"cpu": {"enabled": false, "interval": 10000000000}, "memory": {"interval": 5000000000}, "net": {"include": ["eth*"], "exclude": ["lo"]},
+		"process": {"enabled": true, "processes": [{"name": "api", "pid_file": "/run/api.pid"}, {"name": "db", "cgroup": "/system.slice/db.service"}]}}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Args = []string{"cmd", "-c", path, "-collectors", "cpu"}
+	t.Setenv("DISABLE_COLLECTORS", "memory, runtime")
+
+	ac := NewAgentConfig()
+
+	cpu := ac.Collectors["cpu"]
+	if cpu.Enabled == nil || !*cpu.Enabled || cpu.Interval != 10*time.Second {
+		t.Errorf("Expected cpu collector to be enabled with 10s interval, got %+v", cpu)
+	}
+	memory := ac.Collectors["memory"]
+	if memory.Enabled == nil || *memory.Enabled || memory.Interval != 5*time.Second {
+		t.Errorf("Expected memory collector to be disabled with 5s interval, got %+v", memory)
+	}
+	if runtime := ac.Collectors["runtime"]; runtime.Enabled == nil || *runtime.Enabled {
+		t.Errorf("Expected runtime collector to be disabled, got %+v", runtime)
+	}
+	if net := ac.Collectors["net"]; !slices.Equal(net.Include, []string{"eth*"}) || !slices.Equal(net.Exclude, []string{"lo"}) {
+		t.Errorf("Expected net collector filters, got %+v", net)
+	}
+	want := []ProcessConfig{{Name: "api", PIDFile: "/run/api.pid"}, {Name: "db", Cgroup: "/system.slice/db.service"}}
+	if process := ac.Collectors["process"]; !slices.Equal(process.Processes, want) {
+		t.Errorf("Expected watched processes %+v, got %+v", want, process.Processes)
+	}
+}
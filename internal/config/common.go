@@ -1,6 +1,9 @@
 package config
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
 // Конфигурация по умолчанию.
 const (
@@ -11,6 +14,10 @@ const (
 	DefaultRateLimit       = 0
 	DefaultCryptoKeyServer = "keys/private.pem"
 	DefaultCryptoKeyAgent  = "keys/public.pem"
+
+	DefaultBreakerFailureThreshold = 3
+	DefaultBreakerCooldown         = 10
+	DefaultBreakerMaxCooldown      = 120
 )
 
 // ParseURI возвращает полный URI, добавляя протокол и хост по умолчанию при необходимости.
@@ -24,3 +31,21 @@ func ParseURI(uri string) string {
 	}
 	return uri
 }
+
+// ParseLabels разбирает набор меток вида "host=a,env=prod".
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, errors.New("invalid label " + pair)
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestParseURI(t *testing.T) {
 	tests := []struct {
@@ -39,3 +42,30 @@ func TestParseURI(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{name: "Empty string", input: "", expected: map[string]string{}},
+		{name: "Several labels", input: "host=a, env=prod,", expected: map[string]string{"host": "a", "env": "prod"}},
+		{name: "Empty value", input: "host=", expected: map[string]string{"host": ""}},
+		{name: "Without value", input: "host", wantErr: true},
+		{name: "Without name", input: "=a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, err := ParseLabels(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabels(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(labels, tt.expected) {
+				t.Errorf("ParseLabels(%q) = %v, want %v", tt.input, labels, tt.expected)
+			}
+		})
+	}
+}
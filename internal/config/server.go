@@ -18,18 +18,79 @@ type ServerConfig struct {
 	StoreInterval time.Duration `json:"store_interval"`
 	// FileStoragePath - полное имя файла, куда сохраняются текущие значения.
 	FileStoragePath string `json:"store_file"`
+	// SegmentStoragePath - каталог встроенного хранилища сегментов; если задан, метрики сохраняются на диск при каждом изменении.
+	SegmentStoragePath string `json:"segment_storage_path"`
 	// Restore - (булево) определяет, загружать или нет ранее сохранённые значения из файла при старте сервера.
 	Restore bool `json:"restore"`
 	// DatabaseDsn - строка с адресом подключения к БД.
 	DatabaseDsn string `json:"database_dsn"`
+	// DatabaseReplicaDsn - строка с адресом подключения к реплике БД для чтения списков метрик.
+	DatabaseReplicaDsn string `json:"database_replica_dsn"`
+	// DatabaseMaxOpenConns - максимальное число открытых соединений с БД; 0 снимает ограничение.
+	DatabaseMaxOpenConns int `json:"database_max_open_conns"`
+	// DatabaseMaxIdleConns - максимальное число простаивающих соединений с БД; 0 оставляет значение по умолчанию.
+	DatabaseMaxIdleConns int `json:"database_max_idle_conns"`
+	// DatabaseConnMaxLifetime - максимальное время жизни соединения с БД; 0 снимает ограничение.
+	DatabaseConnMaxLifetime time.Duration `json:"database_conn_max_lifetime"`
+	// DatabaseStatementTimeout - максимальное время выполнения запроса (statement_timeout); 0 снимает ограничение.
+	DatabaseStatementTimeout time.Duration `json:"database_statement_timeout"`
+	// Migrate - команда миграций схемы БД: up, down или status; если задана, сервер выполняет её и завершается.
+	Migrate string `json:"-"`
 	// HashKey - ключ для хеша.
 	HashKey string
-	// CryptoKey - путь до файла с публичным ключом.
+	// CryptoKey - пути до файлов или каталогов с закрытыми ключами через запятую.
 	CryptoKey string `json:"crypto_key"`
 	// Config - путь до файла конфигурации.
 	Config string
-	// TrustedSubnet строковое представление бесклассовой адресации (CIDR)
+	// TrustedSubnet строковое представление бесклассовой адресации (CIDR) доверенной подсети для запросов записи метрик
 	TrustedSubnet string
+	// HistoryRetention - время хранения истории значений метрик; 0 отключает историю.
+	HistoryRetention time.Duration `json:"history_retention"`
+	// AlertRules - правила оповещений, загружаются из файла конфигурации.
+	AlertRules []AlertRule `json:"alert_rules"`
+	// AlertInterval - интервал вычисления правил оповещений.
+	AlertInterval time.Duration `json:"alert_interval"`
+	// AlertWebhooks - адреса, на которые отправляются оповещения, загружаются из файла конфигурации.
+	AlertWebhooks []AlertWebhook `json:"alert_webhooks"`
+	// AlertRepeatInterval - интервал повторной отправки продолжающегося оповещения; 0 отключает повтор.
+	AlertRepeatInterval time.Duration `json:"alert_repeat_interval"`
+	// TLSCert - путь до сертификата сервера; если задан, HTTP- и gRPC-сервер принимают соединения по TLS.
+	TLSCert string `json:"tls_cert"`
+	// TLSKey - путь до закрытого ключа сертификата сервера.
+	TLSKey string `json:"tls_key"`
+	// TLSCA - путь до сертификата удостоверяющего центра; если задан, сервер требует сертификат клиента (mTLS).
+	TLSCA string `json:"tls_ca"`
+}
+
+// AlertWebhook описывает адрес доставки оповещений.
+type AlertWebhook struct {
+	// URL - адрес, на который методом POST отправляются оповещения.
+	URL string `json:"url"`
+	// Key - ключ подписи оповещений; если не задан, используется HashKey сервера.
+	Key string `json:"key,omitempty"`
+}
+
+// AlertRule описывает правило оповещения в файле конфигурации.
+type AlertRule struct {
+	// Name - имя правила.
+	Name string `json:"name"`
+	// Expr - условие срабатывания, например "HeapAlloc > 1e9 for 2m".
+	Expr string `json:"expr"`
+	// Labels - дополнительные метки оповещения.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations - описание оповещения.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// CryptoKeys возвращает пути до файлов и каталогов с закрытыми ключами из CryptoKey.
+func (sc ServerConfig) CryptoKeys() []string {
+	var paths []string
+	for _, path := range strings.Split(sc.CryptoKey, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
 }
 
 // NewServerConfig создаёт и возвращает новый экземпляр ServerConfig, инициализированный с помощью флагов.
@@ -45,13 +106,25 @@ func (sc *ServerConfig) parseFlag() {
 	flag.StringVar(&scFlags.Host, "a", DefaultHost, "address and port to run server")
 	flag.DurationVar(&scFlags.StoreInterval, "i", 300*time.Second, "store interval")
 	flag.StringVar(&scFlags.FileStoragePath, "f", "", "file storage path")
+	flag.StringVar(&scFlags.SegmentStoragePath, "segment-storage-path", "", "embedded segment storage directory")
 	flag.StringVar(&restore, "r", "", "restore")
 	flag.StringVar(&scFlags.DatabaseDsn, "d", "", "database dsn")
+	flag.StringVar(&scFlags.DatabaseReplicaDsn, "database-replica-dsn", "", "read replica database dsn")
+	flag.IntVar(&scFlags.DatabaseMaxOpenConns, "database-max-open-conns", 0, "max open database connections, 0 is unlimited")
+	flag.IntVar(&scFlags.DatabaseMaxIdleConns, "database-max-idle-conns", 0, "max idle database connections")
+	flag.DurationVar(&scFlags.DatabaseConnMaxLifetime, "database-conn-max-lifetime", 0, "max database connection lifetime, 0 is unlimited")
+	flag.DurationVar(&scFlags.DatabaseStatementTimeout, "database-statement-timeout", 0, "database statement timeout, 0 is unlimited")
+	flag.StringVar(&scFlags.Migrate, "migrate", "", "run schema migration command (up, down, status) and exit")
 	flag.StringVar(&scFlags.HashKey, "k", "", "hash key")
 	flag.StringVar(&scFlags.CryptoKey, "crypto-key", DefaultCryptoKeyServer, "crypto key")
 	flag.StringVar(&scFlags.Config, "c", "", "file config")
 	flag.StringVar(&sc.Config, "config", "", "file config")
 	flag.StringVar(&scFlags.TrustedSubnet, "t", "", "trusted subnet")
+	flag.DurationVar(&scFlags.HistoryRetention, "history-retention", 0, "history retention, 0 disables history")
+	flag.DurationVar(&scFlags.AlertInterval, "alert-interval", 0, "alert rules evaluation interval")
+	flag.StringVar(&scFlags.TLSCert, "tls-cert", "", "TLS certificate file")
+	flag.StringVar(&scFlags.TLSKey, "tls-key", "", "TLS private key file")
+	flag.StringVar(&scFlags.TLSCA, "tls-ca", "", "CA certificate file to verify client certificates")
 	flag.Parse()
 
 	if scFlags.Config != "" {
@@ -80,6 +153,9 @@ func (sc *ServerConfig) parseFlag() {
 	if scFlags.FileStoragePath != "" {
 		sc.FileStoragePath = scFlags.FileStoragePath
 	}
+	if scFlags.SegmentStoragePath != "" {
+		sc.SegmentStoragePath = scFlags.SegmentStoragePath
+	}
 	if restore != "" {
 		switch restore {
 		case "false":
@@ -91,12 +167,45 @@ func (sc *ServerConfig) parseFlag() {
 	if scFlags.DatabaseDsn != "" {
 		sc.DatabaseDsn = scFlags.DatabaseDsn
 	}
+	if scFlags.DatabaseReplicaDsn != "" {
+		sc.DatabaseReplicaDsn = scFlags.DatabaseReplicaDsn
+	}
+	if scFlags.DatabaseMaxOpenConns > 0 {
+		sc.DatabaseMaxOpenConns = scFlags.DatabaseMaxOpenConns
+	}
+	if scFlags.DatabaseMaxIdleConns > 0 {
+		sc.DatabaseMaxIdleConns = scFlags.DatabaseMaxIdleConns
+	}
+	if scFlags.DatabaseConnMaxLifetime > 0 {
+		sc.DatabaseConnMaxLifetime = scFlags.DatabaseConnMaxLifetime
+	}
+	if scFlags.DatabaseStatementTimeout > 0 {
+		sc.DatabaseStatementTimeout = scFlags.DatabaseStatementTimeout
+	}
+	if scFlags.Migrate != "" {
+		sc.Migrate = scFlags.Migrate
+	}
 	if scFlags.HashKey != "" {
 		sc.HashKey = scFlags.HashKey
 	}
 	if scFlags.CryptoKey != "" {
 		sc.CryptoKey = scFlags.CryptoKey
 	}
+	if scFlags.HistoryRetention > 0 {
+		sc.HistoryRetention = scFlags.HistoryRetention
+	}
+	if scFlags.AlertInterval > 0 {
+		sc.AlertInterval = scFlags.AlertInterval
+	}
+	if scFlags.TLSCert != "" {
+		sc.TLSCert = scFlags.TLSCert
+	}
+	if scFlags.TLSKey != "" {
+		sc.TLSKey = scFlags.TLSKey
+	}
+	if scFlags.TLSCA != "" {
+		sc.TLSCA = scFlags.TLSCA
+	}
 
 	if envRunAddr := os.Getenv("ADDRESS"); envRunAddr != "" {
 		sc.Host = envRunAddr
@@ -114,9 +223,46 @@ func (sc *ServerConfig) parseFlag() {
 	if envFileStoragePath := os.Getenv("FILE_STORAGE_PATH"); envFileStoragePath != "" {
 		sc.FileStoragePath = envFileStoragePath
 	}
+	if envSegmentStoragePath := os.Getenv("SEGMENT_STORAGE_PATH"); envSegmentStoragePath != "" {
+		sc.SegmentStoragePath = envSegmentStoragePath
+	}
 	if envDatabaseDsn := os.Getenv("DATABASE_DSN"); envDatabaseDsn != "" {
 		sc.DatabaseDsn = envDatabaseDsn
 	}
+	if envReplicaDsn := os.Getenv("DATABASE_REPLICA_DSN"); envReplicaDsn != "" {
+		sc.DatabaseReplicaDsn = envReplicaDsn
+	}
+	if envMaxOpenConns := os.Getenv("DATABASE_MAX_OPEN_CONNS"); envMaxOpenConns != "" {
+		i, err := strconv.Atoi(envMaxOpenConns)
+		if err != nil {
+			log.Fatal("Invalid DATABASE_MAX_OPEN_CONNS")
+		}
+		sc.DatabaseMaxOpenConns = i
+	}
+	if envMaxIdleConns := os.Getenv("DATABASE_MAX_IDLE_CONNS"); envMaxIdleConns != "" {
+		i, err := strconv.Atoi(envMaxIdleConns)
+		if err != nil {
+			log.Fatal("Invalid DATABASE_MAX_IDLE_CONNS")
+		}
+		sc.DatabaseMaxIdleConns = i
+	}
+	if envConnMaxLifetime := os.Getenv("DATABASE_CONN_MAX_LIFETIME"); envConnMaxLifetime != "" {
+		dur, err := time.ParseDuration(strings.Trim(envConnMaxLifetime, "\""))
+		if err != nil {
+			log.Fatal("Invalid DATABASE_CONN_MAX_LIFETIME")
+		}
+		sc.DatabaseConnMaxLifetime = dur
+	}
+	if envStatementTimeout := os.Getenv("DATABASE_STATEMENT_TIMEOUT"); envStatementTimeout != "" {
+		dur, err := time.ParseDuration(strings.Trim(envStatementTimeout, "\""))
+		if err != nil {
+			log.Fatal("Invalid DATABASE_STATEMENT_TIMEOUT")
+		}
+		sc.DatabaseStatementTimeout = dur
+	}
+	if envMigrate := os.Getenv("MIGRATE"); envMigrate != "" {
+		sc.Migrate = envMigrate
+	}
 	if envHashKey := os.Getenv("KEY"); envHashKey != "" {
 		sc.HashKey = envHashKey
 	}
@@ -134,4 +280,33 @@ func (sc *ServerConfig) parseFlag() {
 	if evnTrustedSubnet := os.Getenv("TRUSTED_SUBNET"); evnTrustedSubnet != "" {
 		sc.TrustedSubnet = evnTrustedSubnet
 	}
+	if envHistoryRetention := os.Getenv("HISTORY_RETENTION"); envHistoryRetention != "" {
+		str := strings.Trim(envHistoryRetention, "\"")
+		if i, err := strconv.Atoi(str); err == nil {
+			sc.HistoryRetention = time.Duration(i) * time.Second
+		}
+
+		if dur, err := time.ParseDuration(str); err == nil {
+			sc.HistoryRetention = dur
+		}
+	}
+	if envAlertInterval := os.Getenv("ALERT_INTERVAL"); envAlertInterval != "" {
+		str := strings.Trim(envAlertInterval, "\"")
+		if i, err := strconv.Atoi(str); err == nil {
+			sc.AlertInterval = time.Duration(i) * time.Second
+		}
+
+		if dur, err := time.ParseDuration(str); err == nil {
+			sc.AlertInterval = dur
+		}
+	}
+	if envTLSCert := os.Getenv("TLS_CERT"); envTLSCert != "" {
+		sc.TLSCert = envTLSCert
+	}
+	if envTLSKey := os.Getenv("TLS_KEY"); envTLSKey != "" {
+		sc.TLSKey = envTLSKey
+	}
+	if envTLSCA := os.Getenv("TLS_CA"); envTLSCA != "" {
+		sc.TLSCA = envTLSCA
+	}
 }
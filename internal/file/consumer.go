@@ -2,17 +2,18 @@ package file
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"os"
-
-	"github.com/moonicy/gometrics/pkg/retry"
 )
 
 // Consumer читает события из файла.
 type Consumer struct {
 	file     *os.File
 	filename string
-	scanner  *bufio.Scanner
+	decoder  *json.Decoder
 }
 
 // NewConsumer создаёт и возвращает новый Consumer для указанного файла.
@@ -20,38 +21,30 @@ func NewConsumer(filename string) *Consumer {
 	return &Consumer{filename: filename}
 }
 
-// Open открывает файл для чтения и инициализирует сканер.
-// В случае ошибок доступа выполняет повторные попытки.
-func (c *Consumer) Open() error {
-	var file *os.File
-	var err error
-	err = retry.RetryHandle(func() error {
-		file, err = os.OpenFile(c.filename, os.O_RDONLY|os.O_CREATE, 0666)
-		if err != nil {
-			if os.IsPermission(err) {
-				return retry.NewRetryableError(err.Error())
-			}
-		}
-		return err
+// Open открывает файл для чтения и инициализирует декодер.
+// В случае ошибок доступа выполняет повторные попытки, пока не завершится ctx.
+func (c *Consumer) Open(ctx context.Context) error {
+	file, err := openRetry(ctx, func() (*os.File, error) {
+		return os.OpenFile(c.filename, os.O_RDONLY|os.O_CREATE, 0666)
 	})
 	if err != nil {
 		return err
 	}
 	c.file = file
-	c.scanner = bufio.NewScanner(file)
+	// Снимок хранилища записывается одной строкой произвольной длины, поэтому события читаются
+	// декодером JSON, а не построчно.
+	c.decoder = json.NewDecoder(bufio.NewReader(file))
 	return nil
 }
 
 // ReadEvent читает следующее событие из файла.
 // Возвращает Event или ошибку при неудачном чтении.
 func (c *Consumer) ReadEvent() (*Event, error) {
-	if !c.scanner.Scan() {
-		return nil, c.scanner.Err()
-	}
-	data := c.scanner.Bytes()
-
 	event := Event{}
-	err := json.Unmarshal(data, &event)
+	err := c.decoder.Decode(&event)
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +56,7 @@ func (c *Consumer) ReadEvent() (*Event, error) {
 func (c *Consumer) Close() error {
 	defer func() {
 		c.file = nil
-		c.scanner = nil
+		c.decoder = nil
 	}()
 	return c.file.Close()
 }
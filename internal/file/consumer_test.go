@@ -1,6 +1,9 @@
 package file
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"testing"
 )
@@ -15,8 +18,8 @@ func TestNewConsumer(t *testing.T) {
 	if consumer.file != nil {
 		t.Errorf("Expected file to be nil, got %v", consumer.file)
 	}
-	if consumer.scanner != nil {
-		t.Errorf("Expected scanner to be nil, got %v", consumer.scanner)
+	if consumer.decoder != nil {
+		t.Errorf("Expected decoder to be nil, got %v", consumer.decoder)
 	}
 }
 
@@ -33,7 +36,7 @@ func TestConsumer_Open_Success(t *testing.T) {
 	}(tmpfile.Name())
 
 	consumer := NewConsumer(tmpfile.Name())
-	err = consumer.Open()
+	err = consumer.Open(context.Background())
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -41,14 +44,14 @@ func TestConsumer_Open_Success(t *testing.T) {
 	if consumer.file == nil {
 		t.Errorf("Expected file to be opened, got nil")
 	}
-	if consumer.scanner == nil {
-		t.Errorf("Expected scanner to be initialized, got nil")
+	if consumer.decoder == nil {
+		t.Errorf("Expected decoder to be initialized, got nil")
 	}
 }
 
 func TestConsumer_Open_RetryableError(t *testing.T) {
 	consumer := NewConsumer("/nonexistent_dir/test.log")
-	err := consumer.Open()
+	err := consumer.Open(context.Background())
 	if err == nil {
 		t.Fatalf("Expected error, got nil")
 	}
@@ -72,7 +75,7 @@ func TestConsumer_ReadEvent_InvalidJSON(t *testing.T) {
 	}
 
 	consumer := NewConsumer(tmpfile.Name())
-	err = consumer.Open()
+	err = consumer.Open(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to open consumer: %v", err)
 	}
@@ -88,3 +91,55 @@ func TestConsumer_ReadEvent_InvalidJSON(t *testing.T) {
 		t.Errorf("Expected JSON unmarshal error, got nil")
 	}
 }
+
+func TestConsumer_ReadEvent_LargeSnapshot(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test_consumer_large_*.log")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer func(name string) {
+		err = os.Remove(name)
+		if err != nil {
+			t.Errorf("Failed to remove temp file: %v", err)
+		}
+	}(tmpfile.Name())
+
+	want := Event{Gauge: make(map[string]float64), Counter: map[string]int64{"PollCount": 1}}
+	for i := 0; i < 20000; i++ {
+		want.Gauge[fmt.Sprintf("gauge_%d", i)] = float64(i)
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	if len(data) <= 64*1024 {
+		t.Fatalf("Expected snapshot larger than 64 KB, got %d bytes", len(data))
+	}
+	if _, err = tmpfile.Write(append(data, '\n')); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+
+	consumer := NewConsumer(tmpfile.Name())
+	if err = consumer.Open(context.Background()); err != nil {
+		t.Fatalf("Failed to open consumer: %v", err)
+	}
+	defer func(consumer *Consumer) {
+		err = consumer.Close()
+		if err != nil {
+			t.Fatalf("Failed to close consumer: %v", err)
+		}
+	}(consumer)
+
+	event, err := consumer.ReadEvent()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if event == nil || len(event.Gauge) != len(want.Gauge) || event.Gauge["gauge_19999"] != 19999 {
+		t.Errorf("Expected %d gauges to be restored", len(want.Gauge))
+	}
+
+	event, err = consumer.ReadEvent()
+	if err != nil || event != nil {
+		t.Errorf("Expected end of file, got %v, %v", event, err)
+	}
+}
@@ -1,8 +1,40 @@
 package file
 
+import (
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
 // Event представляет событие, содержащее метрики и временную метку.
 type Event struct {
-	Gauge     map[string]float64 // Gauge хранит метрики типа gauge с их значениями.
-	Counter   map[string]int64   // Counter хранит метрики типа counter с их значениями.
-	Timestamp int64              // Timestamp содержит временную метку события.
+	Gauge     map[string]float64                // Gauge хранит метрики типа gauge с их значениями.
+	Counter   map[string]int64                  // Counter хранит метрики типа counter с их значениями.
+	Histogram map[string]metrics.HistogramValue `json:",omitempty"` // Histogram хранит метрики типа histogram.
+	Summary   map[string]metrics.SummaryValue   `json:",omitempty"` // Summary хранит метрики типа summary.
+	Timestamp int64                             // Timestamp содержит временную метку события.
+	// GaugeHistory хранит историю значений метрик типа gauge.
+	GaugeHistory map[string][]metrics.Sample `json:",omitempty"`
+	// CounterHistory хранит историю значений метрик типа counter.
+	CounterHistory map[string][]metrics.Sample `json:",omitempty"`
+	// WALSeq содержит номер последней записи журнала, уже учтённой в снимке.
+	WALSeq uint64 `json:",omitempty"`
+}
+
+// Операции, сохраняемые в журнале упреждающей записи.
+const (
+	OpMetrics      = "metrics"       // OpMetrics применяет значения метрик к хранилищу.
+	OpSamples      = "samples"       // OpSamples добавляет значения метрик в историю.
+	OpDeleteBefore = "delete_before" // OpDeleteBefore удаляет из истории значения, записанные раньше Timestamp.
+)
+
+// Update представляет одну запись журнала упреждающей записи.
+type Update struct {
+	Seq       uint64                            // Seq содержит порядковый номер записи.
+	Op        string                            // Op содержит тип операции.
+	Gauge     map[string]float64                `json:",omitempty"` // Gauge хранит новые значения метрик типа gauge.
+	Counter   map[string]int64                  `json:",omitempty"` // Counter хранит приращения метрик типа counter.
+	Histogram map[string]metrics.HistogramValue `json:",omitempty"` // Histogram хранит наблюдения метрик типа histogram.
+	Summary   map[string]metrics.SummaryValue   `json:",omitempty"` // Summary хранит значения метрик типа summary.
+	Timestamp time.Time                         `json:",omitempty"` // Timestamp содержит время для операций с историей и время записи значений метрик в историю.
 }
@@ -0,0 +1,26 @@
+package file
+
+import (
+	"context"
+	"os"
+
+	"github.com/moonicy/gometrics/pkg/retry"
+)
+
+// openRetry открывает файл функцией open, повторяя попытки при ошибках доступа по политике
+// retry.DefaultPolicy, пока не завершится ctx.
+func openRetry(ctx context.Context, open func() (*os.File, error)) (*os.File, error) {
+	var file *os.File
+	err := retry.DefaultPolicy().Do(ctx, func(context.Context) error {
+		var err error
+		file, err = open()
+		if err != nil && os.IsPermission(err) {
+			return retry.NewRetryableError(err.Error())
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -2,10 +2,10 @@ package file
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"os"
-
-	"github.com/moonicy/gometrics/pkg/retry"
+	"path/filepath"
 )
 
 // Producer представляет структуру для записи событий в файл.
@@ -20,54 +20,82 @@ func NewProducer(filename string) *Producer {
 	return &Producer{filename: filename}
 }
 
-// Open открывает файл для записи и инициализирует буферизированный writer.
-// В случае ошибок доступа выполняет повторные попытки.
-func (p *Producer) Open() error {
-	var file *os.File
-	var err error
-	err = retry.RetryHandle(func() error {
-		file, err = os.OpenFile(p.filename, os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			if os.IsPermission(err) {
-				return retry.NewRetryableError(err.Error())
-			}
-		}
-		return err
-	})
+// Open создаёт рядом с файлом временный файл для записи и инициализирует буферизированный writer.
+// В случае ошибок доступа выполняет повторные попытки, пока не завершится ctx.
+func (p *Producer) Open(ctx context.Context) error {
+	file, err := openRetry(ctx, p.createTemp)
 	if err != nil {
 		return err
 	}
+	p.setFile(file)
+	return nil
+}
+
+func (p *Producer) createTemp() (*os.File, error) {
+	return os.CreateTemp(filepath.Dir(p.filename), filepath.Base(p.filename)+".tmp*")
+}
+
+func (p *Producer) setFile(file *os.File) {
 	p.file = file
 	p.writer = bufio.NewWriter(file)
-	return nil
 }
 
-// WriteEvent записывает событие Event в файл.
-// При записи перезаписывает содержимое файла.
+// WriteEvent атомарно заменяет содержимое файла событием Event.
+// Событие записывается во временный файл, который сбрасывается на диск и переименовывается в целевой,
+// поэтому при аварийном завершении в файле остаётся либо прежнее, либо новое событие.
 func (p *Producer) WriteEvent(event *Event) error {
 	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	err = p.file.Truncate(0)
-	if err != nil {
+	if _, err = p.writer.Write(data); err != nil {
 		return err
 	}
-	_, err = p.file.Seek(0, 0)
-	if err != nil {
+	if err = p.writer.Flush(); err != nil {
 		return err
 	}
-	if _, err = p.writer.Write(data); err != nil {
+	if err = p.file.Sync(); err != nil {
+		return err
+	}
+	if err = p.file.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(p.file.Name(), p.filename); err != nil {
 		return err
 	}
-	return p.writer.Flush()
+	if err = syncDir(filepath.Dir(p.filename)); err != nil {
+		return err
+	}
+	// Следующее событие записывается в новый временный файл.
+	file, err := p.createTemp()
+	if err != nil {
+		return err
+	}
+	p.setFile(file)
+	return nil
 }
 
-// Close закрывает файл и освобождает связанные ресурсы.
+// Close закрывает и удаляет неиспользованный временный файл.
 func (p *Producer) Close() error {
 	defer func() {
 		p.file = nil
 		p.writer = nil
 	}()
-	return p.file.Close()
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(p.file.Name())
+}
+
+// syncDir сбрасывает на диск каталог, чтобы переименование файла пережило аварийное завершение.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err = d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
 }
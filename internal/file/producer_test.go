@@ -2,9 +2,11 @@ package file
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -36,7 +38,7 @@ func TestProducer_Open_Success(t *testing.T) {
 	}(tmpfile.Name())
 
 	producer := NewProducer(tmpfile.Name())
-	err = producer.Open()
+	err = producer.Open(context.Background())
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -51,7 +53,7 @@ func TestProducer_Open_Success(t *testing.T) {
 
 func TestProducer_Open_RetryableError(t *testing.T) {
 	producer := NewProducer("/nonexistent_dir/test.log")
-	err := producer.Open()
+	err := producer.Open(context.Background())
 	if err == nil {
 		t.Fatalf("Expected error, got nil")
 	}
@@ -70,7 +72,7 @@ func TestProducer_WriteEvent_Success(t *testing.T) {
 	}(tmpfile.Name())
 
 	producer := NewProducer(tmpfile.Name())
-	err = producer.Open()
+	err = producer.Open(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to open producer: %v", err)
 	}
@@ -123,6 +125,48 @@ func TestProducer_WriteEvent_Success(t *testing.T) {
 	}
 }
 
+func TestProducer_WriteEvent_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "metrics.json")
+
+	producer := NewProducer(filename)
+	if err := producer.Open(context.Background()); err != nil {
+		t.Fatalf("Failed to open producer: %v", err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := producer.WriteEvent(&Event{Counter: map[string]int64{"requests": i}, Timestamp: i}); err != nil {
+			t.Fatalf("Expected no error on WriteEvent, got %v", err)
+		}
+	}
+	if err := producer.Close(); err != nil {
+		t.Fatalf("Failed to close producer: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "metrics.json" {
+		t.Errorf("Expected only the target file to remain, got %v", entries)
+	}
+
+	consumer := NewConsumer(filename)
+	if err = consumer.Open(context.Background()); err != nil {
+		t.Fatalf("Failed to open consumer: %v", err)
+	}
+	defer consumer.Close()
+	event, err := consumer.ReadEvent()
+	if err != nil {
+		t.Fatalf("Expected no error on ReadEvent, got %v", err)
+	}
+	if event.Counter["requests"] != 3 {
+		t.Errorf("Expected last event to be stored, got %+v", event)
+	}
+	if next, _ := consumer.ReadEvent(); next != nil {
+		t.Errorf("Expected only one event in the file, got %+v", next)
+	}
+}
+
 func TestProducer_WriteEvent_InvalidJSON(t *testing.T) {
 	tmpfile, err := os.CreateTemp("", "test_producer_invalid_json_*.log")
 	if err != nil {
@@ -136,7 +180,7 @@ func TestProducer_WriteEvent_InvalidJSON(t *testing.T) {
 	}(tmpfile.Name())
 
 	producer := NewProducer(tmpfile.Name())
-	err = producer.Open()
+	err = producer.Open(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to open producer: %v", err)
 	}
@@ -175,7 +219,7 @@ func TestProducer_WriteEvent_InvalidJSON(t *testing.T) {
 	}
 
 	consumer := NewConsumer(tmpfile.Name())
-	err = consumer.Open()
+	err = consumer.Open(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to open consumer: %v", err)
 	}
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WAL реализует журнал упреждающей записи: каждое изменение дописывается в конец файла
+// отдельной строкой JSON и сбрасывается на диск до подтверждения записи.
+type WAL struct {
+	file     *os.File
+	filename string
+}
+
+// NewWAL создаёт и возвращает новый журнал для указанного файла.
+func NewWAL(filename string) *WAL {
+	return &WAL{filename: filename}
+}
+
+// Open открывает файл журнала для дозаписи.
+// В случае ошибок доступа выполняет повторные попытки, пока не завершится ctx.
+func (w *WAL) Open(ctx context.Context) error {
+	file, err := openRetry(ctx, func() (*os.File, error) {
+		return os.OpenFile(w.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	})
+	if err != nil {
+		return err
+	}
+	w.file = file
+	return nil
+}
+
+// Append дописывает запись в журнал и сбрасывает файл на диск.
+func (w *WAL) Append(update *Update) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err = w.file.Write(data); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Replay последовательно передаёт в fn все записи журнала.
+// Отсутствующий файл считается пустым журналом. Незавершённая последняя строка,
+// оставшаяся после аварийного завершения, пропускается.
+func (w *WAL) Replay(fn func(update *Update) error) error {
+	file, err := os.Open(w.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		update := &Update{}
+		if err = json.Unmarshal(data, update); err != nil {
+			return fmt.Errorf("wal %s line %d: %w", w.filename, line, err)
+		}
+		if err = fn(update); err != nil {
+			return err
+		}
+	}
+}
+
+// Truncate очищает журнал после того, как его записи вошли в снимок.
+func (w *WAL) Truncate() error {
+	if w.file == nil {
+		err := os.Truncate(w.filename, 0)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Close закрывает файл журнала.
+func (w *WAL) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
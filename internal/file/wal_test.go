@@ -0,0 +1,85 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL_AppendReplay(t *testing.T) {
+	wal := NewWAL(filepath.Join(t.TempDir(), "metrics.wal"))
+	require.NoError(t, wal.Open(context.Background()))
+	defer wal.Close()
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, wal.Append(&Update{Seq: 1, Op: OpMetrics, Gauge: map[string]float64{"cpu": 0.5}}))
+	require.NoError(t, wal.Append(&Update{Seq: 2, Op: OpSamples, Counter: map[string]int64{"requests": 3}, Timestamp: ts}))
+
+	var updates []*Update
+	require.NoError(t, wal.Replay(func(update *Update) error {
+		updates = append(updates, update)
+		return nil
+	}))
+
+	require.Len(t, updates, 2)
+	assert.Equal(t, uint64(1), updates[0].Seq)
+	assert.Equal(t, 0.5, updates[0].Gauge["cpu"])
+	assert.Equal(t, OpSamples, updates[1].Op)
+	assert.Equal(t, int64(3), updates[1].Counter["requests"])
+	assert.True(t, ts.Equal(updates[1].Timestamp))
+}
+
+func TestWAL_ReplayMissingFile(t *testing.T) {
+	wal := NewWAL(filepath.Join(t.TempDir(), "missing.wal"))
+	err := wal.Replay(func(update *Update) error {
+		t.Fatalf("unexpected update %+v", update)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestWAL_ReplaySkipsTornTail(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.wal")
+	data := `{"Seq":1,"Op":"metrics","Counter":{"requests":1}}` + "\n" + `{"Seq":2,"Op":"met`
+	require.NoError(t, os.WriteFile(filename, []byte(data), 0600))
+
+	var seqs []uint64
+	err := NewWAL(filename).Replay(func(update *Update) error {
+		seqs = append(seqs, update.Seq)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, seqs)
+}
+
+func TestWAL_ReplayCorruptRecord(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.wal")
+	data := `{"Seq":1,"Op":"met` + "\n" + `{"Seq":2,"Op":"metrics"}` + "\n"
+	require.NoError(t, os.WriteFile(filename, []byte(data), 0600))
+
+	err := NewWAL(filename).Replay(func(update *Update) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestWAL_Truncate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics.wal")
+	wal := NewWAL(filename)
+	require.NoError(t, wal.Open(context.Background()))
+	defer wal.Close()
+
+	require.NoError(t, wal.Append(&Update{Seq: 1, Op: OpMetrics}))
+	require.NoError(t, wal.Truncate())
+	require.NoError(t, wal.Append(&Update{Seq: 2, Op: OpMetrics}))
+
+	var seqs []uint64
+	require.NoError(t, wal.Replay(func(update *Update) error {
+		seqs = append(seqs, update.Seq)
+		return nil
+	}))
+	assert.Equal(t, []uint64{2}, seqs)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/moonicy/gometrics/internal/alerting"
+)
+
+// GetAlerts обрабатывает HTTP-запрос списка оповещений.
+// По умолчанию возвращает активные оповещения в состояниях pending и firing;
+// параметр state=pending|firing|resolved|all отбирает оповещения по состоянию.
+// Если правила оповещений не заданы, возвращает HTTP 501 Not Implemented.
+func (mh *MetricsHandler) GetAlerts(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if mh.alerts == nil {
+		http.Error(res, "alerting is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	state := req.URL.Query().Get("state")
+	switch alerting.State(state) {
+	case "", "all", alerting.StatePending, alerting.StateFiring, alerting.StateResolved:
+	default:
+		http.Error(res, "unknown state: "+state, http.StatusBadRequest)
+		return
+	}
+
+	alerts := make([]alerting.Alert, 0)
+	for _, alert := range mh.alerts.Alerts() {
+		switch {
+		case state == "all", alert.State == alerting.State(state):
+		case state == "" && alert.State != alerting.StateResolved:
+		default:
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+
+	out, err := json.Marshal(alerts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = res.Write(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/alerting"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/storage"
+)
+
+type staticAlerts []alerting.Alert
+
+func (a staticAlerts) Alerts() []alerting.Alert {
+	return a
+}
+
+func TestMetricsHandler_GetAlerts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mh := NewMetricsHandler(storage.NewMemStorage(), nil, nil)
+	mh.SetAlerts(staticAlerts{
+		{Rule: "A", State: alerting.StatePending, ActiveAt: now},
+		{Rule: "B", State: alerting.StateFiring, ActiveAt: now, FiredAt: &now},
+		{Rule: "C", State: alerting.StateResolved, ActiveAt: now, FiredAt: &now, ResolvedAt: &now},
+	})
+
+	tests := []struct {
+		query  string
+		status int
+		rules  []string
+	}{
+		{query: "", status: http.StatusOK, rules: []string{"A", "B"}},
+		{query: "?state=firing", status: http.StatusOK, rules: []string{"B"}},
+		{query: "?state=resolved", status: http.StatusOK, rules: []string{"C"}},
+		{query: "?state=all", status: http.StatusOK, rules: []string{"A", "B", "C"}},
+		{query: "?state=unknown", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mh.GetAlerts(rec, httptest.NewRequest("GET", "/alerts"+tt.query, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var alerts []alerting.Alert
+			if err := json.Unmarshal(rec.Body.Bytes(), &alerts); err != nil {
+				t.Fatal(err)
+			}
+			if len(alerts) != len(tt.rules) {
+				t.Fatalf("expected rules %v, got %+v", tt.rules, alerts)
+			}
+			for i, alert := range alerts {
+				if alert.Rule != tt.rules[i] {
+					t.Errorf("expected rules %v, got %+v", tt.rules, alerts)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsHandler_GetAlerts_Disabled(t *testing.T) {
+	mh := NewMetricsHandler(storage.NewMemStorage(), nil, nil)
+	rec := httptest.NewRecorder()
+	mh.GetAlerts(rec, httptest.NewRequest("GET", "/alerts", nil))
+
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d, got %d", http.StatusNotImplemented, rec.Code)
+	}
+}
+
+func ExampleMetricsHandler_GetAlerts() {
+	// Инициализируем хранилище и добавляем метрику, превышающую порог.
+	memStorage := storage.NewMemStorage()
+	_ = memStorage.SetGauge(context.Background(), "HeapAlloc", 2e9)
+
+	// Создаём движок оповещений с правилом без задержки for и вычисляем правила.
+	rules, _ := alerting.ParseRules([]config.AlertRule{{Name: "HighHeap", Expr: "HeapAlloc > 1e9"}})
+	engine := alerting.NewEngine(rules, memStorage)
+	_ = engine.Evaluate(context.Background())
+
+	// Создаём новый MetricsHandler с источником оповещений.
+	mh := NewMetricsHandler(memStorage, nil, nil)
+	mh.SetAlerts(engine)
+
+	// Вызываем обработчик.
+	rr := httptest.NewRecorder()
+	mh.GetAlerts(rr, httptest.NewRequest("GET", "/alerts", nil))
+
+	var alerts []alerting.Alert
+	_ = json.Unmarshal(rr.Body.Bytes(), &alerts)
+	fmt.Println("Status Code:", rr.Code)
+	fmt.Println(alerts[0].Rule, alerts[0].State, alerts[0].Metric, alerts[0].Value)
+
+	// Output:
+	// Status Code: 200
+	// HighHeap firing HeapAlloc 2e+09
+}
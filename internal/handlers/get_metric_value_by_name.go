@@ -24,11 +24,17 @@ func (mh *MetricsHandler) GetMetricValueByName(res http.ResponseWriter, req *htt
 
 	if name == "" {
 		http.Error(res, "Not found", http.StatusNotFound)
+		return
+	}
+	key, err := seriesKeyFromRequest(req, name)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	switch tp {
 	case metrics.Gauge:
-		value, err := mh.storage.GetGauge(req.Context(), name)
+		value, err := mh.storage.GetGauge(req.Context(), key)
 		if err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				http.Error(res, "Not found", http.StatusNotFound)
@@ -42,7 +48,7 @@ func (mh *MetricsHandler) GetMetricValueByName(res http.ResponseWriter, req *htt
 			http.Error(res, "Internal Error", http.StatusInternalServerError)
 		}
 	case metrics.Counter:
-		value, err := mh.storage.GetCounter(req.Context(), name)
+		value, err := mh.storage.GetCounter(req.Context(), key)
 		if err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				http.Error(res, "Not found", http.StatusNotFound)
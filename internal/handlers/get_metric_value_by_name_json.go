@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"log"
 	"net/http"
+	"sort"
 
 	"github.com/moonicy/gometrics/internal/metrics"
 	"github.com/moonicy/gometrics/internal/storage"
@@ -13,9 +15,12 @@ import (
 
 // GetMetricValueByNameJSON обрабатывает HTTP-запрос в формате json для получения значения метрики по её имени и типу.
 // Он извлекает параметры из json и возвращает значение метрики клиенту.
+// Если в запросе заданы условия на метки (matchers), возвращается массив всех подходящих временных рядов.
+// Для counter поле func (rate или increase) с окном window или моментом since возвращает результат
+// вычисления функции над историей значений.
 // В случае ошибки возвращает соответствующий HTTP-статус и сообщение об ошибке.
 func (mh *MetricsHandler) GetMetricValueByNameJSON(res http.ResponseWriter, req *http.Request) {
-	var mt metrics.MetricName
+	var mt metrics.MetricQuery
 
 	res.Header().Set("Content-Type", "application/json")
 
@@ -35,13 +40,38 @@ func (mh *MetricsHandler) GetMetricValueByNameJSON(res http.ResponseWriter, req
 	if err = mt.Validate(); err != nil {
 		if errors.Is(err, metrics.ErrNotFound) {
 			http.Error(res, err.Error(), http.StatusNotFound)
+			return
 		}
 		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if mt.Func != "" {
+		mh.applyCounterFunc(res, req, mt)
+		return
 	}
 
+	if len(mt.Matchers) > 0 {
+		found, err := mh.selectMetrics(req.Context(), mt)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out, err := json.Marshal(found)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, err = res.Write(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	key := mt.Key()
 	switch mt.MType {
 	case metrics.Gauge:
-		value, err := mh.storage.GetGauge(req.Context(), mt.ID)
+		value, err := mh.storage.GetGauge(req.Context(), key)
 		if err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				http.Error(res, "Not found", http.StatusNotFound)
@@ -50,7 +80,7 @@ func (mh *MetricsHandler) GetMetricValueByNameJSON(res http.ResponseWriter, req
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		resBody := metrics.Metric{MetricName: metrics.MetricName{ID: mt.ID, MType: mt.MType}, Value: &value}
+		resBody := metrics.Metric{MetricName: mt.MetricName, Value: &value}
 		out, err := json.Marshal(resBody)
 		if err != nil {
 			log.Fatal(err)
@@ -60,7 +90,45 @@ func (mh *MetricsHandler) GetMetricValueByNameJSON(res http.ResponseWriter, req
 			log.Fatal(err)
 		}
 	case metrics.Counter:
-		delta, err := mh.storage.GetCounter(req.Context(), mt.ID)
+		delta, err := mh.storage.GetCounter(req.Context(), key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(res, "Not found", http.StatusNotFound)
+				return
+			}
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resBody := metrics.Metric{MetricName: mt.MetricName, Delta: &delta}
+		out, err := json.Marshal(resBody)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, err = res.Write(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case metrics.Histogram:
+		histogram, err := mh.storage.GetHistogram(req.Context(), key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(res, "Not found", http.StatusNotFound)
+				return
+			}
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resBody := metrics.Metric{MetricName: mt.MetricName, Histogram: &histogram}
+		out, err := json.Marshal(resBody)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_, err = res.Write(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case metrics.Summary:
+		summary, err := mh.storage.GetSummary(req.Context(), key)
 		if err != nil {
 			if errors.Is(err, storage.ErrNotFound) {
 				http.Error(res, "Not found", http.StatusNotFound)
@@ -69,7 +137,7 @@ func (mh *MetricsHandler) GetMetricValueByNameJSON(res http.ResponseWriter, req
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		resBody := metrics.Metric{MetricName: metrics.MetricName{ID: mt.ID, MType: mt.MType}, Delta: &delta}
+		resBody := metrics.Metric{MetricName: mt.MetricName, Summary: &summary}
 		out, err := json.Marshal(resBody)
 		if err != nil {
 			log.Fatal(err)
@@ -80,3 +148,90 @@ func (mh *MetricsHandler) GetMetricValueByNameJSON(res http.ResponseWriter, req
 		}
 	}
 }
+
+// selectMetrics возвращает все временные ряды метрики с именем и типом из запроса, метки которых удовлетворяют условиям.
+// Результат упорядочен по ключу временного ряда.
+func (mh *MetricsHandler) selectMetrics(ctx context.Context, mq metrics.MetricQuery) ([]metrics.Metric, error) {
+	found := make(map[string]metrics.Metric)
+	add := func(key string, fill func(m *metrics.Metric)) {
+		id, labels, err := metrics.ParseSeriesKey(key)
+		if err != nil || id != mq.ID || !metrics.MatchAll(mq.Matchers, labels) {
+			return
+		}
+		m := metrics.Metric{MetricName: metrics.MetricName{ID: id, MType: mq.MType, Labels: labels}}
+		fill(&m)
+		found[key] = m
+	}
+
+	switch mq.MType {
+	case metrics.Gauge, metrics.Counter:
+		counter, gauge, err := mh.storage.GetMetrics(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if mq.MType == metrics.Gauge {
+			for key, value := range gauge {
+				add(key, func(m *metrics.Metric) { m.Value = &value })
+			}
+		} else {
+			for key, delta := range counter {
+				add(key, func(m *metrics.Metric) { m.Delta = &delta })
+			}
+		}
+	case metrics.Histogram, metrics.Summary:
+		histogram, summary, err := mh.storage.GetDistributions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if mq.MType == metrics.Histogram {
+			for key, value := range histogram {
+				add(key, func(m *metrics.Metric) { m.Histogram = &value })
+			}
+		} else {
+			for key, value := range summary {
+				add(key, func(m *metrics.Metric) { m.Summary = &value })
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]metrics.Metric, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, found[key])
+	}
+	return result, nil
+}
+
+// applyCounterFunc вычисляет функцию над историей значений counter и возвращает metrics.FuncResult.
+// Если хранилище не ведёт историю значений, возвращает HTTP 501 Not Implemented.
+func (mh *MetricsHandler) applyCounterFunc(res http.ResponseWriter, req *http.Request, mq metrics.MetricQuery) {
+	applier, ok := mh.storage.(CounterFuncApplier)
+	if !ok {
+		http.Error(res, "history is disabled", http.StatusNotImplemented)
+		return
+	}
+	result, err := applier.ApplyCounterFunc(req.Context(), mq.MetricName, mq.CounterFunc)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			http.Error(res, "Not found", http.StatusNotFound)
+		case errors.Is(err, metrics.ErrWrongFunc):
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = res.Write(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
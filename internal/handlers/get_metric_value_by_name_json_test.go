@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -30,6 +31,14 @@ func TestMetricsHandler_GetJSONMetricsByName(t *testing.T) {
 		if err != nil {
 			log.Fatal(err)
 		}
+		err = mem.AddHistogram(ctx, "latency", metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = mem.SetSummary(ctx, "duration", metrics.SummaryValue{Sum: 1, Count: 1})
+		if err != nil {
+			log.Fatal(err)
+		}
 		return mem
 	}
 	tests := []struct {
@@ -42,6 +51,9 @@ func TestMetricsHandler_GetJSONMetricsByName(t *testing.T) {
 		{name: "counter not found", body: metrics.MetricName{ID: agent.Frees, MType: agent.Counter}, mem: defaultMemStorage, status: http.StatusNotFound},
 		{name: "response 200 for gauge", body: metrics.MetricName{ID: agent.Frees, MType: agent.Gauge}, mem: presetMemStorage(), status: http.StatusOK},
 		{name: "response 200 for counter", body: metrics.MetricName{ID: agent.Alloc, MType: agent.Counter}, mem: presetMemStorage(), status: http.StatusOK},
+		{name: "histogram not found", body: metrics.MetricName{ID: "latency", MType: metrics.Histogram}, mem: defaultMemStorage, status: http.StatusNotFound},
+		{name: "response 200 for histogram", body: metrics.MetricName{ID: "latency", MType: metrics.Histogram}, mem: presetMemStorage(), status: http.StatusOK},
+		{name: "response 200 for summary", body: metrics.MetricName{ID: "duration", MType: metrics.Summary}, mem: presetMemStorage(), status: http.StatusOK},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -76,6 +88,129 @@ func TestMetricsHandler_GetJSONMetricsByName(t *testing.T) {
 	}
 }
 
+func TestMetricsHandler_GetJSONMetricsByMatchers(t *testing.T) {
+	ctx := context.Background()
+	memStorage := storage.NewMemStorage()
+	for _, m := range []struct {
+		id    string
+		host  string
+		env   string
+		value float64
+	}{
+		{id: "CPU", host: "a", env: "prod", value: 1},
+		{id: "CPU", host: "b", env: "prod", value: 2},
+		{id: "CPU", host: "c", env: "dev", value: 3},
+		{id: "RAM", host: "a", env: "prod", value: 4},
+	} {
+		key := metrics.SeriesKey(m.id, map[string]string{"host": m.host, "env": m.env})
+		if err := memStorage.SetGauge(ctx, key, m.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mh := NewMetricsHandler(memStorage, nil, nil)
+
+	body := metrics.MetricQuery{
+		MetricName: metrics.MetricName{ID: "CPU", MType: metrics.Gauge},
+		Matchers: []metrics.LabelMatcher{
+			{Name: "env", Op: metrics.MatchEqual, Value: "prod"},
+			{Name: "host", Op: metrics.MatchRegexp, Value: "a|c"},
+		},
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mh.GetMetricValueByNameJSON(rec, httptest.NewRequest("POST", "/value/", bytes.NewBuffer(out)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var got []metrics.Metric
+	if err = json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Labels["host"] != "a" || *got[0].Value != 1 {
+		t.Errorf("unexpected metrics: %s", rec.Body.String())
+	}
+
+	body.Matchers = []metrics.LabelMatcher{{Name: "host", Op: metrics.MatchRegexp, Value: "("}}
+	out, err = json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	mh.GetMetricValueByNameJSON(rec, httptest.NewRequest("POST", "/value/", bytes.NewBuffer(out)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for wrong matcher, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	body = metrics.MetricQuery{MetricName: metrics.MetricName{ID: "CPU", MType: metrics.Gauge, Labels: map[string]string{"host": "b", "env": "prod"}}}
+	out, err = json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	mh.GetMetricValueByNameJSON(rec, httptest.NewRequest("POST", "/value/", bytes.NewBuffer(out)))
+	var single metrics.Metric
+	if err = json.Unmarshal(rec.Body.Bytes(), &single); err != nil {
+		t.Fatal(err)
+	}
+	if single.Value == nil || *single.Value != 2 || single.Labels["host"] != "b" {
+		t.Errorf("unexpected metric: %s", rec.Body.String())
+	}
+}
+
+func TestMetricsHandler_GetJSONMetricsByNameFunc(t *testing.T) {
+	ctx := context.Background()
+	history := storage.NewHistoryStorage(storage.NewMemStorage(), storage.NewMemHistory(), time.Hour)
+	for _, delta := range []int64{10, 5} {
+		if err := history.AddCounter(ctx, agent.PollCount, delta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	since := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		storage Storage
+		body    string
+		status  int
+		value   float64
+	}{
+		{name: "increase", storage: history, body: `{"id":"PollCount","type":"counter","func":"increase","window":"1h"}`, status: http.StatusOK, value: 5},
+		{name: "increase since", storage: history, body: `{"id":"PollCount","type":"counter","func":"increase","since":"` + since.Format(time.RFC3339Nano) + `"}`, status: http.StatusOK, value: 5},
+		{name: "rate", storage: history, body: `{"id":"PollCount","type":"counter","func":"rate","window":"1m"}`, status: http.StatusOK},
+		{name: "missing counter", storage: history, body: `{"id":"Missing","type":"counter","func":"rate","window":"1m"}`, status: http.StatusNotFound},
+		{name: "rate without window", storage: history, body: `{"id":"PollCount","type":"counter","func":"rate"}`, status: http.StatusBadRequest},
+		{name: "func for gauge", storage: history, body: `{"id":"Alloc","type":"gauge","func":"rate","window":"1m"}`, status: http.StatusBadRequest},
+		{name: "history disabled", storage: storage.NewMemStorage(), body: `{"id":"PollCount","type":"counter","func":"rate","window":"1m"}`, status: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mh := NewMetricsHandler(tt.storage, nil, nil)
+			rec := httptest.NewRecorder()
+			mh.GetMetricValueByNameJSON(rec, httptest.NewRequest("POST", "/value/", bytes.NewBufferString(tt.body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var result metrics.FuncResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.ID != agent.PollCount || result.Func == "" {
+				t.Errorf("unexpected result: %s", rec.Body.String())
+			}
+			if tt.value != 0 && result.Value != tt.value {
+				t.Errorf("expected value %v, got %v", tt.value, result.Value)
+			}
+		})
+	}
+}
+
 func ExampleMetricsHandler_GetMetricValueByNameJSON() {
 	// Инициализируем хранилище и добавляем метрику типа gauge.
 	memStorage := storage.NewMemStorage()
@@ -27,6 +27,10 @@ func TestMetricsHandler_GetMetricsByName(t *testing.T) {
 		if err != nil {
 			log.Fatal(err)
 		}
+		err = mem.SetGauge(ctx, `Frees{host="a"}`, 23)
+		if err != nil {
+			log.Fatal(err)
+		}
 		return mem
 	}
 	tests := []struct {
@@ -40,6 +44,9 @@ func TestMetricsHandler_GetMetricsByName(t *testing.T) {
 		{name: "counter not found", tpMet: agent.Counter, nameMet: agent.Frees, mem: defaultMemStorage, status: http.StatusNotFound},
 		{name: "response 200 for gauge", tpMet: agent.Gauge, nameMet: agent.Frees, mem: presetMemStorage(), status: http.StatusOK},
 		{name: "response 200 for counter", tpMet: agent.Counter, nameMet: agent.Alloc, mem: presetMemStorage(), status: http.StatusOK},
+		{name: "response 200 for labelled gauge", tpMet: agent.Gauge, nameMet: agent.Frees + "?host=a", mem: presetMemStorage(), status: http.StatusOK},
+		{name: "labelled gauge not found", tpMet: agent.Gauge, nameMet: agent.Frees + "?host=b", mem: presetMemStorage(), status: http.StatusNotFound},
+		{name: "wrong label name", tpMet: agent.Gauge, nameMet: agent.Frees + "?1host=a", mem: presetMemStorage(), status: http.StatusBadRequest},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -24,6 +24,17 @@ func (mh *MetricsHandler) GetMetrics(res http.ResponseWriter, req *http.Request)
 	for k, v := range gotGauge {
 		builder.WriteString(fmt.Sprintf("%s: %s\n", k, floattostr.FloatToString(v)))
 	}
+	gotHistogram, gotSummary, err := mh.storage.GetDistributions(req.Context())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for k, v := range gotHistogram {
+		builder.WriteString(fmt.Sprintf("%s: count=%d sum=%s\n", k, v.Count, floattostr.FloatToString(v.Sum)))
+	}
+	for k, v := range gotSummary {
+		builder.WriteString(fmt.Sprintf("%s: count=%d sum=%s\n", k, v.Count, floattostr.FloatToString(v.Sum)))
+	}
 	_, err = res.Write([]byte(builder.String()))
 	if err != nil {
 		http.Error(res, "Internal Error", http.StatusInternalServerError)
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// PrometheusContentType - тип содержимого текстового формата Prometheus.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var errTypeConflict = errors.New("metric name is already used by another type")
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// GetPrometheusMetrics обрабатывает HTTP-запрос для получения всех метрик в текстовом формате Prometheus.
+// Метрики группируются по имени, для каждой группы выводится строка # TYPE, группы и временные ряды упорядочены.
+// В случае ошибки возвращает соответствующий HTTP-статус и сообщение об ошибке.
+func (mh *MetricsHandler) GetPrometheusMetrics(res http.ResponseWriter, req *http.Request) {
+	gotCounter, gotGauge, err := mh.storage.GetMetrics(req.Context())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	gotHistogram, gotSummary, err := mh.storage.GetDistributions(req.Context())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	exp := newPromExposition()
+	for k, v := range gotCounter {
+		mh.addFamily(exp, k, metrics.Counter, func(name string, labels map[string]string) string {
+			return promSample(name, labels, strconv.FormatInt(v, 10))
+		})
+	}
+	for k, v := range gotGauge {
+		mh.addFamily(exp, k, metrics.Gauge, func(name string, labels map[string]string) string {
+			return promSample(name, labels, formatPromFloat(v))
+		})
+	}
+	for k, v := range gotHistogram {
+		mh.addFamily(exp, k, metrics.Histogram, func(name string, labels map[string]string) string {
+			return promHistogram(name, labels, v)
+		})
+	}
+	for k, v := range gotSummary {
+		mh.addFamily(exp, k, metrics.Summary, func(name string, labels map[string]string) string {
+			return promSummary(name, labels, v)
+		})
+	}
+
+	res.Header().Set("Content-Type", PrometheusContentType)
+	_, err = res.Write([]byte(exp.String()))
+	if err != nil {
+		http.Error(res, "Internal Error", http.StatusInternalServerError)
+	}
+}
+
+func (mh *MetricsHandler) addFamily(exp *promExposition, key string, mType string, render func(name string, labels map[string]string) string) {
+	if err := exp.add(key, mType, render); err != nil && mh.logger != nil {
+		mh.logger.Warnf("skip metric %s in prometheus exposition: %v", key, err)
+	}
+}
+
+type promSeries struct {
+	key      string
+	original string
+	text     string
+}
+
+type promFamily struct {
+	mType  string
+	series []promSeries
+	index  map[string]int
+}
+
+type promExposition struct {
+	families map[string]*promFamily
+}
+
+func newPromExposition() *promExposition {
+	return &promExposition{families: make(map[string]*promFamily)}
+}
+
+// add добавляет временной ряд в группу с очищенным именем метрики.
+// Временной ряд, имя которого после очистки совпало с именем группы другого типа, пропускается.
+// Если после очистки совпали ключи двух временных рядов, остаётся ряд с меньшим исходным ключом.
+func (e *promExposition) add(key string, mType string, render func(name string, labels map[string]string) string) error {
+	id, labels, err := metrics.ParseSeriesKey(key)
+	if err != nil {
+		id, labels = key, nil
+	}
+	name := SanitizeMetricName(id)
+	cleanLabels := make(map[string]string, len(labels))
+	for k, v := range labels {
+		cleanLabels[sanitizeLabelName(k)] = v
+	}
+
+	family, ok := e.families[name]
+	if !ok {
+		family = &promFamily{mType: mType, index: make(map[string]int)}
+		e.families[name] = family
+	}
+	if family.mType != mType {
+		return errTypeConflict
+	}
+	series := promSeries{
+		key:      metrics.SeriesKey(name, cleanLabels),
+		original: key,
+		text:     render(name, cleanLabels),
+	}
+	if i, ok := family.index[series.key]; ok {
+		if key < family.series[i].original {
+			family.series[i] = series
+		}
+		return nil
+	}
+	family.index[series.key] = len(family.series)
+	family.series = append(family.series, series)
+	return nil
+}
+
+// String возвращает все группы метрик в текстовом формате Prometheus.
+func (e *promExposition) String() string {
+	names := make([]string, 0, len(e.families))
+	for name := range e.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		family := e.families[name]
+		sort.Slice(family.series, func(i, j int) bool { return family.series[i].key < family.series[j].key })
+		b.WriteString("# TYPE " + name + " " + family.mType + "\n")
+		for _, s := range family.series {
+			b.WriteString(s.text)
+		}
+	}
+	return b.String()
+}
+
+// SanitizeMetricName приводит имя метрики к виду, допустимому в Prometheus: [a-zA-Z_:][a-zA-Z0-9_:]*.
+// Недопустимые символы заменяются на подчёркивание.
+func SanitizeMetricName(name string) string {
+	return sanitizeName(name, true)
+}
+
+func sanitizeLabelName(name string) string {
+	return sanitizeName(name, false)
+}
+
+func sanitizeName(name string, allowColon bool) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && allowColon:
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func promSample(name string, labels map[string]string, value string, extra ...string) string {
+	return name + formatPromLabels(labels, extra...) + " " + value + "\n"
+}
+
+func promHistogram(name string, labels map[string]string, v metrics.HistogramValue) string {
+	var b strings.Builder
+	var cumulative uint64
+	for i, bound := range v.Bounds {
+		if i < len(v.Counts) {
+			cumulative += v.Counts[i]
+		}
+		b.WriteString(promSample(name+"_bucket", labels, strconv.FormatUint(cumulative, 10), "le", formatPromFloat(bound)))
+	}
+	b.WriteString(promSample(name+"_bucket", labels, strconv.FormatUint(v.Count, 10), "le", "+Inf"))
+	b.WriteString(promSample(name+"_sum", labels, formatPromFloat(v.Sum)))
+	b.WriteString(promSample(name+"_count", labels, strconv.FormatUint(v.Count, 10)))
+	return b.String()
+}
+
+func promSummary(name string, labels map[string]string, v metrics.SummaryValue) string {
+	var b strings.Builder
+	for _, q := range v.Quantiles {
+		b.WriteString(promSample(name, labels, formatPromFloat(q.Value), "quantile", formatPromFloat(q.Quantile)))
+	}
+	b.WriteString(promSample(name+"_sum", labels, formatPromFloat(v.Sum)))
+	b.WriteString(promSample(name+"_count", labels, strconv.FormatUint(v.Count, 10)))
+	return b.String()
+}
+
+// formatPromLabels форматирует метки в виде {a="1",b="2"}; extra задаёт дополнительные пары имя-значение, выводимые последними.
+func formatPromLabels(labels map[string]string, extra ...string) string {
+	if len(labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for _, k := range names {
+		pairs = append(pairs, k+`="`+labelValueReplacer.Replace(labels[k])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelValueReplacer.Replace(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatPromFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
+)
+
+func TestMetricsHandler_GetPrometheusMetrics(t *testing.T) {
+	ctx := context.Background()
+	mem := storage.NewMemStorage()
+	if err := mem.AddCounter(ctx, "PollCount", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.AddCounter(ctx, metrics.SeriesKey("http.requests", map[string]string{"code": "500"}), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.AddCounter(ctx, metrics.SeriesKey("http.requests", map[string]string{"code": "200"}), 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SetGauge(ctx, "1st-gauge", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SetGauge(ctx, metrics.SeriesKey("Alloc", map[string]string{"path": "a\"b\\c\nd"}), 1e21); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SetGauge(ctx, "PollCount", 1); err != nil {
+		t.Fatal(err)
+	}
+	err := mem.AddHistogram(ctx, "latency", metrics.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{2, 3, 1}, Sum: 4.2, Count: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mem.SetSummary(ctx, "duration", metrics.SummaryValue{Quantiles: []metrics.Quantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.99, Value: 3}}, Sum: 10, Count: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const want = `# TYPE Alloc gauge
+Alloc{path="a\"b\\c\nd"} 1e+21
+# TYPE PollCount counter
+PollCount 5
+# TYPE _1st_gauge gauge
+_1st_gauge 0.5
+# TYPE duration summary
+duration{quantile="0.5"} 1
+duration{quantile="0.99"} 3
+duration_sum 10
+duration_count 4
+# TYPE http_requests counter
+http_requests{code="200"} 7
+http_requests{code="500"} 1
+# TYPE latency histogram
+latency_bucket{le="0.1"} 2
+latency_bucket{le="1"} 5
+latency_bucket{le="+Inf"} 6
+latency_sum 4.2
+latency_count 6
+`
+
+	mh := NewMetricsHandler(mem, nil, nil)
+	rec := httptest.NewRecorder()
+	mh.GetPrometheusMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != PrometheusContentType {
+		t.Errorf("expected content type %q, got %q", PrometheusContentType, ct)
+	}
+	if rec.Body.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, rec.Body.String())
+	}
+}
+
+func TestMetricsHandler_GetPrometheusMetrics_DuplicateAfterSanitize(t *testing.T) {
+	ctx := context.Background()
+	mem := storage.NewMemStorage()
+	if err := mem.SetGauge(ctx, "a-b", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SetGauge(ctx, "a.b", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	mh := NewMetricsHandler(mem, nil, nil)
+	rec := httptest.NewRecorder()
+	mh.GetPrometheusMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	const want = "# TYPE a_b gauge\na_b 2\n"
+	if rec.Body.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, rec.Body.String())
+	}
+}
+
+type failingStorage struct {
+	*storage.MemStorage
+}
+
+func (fs failingStorage) GetMetrics(_ context.Context) (map[string]int64, map[string]float64, error) {
+	return nil, nil, errors.New("storage is down")
+}
+
+func TestMetricsHandler_GetPrometheusMetrics_StorageError(t *testing.T) {
+	mh := NewMetricsHandler(failingStorage{storage.NewMemStorage()}, nil, nil)
+	rec := httptest.NewRecorder()
+	mh.GetPrometheusMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	tests := map[string]string{
+		"Alloc":            "Alloc",
+		"http.requests":    "http_requests",
+		"ns:sub_total":     "ns:sub_total",
+		"9lives":           "_9lives",
+		"cpu usage %":      "cpu_usage__",
+		"":                 "_",
+		"метрика":          "_______",
+		"node_cpu_seconds": "node_cpu_seconds",
+	}
+	for in, want := range tests {
+		if got := SanitizeMetricName(in); got != want {
+			t.Errorf("SanitizeMetricName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func ExampleMetricsHandler_GetPrometheusMetrics() {
+	// Инициализируем хранилище и добавляем метрики.
+	memStorage := storage.NewMemStorage()
+	_ = memStorage.SetGauge(context.Background(), "Alloc", 12345.67)
+	_ = memStorage.AddCounter(context.Background(), "PollCount", 42)
+
+	// Создаём новый MetricsHandler.
+	mh := NewMetricsHandler(memStorage, nil, nil)
+
+	// Создаём новый HTTP-запрос и Recorder для записи ответа.
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+
+	// Вызываем обработчик.
+	mh.GetPrometheusMetrics(rr, req)
+
+	// Выводим статусный код и тело ответа.
+	fmt.Println("Status Code:", rr.Code)
+	fmt.Println(strings.TrimSpace(rr.Body.String()))
+
+	// Output:
+	// Status Code: 200
+	// # TYPE Alloc gauge
+	// Alloc 12345.67
+	// # TYPE PollCount counter
+	// PollCount 42
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// Ограничения запроса истории значений метрик.
+const (
+	DefaultQueryRange = time.Hour // интервал запроса, если параметр from не задан
+	MaxQueryPoints    = 11000     // максимальное количество точек при заданном шаге
+)
+
+// GetQuery обрабатывает HTTP-запрос истории значений метрики вида /query?name=&type=&from=&to=&step=.
+// Имя может содержать метки в виде name{a="1"}. Параметры from и to принимают время в формате RFC 3339
+// или в секундах Unix, по умолчанию возвращается история за последний час. Если задан шаг step,
+// значения прореживаются: для каждой точки берётся последнее значение за предшествующий шаг.
+// В случае ошибки возвращает соответствующий HTTP-статус и сообщение об ошибке.
+func (mh *MetricsHandler) GetQuery(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	history, ok := mh.storage.(HistoryQuerier)
+	if !ok {
+		http.Error(res, "history is disabled", http.StatusNotImplemented)
+		return
+	}
+
+	query := req.URL.Query()
+	id, labels, err := metrics.ParseSeriesKey(query.Get("name"))
+	if err != nil {
+		if query.Get("name") == "" {
+			http.Error(res, metrics.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mn := metrics.MetricName{ID: id, MType: query.Get("type"), Labels: labels}
+	if err = mn.Validate(); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if mn.MType != metrics.Gauge && mn.MType != metrics.Counter {
+		http.Error(res, "history is kept only for gauge and counter", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now().UTC()
+	if v := query.Get("to"); v != "" {
+		if to, err = parseQueryTime(v); err != nil {
+			http.Error(res, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.Add(-DefaultQueryRange)
+	if v := query.Get("from"); v != "" {
+		if from, err = parseQueryTime(v); err != nil {
+			http.Error(res, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if from.After(to) {
+		http.Error(res, "from is after to", http.StatusBadRequest)
+		return
+	}
+	var step time.Duration
+	if v := query.Get("step"); v != "" {
+		if step, err = parseQueryStep(v); err != nil {
+			http.Error(res, "invalid step: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.Sub(from)/step >= MaxQueryPoints {
+			http.Error(res, "too many points, increase step", http.StatusBadRequest)
+			return
+		}
+	}
+
+	samples, err := history.QueryRange(req.Context(), mn.MType, mn.Key(), from, to)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if step > 0 {
+		samples = metrics.Downsample(samples, from, to, step)
+	}
+
+	out, err := json.Marshal(metrics.Series{MetricName: mn, Samples: samples})
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = res.Write(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseQueryTime разбирает время в формате RFC 3339 или в секундах Unix, в том числе дробных.
+func parseQueryTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t.UTC(), nil
+	}
+	sec, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return time.Time{}, errors.New("expected RFC 3339 or unix seconds")
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
+
+// parseQueryStep разбирает шаг в виде длительности (30s, 5m) или в секундах.
+func parseQueryStep(v string) (time.Duration, error) {
+	step, err := time.ParseDuration(v)
+	if err != nil {
+		sec, errSec := strconv.ParseFloat(v, 64)
+		if errSec != nil || math.IsNaN(sec) || math.IsInf(sec, 0) {
+			return 0, errors.New("expected duration or seconds")
+		}
+		step = time.Duration(sec * float64(time.Second))
+	}
+	if step <= 0 {
+		return 0, errors.New("step must be positive")
+	}
+	return step, nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
+)
+
+func TestMetricsHandler_GetQuery(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := storage.NewMemHistory()
+	key := metrics.SeriesKey("CPU", map[string]string{"host": "a"})
+	for i, v := range []float64{1, 2, 3, 4} {
+		err := history.AddSamples(ctx, base.Add(time.Duration(i)*10*time.Second), nil, map[string]float64{key: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := history.AddSamples(ctx, base, map[string]int64{"PollCount": 5}, nil); err != nil {
+		t.Fatal(err)
+	}
+	mh := NewMetricsHandler(storage.NewHistoryStorage(storage.NewMemStorage(), history, time.Hour), nil, nil)
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+		want   []float64
+	}{
+		{
+			name:   "range",
+			query:  url.Values{"name": {`CPU{host="a"}`}, "type": {"gauge"}, "from": {"2024-01-01T00:00:05Z"}, "to": {"1704067225"}},
+			status: http.StatusOK,
+			want:   []float64{2, 3},
+		},
+		{
+			name:   "step",
+			query:  url.Values{"name": {`CPU{host="a"}`}, "type": {"gauge"}, "from": {"2024-01-01T00:00:00Z"}, "to": {"2024-01-01T00:00:40Z"}, "step": {"20s"}},
+			status: http.StatusOK,
+			want:   []float64{1, 3, 4},
+		},
+		{
+			name:   "counter",
+			query:  url.Values{"name": {"PollCount"}, "type": {"counter"}, "from": {"2024-01-01T00:00:00Z"}, "to": {"2024-01-01T00:01:00Z"}},
+			status: http.StatusOK,
+			want:   []float64{5},
+		},
+		{
+			name:   "unknown series",
+			query:  url.Values{"name": {"CPU"}, "type": {"gauge"}, "from": {"2024-01-01T00:00:00Z"}, "to": {"2024-01-01T00:01:00Z"}},
+			status: http.StatusOK,
+			want:   []float64{},
+		},
+		{name: "without name", query: url.Values{"type": {"gauge"}}, status: http.StatusNotFound},
+		{name: "wrong type", query: url.Values{"name": {"CPU"}, "type": {"histogram"}}, status: http.StatusBadRequest},
+		{name: "wrong selector", query: url.Values{"name": {`CPU{host=~"a"}`}, "type": {"gauge"}}, status: http.StatusBadRequest},
+		{name: "wrong from", query: url.Values{"name": {"CPU"}, "type": {"gauge"}, "from": {"yesterday"}}, status: http.StatusBadRequest},
+		{name: "from after to", query: url.Values{"name": {"CPU"}, "type": {"gauge"}, "from": {"200"}, "to": {"100"}}, status: http.StatusBadRequest},
+		{name: "wrong step", query: url.Values{"name": {"CPU"}, "type": {"gauge"}, "step": {"-1s"}}, status: http.StatusBadRequest},
+		{name: "too many points", query: url.Values{"name": {"CPU"}, "type": {"gauge"}, "step": {"1ms"}}, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mh.GetQuery(rec, httptest.NewRequest("GET", "/query?"+tt.query.Encode(), nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var series metrics.Series
+			if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]float64, 0, len(series.Samples))
+			for _, s := range series.Samples {
+				got = append(got, s.Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected values %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected values %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsHandler_GetQuery_HistoryDisabled(t *testing.T) {
+	mh := NewMetricsHandler(storage.NewMemStorage(), nil, nil)
+	rec := httptest.NewRecorder()
+	mh.GetQuery(rec, httptest.NewRequest("GET", "/query?name=Alloc&type=gauge", nil))
+
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected status %d, got %d", http.StatusNotImplemented, rec.Code)
+	}
+}
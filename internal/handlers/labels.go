@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// seriesKeyFromRequest формирует ключ временного ряда из имени метрики и меток, переданных параметрами запроса.
+// Например, /value/gauge/CPU?host=a адресует временной ряд CPU{host="a"}.
+func seriesKeyFromRequest(req *http.Request, name string) (string, error) {
+	query := req.URL.Query()
+	if len(query) == 0 {
+		return name, nil
+	}
+	labels := make(map[string]string, len(query))
+	for k, v := range query {
+		if len(v) != 1 {
+			return "", metrics.ErrWrongLabels
+		}
+		labels[k] = v[0]
+	}
+	if err := metrics.ValidateLabels(labels); err != nil {
+		return "", err
+	}
+	return metrics.SeriesKey(name, labels), nil
+}
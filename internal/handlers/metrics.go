@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"context"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/moonicy/gometrics/internal/alerting"
 	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/segment"
 	"github.com/moonicy/gometrics/internal/storage"
 )
 
@@ -27,6 +31,12 @@ type Storage interface {
 	GetGauge(ctx context.Context, key string) (value float64, err error)
 	GetMetrics(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
 	SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
+	AddHistogram(ctx context.Context, key string, value metrics.HistogramValue) error
+	SetSummary(ctx context.Context, key string, value metrics.SummaryValue) error
+	GetHistogram(ctx context.Context, key string) (value metrics.HistogramValue, err error)
+	GetSummary(ctx context.Context, key string) (value metrics.SummaryValue, err error)
+	GetDistributions(ctx context.Context) (histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue, err error)
+	SetDistributions(ctx context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error
 }
 
 // MetricsHandler содержит логику обработки метрик и взаимодействия с хранилищем.
@@ -34,22 +44,67 @@ type MetricsHandler struct {
 	storage Storage
 	pinger  Pingable
 	logger  *zap.SugaredLogger
+	alerts  AlertLister
 }
 
 // NewMetricsHandler создаёт и возвращает новый экземпляр MetricsHandler.
 func NewMetricsHandler(storage Storage, pinger Pingable, logger *zap.SugaredLogger) *MetricsHandler {
-	return &MetricsHandler{storage, pinger, logger}
+	return &MetricsHandler{storage: storage, pinger: pinger, logger: logger}
+}
+
+// AlertLister определяет интерфейс источника оповещений.
+type AlertLister interface {
+	Alerts() []alerting.Alert
+}
+
+// SetAlerts задаёт источник оповещений для эндпоинта /alerts.
+func (mh *MetricsHandler) SetAlerts(alerts AlertLister) {
+	mh.alerts = alerts
+}
+
+// HistoryQuerier определяет интерфейс хранилища, которое хранит историю значений метрик.
+type HistoryQuerier interface {
+	QueryRange(ctx context.Context, mType string, key string, from, to time.Time) ([]metrics.Sample, error)
+}
+
+// CounterFuncApplier определяет интерфейс хранилища, вычисляющего функции над историей значений counter.
+type CounterFuncApplier interface {
+	ApplyCounterFunc(ctx context.Context, mn metrics.MetricName, cf metrics.CounterFunc) (metrics.FuncResult, error)
 }
 
 // NewStorage создаёт и возвращает новое хранилище метрик в зависимости от конфигурации.
-func NewStorage(cfg config.ServerConfig, db storage.DB, cr storage.Consumer, pr storage.Producer) interface {
+// Если задано время хранения истории, хранилище дополнительно записывает историю значений метрик.
+// Если передан журнал wal, файловое хранилище сохраняет в нём каждое изменение.
+// Порядок выбора: база данных, встроенное хранилище сегментов, файл, память.
+func NewStorage(cfg config.ServerConfig, db storage.DB, cr storage.Consumer, pr storage.Producer, wal storage.WAL) interface {
 	Storage
 	Initable
 } {
 	if cfg.DatabaseDsn != "" {
-		return storage.NewDBStorage(db)
+		dbs := storage.NewDBStorage(db)
+		if cfg.HistoryRetention > 0 {
+			return storage.NewHistoryStorage(dbs, storage.NewDBHistory(db), cfg.HistoryRetention)
+		}
+		return dbs
+	} else if cfg.SegmentStoragePath != "" {
+		ss := storage.NewSegmentStorage(segment.NewStore(cfg.SegmentStoragePath, segment.DefaultMaxSegmentSize))
+		if cfg.HistoryRetention > 0 {
+			return storage.NewHistoryStorage(ss, storage.NewMemHistory(), cfg.HistoryRetention)
+		}
+		return ss
 	} else if cfg.FileStoragePath != "" {
-		return storage.NewFileStorage(cfg, cr, pr)
+		fs := storage.NewFileStorage(cfg, cr, pr)
+		if wal != nil {
+			fs.SetWAL(wal)
+		}
+		if cfg.HistoryRetention > 0 {
+			return storage.NewHistoryStorage(fs, fs, cfg.HistoryRetention)
+		}
+		return fs
+	}
+	mem := storage.NewMemStorage()
+	if cfg.HistoryRetention > 0 {
+		return storage.NewHistoryStorage(mem, storage.NewMemHistory(), cfg.HistoryRetention)
 	}
-	return storage.NewMemStorage()
+	return mem
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/moonicy/gometrics/internal/config"
 	"github.com/moonicy/gometrics/internal/file"
@@ -24,10 +25,13 @@ func (m *MockDB) QueryRowContext(_ context.Context, _ string, _ ...any) *sql.Row
 func (m *MockDB) Begin() (*sql.Tx, error) {
 	return nil, nil
 }
+func (m *MockDB) BeginTx(_ context.Context, _ *sql.TxOptions) (*sql.Tx, error) {
+	return nil, nil
+}
 
 type MockConsumer struct{}
 
-func (m *MockConsumer) Open() error {
+func (m *MockConsumer) Open(_ context.Context) error {
 	return nil
 }
 func (m *MockConsumer) ReadEvent() (*file.Event, error) {
@@ -39,7 +43,7 @@ func (m *MockConsumer) Close() error {
 
 type MockProducer struct{}
 
-func (m *MockProducer) Open() error {
+func (m *MockProducer) Open(_ context.Context) error {
 	return nil
 }
 func (m *MockProducer) WriteEvent(_ *file.Event) error {
@@ -64,6 +68,11 @@ func TestNewStorage(t *testing.T) {
 			cfg:      config.ServerConfig{DatabaseDsn: "test_dsn"},
 			wantType: "*storage.DBStorage",
 		},
+		{
+			name:     "Segment storage",
+			cfg:      config.ServerConfig{SegmentStoragePath: "/path/to/segments", FileStoragePath: "/path/to/file"},
+			wantType: "*storage.SegmentStorage",
+		},
 		{
 			name:     "File storage",
 			cfg:      config.ServerConfig{FileStoragePath: "/path/to/file"},
@@ -74,11 +83,21 @@ func TestNewStorage(t *testing.T) {
 			cfg:      config.ServerConfig{},
 			wantType: "*storage.MemStorage",
 		},
+		{
+			name:     "Memory storage with history",
+			cfg:      config.ServerConfig{HistoryRetention: time.Hour},
+			wantType: "*storage.HistoryStorage",
+		},
+		{
+			name:     "Database storage with history",
+			cfg:      config.ServerConfig{DatabaseDsn: "test_dsn", HistoryRetention: time.Hour},
+			wantType: "*storage.HistoryStorage",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storage := NewStorage(tt.cfg, mockDB, mockConsumer, mockProducer)
+			storage := NewStorage(tt.cfg, mockDB, mockConsumer, mockProducer, nil)
 
 			if gotType := fmt.Sprintf("%T", storage); gotType != tt.wantType {
 				t.Errorf("Expected type %s, got %s", tt.wantType, gotType)
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
 
@@ -20,6 +19,12 @@ func (mh *MetricsHandler) PostMetricUpdate(res http.ResponseWriter, req *http.Re
 
 	if name == "" {
 		http.Error(res, "Not found", http.StatusNotFound)
+		return
+	}
+	key, err := seriesKeyFromRequest(req, name)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	switch tp {
@@ -29,7 +34,7 @@ func (mh *MetricsHandler) PostMetricUpdate(res http.ResponseWriter, req *http.Re
 			http.Error(res, "Value is not a valid float64", http.StatusBadRequest)
 			return
 		}
-		err = mh.storage.SetGauge(req.Context(), name, valFloat)
+		err = mh.storage.SetGauge(req.Context(), key, valFloat)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
@@ -40,7 +45,7 @@ func (mh *MetricsHandler) PostMetricUpdate(res http.ResponseWriter, req *http.Re
 			http.Error(res, "Value is not a valid int64", http.StatusBadRequest)
 			return
 		}
-		err = mh.storage.AddCounter(req.Context(), name, valInt)
+		err = mh.storage.AddCounter(req.Context(), key, valInt)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
@@ -48,5 +53,4 @@ func (mh *MetricsHandler) PostMetricUpdate(res http.ResponseWriter, req *http.Re
 	default:
 		http.Error(res, "Bad request", http.StatusBadRequest)
 	}
-	fmt.Printf("%s\t%s\t%s\n", name, val, tp)
 }
@@ -3,7 +3,6 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -22,28 +21,35 @@ func (mh *MetricsHandler) PostMetricUpdateJSON(res http.ResponseWriter, req *htt
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 	if err = json.Unmarshal(body, &mt); err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	if err = mt.Validate(); err != nil {
 		if errors.Is(err, metrics.ErrNotFound) {
 			http.Error(res, err.Error(), http.StatusNotFound)
+			return
 		}
 		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	key := mt.Key()
 	var value *float64
 	var delta *int64
+	var histogram *metrics.HistogramValue
+	var summary *metrics.SummaryValue
 	switch mt.MType {
 	case metrics.Gauge:
-		err = mh.storage.SetGauge(req.Context(), mt.ID, *mt.Value)
+		err = mh.storage.SetGauge(req.Context(), key, *mt.Value)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		gv, erro := mh.storage.GetGauge(req.Context(), mt.ID)
+		gv, erro := mh.storage.GetGauge(req.Context(), key)
 		if erro != nil {
 			if errors.Is(erro, metrics.ErrNotFound) {
 				break
@@ -52,14 +58,13 @@ func (mh *MetricsHandler) PostMetricUpdateJSON(res http.ResponseWriter, req *htt
 			return
 		}
 		value = &gv
-		fmt.Printf("%s\t%s\t%f\n", mt.ID, mt.MType, *mt.Value)
 	case metrics.Counter:
-		err = mh.storage.AddCounter(req.Context(), mt.ID, *mt.Delta)
+		err = mh.storage.AddCounter(req.Context(), key, *mt.Delta)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		cv, erro := mh.storage.GetCounter(req.Context(), mt.ID)
+		cv, erro := mh.storage.GetCounter(req.Context(), key)
 		if erro != nil {
 			if errors.Is(erro, metrics.ErrNotFound) {
 				break
@@ -68,10 +73,43 @@ func (mh *MetricsHandler) PostMetricUpdateJSON(res http.ResponseWriter, req *htt
 			return
 		}
 		delta = &cv
-		fmt.Printf("%s\t%s\t%d\n", mt.ID, mt.MType, *mt.Delta)
+	case metrics.Histogram:
+		err = mh.storage.AddHistogram(req.Context(), key, *mt.Histogram)
+		if err != nil {
+			if errors.Is(err, metrics.ErrWrongValue) {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hv, erro := mh.storage.GetHistogram(req.Context(), key)
+		if erro != nil {
+			http.Error(res, erro.Error(), http.StatusInternalServerError)
+			return
+		}
+		histogram = &hv
+	case metrics.Summary:
+		err = mh.storage.SetSummary(req.Context(), key, *mt.Summary)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sv, erro := mh.storage.GetSummary(req.Context(), key)
+		if erro != nil {
+			http.Error(res, erro.Error(), http.StatusInternalServerError)
+			return
+		}
+		summary = &sv
 	}
 
-	resBody := metrics.Metric{MetricName: metrics.MetricName{ID: mt.ID, MType: mt.MType}, Value: value, Delta: delta}
+	resBody := metrics.Metric{
+		MetricName: mt.MetricName,
+		Value:      value,
+		Delta:      delta,
+		Histogram:  histogram,
+		Summary:    summary,
+	}
 	out, err := json.Marshal(resBody)
 	if err != nil {
 		log.Fatal(err)
@@ -20,15 +20,25 @@ import (
 func TestUpdateMetrics_updateJSONMetrics(t *testing.T) {
 	value := 11.1
 	delta := int64(11)
+	histogram := metrics.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 1, 0}, Sum: 0.6, Count: 2}
+	brokenHistogram := metrics.HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1}, Count: 1}
+	summary := metrics.SummaryValue{Quantiles: []metrics.Quantile{{Quantile: 0.99, Value: 0.5}}, Sum: 1, Count: 3}
 	tests := []struct {
 		status int
 		name   string
 		body   metrics.Metric
 	}{
+		{name: "response 200 for histogram", body: metrics.Metric{MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram}, Histogram: &histogram}, status: http.StatusOK},
+		{name: "response 200 for summary", body: metrics.Metric{MetricName: metrics.MetricName{ID: "duration", MType: metrics.Summary}, Summary: &summary}, status: http.StatusOK},
+		{name: "histogram without value", body: metrics.Metric{MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram}}, status: http.StatusBadRequest},
+		{name: "broken histogram", body: metrics.Metric{MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram}, Histogram: &brokenHistogram}, status: http.StatusBadRequest},
 		{name: "response 200 for gauge", body: metrics.Metric{MetricName: metrics.MetricName{ID: agent.Alloc, MType: agent.Gauge}, Value: &value}, status: http.StatusOK},
 		{name: "response 200 for counter", body: metrics.Metric{MetricName: metrics.MetricName{ID: agent.Frees, MType: agent.Counter}, Delta: &delta}, status: http.StatusOK},
 		{name: "wrong type", body: metrics.Metric{MetricName: metrics.MetricName{ID: agent.Alloc, MType: "wrong"}, Delta: &delta}, status: http.StatusBadRequest},
 		{name: "without name", body: metrics.Metric{MetricName: metrics.MetricName{ID: "", MType: agent.Gauge}, Value: &value}, status: http.StatusNotFound},
+		{name: "response 200 for labelled gauge", body: metrics.Metric{MetricName: metrics.MetricName{ID: agent.Alloc, MType: agent.Gauge, Labels: map[string]string{"host": "a"}}, Value: &value}, status: http.StatusOK},
+		{name: "wrong label name", body: metrics.Metric{MetricName: metrics.MetricName{ID: agent.Alloc, MType: agent.Gauge, Labels: map[string]string{"host name": "a"}}, Value: &value}, status: http.StatusBadRequest},
+		{name: "braces in name", body: metrics.Metric{MetricName: metrics.MetricName{ID: `Alloc{host="a"}`, MType: agent.Gauge}, Value: &value}, status: http.StatusBadRequest},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -105,7 +115,6 @@ func ExampleMetricsHandler_PostMetricUpdateJSON() {
 	}
 
 	// Output:
-	// Alloc	gauge	12345.670000
 	// Status Code: 200
 	// Updated Gauge Alloc: 12345.67
 }
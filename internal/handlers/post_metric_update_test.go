@@ -28,6 +28,8 @@ func TestUpdateMetrics_updateMetrics(t *testing.T) {
 		{name: "without name", tpMet: agent.Gauge, nameMet: "", valMet: "11", status: http.StatusNotFound},
 		{name: "value for gauge not float", tpMet: agent.Gauge, nameMet: agent.Frees, valMet: "str", status: http.StatusBadRequest},
 		{name: "value for counter not int", tpMet: agent.Counter, nameMet: agent.Alloc, valMet: "11.1", status: http.StatusBadRequest},
+		{name: "response 200 for labelled gauge", tpMet: agent.Gauge, nameMet: agent.Alloc, valMet: "11.1?host=a", status: http.StatusOK},
+		{name: "wrong label name", tpMet: agent.Gauge, nameMet: agent.Alloc, valMet: "11.1?host-name=a", status: http.StatusBadRequest},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -94,7 +96,6 @@ func ExampleMetricsHandler_PostMetricUpdate() {
 	}
 
 	// Output:
-	// Alloc	12345.67	gauge
 	// Status Code: 200
 	// Updated Gauge Alloc: 12345.67
 }
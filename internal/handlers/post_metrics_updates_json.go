@@ -20,35 +20,69 @@ func (mh *MetricsHandler) PostMetricsUpdatesJSON(res http.ResponseWriter, req *h
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 	if mh.logger != nil {
 		mh.logger.Infoln(string(body))
 	}
 	if err = json.Unmarshal(body, &mt); err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
 	}
 	if len(mt) == 0 {
 		http.Error(res, "no metrics found", http.StatusBadRequest)
+		return
 	}
 	for _, m := range mt {
 		if err = m.Validate(); err != nil {
 			if errors.Is(err, metrics.ErrNotFound) {
 				http.Error(res, err.Error(), http.StatusNotFound)
+				return
 			}
 			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
 	mtGauge := make(map[string]float64)
 	mtCounter := make(map[string]int64)
+	mtHistogram := make(map[string]metrics.HistogramValue)
+	mtSummary := make(map[string]metrics.SummaryValue)
 	for _, m := range mt {
-		if m.MType == metrics.Gauge {
-			mtGauge[m.ID] = *m.Value
-		} else {
-			mtCounter[m.ID] += *m.Delta
+		key := m.Key()
+		switch m.MType {
+		case metrics.Gauge:
+			mtGauge[key] = *m.Value
+		case metrics.Counter:
+			mtCounter[key] += *m.Delta
+		case metrics.Histogram:
+			current, ok := mtHistogram[key]
+			if !ok {
+				mtHistogram[key] = m.Histogram.Clone()
+				continue
+			}
+			if err = current.Merge(*m.Histogram); err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mtHistogram[key] = current
+		case metrics.Summary:
+			mtSummary[key] = *m.Summary
 		}
 	}
 	err = mh.storage.SetMetrics(req.Context(), mtCounter, mtGauge)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(mtHistogram) == 0 && len(mtSummary) == 0 {
+		return
+	}
+	err = mh.storage.SetDistributions(req.Context(), mtHistogram, mtSummary)
+	if err != nil {
+		if errors.Is(err, metrics.ErrWrongValue) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -75,6 +75,95 @@ func TestMetricsHandler_UpdatesJSONMetrics(t *testing.T) {
 	}
 }
 
+func TestMetricsHandler_UpdatesJSONDistributions(t *testing.T) {
+	memStorage := storage.NewMemStorage()
+	mh := NewMetricsHandler(memStorage, nil, nil)
+
+	body := []metrics.Metric{
+		{
+			MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram},
+			Histogram:  &metrics.HistogramValue{Bounds: []float64{0.5}, Counts: []uint64{1, 0}, Sum: 0.2, Count: 1},
+		},
+		{
+			MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram},
+			Histogram:  &metrics.HistogramValue{Bounds: []float64{0.5}, Counts: []uint64{0, 2}, Sum: 3, Count: 2},
+		},
+		{
+			MetricName: metrics.MetricName{ID: "duration", MType: metrics.Summary},
+			Summary:    &metrics.SummaryValue{Quantiles: []metrics.Quantile{{Quantile: 0.95, Value: 1.5}}, Sum: 4, Count: 3},
+		},
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	mh.PostMetricsUpdatesJSON(rec, httptest.NewRequest("POST", "/updates/", bytes.NewBuffer(out)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	histogram, err := memStorage.GetHistogram(context.Background(), "latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if histogram.Count != 3 || histogram.Counts[1] != 2 {
+		t.Errorf("unexpected histogram: %+v", histogram)
+	}
+	summary, err := memStorage.GetSummary(context.Background(), "duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Quantiles[0].Value != 1.5 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	body = []metrics.Metric{{
+		MetricName: metrics.MetricName{ID: "latency", MType: metrics.Histogram},
+		Histogram:  &metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1},
+	}}
+	out, err = json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	mh.PostMetricsUpdatesJSON(rec, httptest.NewRequest("POST", "/updates/", bytes.NewBuffer(out)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for mismatched bounds, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestMetricsHandler_UpdatesJSONLabels(t *testing.T) {
+	memStorage := storage.NewMemStorage()
+	mh := NewMetricsHandler(memStorage, nil, nil)
+
+	delta := int64(2)
+	body := []metrics.Metric{
+		{MetricName: metrics.MetricName{ID: "requests", MType: metrics.Counter, Labels: map[string]string{"code": "200"}}, Delta: &delta},
+		{MetricName: metrics.MetricName{ID: "requests", MType: metrics.Counter, Labels: map[string]string{"code": "500"}}, Delta: &delta},
+		{MetricName: metrics.MetricName{ID: "requests", MType: metrics.Counter, Labels: map[string]string{"code": "200"}}, Delta: &delta},
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	mh.PostMetricsUpdatesJSON(rec, httptest.NewRequest("POST", "/updates/", bytes.NewBuffer(out)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	counter, _, err := memStorage.GetMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{`requests{code="200"}`: 4, `requests{code="500"}`: 2}
+	if len(counter) != len(want) || counter[`requests{code="200"}`] != 4 || counter[`requests{code="500"}`] != 2 {
+		t.Errorf("expected %v, got %v", want, counter)
+	}
+}
+
 func ExampleMetricsHandler_PostMetricsUpdatesJSON() {
 	// Инициализируем хранилище.
 	memStorage := storage.NewMemStorage()
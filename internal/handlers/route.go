@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/pkg/crypt"
 	"github.com/moonicy/gometrics/pkg/middlewares"
 )
 
@@ -18,15 +19,21 @@ type MetricsHandlers interface {
 	PostMetricUpdate(res http.ResponseWriter, req *http.Request)
 	PostMetricsUpdatesJSON(res http.ResponseWriter, req *http.Request)
 	GetPing(res http.ResponseWriter, req *http.Request)
+	GetPrometheusMetrics(res http.ResponseWriter, req *http.Request)
+	GetQuery(res http.ResponseWriter, req *http.Request)
+	GetAlerts(res http.ResponseWriter, req *http.Request)
 }
 
 // NewRoute создаёт и настраивает новый маршрутизатор chi.Mux с необходимыми маршрутами и middleware.
-// Он принимает MetricsHandler для обработки HTTP-запросов метрик, логгер и конфигурацию сервера.
-func NewRoute(mh MetricsHandlers, log *zap.SugaredLogger, cfg config.ServerConfig) *chi.Mux {
+// Он принимает MetricsHandler для обработки HTTP-запросов метрик, логгер, конфигурацию сервера
+// и набор ключей для расшифровки запросов.
+func NewRoute(mh MetricsHandlers, log *zap.SugaredLogger, cfg config.ServerConfig, keyring *crypt.Keyring) *chi.Mux {
 	router := chi.NewRouter()
+	// Эндпоинт для сбора метрик Prometheus не шифрует ответ и не требует подписи.
+	router.With(middlewares.GzipMiddleware, middlewares.WithLogging(log)).Get("/metrics", mh.GetPrometheusMetrics)
 	router.Route("/", func(r chi.Router) {
 		r.Use(middleware.StripSlashes)
-		r.Use(middlewares.CryptMiddleware("", cfg.CryptoKey))
+		r.Use(middlewares.CryptMiddleware("", keyring))
 		r.Use(middlewares.GzipMiddleware)
 		r.Use(middlewares.WithLogging(log))
 		r.Use(middlewares.SignCheckMiddleware(cfg.HashKey))
@@ -44,6 +51,8 @@ func NewRoute(mh MetricsHandlers, log *zap.SugaredLogger, cfg config.ServerConfi
 			r.Post("/", mh.PostMetricsUpdatesJSON)
 		})
 		r.Get("/ping", mh.GetPing)
+		r.Get("/query", mh.GetQuery)
+		r.Get("/alerts", mh.GetAlerts)
 	})
 
 	return router
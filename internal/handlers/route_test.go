@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/pkg/crypt"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -33,6 +36,16 @@ func (m *MockMetricsHandler) PostMetricsUpdatesJSON(w http.ResponseWriter, _ *ht
 func (m *MockMetricsHandler) GetPing(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
+func (m *MockMetricsHandler) GetQuery(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+func (m *MockMetricsHandler) GetAlerts(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+func (m *MockMetricsHandler) GetPrometheusMetrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", PrometheusContentType)
+	_, _ = w.Write([]byte("# TYPE up gauge\nup 1\n"))
+}
 
 func TestNewRoute(t *testing.T) {
 	mh := &MockMetricsHandler{}
@@ -45,7 +58,7 @@ func TestNewRoute(t *testing.T) {
 		TrustedSubnet: "192.168.1.0/24",
 	}
 
-	router := NewRoute(mh, log, cfg)
+	router := NewRoute(mh, log, cfg, crypt.NewKeyring(cfg.CryptoKeys()...))
 
 	tests := []struct {
 		method     string
@@ -59,6 +72,9 @@ func TestNewRoute(t *testing.T) {
 		{method: "POST", target: "/update/gauge/example/100", statusCode: http.StatusOK},
 		{method: "POST", target: "/updates", statusCode: http.StatusForbidden},
 		{method: "GET", target: "/ping", statusCode: http.StatusOK},
+		{method: "GET", target: "/metrics", statusCode: http.StatusOK},
+		{method: "GET", target: "/query?name=Alloc&type=gauge", statusCode: http.StatusOK},
+		{method: "GET", target: "/alerts", statusCode: http.StatusOK},
 	}
 
 	for _, tt := range tests {
@@ -73,3 +89,65 @@ func TestNewRoute(t *testing.T) {
 		assert.Equal(t, tt.statusCode, resp.StatusCode, "Expected status code %d for %s %s, got %d", tt.statusCode, tt.method, tt.target, resp.StatusCode)
 	}
 }
+
+// bodyMetricsHandler запоминает тела запросов на запись метрик.
+type bodyMetricsHandler struct {
+	MockMetricsHandler
+	bodies []string
+}
+
+func (m *bodyMetricsHandler) record(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	m.bodies = append(m.bodies, string(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *bodyMetricsHandler) PostMetricUpdateJSON(w http.ResponseWriter, r *http.Request) {
+	m.record(w, r)
+}
+
+func (m *bodyMetricsHandler) PostMetricsUpdatesJSON(w http.ResponseWriter, r *http.Request) {
+	m.record(w, r)
+}
+
+func (m *bodyMetricsHandler) GetMetricValueByNameJSON(w http.ResponseWriter, r *http.Request) {
+	m.record(w, r)
+}
+
+func TestNewRoute_EmptyKeyring(t *testing.T) {
+	mh := &bodyMetricsHandler{}
+	log := zap.NewExample().Sugar()
+	defer log.Sync()
+
+	// Сервер без закрытых ключей принимает незашифрованные запросы.
+	router := NewRoute(mh, log, config.ServerConfig{}, crypt.NewKeyring())
+
+	body := `{"id":"Alloc","type":"gauge","value":1}`
+	for _, target := range []string{"/update", "/updates", "/value"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, target)
+	}
+	assert.Equal(t, []string{body, body, body}, mh.bodies)
+}
+
+func TestNewRoute_PrometheusNotEncrypted(t *testing.T) {
+	log := zap.NewExample().Sugar()
+	defer log.Sync()
+
+	router := NewRoute(&MockMetricsHandler{}, log, config.ServerConfig{CryptoKey: "test-crypto-key"}, crypt.NewKeyring("test-crypto-key"))
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, PrometheusContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "# TYPE up gauge\nup 1\n", w.Body.String())
+}
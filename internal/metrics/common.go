@@ -5,6 +5,10 @@ const (
 	Gauge = "gauge"
 	// Counter представляет тип метрики counter.
 	Counter = "counter"
+	// Histogram представляет тип метрики histogram.
+	Histogram = "histogram"
+	// Summary представляет тип метрики summary.
+	Summary = "summary"
 	// MName используется как ключ для имени метрики.
 	MName = "name"
 	// MType используется как ключ для типа метрики.
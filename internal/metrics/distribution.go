@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// HistogramValue содержит значение метрики типа histogram.
+// Counts содержит количество наблюдений в каждой корзине: len(Counts) == len(Bounds)+1,
+// последняя корзина соответствует верхней границе +Inf.
+type HistogramValue struct {
+	Bounds []float64 `json:"bounds"` // верхние границы корзин в порядке возрастания
+	Counts []uint64  `json:"counts"` // количество наблюдений в каждой корзине
+	Sum    float64   `json:"sum"`    // сумма всех наблюдений
+	Count  uint64    `json:"count"`  // общее количество наблюдений
+}
+
+// Quantile содержит значение одного квантиля метрики типа summary.
+type Quantile struct {
+	Quantile float64 `json:"quantile"` // квантиль в диапазоне [0, 1]
+	Value    float64 `json:"value"`    // значение квантиля
+}
+
+// SummaryValue содержит значение метрики типа summary.
+type SummaryValue struct {
+	Quantiles []Quantile `json:"quantiles"` // рассчитанные квантили
+	Sum       float64    `json:"sum"`       // сумма всех наблюдений
+	Count     uint64     `json:"count"`     // общее количество наблюдений
+}
+
+// NewHistogramValue создаёт пустую гистограмму с заданными границами корзин.
+func NewHistogramValue(bounds []float64) HistogramValue {
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	sort.Float64s(b)
+	return HistogramValue{Bounds: b, Counts: make([]uint64, len(b)+1)}
+}
+
+// Observe добавляет наблюдение в соответствующую корзину гистограммы.
+func (h *HistogramValue) Observe(v float64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// Validate проверяет согласованность границ корзин и счётчиков гистограммы.
+func (h HistogramValue) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return ErrWrongValue
+	}
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || (i > 0 && b <= h.Bounds[i-1]) {
+			return ErrWrongValue
+		}
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != h.Count {
+		return ErrWrongValue
+	}
+	return nil
+}
+
+// SameBounds сообщает, совпадают ли границы корзин гистограмм.
+func (h HistogramValue) SameBounds(other HistogramValue) bool {
+	if len(h.Bounds) != len(other.Bounds) {
+		return false
+	}
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge прибавляет к гистограмме наблюдения из other.
+// Границы корзин обеих гистограмм должны совпадать, иначе возвращается ErrWrongValue.
+func (h *HistogramValue) Merge(other HistogramValue) error {
+	if !h.SameBounds(other) {
+		return ErrWrongValue
+	}
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return nil
+}
+
+// Clone возвращает глубокую копию гистограммы.
+func (h HistogramValue) Clone() HistogramValue {
+	c := h
+	c.Bounds = append([]float64(nil), h.Bounds...)
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
+
+// Validate проверяет, что все квантили находятся в диапазоне [0, 1].
+func (s SummaryValue) Validate() error {
+	for _, q := range s.Quantiles {
+		if math.IsNaN(q.Quantile) || q.Quantile < 0 || q.Quantile > 1 {
+			return ErrWrongValue
+		}
+	}
+	return nil
+}
+
+// Clone возвращает глубокую копию summary.
+func (s SummaryValue) Clone() SummaryValue {
+	c := s
+	c.Quantiles = append([]Quantile(nil), s.Quantiles...)
+	return c
+}
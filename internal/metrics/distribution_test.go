@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramValue_Observe(t *testing.T) {
+	h := NewHistogramValue([]float64{1, 0.1, 10})
+	assert.Equal(t, []float64{0.1, 1, 10}, h.Bounds)
+
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(5)
+	h.Observe(100)
+
+	assert.Equal(t, []uint64{2, 0, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.InDelta(t, 105.15, h.Sum, 1e-9)
+	assert.NoError(t, h.Validate())
+}
+
+func TestHistogramValue_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   HistogramValue
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			value:   HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 1, 1}, Sum: 4, Count: 3},
+			wantErr: nil,
+		},
+		{
+			name:    "counts length mismatch",
+			value:   HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 1}, Count: 2},
+			wantErr: ErrWrongValue,
+		},
+		{
+			name:    "bounds not increasing",
+			value:   HistogramValue{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}},
+			wantErr: ErrWrongValue,
+		},
+		{
+			name:    "count mismatch",
+			value:   HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 5},
+			wantErr: ErrWrongValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantErr, tc.value.Validate())
+		})
+	}
+}
+
+func TestHistogramValue_Merge(t *testing.T) {
+	h := HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 1}, Sum: 3.5, Count: 2}
+
+	err := h.Merge(HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{0, 2, 0}, Sum: 3, Count: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 1}, h.Counts)
+	assert.Equal(t, 6.5, h.Sum)
+	assert.Equal(t, uint64(4), h.Count)
+
+	err = h.Merge(HistogramValue{Bounds: []float64{1, 3}, Counts: []uint64{0, 0, 0}})
+	assert.Equal(t, ErrWrongValue, err)
+
+	err = h.Merge(HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 0}})
+	assert.Equal(t, ErrWrongValue, err)
+}
+
+func TestHistogramValue_Clone(t *testing.T) {
+	h := HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}
+	c := h.Clone()
+	c.Counts[0] = 10
+	c.Bounds[0] = 5
+
+	assert.Equal(t, uint64(1), h.Counts[0])
+	assert.Equal(t, 1.0, h.Bounds[0])
+}
+
+func TestSummaryValue_Validate(t *testing.T) {
+	valid := SummaryValue{Quantiles: []Quantile{{Quantile: 0.5, Value: 1}, {Quantile: 1, Value: 2}}}
+	assert.NoError(t, valid.Validate())
+
+	invalid := SummaryValue{Quantiles: []Quantile{{Quantile: 1.5, Value: 1}}}
+	assert.Equal(t, ErrWrongValue, invalid.Validate())
+}
+
+func TestSummaryValue_Clone(t *testing.T) {
+	s := SummaryValue{Quantiles: []Quantile{{Quantile: 0.5, Value: 1}}, Sum: 1, Count: 1}
+	c := s.Clone()
+	c.Quantiles[0].Value = 100
+
+	assert.Equal(t, 1.0, s.Quantiles[0].Value)
+}
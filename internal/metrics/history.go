@@ -0,0 +1,39 @@
+package metrics
+
+import "time"
+
+// Sample содержит значение метрики в момент времени.
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"` // время записи значения
+	Value     float64   `json:"value"`     // значение gauge или накопленное значение counter
+}
+
+// Series содержит временной ряд значений метрики.
+type Series struct {
+	MetricName
+	Samples []Sample `json:"samples"` // значения, упорядоченные по времени
+}
+
+// Downsample прореживает упорядоченные по времени значения с шагом step.
+// Для каждой точки t = from + k*step, не превосходящей to, берётся последнее значение из интервала (t-step, t].
+// Точки, на интервале которых нет значений, пропускаются.
+func Downsample(samples []Sample, from, to time.Time, step time.Duration) []Sample {
+	result := make([]Sample, 0)
+	if step <= 0 {
+		return result
+	}
+	i := 0
+	for t := from; !t.After(to); t = t.Add(step) {
+		var last *Sample
+		for i < len(samples) && !samples[i].Timestamp.After(t) {
+			if samples[i].Timestamp.After(t.Add(-step)) {
+				last = &samples[i]
+			}
+			i++
+		}
+		if last != nil {
+			result = append(result, Sample{Timestamp: t, Value: last.Value})
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownsample(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+	samples := []Sample{
+		{Timestamp: at(0), Value: 1},
+		{Timestamp: at(3), Value: 2},
+		{Timestamp: at(9), Value: 3},
+		{Timestamp: at(10), Value: 4},
+		{Timestamp: at(25), Value: 5},
+	}
+
+	got := Downsample(samples, at(0), at(30), 10*time.Second)
+
+	assert.Equal(t, []Sample{
+		{Timestamp: at(0), Value: 1},
+		{Timestamp: at(10), Value: 4},
+		{Timestamp: at(30), Value: 5},
+	}, got)
+}
+
+func TestDownsample_Empty(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, Downsample(nil, base, base.Add(time.Minute), time.Second))
+	assert.Empty(t, Downsample([]Sample{{Timestamp: base, Value: 1}}, base, base, 0))
+}
+
+func TestIncreaseAndRate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Timestamp: base, Value: 100},
+		{Timestamp: base.Add(10 * time.Second), Value: 120},
+		{Timestamp: base.Add(20 * time.Second), Value: 5},
+	}
+
+	assert.Equal(t, 25.0, Increase(samples))
+	value, ok := Rate(samples)
+	assert.True(t, ok)
+	assert.Equal(t, 25.0/20, value)
+
+	_, ok = Rate(samples[:1])
+	assert.False(t, ok)
+}
+
+func TestCounterFunc_Validate(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, CounterFunc{Func: FuncRate, Window: "1m"}.Validate())
+	assert.NoError(t, CounterFunc{Func: FuncIncrease, Window: "5m"}.Validate())
+	assert.NoError(t, CounterFunc{Func: FuncIncrease, Since: &since}.Validate())
+
+	for _, cf := range []CounterFunc{
+		{Func: "avg", Window: "1m"},
+		{Func: FuncRate},
+		{Func: FuncRate, Since: &since},
+		{Func: FuncRate, Window: "-1m"},
+		{Func: FuncIncrease},
+		{Func: FuncIncrease, Window: "1m", Since: &since},
+	} {
+		assert.ErrorIs(t, cf.Validate(), ErrWrongFunc, "%+v", cf)
+	}
+}
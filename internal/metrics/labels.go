@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Операторы сравнения меток в селекторах.
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// LabelMatcher описывает условие на значение одной метки.
+type LabelMatcher struct {
+	Name  string `json:"name"`  // имя метки
+	Op    string `json:"op"`    // оператор: =, !=, =~ или !~
+	Value string `json:"value"` // значение или регулярное выражение
+	re    *regexp.Regexp
+}
+
+// Key возвращает ключ временного ряда: имя метрики вместе с набором меток.
+func (mn MetricName) Key() string {
+	return SeriesKey(mn.ID, mn.Labels)
+}
+
+// SeriesKey формирует ключ временного ряда вида name{a="1",b="2"}.
+// Метки сортируются по имени, поэтому ключ не зависит от порядка меток.
+// Для метрики без меток ключ совпадает с её именем.
+func SeriesKey(id string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return id
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(id)
+	b.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesKey разбирает ключ временного ряда, сформированный SeriesKey, на имя метрики и набор меток.
+func ParseSeriesKey(key string) (string, map[string]string, error) {
+	id, matchers, err := ParseSelector(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(matchers) == 0 {
+		return id, nil, nil
+	}
+	labels := make(map[string]string, len(matchers))
+	for _, m := range matchers {
+		if m.Op != MatchEqual {
+			return "", nil, ErrWrongLabels
+		}
+		labels[m.Name] = m.Value
+	}
+	return id, labels, nil
+}
+
+// ParseSelector разбирает селектор вида name{a="1",b=~"x.*"} на имя метрики и список условий на метки.
+func ParseSelector(selector string) (string, []LabelMatcher, error) {
+	selector = strings.TrimSpace(selector)
+	open := strings.IndexByte(selector, '{')
+	if open < 0 {
+		if selector == "" || strings.ContainsAny(selector, "{}") {
+			return "", nil, ErrWrongLabels
+		}
+		return selector, nil, nil
+	}
+	id := strings.TrimSpace(selector[:open])
+	if id == "" || !strings.HasSuffix(selector, "}") {
+		return "", nil, ErrWrongLabels
+	}
+	body := selector[open+1 : len(selector)-1]
+
+	var matchers []LabelMatcher
+	for {
+		body = strings.TrimLeft(body, " ,")
+		if body == "" {
+			break
+		}
+		i := strings.IndexAny(body, "=!")
+		if i <= 0 {
+			return "", nil, ErrWrongLabels
+		}
+		name := strings.TrimSpace(body[:i])
+		rest := body[i:]
+		var op string
+		for _, candidate := range []string{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+			if strings.HasPrefix(rest, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return "", nil, ErrWrongLabels
+		}
+		rest = strings.TrimLeft(rest[len(op):], " ")
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return "", nil, ErrWrongLabels
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", nil, ErrWrongLabels
+		}
+		m := LabelMatcher{Name: name, Op: op, Value: value}
+		if err = m.Compile(); err != nil {
+			return "", nil, err
+		}
+		matchers = append(matchers, m)
+		body = rest[len(quoted):]
+	}
+	return id, matchers, nil
+}
+
+// ValidateLabels проверяет, что имена меток допустимы.
+func ValidateLabels(labels map[string]string) error {
+	for k := range labels {
+		if !labelNameRe.MatchString(k) {
+			return ErrWrongLabels
+		}
+	}
+	return nil
+}
+
+// Compile проверяет условие и подготавливает регулярное выражение для операторов =~ и !~.
+func (lm *LabelMatcher) Compile() error {
+	if !labelNameRe.MatchString(lm.Name) {
+		return ErrWrongLabels
+	}
+	switch lm.Op {
+	case MatchEqual, MatchNotEqual:
+		return nil
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + lm.Value + ")$")
+		if err != nil {
+			return ErrWrongLabels
+		}
+		lm.re = re
+		return nil
+	}
+	return ErrWrongLabels
+}
+
+// Matches проверяет, удовлетворяет ли набор меток условию.
+// Отсутствующая метка считается равной пустой строке.
+func (lm *LabelMatcher) Matches(labels map[string]string) bool {
+	value := labels[lm.Name]
+	switch lm.Op {
+	case MatchEqual:
+		return value == lm.Value
+	case MatchNotEqual:
+		return value != lm.Value
+	case MatchRegexp, MatchNotRegexp:
+		if lm.re == nil && lm.Compile() != nil {
+			return false
+		}
+		return lm.re.MatchString(value) == (lm.Op == MatchRegexp)
+	}
+	return false
+}
+
+// MatchAll проверяет, удовлетворяет ли набор меток всем условиям.
+func MatchAll(matchers []LabelMatcher, labels map[string]string) bool {
+	for i := range matchers {
+		if !matchers[i].Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// MetricQuery описывает запрос значений метрики с условиями на метки.
+// Если условия не заданы, запрос адресует единственный временной ряд с метками Labels.
+// Для counter можно запросить вычисление функции (rate, increase) над историей значений.
+type MetricQuery struct {
+	MetricName
+	Matchers []LabelMatcher `json:"matchers,omitempty"` // условия на метки
+	CounterFunc
+}
+
+// Validate проверяет корректность запроса и подготавливает условия на метки.
+func (mq *MetricQuery) Validate() error {
+	if err := mq.MetricName.Validate(); err != nil {
+		return err
+	}
+	if mq.Func != "" || mq.Window != "" || mq.Since != nil {
+		if mq.MType != Counter || len(mq.Matchers) > 0 {
+			return ErrWrongFunc
+		}
+		if err := mq.CounterFunc.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range mq.Matchers {
+		if err := mq.Matchers[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
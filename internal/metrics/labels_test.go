@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesKey(t *testing.T) {
+	assert.Equal(t, "Alloc", SeriesKey("Alloc", nil))
+	assert.Equal(t, `CPU{host="a",service="api"}`, SeriesKey("CPU", map[string]string{"service": "api", "host": "a"}))
+	assert.Equal(t, `CPU{path="/a\"b"}`, SeriesKey("CPU", map[string]string{"path": `/a"b`}))
+	assert.Equal(t, `CPU{host="a"}`, MetricName{ID: "CPU", Labels: map[string]string{"host": "a"}}.Key())
+}
+
+func TestParseSeriesKey(t *testing.T) {
+	labels := map[string]string{"host": "a", "path": `/x{y}"z`}
+	id, got, err := ParseSeriesKey(SeriesKey("CPU", labels))
+	assert.NoError(t, err)
+	assert.Equal(t, "CPU", id)
+	assert.Equal(t, labels, got)
+
+	id, got, err = ParseSeriesKey("Alloc")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alloc", id)
+	assert.Nil(t, got)
+
+	_, _, err = ParseSeriesKey(`CPU{host=~"a"}`)
+	assert.ErrorIs(t, err, ErrWrongLabels)
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		id       string
+		matchers []LabelMatcher
+		wantErr  bool
+	}{
+		{name: "plain name", selector: "HeapAlloc", id: "HeapAlloc"},
+		{name: "empty labels", selector: "HeapAlloc{}", id: "HeapAlloc"},
+		{
+			name:     "all operators",
+			selector: `CPU{host="a", dc!="b",service=~"api|web",env!~"dev.*"}`,
+			id:       "CPU",
+			matchers: []LabelMatcher{
+				{Name: "host", Op: MatchEqual, Value: "a"},
+				{Name: "dc", Op: MatchNotEqual, Value: "b"},
+				{Name: "service", Op: MatchRegexp, Value: "api|web"},
+				{Name: "env", Op: MatchNotRegexp, Value: "dev.*"},
+			},
+		},
+		{name: "empty", selector: "", wantErr: true},
+		{name: "unclosed", selector: `CPU{host="a"`, wantErr: true},
+		{name: "unquoted value", selector: `CPU{host=a}`, wantErr: true},
+		{name: "bad label name", selector: `CPU{1host="a"}`, wantErr: true},
+		{name: "bad regexp", selector: `CPU{host=~"("}`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			id, matchers, err := ParseSelector(tc.selector)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrWrongLabels)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.id, id)
+			assert.Len(t, matchers, len(tc.matchers))
+			for i := range tc.matchers {
+				assert.Equal(t, tc.matchers[i].Name, matchers[i].Name)
+				assert.Equal(t, tc.matchers[i].Op, matchers[i].Op)
+				assert.Equal(t, tc.matchers[i].Value, matchers[i].Value)
+			}
+		})
+	}
+}
+
+func TestLabelMatcher_Matches(t *testing.T) {
+	labels := map[string]string{"host": "web-1", "env": "prod"}
+
+	tests := []struct {
+		name    string
+		matcher LabelMatcher
+		want    bool
+	}{
+		{name: "equal", matcher: LabelMatcher{Name: "host", Op: MatchEqual, Value: "web-1"}, want: true},
+		{name: "not equal", matcher: LabelMatcher{Name: "host", Op: MatchNotEqual, Value: "web-1"}, want: false},
+		{name: "regexp", matcher: LabelMatcher{Name: "host", Op: MatchRegexp, Value: "web-.*"}, want: true},
+		{name: "regexp is anchored", matcher: LabelMatcher{Name: "host", Op: MatchRegexp, Value: "web"}, want: false},
+		{name: "not regexp", matcher: LabelMatcher{Name: "env", Op: MatchNotRegexp, Value: "dev|test"}, want: true},
+		{name: "missing label equals empty", matcher: LabelMatcher{Name: "dc", Op: MatchEqual, Value: ""}, want: true},
+		{name: "unknown operator", matcher: LabelMatcher{Name: "host", Op: ">", Value: "a"}, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.matcher.Matches(labels))
+		})
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	labels := map[string]string{"host": "a", "env": "prod"}
+	matchers := []LabelMatcher{
+		{Name: "host", Op: MatchEqual, Value: "a"},
+		{Name: "env", Op: MatchRegexp, Value: "prod|stage"},
+	}
+	assert.True(t, MatchAll(matchers, labels))
+	assert.True(t, MatchAll(nil, labels))
+	assert.False(t, MatchAll(append(matchers, LabelMatcher{Name: "env", Op: MatchNotEqual, Value: "prod"}), labels))
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, ValidateLabels(map[string]string{"host": "a", "_x1": ""}))
+	assert.ErrorIs(t, ValidateLabels(map[string]string{"bad-name": "a"}), ErrWrongLabels)
+}
+
+func TestMetricQuery_Validate(t *testing.T) {
+	mq := MetricQuery{
+		MetricName: MetricName{ID: "CPU", MType: Gauge},
+		Matchers:   []LabelMatcher{{Name: "host", Op: MatchRegexp, Value: "a|b"}},
+	}
+	assert.NoError(t, mq.Validate())
+	assert.True(t, MatchAll(mq.Matchers, map[string]string{"host": "b"}))
+
+	mq.Matchers = []LabelMatcher{{Name: "host", Op: "~", Value: "a"}}
+	assert.ErrorIs(t, mq.Validate(), ErrWrongLabels)
+
+	mq = MetricQuery{MetricName: MetricName{ID: "CPU", MType: "unknown"}}
+	assert.ErrorIs(t, mq.Validate(), ErrUnknownMetric)
+}
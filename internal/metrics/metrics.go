@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"errors"
+	"strings"
 )
 
 // ErrUnknownMetric возвращается, когда тип метрики неизвестен.
@@ -13,10 +14,15 @@ var ErrNotFound = errors.New("not found")
 // ErrWrongValue возвращается, когда значение метрики некорректно.
 var ErrWrongValue = errors.New("wrong value")
 
+// ErrWrongLabels возвращается, когда имя метрики, набор меток или селектор некорректны.
+var ErrWrongLabels = errors.New("wrong labels")
+
 // MetricName представляет имя и тип метрики.
 type MetricName struct {
 	ID    string `json:"id"`   // имя метрики
-	MType string `json:"type"` // параметр, принимающий значение gauge или counter
+	MType string `json:"type"` // параметр, принимающий значение gauge, counter, histogram или summary
+	// Labels содержит набор меток, который вместе с именем определяет временной ряд.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // Metric содержит данные метрики, включая её значение.
@@ -24,6 +30,10 @@ type Metric struct {
 	MetricName
 	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	// Histogram содержит значение метрики в случае передачи histogram.
+	Histogram *HistogramValue `json:"histogram,omitempty"`
+	// Summary содержит значение метрики в случае передачи summary.
+	Summary *SummaryValue `json:"summary,omitempty"`
 }
 
 // Validate проверяет корректность полей структуры MetricName.
@@ -31,7 +41,15 @@ func (mn MetricName) Validate() error {
 	if mn.ID == "" {
 		return ErrNotFound
 	}
-	if mn.MType != Gauge && mn.MType != Counter {
+	if strings.ContainsAny(mn.ID, "{}") {
+		return ErrWrongLabels
+	}
+	if err := ValidateLabels(mn.Labels); err != nil {
+		return err
+	}
+	switch mn.MType {
+	case Gauge, Counter, Histogram, Summary:
+	default:
 		return ErrUnknownMetric
 	}
 	return nil
@@ -48,5 +66,17 @@ func (m Metric) Validate() error {
 	if m.MType == Counter && m.Delta == nil {
 		return ErrWrongValue
 	}
+	if m.MType == Histogram {
+		if m.Histogram == nil {
+			return ErrWrongValue
+		}
+		return m.Histogram.Validate()
+	}
+	if m.MType == Summary {
+		if m.Summary == nil {
+			return ErrWrongValue
+		}
+		return m.Summary.Validate()
+	}
 	return nil
 }
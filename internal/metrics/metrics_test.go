@@ -22,6 +22,31 @@ func TestMetricName_Validate(t *testing.T) {
 			metricName: MetricName{ID: "testMetric", MType: "counter"},
 			wantErr:    nil,
 		},
+		{
+			name:       "Valid histogram metric name",
+			metricName: MetricName{ID: "testMetric", MType: "histogram"},
+			wantErr:    nil,
+		},
+		{
+			name:       "Valid summary metric name",
+			metricName: MetricName{ID: "testMetric", MType: "summary"},
+			wantErr:    nil,
+		},
+		{
+			name:       "Valid metric name with labels",
+			metricName: MetricName{ID: "testMetric", MType: "gauge", Labels: map[string]string{"host": "a"}},
+			wantErr:    nil,
+		},
+		{
+			name:       "Invalid label name",
+			metricName: MetricName{ID: "testMetric", MType: "gauge", Labels: map[string]string{"host-name": "a"}},
+			wantErr:    ErrWrongLabels,
+		},
+		{
+			name:       "ID with braces",
+			metricName: MetricName{ID: `testMetric{host="a"}`, MType: "gauge"},
+			wantErr:    ErrWrongLabels,
+		},
 		{
 			name:       "Empty ID",
 			metricName: MetricName{ID: "", MType: "gauge"},
@@ -45,6 +70,9 @@ func TestMetricName_Validate(t *testing.T) {
 func TestMetric_Validate(t *testing.T) {
 	gaugeValue := 3.14
 	counterValue := int64(42)
+	histogramValue := HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 2, 0}, Sum: 1.5, Count: 3}
+	brokenHistogram := HistogramValue{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 2}, Sum: 1.5, Count: 3}
+	summaryValue := SummaryValue{Quantiles: []Quantile{{Quantile: 0.99, Value: 1.2}}, Sum: 10, Count: 5}
 
 	tests := []struct {
 		name    string
@@ -71,6 +99,31 @@ func TestMetric_Validate(t *testing.T) {
 			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "counter"}, Delta: nil},
 			wantErr: ErrWrongValue,
 		},
+		{
+			name:    "Valid histogram metric",
+			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "histogram"}, Histogram: &histogramValue},
+			wantErr: nil,
+		},
+		{
+			name:    "Histogram metric with nil value",
+			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "histogram"}},
+			wantErr: ErrWrongValue,
+		},
+		{
+			name:    "Histogram metric with wrong counts",
+			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "histogram"}, Histogram: &brokenHistogram},
+			wantErr: ErrWrongValue,
+		},
+		{
+			name:    "Valid summary metric",
+			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "summary"}, Summary: &summaryValue},
+			wantErr: nil,
+		},
+		{
+			name:    "Summary metric with nil value",
+			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "summary"}},
+			wantErr: ErrWrongValue,
+		},
 		{
 			name:    "Unknown metric type",
 			metric:  Metric{MetricName: MetricName{ID: "testMetric", MType: "unknown"}},
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+)
+
+// Функции, вычисляемые над историей значений counter.
+const (
+	FuncRate     = "rate"     // скорость роста в секунду за окно
+	FuncIncrease = "increase" // прирост за окно или с заданного момента
+)
+
+// ErrWrongFunc возвращается, когда функция над counter задана некорректно.
+var ErrWrongFunc = errors.New("wrong func")
+
+// CounterFunc описывает вычисление над историей значений counter.
+// Для rate обязательно окно Window, для increase задаётся либо окно Window, либо момент Since.
+type CounterFunc struct {
+	Func   string     `json:"func,omitempty"`   // rate или increase
+	Window string     `json:"window,omitempty"` // окно в формате длительности, например 1m
+	Since  *time.Time `json:"since,omitempty"`  // начало интервала для increase
+}
+
+// Validate проверяет корректность параметров вычисления.
+func (cf CounterFunc) Validate() error {
+	switch cf.Func {
+	case FuncRate:
+		if cf.Window == "" || cf.Since != nil {
+			return ErrWrongFunc
+		}
+	case FuncIncrease:
+		if (cf.Window == "") == (cf.Since == nil) {
+			return ErrWrongFunc
+		}
+	default:
+		return ErrWrongFunc
+	}
+	if cf.Window != "" {
+		if window, err := time.ParseDuration(cf.Window); err != nil || window <= 0 {
+			return ErrWrongFunc
+		}
+	}
+	return nil
+}
+
+// From возвращает начало интервала вычисления, заканчивающегося в now.
+func (cf CounterFunc) From(now time.Time) time.Time {
+	if cf.Since != nil {
+		return *cf.Since
+	}
+	window, _ := time.ParseDuration(cf.Window)
+	return now.Add(-window)
+}
+
+// Apply вычисляет функцию по упорядоченным по времени значениям counter из интервала.
+func (cf CounterFunc) Apply(samples []Sample) float64 {
+	if cf.Func == FuncRate {
+		value, _ := Rate(samples)
+		return value
+	}
+	return Increase(samples)
+}
+
+// Increase возвращает прирост counter по упорядоченным по времени значениям.
+// Уменьшение значения считается сбросом counter: прирост после сброса равен новому значению.
+func Increase(samples []Sample) float64 {
+	var increase float64
+	for i := 1; i < len(samples); i++ {
+		if diff := samples[i].Value - samples[i-1].Value; diff >= 0 {
+			increase += diff
+		} else {
+			increase += samples[i].Value
+		}
+	}
+	return increase
+}
+
+// Rate возвращает скорость роста counter в секунду между первым и последним значением.
+// Если значений меньше двух или они записаны в один момент, возвращает false.
+func Rate(samples []Sample) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	elapsed := samples[len(samples)-1].Timestamp.Sub(samples[0].Timestamp).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return Increase(samples) / elapsed, true
+}
+
+// FuncResult - результат вычисления функции над counter.
+type FuncResult struct {
+	MetricName
+	CounterFunc
+	Value float64   `json:"value"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+}
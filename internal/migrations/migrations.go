@@ -0,0 +1,345 @@
+// Package migrations содержит встроенные версионированные миграции схемы базы данных и средство их применения.
+//
+// Миграции хранятся в каталоге sql в файлах вида <версия>_<имя>.up.sql и <версия>_<имя>.down.sql.
+// Применённые версии записываются в таблицу schema_migrations. Все миграции одного запуска выполняются
+// в одной транзакции под транзакционной рекомендательной блокировкой, поэтому несколько реплик сервера
+// могут запускаться одновременно: вторая дождётся первой и не найдёт неприменённых миграций.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID - ключ рекомендательной блокировки, под которой применяются миграции.
+const lockID int64 = 7_264_100_511
+
+// Команды, поддерживаемые Migrator.Run.
+const (
+	CommandUp     = "up"     // CommandUp применяет все неприменённые миграции.
+	CommandDown   = "down"   // CommandDown откатывает последнюю применённую миграцию.
+	CommandStatus = "status" // CommandStatus выводит состояние миграций.
+)
+
+var (
+	// ErrUnknownCommand возвращается для неизвестной команды Migrator.Run.
+	ErrUnknownCommand = errors.New("unknown migrate command")
+	// ErrUnknownVersion возвращается при откате версии, которой нет среди встроенных миграций.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// DB определяет интерфейс базы данных, необходимый для применения миграций.
+type DB interface {
+	Begin() (tx *sql.Tx, err error)
+}
+
+// Migration описывает одну миграцию схемы.
+type Migration struct {
+	Version int64  // Version - номер версии схемы.
+	Name    string // Name - краткое описание миграции.
+	Up      string // Up - SQL-запросы применения миграции.
+	Down    string // Down - SQL-запросы отката миграции.
+}
+
+// Status описывает состояние миграции в базе данных.
+type Status struct {
+	Migration
+	Applied   bool      // Applied сообщает, применена ли миграция.
+	AppliedAt time.Time // AppliedAt содержит время применения миграции.
+}
+
+// Migrator применяет и откатывает миграции.
+type Migrator struct {
+	db         DB
+	migrations []Migration
+}
+
+// NewMigrator создаёт и возвращает Migrator со встроенными миграциями.
+func NewMigrator(db DB) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load читает миграции из каталога sql файловой системы fsys и возвращает их в порядке возрастания версии.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>", name)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		data, err := fs.ReadFile(fsys, "sql/"+name)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+		if m.Name != title {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, title)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up применяет все неприменённые миграции и возвращает их.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.inTx(ctx, true, func(tx *sql.Tx, versions map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := execScript(ctx, tx, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.inTx(ctx, true, func(tx *sql.Tx, versions map[int64]time.Time) error {
+		applied := make([]int64, 0, len(versions))
+		for version := range versions {
+			applied = append(applied, version)
+		}
+		sort.Slice(applied, func(i, j int) bool { return applied[i] > applied[j] })
+		if steps < len(applied) {
+			applied = applied[:steps]
+		}
+		for _, version := range applied {
+			migration, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+			}
+			if err := execScript(ctx, tx, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// Status возвращает состояние всех встроенных миграций. База данных не изменяется.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.inTx(ctx, false, func(_ *sql.Tx, versions map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			appliedAt, ok := versions[migration.Version]
+			statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Run выполняет команду command (CommandUp, CommandDown или CommandStatus) и выводит результат в out.
+func (m *Migrator) Run(ctx context.Context, command string, out io.Writer) error {
+	switch command {
+	case CommandUp:
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			_, err = fmt.Fprintln(out, "no pending migrations")
+			return err
+		}
+		for _, migration := range applied {
+			if _, err = fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CommandDown:
+		reverted, err := m.Down(ctx, 1)
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			_, err = fmt.Fprintln(out, "no applied migrations")
+			return err
+		}
+		_, err = fmt.Fprintf(out, "reverted %04d_%s\n", reverted[0].Version, reverted[0].Name)
+		return err
+	case CommandStatus:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if _, err = fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, state); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownCommand, command)
+	}
+}
+
+// inTx выполняет fn в транзакции под рекомендательной блокировкой, передавая применённые версии.
+// Если commit равен false, транзакция откатывается.
+func (m *Migrator) inTx(ctx context.Context, commit bool, fn func(tx *sql.Tx, versions map[int64]time.Time) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, lockID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now())`)
+	if err != nil {
+		return err
+	}
+	versions, err := appliedVersions(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx, versions); err != nil {
+		return err
+	}
+	if !commit {
+		return nil
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func appliedVersions(ctx context.Context, tx *sql.Tx) (map[int64]time.Time, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// execScript выполняет запросы скрипта по одному. Запросы разделяются точкой с запятой, строки комментариев пропускаются.
+func execScript(ctx context.Context, tx *sql.Tx, script string) error {
+	for _, query := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	var statements []string
+	for _, query := range strings.Split(b.String(), ";") {
+		if query = strings.TrimSpace(query); query != "" {
+			statements = append(statements, query)
+		}
+	}
+	return statements
+}
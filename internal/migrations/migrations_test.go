@@ -0,0 +1,178 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"sql/0002_second.up.sql":   {Data: []byte("-- вторая миграция\nALTER TABLE a ADD COLUMN b text;\nCREATE INDEX a_b ON a (b);\n")},
+	"sql/0002_second.down.sql": {Data: []byte("DROP INDEX a_b;\nALTER TABLE a DROP COLUMN b;\n")},
+	"sql/0001_first.up.sql":    {Data: []byte("CREATE TABLE a (\n\tid serial PRIMARY KEY);\n")},
+	"sql/0001_first.down.sql":  {Data: []byte("DROP TABLE a;\n")},
+	"sql/README.md":            {Data: []byte("ignored")},
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	migrations, err := Load(testFS)
+	require.NoError(t, err)
+	return &Migrator{db: db, migrations: migrations}, mock
+}
+
+func expectLocked(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func versionRows(versions ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, v := range versions {
+		rows.AddRow(v, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	return rows
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE a;\n", migrations[0].Down)
+	assert.Equal(t, "second", migrations[1].Name)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no version separator": {"sql/first.up.sql": {Data: []byte("SELECT 1")}},
+		"bad version":          {"sql/x_first.up.sql": {Data: []byte("SELECT 1")}},
+		"missing up":           {"sql/0001_first.down.sql": {Data: []byte("SELECT 1")}},
+		"name mismatch": {
+			"sql/0001_first.up.sql":   {Data: []byte("SELECT 1")},
+			"sql/0001_other.down.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(files)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versions must be sequential")
+		assert.NotEmpty(t, m.Down, "migration %d must have a down file", m.Version)
+		assert.NotEmpty(t, splitStatements(m.Up))
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	m, mock := newTestMigrator(t)
+
+	expectLocked(mock, versionRows(1))
+	mock.ExpectExec("ALTER TABLE a ADD COLUMN b text").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE INDEX a_b ON a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(2), "second").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	applied, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, int64(2), applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_FailureRollsBack(t *testing.T) {
+	m, mock := newTestMigrator(t)
+
+	expectLocked(mock, versionRows())
+	mock.ExpectExec("CREATE TABLE a").WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+
+	_, err := m.Up(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "migration 1_first")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	m, mock := newTestMigrator(t)
+
+	expectLocked(mock, versionRows(1, 2))
+	mock.ExpectExec("DROP INDEX a_b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE a DROP COLUMN b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, err := m.Down(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, "second", reverted[0].Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down_UnknownVersion(t *testing.T) {
+	m, mock := newTestMigrator(t)
+
+	expectLocked(mock, versionRows(1, 7))
+	mock.ExpectRollback()
+
+	_, err := m.Down(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_RunStatus(t *testing.T) {
+	m, mock := newTestMigrator(t)
+
+	expectLocked(mock, versionRows(1))
+	mock.ExpectRollback()
+
+	var out bytes.Buffer
+	require.NoError(t, m.Run(context.Background(), CommandStatus, &out))
+	assert.Equal(t, "0001_first\tapplied 2024-01-01T00:00:00Z\n0002_second\tpending\n", out.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_RunUpNothingPending(t *testing.T) {
+	m, mock := newTestMigrator(t)
+
+	expectLocked(mock, versionRows(1, 2))
+	mock.ExpectCommit()
+
+	var out bytes.Buffer
+	require.NoError(t, m.Run(context.Background(), CommandUp, &out))
+	assert.Equal(t, "no pending migrations\n", out.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_RunUnknownCommand(t *testing.T) {
+	m, _ := newTestMigrator(t)
+	err := m.Run(context.Background(), "sideways", &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- комментарий\nCREATE TABLE a (\n\tid int);\n\n  -- ещё\nDROP TABLE b;"
+	assert.Equal(t, []string{"CREATE TABLE a (\n\tid int)", "DROP TABLE b"}, splitStatements(script))
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS summary;
+DROP TABLE IF EXISTS histogram;
+DROP TABLE IF EXISTS counter;
+DROP TABLE IF EXISTS gauge;
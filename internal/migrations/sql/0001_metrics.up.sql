@@ -0,0 +1,21 @@
+-- Таблицы текущих значений метрик. Временной ряд определяется именем метрики и набором меток.
+-- Запросы идемпотентны, чтобы базы, созданные до появления миграций, переводились на них без потери данных.
+CREATE TABLE IF NOT EXISTS gauge (id serial PRIMARY KEY, name text, labels jsonb NOT NULL DEFAULT '{}', value double precision);
+ALTER TABLE gauge ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE gauge DROP CONSTRAINT IF EXISTS gauge_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS gauge_name_labels_key ON gauge (name, labels);
+
+CREATE TABLE IF NOT EXISTS counter (id serial PRIMARY KEY, name text, labels jsonb NOT NULL DEFAULT '{}', value bigint);
+ALTER TABLE counter ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE counter DROP CONSTRAINT IF EXISTS counter_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS counter_name_labels_key ON counter (name, labels);
+
+CREATE TABLE IF NOT EXISTS histogram (id serial PRIMARY KEY, name text, labels jsonb NOT NULL DEFAULT '{}', value jsonb);
+ALTER TABLE histogram ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE histogram DROP CONSTRAINT IF EXISTS histogram_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS histogram_name_labels_key ON histogram (name, labels);
+
+CREATE TABLE IF NOT EXISTS summary (id serial PRIMARY KEY, name text, labels jsonb NOT NULL DEFAULT '{}', value jsonb);
+ALTER TABLE summary ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE summary DROP CONSTRAINT IF EXISTS summary_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS summary_name_labels_key ON summary (name, labels);
@@ -0,0 +1,8 @@
+-- История значений метрик типа counter и gauge с индексом для выборки временного ряда по интервалу времени.
+CREATE TABLE IF NOT EXISTS metric_samples (
+	type text NOT NULL,
+	name text NOT NULL,
+	labels jsonb NOT NULL DEFAULT '{}',
+	ts timestamptz NOT NULL,
+	value double precision NOT NULL);
+CREATE INDEX IF NOT EXISTS metric_samples_series_ts_idx ON metric_samples (type, name, labels, ts);
@@ -0,0 +1,472 @@
+// Package segment реализует встроенное журнально-структурированное хранилище ключ-значение.
+//
+// Записи дописываются в конец активного сегмента и сбрасываются на диск до подтверждения.
+// В памяти хранится только индекс ключей с положением последней записи, значения читаются с диска.
+// Когда активный сегмент достигает предельного размера, открывается новый, а накопившиеся
+// устаревшие записи удаляются компактизацией закрытых сегментов.
+package segment
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxSegmentSize - размер сегмента по умолчанию, после которого открывается новый сегмент.
+const DefaultMaxSegmentSize = 64 << 20
+
+const (
+	// headerSize - размер заголовка записи: crc32, длина ключа и длина значения.
+	headerSize  = 12
+	segmentExt  = ".seg"
+	compactFile = "compact.tmp"
+)
+
+var (
+	// ErrNotFound возвращается, когда ключ отсутствует в хранилище.
+	ErrNotFound = errors.New("key not found")
+	// ErrCorrupted возвращается, когда повреждена запись не в хвосте последнего сегмента.
+	ErrCorrupted = errors.New("segment corrupted")
+	// ErrClosed возвращается при обращении к закрытому хранилищу.
+	ErrClosed = errors.New("store is closed")
+)
+
+// location описывает положение записи на диске.
+type location struct {
+	segment uint32
+	offset  int64
+	size    int64
+}
+
+// Store представляет хранилище ключ-значение из файлов-сегментов в одном каталоге.
+type Store struct {
+	dir            string
+	maxSegmentSize int64
+	index          map[string]location
+	segments       map[uint32]*os.File
+	activeID       uint32
+	activeSize     int64
+	garbage        int64
+	opened         bool
+	mx             sync.RWMutex
+}
+
+// NewStore создаёт и возвращает хранилище в каталоге dir.
+// Если maxSegmentSize не положителен, используется DefaultMaxSegmentSize.
+func NewStore(dir string, maxSegmentSize int64) *Store {
+	if maxSegmentSize <= 0 {
+		maxSegmentSize = DefaultMaxSegmentSize
+	}
+	return &Store{dir: dir, maxSegmentSize: maxSegmentSize}
+}
+
+// Open открывает хранилище и восстанавливает индекс по сегментам на диске.
+// Незавершённая запись в конце последнего сегмента, оставшаяся после аварийного завершения, отбрасывается.
+func (s *Store) Open() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, compactFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ids, err := s.segmentIDs()
+	if err != nil {
+		return err
+	}
+
+	s.index = make(map[string]location)
+	s.segments = make(map[uint32]*os.File)
+	s.garbage = 0
+	for i, id := range ids {
+		f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR, 0644)
+		if err != nil {
+			s.closeSegments()
+			return err
+		}
+		s.segments[id] = f
+		end, err := s.load(id, f)
+		if err != nil {
+			if !errors.Is(err, ErrCorrupted) || i != len(ids)-1 {
+				s.closeSegments()
+				return err
+			}
+			log.Printf("Truncating segment %d at offset %d: %v", id, end, err)
+			if err = f.Truncate(end); err != nil {
+				s.closeSegments()
+				return err
+			}
+		}
+		s.activeID = id
+		s.activeSize = end
+	}
+	if len(ids) == 0 {
+		if err = s.createSegment(1); err != nil {
+			return err
+		}
+	}
+	s.opened = true
+	return nil
+}
+
+// Get возвращает значение по ключу или ErrNotFound.
+func (s *Store) Get(key string) ([]byte, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if !s.opened {
+		return nil, ErrClosed
+	}
+	loc, ok := s.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	_, value, err := s.read(loc)
+	return value, err
+}
+
+// Put сохраняет значение по ключу.
+func (s *Store) Put(key string, value []byte) error {
+	return s.PutBatch(map[string][]byte{key: value})
+}
+
+// PutBatch сохраняет несколько значений одной записью на диск с единственным сбросом файла.
+func (s *Store) PutBatch(entries map[string][]byte) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf []byte
+	sizes := make([]int64, len(keys))
+	for i, k := range keys {
+		before := len(buf)
+		buf = appendRecord(buf, k, entries[k])
+		sizes[i] = int64(len(buf) - before)
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.opened {
+		return ErrClosed
+	}
+	if s.activeSize > 0 && s.activeSize+int64(len(buf)) > s.maxSegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		if s.garbage >= s.maxSegmentSize {
+			if err := s.compact(); err != nil {
+				return err
+			}
+		}
+	}
+
+	active := s.segments[s.activeID]
+	if _, err := active.WriteAt(buf, s.activeSize); err != nil {
+		return err
+	}
+	if err := active.Sync(); err != nil {
+		return err
+	}
+	offset := s.activeSize
+	for i, k := range keys {
+		if old, ok := s.index[k]; ok {
+			s.garbage += old.size
+		}
+		s.index[k] = location{segment: s.activeID, offset: offset, size: sizes[i]}
+		offset += sizes[i]
+	}
+	s.activeSize = offset
+	return nil
+}
+
+// Keys возвращает отсортированные ключи с префиксом prefix.
+func (s *Store) Keys(prefix string) []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	var keys []string
+	for k := range s.index {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Compact закрывает активный сегмент и переписывает актуальные записи всех закрытых сегментов в один.
+func (s *Store) Compact() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.opened {
+		return ErrClosed
+	}
+	if s.activeSize > 0 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	return s.compact()
+}
+
+// Close закрывает файлы сегментов.
+func (s *Store) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.opened {
+		return nil
+	}
+	s.opened = false
+	return s.closeSegments()
+}
+
+// load читает записи сегмента в индекс и возвращает смещение конца последней целой записи.
+func (s *Store) load(id uint32, f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(f)
+	var offset int64
+	header := make([]byte, headerSize)
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return offset, nil
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return offset, fmt.Errorf("%w: torn record header", ErrCorrupted)
+			}
+			return offset, err
+		}
+		keyLen := int64(binary.LittleEndian.Uint32(header[4:8]))
+		valueLen := int64(binary.LittleEndian.Uint32(header[8:12]))
+		size := headerSize + keyLen + valueLen
+		if offset+size > info.Size() {
+			return offset, fmt.Errorf("%w: torn record", ErrCorrupted)
+		}
+		record := make([]byte, size)
+		copy(record, header)
+		if _, err = io.ReadFull(reader, record[headerSize:]); err != nil {
+			return offset, err
+		}
+		key, _, err := decodeRecord(record)
+		if err != nil {
+			return offset, err
+		}
+		if old, ok := s.index[key]; ok {
+			s.garbage += old.size
+		}
+		s.index[key] = location{segment: id, offset: offset, size: size}
+		offset += size
+	}
+}
+
+// read читает и проверяет запись по её положению.
+func (s *Store) read(loc location) (string, []byte, error) {
+	f, ok := s.segments[loc.segment]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: segment %d is missing", ErrCorrupted, loc.segment)
+	}
+	record := make([]byte, loc.size)
+	if _, err := f.ReadAt(record, loc.offset); err != nil {
+		return "", nil, err
+	}
+	return decodeRecord(record)
+}
+
+// rotate закрывает активный сегмент для записи и открывает следующий.
+func (s *Store) rotate() error {
+	return s.createSegment(s.activeID + 1)
+}
+
+func (s *Store) createSegment(id uint32) error {
+	f, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err = syncDir(s.dir); err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.segments[id] = f
+	s.activeID = id
+	s.activeSize = 0
+	return nil
+}
+
+// compact переписывает актуальные записи закрытых сегментов в сегмент с наибольшим номером среди них.
+// Новый сегмент атомарно заменяет старый переименованием, после чего остальные закрытые сегменты удаляются.
+// Сбой между этими шагами безопасен: при восстановлении более новый сегмент перекрывает старые записи.
+func (s *Store) compact() error {
+	var sealed []uint32
+	for id := range s.segments {
+		if id != s.activeID {
+			sealed = append(sealed, id)
+		}
+	}
+	if len(sealed) == 0 {
+		return nil
+	}
+	sort.Slice(sealed, func(i, j int) bool { return sealed[i] < sealed[j] })
+	target := sealed[len(sealed)-1]
+
+	keys := make([]string, 0, len(s.index))
+	for k, loc := range s.index {
+		if loc.segment != s.activeID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	tmpPath := filepath.Join(s.dir, compactFile)
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	moved, err := s.copyRecords(tmp, keys, target)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = os.Rename(tmpPath, s.segmentPath(target)); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = syncDir(s.dir); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	for _, id := range sealed {
+		_ = s.segments[id].Close()
+		delete(s.segments, id)
+		if id != target {
+			if err = os.Remove(s.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+				log.Printf("Error removing segment %d: %v", id, err)
+			}
+		}
+	}
+	s.segments[target] = tmp
+	for k, loc := range moved {
+		s.index[k] = loc
+	}
+	s.garbage = 0
+	return syncDir(s.dir)
+}
+
+// copyRecords копирует записи ключей keys в файл dst и возвращает их новые положения в сегменте id.
+func (s *Store) copyRecords(dst *os.File, keys []string, id uint32) (map[string]location, error) {
+	writer := bufio.NewWriter(dst)
+	moved := make(map[string]location, len(keys))
+	var offset int64
+	for _, k := range keys {
+		key, value, err := s.read(s.index[k])
+		if err != nil {
+			return nil, err
+		}
+		record := appendRecord(nil, key, value)
+		if _, err = writer.Write(record); err != nil {
+			return nil, err
+		}
+		moved[k] = location{segment: id, offset: offset, size: int64(len(record))}
+		offset += int64(len(record))
+	}
+	return moved, writer.Flush()
+}
+
+func (s *Store) closeSegments() error {
+	var errs []error
+	for id, f := range s.segments {
+		errs = append(errs, f.Close())
+		delete(s.segments, id)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Store) segmentIDs() ([]uint32, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint32
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint32(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (s *Store) segmentPath(id uint32) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%010d%s", id, segmentExt))
+}
+
+// appendRecord дописывает в buf запись: crc32 | длина ключа | длина значения | ключ | значение.
+func appendRecord(buf []byte, key string, value []byte) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, headerSize)...)
+	binary.LittleEndian.PutUint32(buf[start+4:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(buf[start+8:], uint32(len(value)))
+	buf = append(buf, key...)
+	buf = append(buf, value...)
+	binary.LittleEndian.PutUint32(buf[start:], crc32.ChecksumIEEE(buf[start+4:]))
+	return buf
+}
+
+// decodeRecord проверяет контрольную сумму записи и возвращает ключ и значение.
+func decodeRecord(record []byte) (string, []byte, error) {
+	if len(record) < headerSize {
+		return "", nil, fmt.Errorf("%w: short record", ErrCorrupted)
+	}
+	keyLen := int(binary.LittleEndian.Uint32(record[4:8]))
+	valueLen := int(binary.LittleEndian.Uint32(record[8:12]))
+	if len(record) != headerSize+keyLen+valueLen {
+		return "", nil, fmt.Errorf("%w: record length mismatch", ErrCorrupted)
+	}
+	if crc32.ChecksumIEEE(record[4:]) != binary.LittleEndian.Uint32(record[0:4]) {
+		return "", nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+	}
+	key := string(record[headerSize : headerSize+keyLen])
+	return key, record[headerSize+keyLen:], nil
+}
+
+// syncDir сбрасывает на диск каталог, чтобы создание и переименование файлов пережили аварийное завершение.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err = d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
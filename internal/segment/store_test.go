@@ -0,0 +1,148 @@
+package segment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openStore(t *testing.T, dir string, maxSegmentSize int64) *Store {
+	t.Helper()
+	s := NewStore(dir, maxSegmentSize)
+	require.NoError(t, s.Open())
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestStore_PutGet(t *testing.T) {
+	s := openStore(t, t.TempDir(), 0)
+
+	require.NoError(t, s.Put("gauge/cpu", []byte("1")))
+	require.NoError(t, s.PutBatch(map[string][]byte{"gauge/cpu": []byte("2"), "counter/requests": []byte("3")}))
+
+	value, err := s.Get("gauge/cpu")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2"), value)
+
+	_, err = s.Get("gauge/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Equal(t, []string{"gauge/cpu"}, s.Keys("gauge/"))
+	assert.Equal(t, []string{"counter/requests", "gauge/cpu"}, s.Keys(""))
+}
+
+func TestStore_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, 0)
+	require.NoError(t, s.Open())
+	require.NoError(t, s.Put("a", []byte("1")))
+	require.NoError(t, s.Put("a", []byte("2")))
+	require.NoError(t, s.Put("b", []byte("3")))
+	require.NoError(t, s.Close())
+
+	_, err := s.Get("a")
+	assert.ErrorIs(t, err, ErrClosed)
+
+	reopened := openStore(t, dir, 0)
+	value, err := reopened.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2"), value)
+	value, err = reopened.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("3"), value)
+}
+
+func TestStore_RecoverTornTail(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, 0)
+	require.NoError(t, s.Open())
+	require.NoError(t, s.Put("a", []byte("1")))
+	require.NoError(t, s.Put("b", []byte("2")))
+	require.NoError(t, s.Close())
+
+	// Обрезаем последнюю запись, как если бы процесс завершился посреди записи.
+	path := s.segmentPath(1)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-1))
+
+	reopened := openStore(t, dir, 0)
+	value, err := reopened.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+	_, err = reopened.Get("b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, reopened.Put("c", []byte("3")))
+	value, err = reopened.Get("c")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("3"), value)
+}
+
+func TestStore_CorruptedSealedSegment(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, 64)
+	require.NoError(t, s.Open())
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.Put(fmt.Sprintf("key%d", i), make([]byte, 40)))
+	}
+	require.NoError(t, s.Close())
+
+	f, err := os.OpenFile(s.segmentPath(1), os.O_RDWR, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, headerSize)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	err = NewStore(dir, 64).Open()
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestStore_RotateAndCompact(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, 256)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.PutBatch(map[string][]byte{
+			"counter/requests":            []byte(fmt.Sprint(i)),
+			fmt.Sprintf("gauge/g%d", i%5): []byte(fmt.Sprint(i)),
+		}))
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	assert.Less(t, len(segments), 10, "garbage should be compacted away")
+
+	require.NoError(t, s.Compact())
+	segments, err = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	assert.Len(t, segments, 2)
+
+	value, err := s.Get("counter/requests")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("99"), value)
+	assert.Len(t, s.Keys("gauge/"), 5)
+
+	require.NoError(t, s.Close())
+	reopened := openStore(t, dir, 256)
+	value, err = reopened.Get("counter/requests")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("99"), value)
+	value, err = reopened.Get("gauge/g4")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("99"), value)
+}
+
+func TestStore_RemovesStaleCompactFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, compactFile), []byte("partial"), 0644))
+
+	openStore(t, dir, 0)
+
+	_, err := os.Stat(filepath.Join(dir, compactFile))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -2,13 +2,30 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/moonicy/gometrics/internal/handlers"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
 	pb "github.com/moonicy/gometrics/proto"
 )
 
 // Storage определяет интерфейс для операций с хранилищем метрик.
 type Storage interface {
-	SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
+	handlers.Storage
+}
+
+// CounterFuncApplier определяет интерфейс хранилища, вычисляющего функции над историей значений counter.
+type CounterFuncApplier interface {
+	ApplyCounterFunc(ctx context.Context, mn metrics.MetricName, cf metrics.CounterFunc) (metrics.FuncResult, error)
 }
 
 type GRPCServer struct {
@@ -21,25 +38,260 @@ func NewGRPCServer(storage Storage) *GRPCServer {
 }
 
 // UpdateMetrics реализует интерфейс добавления метрик.
+// Пакет сохраняется целиком: если хотя бы одна метрика некорректна, в том числе гистограмма с границами
+// корзин, отличными от сохранённых, возвращается статус InvalidArgument с подробностями google.rpc.BadRequest
+// по каждой отклонённой метрике и ничего не сохраняется. При ошибке хранилища возвращается статус Unavailable.
 func (s *GRPCServer) UpdateMetrics(ctx context.Context, in *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
-	var response pb.UpdateMetricsResponse
+	batch, violations := parseBatch(in, "")
+	if len(violations) == 0 {
+		var err error
+		if violations, err = s.checkHistograms(ctx, batch, ""); err != nil {
+			return nil, err
+		}
+	}
+	if len(violations) > 0 {
+		return nil, invalidArgument(fmt.Sprintf("%d metrics rejected", len(violations)), violations)
+	}
+	if err := s.store(ctx, batch); err != nil {
+		return nil, err
+	}
+	return &pb.UpdateMetricsResponse{}, nil
+}
+
+// StreamMetrics принимает поток пакетов метрик от агента и сохраняет каждый корректный пакет.
+// Некорректный пакет не прерывает приём остальных; после закрытия потока агентом возвращается
+// статус InvalidArgument с нарушениями вида batch[i].gauges[j].id по всем отклонённым пакетам.
+// При ошибке хранилища поток сразу завершается статусом Unavailable.
+func (s *GRPCServer) StreamMetrics(stream pb.Metrics_StreamMetricsServer) error {
+	var total, rejected int
+	var violations []*errdetails.BadRequest_FieldViolation
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		prefix := fmt.Sprintf("batch[%d].", total)
+		batch, batchViolations := parseBatch(in, prefix)
+		total++
+		if len(batchViolations) == 0 {
+			if batchViolations, err = s.checkHistograms(stream.Context(), batch, prefix); err != nil {
+				return err
+			}
+		}
+		if len(batchViolations) > 0 {
+			rejected++
+			violations = append(violations, batchViolations...)
+			continue
+		}
+		if err = s.store(stream.Context(), batch); err != nil {
+			return err
+		}
+	}
+	if rejected > 0 {
+		return invalidArgument(fmt.Sprintf("%d of %d batches rejected", rejected, total), violations)
+	}
+	return stream.SendAndClose(&pb.UpdateMetricsResponse{})
+}
+
+// metricsBatch - проверенный пакет метрик, готовый к сохранению.
+type metricsBatch struct {
+	gauge     map[string]float64
+	counter   map[string]int64
+	histogram map[string]metrics.HistogramValue
+	summary   map[string]metrics.SummaryValue
+	// histogramIndex хранит индекс первой гистограммы каждого ряда в запросе для описания нарушений.
+	histogramIndex map[string]int
+}
+
+// parseBatch проверяет пакет метрик и возвращает нарушения по каждой некорректной метрике.
+// Поля нарушений дополняются префиксом prefix.
+func parseBatch(in *pb.UpdateMetricsRequest, prefix string) (metricsBatch, []*errdetails.BadRequest_FieldViolation) {
+	batch := metricsBatch{
+		gauge:          make(map[string]float64),
+		counter:        make(map[string]int64),
+		histogram:      make(map[string]metrics.HistogramValue),
+		summary:        make(map[string]metrics.SummaryValue),
+		histogramIndex: make(map[string]int),
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	reject := func(field string, i int, id string, err error) {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       fmt.Sprintf("%s%s[%d]", prefix, field, i),
+			Description: fmt.Sprintf("%q: %v", id, err),
+		})
+	}
+
+	for i, m := range in.GetGauges() {
+		key, err := seriesKey(m.GetId(), metrics.Gauge, m.GetLabels())
+		if err != nil {
+			reject("gauges", i, m.GetId(), err)
+			continue
+		}
+		batch.gauge[key] = m.GetValue()
+	}
+	for i, m := range in.GetCounters() {
+		key, err := seriesKey(m.GetId(), metrics.Counter, m.GetLabels())
+		if err != nil {
+			reject("counters", i, m.GetId(), err)
+			continue
+		}
+		batch.counter[key] += m.GetDelta()
+	}
+	for i, m := range in.GetHistograms() {
+		key, err := seriesKey(m.GetId(), metrics.Histogram, m.GetLabels())
+		if err != nil {
+			reject("histograms", i, m.GetId(), err)
+			continue
+		}
+		value := histogramFromProto(m)
+		if err = value.Validate(); err != nil {
+			reject("histograms", i, m.GetId(), err)
+			continue
+		}
+		current, ok := batch.histogram[key]
+		if !ok {
+			batch.histogram[key] = value
+			batch.histogramIndex[key] = i
+			continue
+		}
+		if err = current.Merge(value); err != nil {
+			reject("histograms", i, m.GetId(), err)
+			continue
+		}
+		batch.histogram[key] = current
+	}
+	for i, m := range in.GetSummaries() {
+		key, err := seriesKey(m.GetId(), metrics.Summary, m.GetLabels())
+		if err != nil {
+			reject("summaries", i, m.GetId(), err)
+			continue
+		}
+		value := summaryFromProto(m)
+		if err = value.Validate(); err != nil {
+			reject("summaries", i, m.GetId(), err)
+			continue
+		}
+		batch.summary[key] = value
+	}
+	return batch, violations
+}
 
-	mtGauge := make(map[string]float64)
-	for _, m := range in.Gauges {
-		mtGauge[m.GetId()] = m.GetValue()
-		fmt.Printf("mtGauge[%s] = %f\n", m.GetId(), m.GetValue())
+// checkHistograms сверяет границы корзин гистограмм пакета с сохранёнными и возвращает нарушения
+// по гистограммам, которые нельзя сложить с сохранёнными. Поля нарушений дополняются префиксом prefix.
+func (s *GRPCServer) checkHistograms(ctx context.Context, batch metricsBatch, prefix string) ([]*errdetails.BadRequest_FieldViolation, error) {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for key, value := range batch.histogram {
+		current, err := s.storage.GetHistogram(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, storageError(err)
+		}
+		if !current.SameBounds(value) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       fmt.Sprintf("%shistograms[%d]", prefix, batch.histogramIndex[key]),
+				Description: fmt.Sprintf("%q: bounds %v differ from stored %v: %v", key, value.Bounds, current.Bounds, metrics.ErrWrongValue),
+			})
+		}
 	}
-	mtCounter := make(map[string]int64)
-	for _, m := range in.Counters {
-		mtCounter[m.GetId()] = m.GetDelta()
-		fmt.Printf("mtCounter[%s] = %d\n", m.GetId(), m.GetDelta())
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Field < violations[j].Field })
+	return violations, nil
+}
+
+// store сохраняет проверенный пакет метрик.
+func (s *GRPCServer) store(ctx context.Context, batch metricsBatch) error {
+	if err := s.storage.SetMetrics(ctx, batch.counter, batch.gauge); err != nil {
+		return storageError(err)
+	}
+	if len(batch.histogram) != 0 || len(batch.summary) != 0 {
+		if err := s.storage.SetDistributions(ctx, batch.histogram, batch.summary); err != nil {
+			return storageError(err)
+		}
+	}
+	return nil
+}
+
+// GetCounterFunc вычисляет функцию rate или increase над историей значений counter.
+// Если хранилище не ведёт историю значений, возвращает статус Unimplemented.
+func (s *GRPCServer) GetCounterFunc(ctx context.Context, in *pb.CounterFuncRequest) (*pb.CounterFuncResponse, error) {
+	applier, ok := s.storage.(CounterFuncApplier)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "history is disabled")
 	}
-	err := s.storage.SetMetrics(ctx, mtCounter, mtGauge)
+	mn := metrics.MetricName{ID: in.GetId(), MType: metrics.Counter, Labels: in.GetLabels()}
+	if err := mn.Validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid counter %q: %v", in.GetId(), err)
+	}
+	cf := metrics.CounterFunc{Func: in.GetFunc(), Window: in.GetWindow()}
+	if in.GetSince() != nil {
+		since := in.GetSince().AsTime()
+		cf.Since = &since
+	}
+	result, err := applier.ApplyCounterFunc(ctx, mn, cf)
 	if err != nil {
-		response.Error = fmt.Sprintf("error adding metrics: %v", err)
+		switch {
+		case errors.Is(err, metrics.ErrWrongFunc):
+			return nil, status.Errorf(codes.InvalidArgument, "invalid func %q: %v", in.GetFunc(), err)
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, status.Errorf(codes.NotFound, "counter %s not found", mn.Key())
+		}
+		return nil, storageError(err)
 	}
+	return &pb.CounterFuncResponse{
+		Value: result.Value,
+		From:  timestamppb.New(result.From),
+		To:    timestamppb.New(result.To),
+	}, nil
+}
 
-	fmt.Println("Got new metrics")
+// invalidArgument возвращает статус InvalidArgument с нарушениями в подробностях google.rpc.BadRequest.
+func invalidArgument(msg string, violations []*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, msg)
+	detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
 
-	return &response, nil
+// storageError преобразует ошибку хранилища в статус gRPC: отмену запроса - в Canceled или
+// DeadlineExceeded, некорректное значение - в InvalidArgument, остальные ошибки - в Unavailable.
+func storageError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if errors.Is(err, metrics.ErrWrongValue) || errors.Is(err, metrics.ErrWrongLabels) || errors.Is(err, storage.ErrNotValid) {
+		return status.Errorf(codes.InvalidArgument, "invalid metric: %v", err)
+	}
+	return status.Errorf(codes.Unavailable, "storage error: %v", err)
+}
+
+// seriesKey проверяет имя метрики и метки и возвращает ключ временного ряда.
+func seriesKey(id, mType string, labels map[string]string) (string, error) {
+	mn := metrics.MetricName{ID: id, MType: mType, Labels: labels}
+	if err := mn.Validate(); err != nil {
+		return "", err
+	}
+	return mn.Key(), nil
+}
+
+func histogramFromProto(m *pb.Histogram) metrics.HistogramValue {
+	return metrics.HistogramValue{
+		Bounds: append([]float64(nil), m.GetBounds()...),
+		Counts: append([]uint64(nil), m.GetCounts()...),
+		Sum:    m.GetSum(),
+		Count:  m.GetCount(),
+	}
+}
+
+func summaryFromProto(m *pb.Summary) metrics.SummaryValue {
+	quantiles := make([]metrics.Quantile, 0, len(m.GetQuantiles()))
+	for _, q := range m.GetQuantiles() {
+		quantiles = append(quantiles, metrics.Quantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+	}
+	return metrics.SummaryValue{Quantiles: quantiles, Sum: m.GetSum(), Count: m.GetCount()}
 }
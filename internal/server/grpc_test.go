@@ -3,18 +3,40 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/moonicy/gometrics/internal/handlers"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
 	pb "github.com/moonicy/gometrics/proto"
 	"github.com/stretchr/testify/assert"
 )
 
-// MockStorage - мок реализации интерфейса Storage
+// MockStorage - мок реализации интерфейса Storage; не переопределённые методы не вызываются в тестах.
 type MockStorage struct {
+	handlers.Storage
 	setMetricsCalled bool
 	setMetricsError  error
 	lastCounter      map[string]int64
 	lastGauge        map[string]float64
+	lastHistogram    map[string]metrics.HistogramValue
+	lastSummary      map[string]metrics.SummaryValue
+	histograms       map[string]metrics.HistogramValue
+}
+
+func (m *MockStorage) GetHistogram(_ context.Context, key string) (metrics.HistogramValue, error) {
+	value, ok := m.histograms[key]
+	if !ok {
+		return metrics.HistogramValue{}, storage.ErrNotFound
+	}
+	return value, nil
 }
 
 func (m *MockStorage) SetMetrics(_ context.Context, counter map[string]int64, gauge map[string]float64) error {
@@ -24,6 +46,12 @@ func (m *MockStorage) SetMetrics(_ context.Context, counter map[string]int64, ga
 	return m.setMetricsError
 }
 
+func (m *MockStorage) SetDistributions(_ context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error {
+	m.lastHistogram = histogram
+	m.lastSummary = summary
+	return m.setMetricsError
+}
+
 func TestUpdateMetrics_Success(t *testing.T) {
 	mockStorage := &MockStorage{}
 	server := NewGRPCServer(mockStorage)
@@ -37,10 +65,9 @@ func TestUpdateMetrics_Success(t *testing.T) {
 		},
 	}
 
-	resp, err := server.UpdateMetrics(context.Background(), request)
+	_, err := server.UpdateMetrics(context.Background(), request)
 
 	assert.NoError(t, err)
-	assert.Empty(t, resp.Error)
 	assert.True(t, mockStorage.setMetricsCalled, "Expected SetMetrics to be called")
 
 	assert.Equal(t, map[string]float64{"gauge1": 10.5}, mockStorage.lastGauge)
@@ -62,9 +89,193 @@ func TestUpdateMetrics_StorageError(t *testing.T) {
 		},
 	}
 
+	_, err := server.UpdateMetrics(context.Background(), request)
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "storage error")
+	assert.True(t, mockStorage.setMetricsCalled, "Expected SetMetrics to be called")
+}
+
+func TestUpdateMetrics_Canceled(t *testing.T) {
+	mockStorage := &MockStorage{setMetricsError: context.Canceled}
+	server := NewGRPCServer(mockStorage)
+
+	_, err := server.UpdateMetrics(context.Background(), &pb.UpdateMetricsRequest{Gauges: []*pb.Gauge{{Id: "gauge1"}}})
+
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestUpdateMetrics_InvalidArgument(t *testing.T) {
+	mockStorage := &MockStorage{}
+	server := NewGRPCServer(mockStorage)
+
+	request := &pb.UpdateMetricsRequest{
+		Gauges: []*pb.Gauge{
+			{Id: "gauge1", Value: 10.5},
+			{Id: "", Value: 1},
+		},
+		Counters: []*pb.Counter{
+			{Id: "", Delta: 100},
+		},
+	}
+
 	resp, err := server.UpdateMetrics(context.Background(), request)
 
+	assert.Nil(t, resp)
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "2 metrics rejected", st.Message())
+	assert.Equal(t, []string{"gauges[1]", "counters[0]"}, violationFields(t, err))
+	assert.False(t, mockStorage.setMetricsCalled, "Expected nothing to be stored")
+}
+
+func TestUpdateMetrics_Distributions(t *testing.T) {
+	mockStorage := &MockStorage{}
+	server := NewGRPCServer(mockStorage)
+
+	request := &pb.UpdateMetricsRequest{
+		Histograms: []*pb.Histogram{
+			{Id: "latency", Bounds: []float64{0.1, 1}, Counts: []uint64{1, 2, 0}, Sum: 1.2, Count: 3},
+			{Id: "latency", Bounds: []float64{0.1, 1}, Counts: []uint64{0, 0, 1}, Sum: 5, Count: 1},
+		},
+		Summaries: []*pb.Summary{
+			{Id: "duration", Quantiles: []*pb.Quantile{{Quantile: 0.99, Value: 3}}, Sum: 10, Count: 4},
+		},
+	}
+
+	_, err := server.UpdateMetrics(context.Background(), request)
+
 	assert.NoError(t, err)
-	assert.Equal(t, "error adding metrics: storage error", resp.Error)
-	assert.True(t, mockStorage.setMetricsCalled, "Expected SetMetrics to be called")
+	assert.Equal(t, map[string]metrics.HistogramValue{
+		"latency": {Bounds: []float64{0.1, 1}, Counts: []uint64{1, 2, 1}, Sum: 6.2, Count: 4},
+	}, mockStorage.lastHistogram)
+	assert.Equal(t, map[string]metrics.SummaryValue{
+		"duration": {Quantiles: []metrics.Quantile{{Quantile: 0.99, Value: 3}}, Sum: 10, Count: 4},
+	}, mockStorage.lastSummary)
+}
+
+func TestUpdateMetrics_InvalidHistogram(t *testing.T) {
+	mockStorage := &MockStorage{}
+	server := NewGRPCServer(mockStorage)
+
+	request := &pb.UpdateMetricsRequest{
+		Histograms: []*pb.Histogram{
+			{Id: "latency", Bounds: []float64{0.1, 1}, Counts: []uint64{1}, Count: 1},
+		},
+	}
+
+	_, err := server.UpdateMetrics(context.Background(), request)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"histograms[0]"}, violationFields(t, err))
+	assert.False(t, mockStorage.setMetricsCalled)
+}
+
+func TestUpdateMetrics_HistogramBoundsMismatch(t *testing.T) {
+	mockStorage := &MockStorage{histograms: map[string]metrics.HistogramValue{
+		"latency": metrics.NewHistogramValue([]float64{0.5, 5}),
+	}}
+	server := NewGRPCServer(mockStorage)
+
+	request := &pb.UpdateMetricsRequest{
+		Counters: []*pb.Counter{{Id: "requests", Delta: 1}},
+		Histograms: []*pb.Histogram{
+			{Id: "latency", Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 0}, Sum: 0.05, Count: 1},
+		},
+	}
+
+	_, err := server.UpdateMetrics(context.Background(), request)
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"histograms[0]"}, violationFields(t, err))
+	assert.False(t, mockStorage.setMetricsCalled, "Expected counters not to be stored")
+}
+
+func TestUpdateMetrics_StorageWrongValue(t *testing.T) {
+	mockStorage := &MockStorage{setMetricsError: fmt.Errorf("histogram latency: %w", metrics.ErrWrongValue)}
+	server := NewGRPCServer(mockStorage)
+
+	_, err := server.UpdateMetrics(context.Background(), &pb.UpdateMetricsRequest{Gauges: []*pb.Gauge{{Id: "gauge1"}}})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUpdateMetrics_Labels(t *testing.T) {
+	mockStorage := &MockStorage{}
+	server := NewGRPCServer(mockStorage)
+
+	request := &pb.UpdateMetricsRequest{
+		Gauges: []*pb.Gauge{
+			{Id: "cpu", Value: 0.5, Labels: map[string]string{"host": "a", "core": "0"}},
+		},
+		Counters: []*pb.Counter{
+			{Id: "requests", Delta: 3, Labels: map[string]string{"code": "200"}},
+		},
+	}
+
+	_, err := server.UpdateMetrics(context.Background(), request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{`cpu{core="0",host="a"}`: 0.5}, mockStorage.lastGauge)
+	assert.Equal(t, map[string]int64{`requests{code="200"}`: 3}, mockStorage.lastCounter)
+
+	mockStorage = &MockStorage{}
+	server = NewGRPCServer(mockStorage)
+	_, err = server.UpdateMetrics(context.Background(), &pb.UpdateMetricsRequest{
+		Gauges: []*pb.Gauge{{Id: "cpu", Value: 0.5, Labels: map[string]string{"host name": "a"}}},
+	})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "1 metrics rejected")
+	assert.False(t, mockStorage.setMetricsCalled)
+}
+
+func TestGetCounterFunc(t *testing.T) {
+	ctx := context.Background()
+	history := storage.NewHistoryStorage(storage.NewMemStorage(), storage.NewMemHistory(), time.Hour)
+	for _, delta := range []int64{10, 5} {
+		assert.NoError(t, history.AddCounter(ctx, metrics.SeriesKey("requests", map[string]string{"host": "a"}), delta))
+	}
+	server := NewGRPCServer(history)
+
+	resp, err := server.GetCounterFunc(ctx, &pb.CounterFuncRequest{
+		Id:     "requests",
+		Labels: map[string]string{"host": "a"},
+		Func:   metrics.FuncIncrease,
+		Since:  timestamppb.New(time.Now().Add(-time.Minute)),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, resp.Value)
+	assert.True(t, resp.GetFrom().AsTime().Before(resp.GetTo().AsTime()))
+
+	_, err = server.GetCounterFunc(ctx, &pb.CounterFuncRequest{Id: "requests", Func: metrics.FuncRate, Window: "1m"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = server.GetCounterFunc(ctx, &pb.CounterFuncRequest{Id: "requests", Labels: map[string]string{"host": "a"}, Func: "avg", Window: "1m"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = server.GetCounterFunc(ctx, &pb.CounterFuncRequest{Func: metrics.FuncRate, Window: "1m"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGetCounterFunc_HistoryDisabled(t *testing.T) {
+	server := NewGRPCServer(&MockStorage{})
+
+	_, err := server.GetCounterFunc(context.Background(), &pb.CounterFuncRequest{Id: "requests", Func: metrics.FuncRate, Window: "1m"})
+
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+// violationFields возвращает поля нарушений из подробностей google.rpc.BadRequest статуса ошибки.
+func violationFields(t *testing.T, err error) []string {
+	t.Helper()
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	return fields
 }
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
+	pb "github.com/moonicy/gometrics/proto"
+)
+
+// Интервалы опроса хранилища для WatchMetrics. Каждая подписка опрашивает хранилище отдельно,
+// поэтому интервал из запроса ограничивается снизу MinWatchInterval.
+const (
+	DefaultWatchInterval = time.Second
+	MinWatchInterval     = time.Second
+)
+
+// GetMetric возвращает значение одного временного ряда по имени, типу и меткам.
+// Возвращает статус InvalidArgument для некорректного запроса и NotFound, если временной ряд не найден.
+func (s *GRPCServer) GetMetric(ctx context.Context, in *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+	key, err := seriesKey(in.GetId(), in.GetType(), in.GetLabels())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q: %v", in.GetType(), in.GetId(), err)
+	}
+	var response pb.GetMetricResponse
+	labels := in.GetLabels()
+	switch in.GetType() {
+	case metrics.Gauge:
+		var value float64
+		if value, err = s.storage.GetGauge(ctx, key); err == nil {
+			response.Metric = gaugeToProto(in.GetId(), labels, value)
+		}
+	case metrics.Counter:
+		var delta int64
+		if delta, err = s.storage.GetCounter(ctx, key); err == nil {
+			response.Metric = counterToProto(in.GetId(), labels, delta)
+		}
+	case metrics.Histogram:
+		var value metrics.HistogramValue
+		if value, err = s.storage.GetHistogram(ctx, key); err == nil {
+			response.Metric = histogramToProto(in.GetId(), labels, value)
+		}
+	case metrics.Summary:
+		var value metrics.SummaryValue
+		if value, err = s.storage.GetSummary(ctx, key); err == nil {
+			response.Metric = summaryToProto(in.GetId(), labels, value)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "%s %s not found", in.GetType(), key)
+		}
+		return nil, storageError(err)
+	}
+	return &response, nil
+}
+
+// ListMetrics возвращает метрики, подходящие под тип и селектор, упорядоченные по типу и ключу временного ряда.
+func (s *GRPCServer) ListMetrics(ctx context.Context, in *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+	f, err := newMetricFilter(in.GetType(), in.GetSelector())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	found, err := s.snapshot(ctx, f)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	var response pb.ListMetricsResponse
+	for _, key := range sortedSnapshotKeys(found) {
+		response.Metrics = append(response.Metrics, found[key])
+	}
+	return &response, nil
+}
+
+// WatchMetrics передаёт текущие значения подходящих метрик, а затем их изменения,
+// опрашивая хранилище с интервалом из запроса, до отмены подписки клиентом.
+func (s *GRPCServer) WatchMetrics(in *pb.WatchMetricsRequest, stream pb.Metrics_WatchMetricsServer) error {
+	f, err := newMetricFilter(in.GetType(), in.GetSelector())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	interval := DefaultWatchInterval
+	if in.GetInterval() != nil {
+		interval = max(in.GetInterval().AsDuration(), MinWatchInterval)
+	}
+
+	ctx := stream.Context()
+	sent := make(map[string]*pb.Metric)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		current, err := s.snapshot(ctx, f)
+		if err != nil {
+			return storageError(err)
+		}
+		for _, key := range sortedSnapshotKeys(current) {
+			if prev, ok := sent[key]; ok && proto.Equal(prev, current[key]) {
+				continue
+			}
+			if err = stream.Send(current[key]); err != nil {
+				return err
+			}
+			sent[key] = current[key]
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// metricFilter отбирает временные ряды по типу и селектору.
+type metricFilter struct {
+	mType    string
+	id       string
+	matchers []metrics.LabelMatcher
+}
+
+func newMetricFilter(mType, selector string) (metricFilter, error) {
+	f := metricFilter{mType: mType}
+	switch mType {
+	case "", metrics.Gauge, metrics.Counter, metrics.Histogram, metrics.Summary:
+	default:
+		return f, fmt.Errorf("invalid type %s: %w", mType, metrics.ErrUnknownMetric)
+	}
+	if selector == "" {
+		return f, nil
+	}
+	id, matchers, err := metrics.ParseSelector(selector)
+	if err != nil {
+		return f, fmt.Errorf("invalid selector %s: %w", selector, err)
+	}
+	f.id = id
+	f.matchers = matchers
+	return f, nil
+}
+
+// match проверяет временной ряд и возвращает имя метрики и метки.
+func (f metricFilter) match(mType, key string) (string, map[string]string, bool) {
+	if f.mType != "" && f.mType != mType {
+		return "", nil, false
+	}
+	id, labels, err := metrics.ParseSeriesKey(key)
+	if err != nil || (f.id != "" && f.id != id) || !metrics.MatchAll(f.matchers, labels) {
+		return "", nil, false
+	}
+	return id, labels, true
+}
+
+// wants проверяет, нужны ли фильтру метрики хотя бы одного из типов.
+func (f metricFilter) wants(types ...string) bool {
+	if f.mType == "" {
+		return true
+	}
+	for _, t := range types {
+		if t == f.mType {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot возвращает подходящие под фильтр метрики по ключу "тип\x00ключ временного ряда".
+func (s *GRPCServer) snapshot(ctx context.Context, f metricFilter) (map[string]*pb.Metric, error) {
+	found := make(map[string]*pb.Metric)
+	if f.wants(metrics.Gauge, metrics.Counter) {
+		counter, gauge, err := s.storage.GetMetrics(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range gauge {
+			if id, labels, ok := f.match(metrics.Gauge, key); ok {
+				found[metrics.Gauge+"\x00"+key] = gaugeToProto(id, labels, value)
+			}
+		}
+		for key, delta := range counter {
+			if id, labels, ok := f.match(metrics.Counter, key); ok {
+				found[metrics.Counter+"\x00"+key] = counterToProto(id, labels, delta)
+			}
+		}
+	}
+	if f.wants(metrics.Histogram, metrics.Summary) {
+		histogram, summary, err := s.storage.GetDistributions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range histogram {
+			if id, labels, ok := f.match(metrics.Histogram, key); ok {
+				found[metrics.Histogram+"\x00"+key] = histogramToProto(id, labels, value)
+			}
+		}
+		for key, value := range summary {
+			if id, labels, ok := f.match(metrics.Summary, key); ok {
+				found[metrics.Summary+"\x00"+key] = summaryToProto(id, labels, value)
+			}
+		}
+	}
+	return found, nil
+}
+
+func sortedSnapshotKeys(found map[string]*pb.Metric) []string {
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func gaugeToProto(id string, labels map[string]string, value float64) *pb.Metric {
+	return &pb.Metric{Value: &pb.Metric_Gauge{Gauge: &pb.Gauge{Id: id, Value: value, Labels: labels}}}
+}
+
+func counterToProto(id string, labels map[string]string, delta int64) *pb.Metric {
+	return &pb.Metric{Value: &pb.Metric_Counter{Counter: &pb.Counter{Id: id, Delta: delta, Labels: labels}}}
+}
+
+func histogramToProto(id string, labels map[string]string, value metrics.HistogramValue) *pb.Metric {
+	return &pb.Metric{Value: &pb.Metric_Histogram{Histogram: &pb.Histogram{
+		Id:     id,
+		Bounds: append([]float64(nil), value.Bounds...),
+		Counts: append([]uint64(nil), value.Counts...),
+		Sum:    value.Sum,
+		Count:  value.Count,
+		Labels: labels,
+	}}}
+}
+
+func summaryToProto(id string, labels map[string]string, value metrics.SummaryValue) *pb.Metric {
+	quantiles := make([]*pb.Quantile, 0, len(value.Quantiles))
+	for _, q := range value.Quantiles {
+		quantiles = append(quantiles, &pb.Quantile{Quantile: q.Quantile, Value: q.Value})
+	}
+	return &pb.Metric{Value: &pb.Metric_Summary{Summary: &pb.Summary{
+		Id:        id,
+		Quantiles: quantiles,
+		Sum:       value.Sum,
+		Count:     value.Count,
+		Labels:    labels,
+	}}}
+}
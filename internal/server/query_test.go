@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
+	pb "github.com/moonicy/gometrics/proto"
+)
+
+// newTestClient запускает GRPCServer поверх соединения в памяти и возвращает клиента к нему.
+func newTestClient(t *testing.T, st Storage) pb.MetricsClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, NewGRPCServer(st))
+	go func() {
+		_ = s.Serve(listener)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewMetricsClient(conn)
+}
+
+func newTestStorage(t *testing.T) *storage.MemStorage {
+	t.Helper()
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	require.NoError(t, st.SetGauge(ctx, metrics.SeriesKey("CPU", map[string]string{"env": "prod", "host": "a"}), 0.5))
+	require.NoError(t, st.SetGauge(ctx, metrics.SeriesKey("CPU", map[string]string{"env": "dev", "host": "b"}), 0.7))
+	require.NoError(t, st.AddCounter(ctx, "PollCount", 5))
+	require.NoError(t, st.AddHistogram(ctx, "Latency", metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}))
+	return st
+}
+
+func TestStreamMetrics(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	client := newTestClient(t, st)
+
+	stream, err := client.StreamMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{Counters: []*pb.Counter{{Id: "PollCount", Delta: 2}}}))
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{Gauges: []*pb.Gauge{{Id: "bad{", Value: 1}}}))
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{
+		Counters: []*pb.Counter{{Id: "PollCount", Delta: 3}},
+		Gauges:   []*pb.Gauge{{Id: "Alloc", Value: 10, Labels: map[string]string{"host": "a"}}},
+	}))
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "1 of 3 batches rejected")
+	assert.Equal(t, []string{"batch[1].gauges[0]"}, violationFields(t, err))
+
+	delta, err := st.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), delta)
+	value, err := st.GetGauge(ctx, metrics.SeriesKey("Alloc", map[string]string{"host": "a"}))
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, value)
+}
+
+func TestGetMetric(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, newTestStorage(t))
+
+	resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "CPU", Type: metrics.Gauge, Labels: map[string]string{"host": "a", "env": "prod"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, resp.GetMetric().GetGauge().GetValue())
+
+	resp, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: metrics.Counter})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), resp.GetMetric().GetCounter().GetDelta())
+
+	resp, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Latency", Type: metrics.Histogram})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 0}, resp.GetMetric().GetHistogram().GetCounts())
+
+	resp, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Missing", Type: metrics.Gauge})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Nil(t, resp)
+
+	_, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "CPU", Type: "unknown"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListMetrics(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, newTestStorage(t))
+
+	resp, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 4)
+	assert.Equal(t, "PollCount", resp.GetMetrics()[0].GetCounter().GetId())
+	assert.Equal(t, "dev", resp.GetMetrics()[1].GetGauge().GetLabels()["env"])
+	assert.Equal(t, "prod", resp.GetMetrics()[2].GetGauge().GetLabels()["env"])
+	assert.Equal(t, "Latency", resp.GetMetrics()[3].GetHistogram().GetId())
+
+	resp, err = client.ListMetrics(ctx, &pb.ListMetricsRequest{Type: metrics.Gauge, Selector: `CPU{env=~"pr.*"}`})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 1)
+	assert.Equal(t, "a", resp.GetMetrics()[0].GetGauge().GetLabels()["host"])
+
+	_, err = client.ListMetrics(ctx, &pb.ListMetricsRequest{Selector: `CPU{env=}`})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "invalid selector")
+}
+
+func TestWatchMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := newTestStorage(t)
+	client := newTestClient(t, st)
+
+	start := time.Now()
+	stream, err := client.WatchMetrics(ctx, &pb.WatchMetricsRequest{
+		Type:     metrics.Counter,
+		Interval: durationpb.New(10 * time.Millisecond),
+	})
+	require.NoError(t, err)
+
+	m, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), m.GetCounter().GetDelta(), "current value must be sent first")
+
+	require.NoError(t, st.SetGauge(ctx, "Ignored", 1))
+	require.NoError(t, st.AddCounter(ctx, "PollCount", 2))
+	m, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "PollCount", m.GetCounter().GetId())
+	assert.Equal(t, int64(7), m.GetCounter().GetDelta())
+	assert.GreaterOrEqual(t, time.Since(start), MinWatchInterval, "interval must not be less than MinWatchInterval")
+}
+
+func TestWatchMetrics_InvalidArgument(t *testing.T) {
+	client := newTestClient(t, storage.NewMemStorage())
+
+	stream, err := client.WatchMetrics(context.Background(), &pb.WatchMetricsRequest{Type: "unknown"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
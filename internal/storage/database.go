@@ -3,12 +3,17 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"log"
-	"strconv"
+	"sort"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/migrations"
 )
 
 // DB определяет интерфейс для взаимодействия с базой данных.
@@ -17,11 +22,21 @@ type DB interface {
 	QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error)
 	QueryRowContext(ctx context.Context, query string, args ...any) (row *sql.Row)
 	Begin() (tx *sql.Tx, err error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error)
+}
+
+// ReadRouter определяет базу данных, которая направляет запросы чтения на реплику.
+type ReadRouter interface {
+	ReadQueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error)
 }
 
 // DBStorage представляет хранилище метрик, использующее базу данных.
+// Если база данных реализует ReadRouter, списки метрик читаются через него. Значения отдельных метрик
+// читаются с основной БД, так как их читают сразу после записи: при отставании реплики ответ был бы устаревшим.
 type DBStorage struct {
 	db DB
+	// now задаёт время записи истории; nil, если история не записывается.
+	now func() time.Time
 }
 
 // NewDBStorage создаёт и возвращает новое хранилище метрик с использованием заданного интерфейса DB.
@@ -29,23 +44,57 @@ func NewDBStorage(db DB) *DBStorage {
 	return &DBStorage{db: db}
 }
 
-// Init инициализирует хранилище, создавая необходимые таблицы в базе данных.
+// Init применяет неприменённые миграции схемы базы данных.
+// Временной ряд определяется именем метрики и набором меток, поэтому уникальность обеспечивается по паре (name, labels).
 func (dbs *DBStorage) Init(ctx context.Context) error {
-	_, err := dbs.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS gauge (id serial PRIMARY KEY, name text UNIQUE, value double precision)`)
+	migrator, err := migrations.NewMigrator(dbs.db)
 	if err != nil {
 		return err
 	}
-	_, err = dbs.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS counter (id serial PRIMARY KEY, name text UNIQUE, value bigint)`)
+	applied, err := migrator.Up(ctx)
 	if err != nil {
 		return err
 	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
 	return nil
 }
 
+// setHistory включает запись истории значений метрик. DBStorage записывает новые значения в таблицу
+// metric_samples, из которой историю читает DBHistory, тем же запросом, что и обновление метрик,
+// поэтому history должен быть DBHistory той же базы данных.
+func (dbs *DBStorage) setHistory(_ HistoryStore, now func() time.Time) {
+	dbs.now = now
+}
+
+// withSamples дополняет запрос обновления таблицы метрик типа mType записью новых значений
+// в metric_samples на момент, переданный последним параметром $4.
+func withSamples(query string, mType string) string {
+	return `WITH updated AS (` + query + ` RETURNING name, labels, value)
+						INSERT INTO metric_samples (type, name, labels, ts, value)
+						SELECT '` + mType + `', name, labels, $4::timestamptz, value FROM updated`
+}
+
+// upsert выполняет запрос обновления таблицы метрик типа mType и, если включена запись истории,
+// тем же запросом записывает новые значения в историю.
+func (dbs *DBStorage) upsert(ctx context.Context, exec func(ctx context.Context, query string, args ...any) (sql.Result, error), mType string, query string, args ...any) error {
+	if dbs.now != nil {
+		query = withSamples(query, mType)
+		args = append(args, dbs.now())
+	}
+	_, err := exec(ctx, query, args...)
+	return err
+}
+
 // SetGauge устанавливает значение метрики типа gauge с заданным именем и значением.
 func (dbs *DBStorage) SetGauge(ctx context.Context, key string, value float64) error {
-	_, err := dbs.db.ExecContext(ctx, `INSERT INTO gauge (name, value) VALUES ($1, $2)
-						ON CONFLICT (name) DO UPDATE SET value = $2`, key, value)
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	err = dbs.upsert(ctx, dbs.db.ExecContext, metrics.Gauge, `INSERT INTO gauge (name, labels, value) VALUES ($1, $2::jsonb, $3)
+						ON CONFLICT (name, labels) DO UPDATE SET value = $3`, name, labels, value)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
@@ -58,8 +107,12 @@ func (dbs *DBStorage) SetGauge(ctx context.Context, key string, value float64) e
 
 // AddCounter увеличивает значение метрики типа counter с заданным именем на указанное значение.
 func (dbs *DBStorage) AddCounter(ctx context.Context, key string, value int64) error {
-	_, err := dbs.db.ExecContext(ctx, `INSERT INTO counter (name, value) VALUES ($1, $2)
-						ON CONFLICT (name) DO UPDATE SET value = counter.value + EXCLUDED.value`, key, value)
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	err = dbs.upsert(ctx, dbs.db.ExecContext, metrics.Counter, `INSERT INTO counter (name, labels, value) VALUES ($1, $2::jsonb, $3)
+						ON CONFLICT (name, labels) DO UPDATE SET value = counter.value + EXCLUDED.value`, name, labels, value)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
@@ -72,10 +125,14 @@ func (dbs *DBStorage) AddCounter(ctx context.Context, key string, value int64) e
 
 // GetCounter возвращает текущее значение метрики типа counter с заданным именем.
 func (dbs *DBStorage) GetCounter(ctx context.Context, key string) (int64, error) {
-	row := dbs.db.QueryRowContext(ctx, `SELECT value FROM counter WHERE name = $1`, key)
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return 0, err
+	}
+	row := dbs.db.QueryRowContext(ctx, `SELECT value FROM counter WHERE name = $1 AND labels = $2::jsonb`, name, labels)
 	var value sql.NullInt64
 
-	err := row.Scan(&value)
+	err = row.Scan(&value)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrNotFound
@@ -90,10 +147,14 @@ func (dbs *DBStorage) GetCounter(ctx context.Context, key string) (int64, error)
 
 // GetGauge возвращает текущее значение метрики типа gauge с заданным именем.
 func (dbs *DBStorage) GetGauge(ctx context.Context, key string) (float64, error) {
-	row := dbs.db.QueryRowContext(ctx, `SELECT value FROM gauge WHERE name = $1`, key)
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return 0, err
+	}
+	row := dbs.db.QueryRowContext(ctx, `SELECT value FROM gauge WHERE name = $1 AND labels = $2::jsonb`, name, labels)
 	var value sql.NullFloat64
 
-	err := row.Scan(&value)
+	err = row.Scan(&value)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrNotFound
@@ -108,7 +169,7 @@ func (dbs *DBStorage) GetGauge(ctx context.Context, key string) (float64, error)
 
 // GetMetrics возвращает все сохранённые метрики типа counter и gauge.
 func (dbs *DBStorage) GetMetrics(ctx context.Context) (map[string]int64, map[string]float64, error) {
-	rowsGauge, err := dbs.db.QueryContext(ctx, `SELECT name, value FROM gauge ORDER BY name`)
+	rowsGauge, err := dbs.readQuery(ctx, `SELECT name, labels, value FROM gauge ORDER BY name`)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -123,13 +184,18 @@ func (dbs *DBStorage) GetMetrics(ctx context.Context) (map[string]int64, map[str
 
 	for rowsGauge.Next() {
 		var name string
+		var labels []byte
 		var value float64
-		err = rowsGauge.Scan(&name, &value)
+		err = rowsGauge.Scan(&name, &labels, &value)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := joinKey(name, labels)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		gauge[name] = value
+		gauge[key] = value
 	}
 
 	err = rowsGauge.Err()
@@ -137,7 +203,7 @@ func (dbs *DBStorage) GetMetrics(ctx context.Context) (map[string]int64, map[str
 		return nil, nil, err
 	}
 
-	rowsCounter, err := dbs.db.QueryContext(ctx, `SELECT name, value FROM counter ORDER BY name`)
+	rowsCounter, err := dbs.readQuery(ctx, `SELECT name, labels, value FROM counter ORDER BY name`)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -152,13 +218,18 @@ func (dbs *DBStorage) GetMetrics(ctx context.Context) (map[string]int64, map[str
 
 	for rowsCounter.Next() {
 		var name string
+		var labels []byte
 		var value int64
-		err = rowsCounter.Scan(&name, &value)
+		err = rowsCounter.Scan(&name, &labels, &value)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := joinKey(name, labels)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		counter[name] = value
+		counter[key] = value
 	}
 
 	err = rowsCounter.Err()
@@ -169,96 +240,325 @@ func (dbs *DBStorage) GetMetrics(ctx context.Context) (map[string]int64, map[str
 	return counter, gauge, nil
 }
 
-// SetMetrics сохраняет переданные метрики типа counter и gauge в базе данных.
+// SetMetrics сохраняет переданные метрики типа counter и gauge в базе данных в одной транзакции.
+// Если включена запись истории, новые значения записываются в неё в той же транзакции.
+// Каждая таблица обновляется одним запросом с массивами вместо списка VALUES,
+// поэтому число параметров запроса не зависит от размера пакета.
 func (dbs *DBStorage) SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
-	tx, err := dbs.db.Begin()
+	if len(counter) == 0 && len(gauge) == 0 {
+		return nil
+	}
+	tx, err := dbs.db.BeginTx(ctx, nil)
 	if err != nil {
-		err = tx.Rollback()
-		if err != nil {
-			return err
+		return err
+	}
+	err = dbs.setCounters(ctx, tx, counter)
+	if err == nil {
+		err = dbs.setGauges(ctx, tx, gauge)
+	}
+	if err != nil {
+		if errRb := tx.Rollback(); errRb != nil {
+			return errRb
 		}
 		return err
 	}
+	return tx.Commit()
+}
 
-	err = dbs.setCounters(ctx, tx, counter)
+func (dbs *DBStorage) setCounters(ctx context.Context, tx *sql.Tx, counter map[string]int64) error {
+	if len(counter) == 0 {
+		return nil
+	}
+	names, labels, values, err := seriesColumns(counter, func(current, value int64) int64 { return current + value })
+	if err != nil {
+		return err
+	}
+	return dbs.upsert(ctx, tx.ExecContext, metrics.Counter, `INSERT INTO counter (name, labels, value)
+						SELECT name, labels::jsonb, value FROM unnest($1::text[], $2::text[], $3::bigint[]) AS t(name, labels, value)
+						ON CONFLICT (name, labels) DO UPDATE SET value = counter.value + EXCLUDED.value`, names, labels, values)
+}
+
+func (dbs *DBStorage) setGauges(ctx context.Context, tx *sql.Tx, gauge map[string]float64) error {
+	if len(gauge) == 0 {
+		return nil
+	}
+	names, labels, values, err := seriesColumns(gauge, func(_, value float64) float64 { return value })
 	if err != nil {
-		err = tx.Rollback()
+		return err
+	}
+	return dbs.upsert(ctx, tx.ExecContext, metrics.Gauge, `INSERT INTO gauge (name, labels, value)
+						SELECT name, labels::jsonb, value FROM unnest($1::text[], $2::text[], $3::double precision[]) AS t(name, labels, value)
+						ON CONFLICT (name, labels) DO UPDATE SET value = EXCLUDED.value`, names, labels, values)
+}
+
+// seriesColumns раскладывает значения пакета по колонкам name, labels и value для unnest.
+// Разные ключи одного временного ряда (например, с другим порядком меток) объединяются функцией merge,
+// так как ON CONFLICT не может обновить одну строку дважды в одном запросе.
+func seriesColumns[V int64 | float64](values map[string]V, merge func(current, value V) V) ([]string, []string, []V, error) {
+	names := make([]string, 0, len(values))
+	labels := make([]string, 0, len(values))
+	columns := make([]V, 0, len(values))
+	index := make(map[[2]string]int, len(values))
+	for _, key := range sortedKeys(values) {
+		name, lbs, err := splitKey(key)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
+		}
+		series := [2]string{name, lbs}
+		if i, ok := index[series]; ok {
+			columns[i] = merge(columns[i], values[key])
+			continue
 		}
+		index[series] = len(names)
+		names = append(names, name)
+		labels = append(labels, lbs)
+		columns = append(columns, values[key])
+	}
+	return names, labels, columns, nil
+}
+
+// AddHistogram добавляет наблюдения к метрике типа histogram с заданным именем.
+// Слияние выполняется в транзакции под блокировкой ключа, чтобы параллельные записи не терялись.
+func (dbs *DBStorage) AddHistogram(ctx context.Context, key string, value metrics.HistogramValue) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
 	}
-	err = dbs.setGauges(ctx, tx, gauge)
+	err = dbs.addHistogram(ctx, tx, key, value)
 	if err != nil {
-		err = tx.Rollback()
-		if err != nil {
-			return err
+		if errRb := tx.Rollback(); errRb != nil {
+			return errRb
 		}
 		return err
 	}
 	return tx.Commit()
 }
 
-func (dbs *DBStorage) setCounters(ctx context.Context, tx *sql.Tx, counter map[string]int64) error {
-	sqlStr := "INSERT INTO counter(name, value) VALUES "
-	vals := make([]interface{}, 0, len(counter))
+// SetSummary устанавливает значение метрики типа summary с заданным именем.
+func (dbs *DBStorage) SetSummary(ctx context.Context, key string, value metrics.SummaryValue) error {
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = dbs.db.ExecContext(ctx, `INSERT INTO summary (name, labels, value) VALUES ($1, $2::jsonb, $3)
+						ON CONFLICT (name, labels) DO UPDATE SET value = EXCLUDED.value`, name, labels, data)
+	return err
+}
 
-	n := 0
-	for name, value := range counter {
-		sqlStr += "($" + strconv.Itoa(n+1) + ", $" + strconv.Itoa(n+2) + "),"
-		n += 2
-		vals = append(vals, name, value)
+// GetHistogram возвращает текущее значение метрики типа histogram с заданным именем.
+func (dbs *DBStorage) GetHistogram(ctx context.Context, key string) (metrics.HistogramValue, error) {
+	var value metrics.HistogramValue
+	err := dbs.getJSON(ctx, `SELECT value FROM histogram WHERE name = $1 AND labels = $2::jsonb`, key, &value)
+	return value, err
+}
+
+// GetSummary возвращает текущее значение метрики типа summary с заданным именем.
+func (dbs *DBStorage) GetSummary(ctx context.Context, key string) (metrics.SummaryValue, error) {
+	var value metrics.SummaryValue
+	err := dbs.getJSON(ctx, `SELECT value FROM summary WHERE name = $1 AND labels = $2::jsonb`, key, &value)
+	return value, err
+}
+
+// GetDistributions возвращает все сохранённые метрики типа histogram и summary.
+func (dbs *DBStorage) GetDistributions(ctx context.Context) (map[string]metrics.HistogramValue, map[string]metrics.SummaryValue, error) {
+	histogram := make(map[string]metrics.HistogramValue)
+	err := dbs.scanJSON(ctx, `SELECT name, labels, value FROM histogram ORDER BY name`, func(key string, data []byte) error {
+		var value metrics.HistogramValue
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		histogram[key] = value
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	// trim the last ,
-	sqlStr = sqlStr[0 : len(sqlStr)-1]
-	sqlStr += "ON CONFLICT (name) DO UPDATE SET value = counter.value + EXCLUDED.value"
 
-	stmt, err := tx.Prepare(sqlStr)
+	summary := make(map[string]metrics.SummaryValue)
+	err = dbs.scanJSON(ctx, `SELECT name, labels, value FROM summary ORDER BY name`, func(key string, data []byte) error {
+		var value metrics.SummaryValue
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		summary[key] = value
+		return nil
+	})
 	if err != nil {
+		return nil, nil, err
+	}
+
+	return histogram, summary, nil
+}
+
+// SetDistributions сохраняет переданные метрики типа histogram и summary в базе данных в одной транзакции.
+func (dbs *DBStorage) SetDistributions(ctx context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = dbs.setDistributions(ctx, tx, histogram, summary)
+	if err != nil {
+		if errRb := tx.Rollback(); errRb != nil {
+			return errRb
+		}
 		return err
 	}
-	defer func(stmt *sql.Stmt) {
-		err = stmt.Close()
+	return tx.Commit()
+}
+
+func (dbs *DBStorage) setDistributions(ctx context.Context, tx *sql.Tx, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error {
+	for _, key := range sortedKeys(histogram) {
+		if err := dbs.addHistogram(ctx, tx, key, histogram[key]); err != nil {
+			return err
+		}
+	}
+	for _, key := range sortedKeys(summary) {
+		name, labels, err := splitKey(key)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-	}(stmt)
+		data, err := json.Marshal(summary[key])
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO summary (name, labels, value) VALUES ($1, $2::jsonb, $3)
+						ON CONFLICT (name, labels) DO UPDATE SET value = EXCLUDED.value`, name, labels, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	_, err = stmt.ExecContext(ctx, vals...)
+func (dbs *DBStorage) addHistogram(ctx context.Context, tx *sql.Tx, key string, value metrics.HistogramValue) error {
+	name, labels, err := splitKey(key)
 	if err != nil {
 		return err
 	}
-	return nil
+	// Блокировка строки FOR UPDATE не защищает ещё не созданную гистограмму, поэтому параллельные записи
+	// одного ключа упорядочиваются транзакционной рекомендательной блокировкой.
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended('histogram:' || $1 || $2, 0))`, name, labels)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	err = tx.QueryRowContext(ctx, `SELECT value FROM histogram WHERE name = $1 AND labels = $2::jsonb`, name, labels).Scan(&data)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	merged := value.Clone()
+	if err == nil {
+		var current metrics.HistogramValue
+		if err = json.Unmarshal(data, &current); err != nil {
+			return err
+		}
+		if err = current.Merge(value); err != nil {
+			return err
+		}
+		merged = current
+	}
+	data, err = json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO histogram (name, labels, value) VALUES ($1, $2::jsonb, $3)
+						ON CONFLICT (name, labels) DO UPDATE SET value = EXCLUDED.value`, name, labels, data)
+	return err
 }
 
-func (dbs *DBStorage) setGauges(ctx context.Context, tx *sql.Tx, gauge map[string]float64) error {
-	sqlStr := "INSERT INTO gauge(name, value) VALUES "
-	vals := make([]interface{}, 0, len(gauge))
-
-	n := 0
-	for name, value := range gauge {
-		sqlStr += "($" + strconv.Itoa(n+1) + ", $" + strconv.Itoa(n+2) + "),"
-		n += 2
-		vals = append(vals, name, value)
+func (dbs *DBStorage) getJSON(ctx context.Context, query string, key string, dst any) error {
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	err = dbs.db.QueryRowContext(ctx, query, name, labels).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if data == nil {
+		return ErrNotValid
 	}
-	// trim the last ,
-	sqlStr = sqlStr[0 : len(sqlStr)-1]
-	sqlStr += "ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value"
+	return json.Unmarshal(data, dst)
+}
 
-	stmt, err := tx.Prepare(sqlStr)
+func (dbs *DBStorage) scanJSON(ctx context.Context, query string, fn func(key string, data []byte) error) error {
+	rows, err := dbs.readQuery(ctx, query)
 	if err != nil {
 		return err
 	}
-	defer func(stmt *sql.Stmt) {
-		err = stmt.Close()
+	defer func(rows *sql.Rows) {
+		err = rows.Close()
 		if err != nil {
 			log.Fatal(err)
 		}
-	}(stmt)
+	}(rows)
 
-	_, err = stmt.ExecContext(ctx, vals...)
+	for rows.Next() {
+		var name string
+		var labels, data []byte
+		if err = rows.Scan(&name, &labels, &data); err != nil {
+			return err
+		}
+		key, err := joinKey(name, labels)
+		if err != nil {
+			return err
+		}
+		if err = fn(key, data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// readQuery выполняет запрос чтения через ReadRouter, если база данных его поддерживает.
+func (dbs *DBStorage) readQuery(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r, ok := dbs.db.(ReadRouter); ok {
+		return r.ReadQueryContext(ctx, query, args...)
+	}
+	return dbs.db.QueryContext(ctx, query, args...)
+}
+
+// splitKey разбирает ключ временного ряда на имя метрики и набор меток в виде JSON для колонки labels.
+func splitKey(key string) (string, string, error) {
+	name, labels, err := metrics.ParseSeriesKey(key)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	return nil
+	if labels == nil {
+		return name, "{}", nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", "", err
+	}
+	return name, string(data), nil
+}
+
+// joinKey собирает ключ временного ряда из имени метрики и значения колонки labels.
+func joinKey(name string, labels []byte) (string, error) {
+	if len(labels) == 0 {
+		return name, nil
+	}
+	var lbs map[string]string
+	if err := json.Unmarshal(labels, &lbs); err != nil {
+		return "", err
+	}
+	return metrics.SeriesKey(name, lbs), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -3,22 +3,34 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/moonicy/gometrics/internal/metrics"
 )
 
-// Тест Init: проверка инициализации таблиц
+// Тест Init: применение неприменённых миграций схемы
 func TestDBStorage_Init(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mock.ExpectExec("CREATE TABLE IF NOT EXISTS gauge").WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec("CREATE TABLE IF NOT EXISTS counter").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(int64(1), time.Now()))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS metric_samples").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE INDEX IF NOT EXISTS metric_samples_series_ts_idx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(2), "metric_samples").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	storage := NewDBStorage(db)
 	err = storage.Init(context.Background())
@@ -32,7 +44,7 @@ func TestDBStorage_SetGauge(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mock.ExpectExec("INSERT INTO gauge").WithArgs("gauge1", 10.5).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO gauge").WithArgs("gauge1", "{}", 10.5).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	storage := NewDBStorage(db)
 	err = storage.SetGauge(context.Background(), "gauge1", 10.5)
@@ -40,6 +52,24 @@ func TestDBStorage_SetGauge(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+// Тест SetGauge: при записи истории новое значение записывается в metric_samples тем же запросом
+func TestDBStorage_SetGauge_History(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("WITH updated AS \\(INSERT INTO gauge .* RETURNING name, labels, value\\)\\s+INSERT INTO metric_samples").
+		WithArgs("gauge1", "{}", 10.5, ts).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	storage := NewDBStorage(db)
+	hs := NewHistoryStorage(storage, NewDBHistory(db), time.Hour)
+	hs.now = func() time.Time { return ts }
+	err = hs.SetGauge(context.Background(), "gauge1", 10.5)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 // Тест SetGauge: проверка обработки ошибки ограничения целостности
 func TestDBStorage_SetGauge_ConflictError(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -61,7 +91,7 @@ func TestDBStorage_AddCounter(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mock.ExpectExec("INSERT INTO counter").WithArgs("counter1", 10).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO counter").WithArgs("counter1", "{}", 10).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	storage := NewDBStorage(db)
 	err = storage.AddCounter(context.Background(), "counter1", 10)
@@ -91,7 +121,7 @@ func TestDBStorage_GetCounter(t *testing.T) {
 	defer db.Close()
 
 	rows := sqlmock.NewRows([]string{"value"}).AddRow(100)
-	mock.ExpectQuery("SELECT value FROM counter").WithArgs("counter1").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT value FROM counter").WithArgs("counter1", "{}").WillReturnRows(rows)
 
 	storage := NewDBStorage(db)
 	value, err := storage.GetCounter(context.Background(), "counter1")
@@ -106,7 +136,7 @@ func TestDBStorage_GetCounter_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT value FROM counter").WithArgs("counter1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT value FROM counter").WithArgs("counter1", "{}").WillReturnError(sql.ErrNoRows)
 
 	storage := NewDBStorage(db)
 	_, err = storage.GetCounter(context.Background(), "counter1")
@@ -121,7 +151,7 @@ func TestDBStorage_GetGauge(t *testing.T) {
 	defer db.Close()
 
 	rows := sqlmock.NewRows([]string{"value"}).AddRow(15.5)
-	mock.ExpectQuery("SELECT value FROM gauge").WithArgs("gauge1").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT value FROM gauge").WithArgs("gauge1", "{}").WillReturnRows(rows)
 
 	storage := NewDBStorage(db)
 	value, err := storage.GetGauge(context.Background(), "gauge1")
@@ -136,7 +166,7 @@ func TestDBStorage_GetGauge_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT value FROM gauge").WithArgs("gauge1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT value FROM gauge").WithArgs("gauge1", "{}").WillReturnError(sql.ErrNoRows)
 
 	storage := NewDBStorage(db)
 	_, err = storage.GetGauge(context.Background(), "gauge1")
@@ -150,29 +180,50 @@ func TestDBStorage_GetMetrics(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	rowsGauge := sqlmock.NewRows([]string{"name", "value"}).AddRow("gauge1", 10.5).AddRow("gauge2", 20.5)
-	rowsCounter := sqlmock.NewRows([]string{"name", "value"}).AddRow("counter1", 100).AddRow("counter2", 200)
+	rowsGauge := sqlmock.NewRows([]string{"name", "labels", "value"}).
+		AddRow("gauge1", []byte(`{}`), 10.5).
+		AddRow("gauge2", []byte(`{"host":"a"}`), 20.5)
+	rowsCounter := sqlmock.NewRows([]string{"name", "labels", "value"}).
+		AddRow("counter1", []byte(`{}`), 100).
+		AddRow("counter2", []byte(`{"env":"prod","host":"a"}`), 200)
 
-	mock.ExpectQuery("SELECT name, value FROM gauge ORDER BY name").WillReturnRows(rowsGauge)
-	mock.ExpectQuery("SELECT name, value FROM counter ORDER BY name").WillReturnRows(rowsCounter)
+	mock.ExpectQuery("SELECT name, labels, value FROM gauge ORDER BY name").WillReturnRows(rowsGauge)
+	mock.ExpectQuery("SELECT name, labels, value FROM counter ORDER BY name").WillReturnRows(rowsCounter)
 
 	storage := NewDBStorage(db)
 	counters, gauges, err := storage.GetMetrics(context.Background())
 	assert.NoError(t, err)
-	assert.Equal(t, map[string]float64{"gauge1": 10.5, "gauge2": 20.5}, gauges)
-	assert.Equal(t, map[string]int64{"counter1": 100, "counter2": 200}, counters)
+	assert.Equal(t, map[string]float64{"gauge1": 10.5, `gauge2{host="a"}`: 20.5}, gauges)
+	assert.Equal(t, map[string]int64{"counter1": 100, `counter2{env="prod",host="a"}`: 200}, counters)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestDBStorage_SetMetrics(t *testing.T) {
-	db, mock, err := sqlmock.New()
+// arrayConverter передаёт срезы в драйвер без преобразования, как это делает pgx для параметров-массивов.
+type arrayConverter struct{}
+
+func (arrayConverter) ConvertValue(v any) (driver.Value, error) {
+	switch v.(type) {
+	case []string, []int64, []float64:
+		return v, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func newArrayMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(arrayConverter{}))
 	assert.NoError(t, err)
-	defer db.Close()
+	t.Cleanup(func() { _ = db.Close() })
+	return db, mock
+}
+
+func TestDBStorage_SetMetrics(t *testing.T) {
+	db, mock := newArrayMock(t)
 
 	// Ожидаемые данные для таблицы counter
 	counter := map[string]int64{
-		"counter1": 100,
-		"counter2": 200,
+		"counter1":           100,
+		`counter2{host="a"}`: 200,
 	}
 
 	// Ожидаемые данные для таблицы gauge
@@ -184,25 +235,314 @@ func TestDBStorage_SetMetrics(t *testing.T) {
 	// Начало транзакции
 	mock.ExpectBegin()
 
-	// Проверка вставки или обновления значений для counter
-	counterQuery := "INSERT INTO counter"
-	mock.ExpectPrepare(counterQuery)
-	mock.ExpectExec(counterQuery).
-		WithArgs("counter1", 100, "counter2", 200).
+	// Каждая таблица обновляется одним запросом с массивами значений
+	mock.ExpectExec("INSERT INTO counter .* unnest").
+		WithArgs([]string{"counter1", "counter2"}, []string{"{}", `{"host":"a"}`}, []int64{100, 200}).
 		WillReturnResult(sqlmock.NewResult(1, 2))
-
-	// Проверка вставки или обновления значений для gauge
-	gaugeQuery := "INSERT INTO gauge"
-	mock.ExpectPrepare(gaugeQuery)
-	mock.ExpectExec(gaugeQuery).
-		WithArgs("gauge1", 10.5, "gauge2", 20.5).
+	mock.ExpectExec("INSERT INTO gauge .* unnest").
+		WithArgs([]string{"gauge1", "gauge2"}, []string{"{}", "{}"}, []float64{10.5, 20.5}).
 		WillReturnResult(sqlmock.NewResult(1, 2))
 
 	// Завершение транзакции
 	mock.ExpectCommit()
 
 	storage := NewDBStorage(db)
-	err = storage.SetMetrics(context.Background(), counter, gauge)
+	err := storage.SetMetrics(context.Background(), counter, gauge)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetMetrics: ключи одного временного ряда с разным порядком меток объединяются в одну строку
+// Тест SetMetrics: при записи истории значения записываются в metric_samples в той же транзакции
+func TestDBStorage_SetMetrics_History(t *testing.T) {
+	db, mock := newArrayMock(t)
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("WITH updated AS \\(INSERT INTO counter .* unnest.* RETURNING .*\\)\\s+INSERT INTO metric_samples .* SELECT 'counter'").
+		WithArgs([]string{"counter1"}, []string{"{}"}, []int64{100}, ts).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("WITH updated AS \\(INSERT INTO gauge .* unnest.* RETURNING .*\\)\\s+INSERT INTO metric_samples .* SELECT 'gauge'").
+		WithArgs([]string{"gauge1"}, []string{"{}"}, []float64{10.5}, ts).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	storage := NewDBStorage(db)
+	hs := NewHistoryStorage(storage, NewDBHistory(db), time.Hour)
+	hs.now = func() time.Time { return ts }
+	err := hs.SetMetrics(context.Background(), map[string]int64{"counter1": 100}, map[string]float64{"gauge1": 10.5})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDBStorage_SetMetrics_DuplicateSeries(t *testing.T) {
+	db, mock := newArrayMock(t)
+
+	counter := map[string]int64{
+		`requests{env="prod",host="a"}`: 1,
+		`requests{host="a",env="prod"}`: 2,
+	}
+	gauge := map[string]float64{
+		`cpu{env="prod",host="a"}`: 0.5,
+		`cpu{host="a",env="prod"}`: 0.7,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO counter").
+		WithArgs([]string{"requests"}, []string{`{"env":"prod","host":"a"}`}, []int64{3}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO gauge").
+		WithArgs([]string{"cpu"}, []string{`{"env":"prod","host":"a"}`}, []float64{0.7}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := NewDBStorage(db).SetMetrics(context.Background(), counter, gauge)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetMetrics: пустой пакет не выполняет запросов, пустая часть пакета пропускается
+func TestDBStorage_SetMetrics_Empty(t *testing.T) {
+	db, mock := newArrayMock(t)
+	storage := NewDBStorage(db)
+
+	assert.NoError(t, storage.SetMetrics(context.Background(), nil, map[string]float64{}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO gauge").
+		WithArgs([]string{"cpu"}, []string{"{}"}, []float64{0.5}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, storage.SetMetrics(context.Background(), nil, map[string]float64{"cpu": 0.5}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetMetrics: большой пакет передаётся тремя параметрами независимо от размера
+func TestDBStorage_SetMetrics_LargeBatch(t *testing.T) {
+	db, mock := newArrayMock(t)
+
+	const size = 40000
+	counter := make(map[string]int64, size)
+	for i := 0; i < size; i++ {
+		counter[fmt.Sprintf("counter%05d", i)] = int64(i)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO counter").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(size, size))
+	mock.ExpectCommit()
+
+	err := NewDBStorage(db).SetMetrics(context.Background(), counter, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetMetrics: ошибка запроса откатывает транзакцию
+func TestDBStorage_SetMetrics_Rollback(t *testing.T) {
+	db, mock := newArrayMock(t)
+
+	pgErr := &pgconn.PgError{Code: pgerrcode.UniqueViolation}
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO counter").WillReturnError(pgErr)
+	mock.ExpectRollback()
+
+	err := NewDBStorage(db).SetMetrics(context.Background(), map[string]int64{"requests": 1}, map[string]float64{"cpu": 1})
+	assert.Equal(t, pgErr, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест AddHistogram: создание новой гистограммы
+func TestDBStorage_AddHistogram_New(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs("latency", "{}").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT value FROM histogram WHERE name = \\$1 AND labels = \\$2::jsonb").
+		WithArgs("latency", "{}").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO histogram").
+		WithArgs("latency", "{}", []byte(`{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	storage := NewDBStorage(db)
+	err = storage.AddHistogram(context.Background(), "latency", metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1})
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+// Тест AddHistogram: слияние с существующей гистограммой
+func TestDBStorage_AddHistogram_Merge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs("latency", "{}").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT value FROM histogram").
+		WithArgs("latency", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`)))
+	mock.ExpectExec("INSERT INTO histogram").
+		WithArgs("latency", "{}", []byte(`{"bounds":[1],"counts":[1,1],"sum":2.5,"count":2}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	storage := NewDBStorage(db)
+	err = storage.AddHistogram(context.Background(), "latency", metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 1}, Sum: 2, Count: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест AddHistogram: несовпадающие границы корзин откатывают транзакцию
+func TestDBStorage_AddHistogram_WrongBounds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs("latency", "{}").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT value FROM histogram").
+		WithArgs("latency", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`)))
+	mock.ExpectRollback()
+
+	storage := NewDBStorage(db)
+	err = storage.AddHistogram(context.Background(), "latency", metrics.HistogramValue{Bounds: []float64{2}, Counts: []uint64{0, 1}, Sum: 2, Count: 1})
+	assert.ErrorIs(t, err, metrics.ErrWrongValue)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetSummary: вставка и обновление метрики типа summary
+func TestDBStorage_SetSummary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO summary").
+		WithArgs("duration", "{}", []byte(`{"quantiles":[{"quantile":0.99,"value":3}],"sum":10,"count":4}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	storage := NewDBStorage(db)
+	err = storage.SetSummary(context.Background(), "duration", metrics.SummaryValue{Quantiles: []metrics.Quantile{{Quantile: 0.99, Value: 3}}, Sum: 10, Count: 4})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест GetHistogram: получение значения и обработка отсутствующей метрики
+func TestDBStorage_GetHistogram(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT value FROM histogram WHERE name = \\$1").
+		WithArgs("latency", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`)))
+	mock.ExpectQuery("SELECT value FROM histogram WHERE name = \\$1").
+		WithArgs("missing", "{}").
+		WillReturnError(sql.ErrNoRows)
+
+	storage := NewDBStorage(db)
+	value, err := storage.GetHistogram(context.Background(), "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}, value)
+
+	_, err = storage.GetHistogram(context.Background(), "missing")
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест GetDistributions: получение всех гистограмм и summary
+func TestDBStorage_GetDistributions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT name, labels, value FROM histogram ORDER BY name").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "labels", "value"}).AddRow("latency", []byte(`{}`), []byte(`{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`)))
+	mock.ExpectQuery("SELECT name, labels, value FROM summary ORDER BY name").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "labels", "value"}).AddRow("duration", []byte(`{}`), []byte(`{"quantiles":[{"quantile":0.5,"value":1}],"sum":2,"count":2}`)))
+
+	storage := NewDBStorage(db)
+	histogram, summary, err := storage.GetDistributions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]metrics.HistogramValue{"latency": {Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}}, histogram)
+	assert.Equal(t, map[string]metrics.SummaryValue{"duration": {Quantiles: []metrics.Quantile{{Quantile: 0.5, Value: 1}}, Sum: 2, Count: 2}}, summary)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetGauge: метки из ключа временного ряда сохраняются в колонку labels
+func TestDBStorage_SetGauge_Labels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO gauge").WithArgs("gauge1", `{"env":"prod","host":"a"}`, 10.5).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	storage := NewDBStorage(db)
+	err = storage.SetGauge(context.Background(), `gauge1{host="a",env="prod"}`, 10.5)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест SetGauge: некорректный ключ временного ряда
+func TestDBStorage_SetGauge_WrongKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	storage := NewDBStorage(db)
+	err = storage.SetGauge(context.Background(), `gauge1{host=~"a"}`, 10.5)
+	assert.ErrorIs(t, err, metrics.ErrWrongLabels)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// replicaDB направляет запросы чтения во вторую базу данных, как RetryableDB с репликой.
+type replicaDB struct {
+	*sql.DB
+	replica *sql.DB
+}
+
+func (r replicaDB) ReadQueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.replica.QueryContext(ctx, query, args...)
+}
+
+// Тест ReadRouter: списки метрик читаются с реплики, запись и чтение отдельных значений — с основной БД
+func TestDBStorage_ReadRouter(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer replica.Close()
+
+	primaryMock.ExpectExec("INSERT INTO gauge").WithArgs("cpu", "{}", 0.5).WillReturnResult(sqlmock.NewResult(1, 1))
+	primaryMock.ExpectQuery("SELECT value FROM gauge").WithArgs("cpu", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(0.5))
+	primaryMock.ExpectQuery("SELECT value FROM counter").WithArgs("requests", "{}").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(3))
+	replicaMock.ExpectQuery("SELECT name, labels, value FROM gauge").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "labels", "value"}).AddRow("cpu", []byte("{}"), 0.5))
+	replicaMock.ExpectQuery("SELECT name, labels, value FROM counter").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "labels", "value"}).AddRow("requests", []byte("{}"), 3))
+
+	storage := NewDBStorage(replicaDB{DB: primary, replica: replica})
+	assert.NoError(t, storage.SetGauge(context.Background(), "cpu", 0.5))
+
+	gauge, err := storage.GetGauge(context.Background(), "cpu")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, gauge)
+	counter, err := storage.GetCounter(context.Background(), "requests")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), counter)
+	counters, gauges, err := storage.GetMetrics(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"requests": 3}, counters)
+	assert.Equal(t, map[string]float64{"cpu": 0.5}, gauges)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
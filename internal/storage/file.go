@@ -9,29 +9,49 @@ import (
 
 	"github.com/moonicy/gometrics/internal/config"
 	"github.com/moonicy/gometrics/internal/file"
+	"github.com/moonicy/gometrics/internal/metrics"
 )
 
 // Consumer определяет интерфейс для чтения событий из файла.
 type Consumer interface {
-	Open() error
+	Open(ctx context.Context) error
 	ReadEvent() (*file.Event, error)
 	Close() error
 }
 
 // Producer определяет интерфейс для записи событий в файл.
 type Producer interface {
-	Open() error
+	Open(ctx context.Context) error
 	WriteEvent(event *file.Event) error
 	Close() error
 }
 
+// WAL определяет интерфейс журнала упреждающей записи.
+type WAL interface {
+	Open(ctx context.Context) error
+	Append(update *file.Update) error
+	Replay(fn func(update *file.Update) error) error
+	Truncate() error
+	Close() error
+}
+
+// walCompactThreshold задаёт число записей журнала, после которого выполняется компактизация
+// при синхронной записи (StoreInterval == 0).
+const walCompactThreshold = 1000
+
 // FileStorage представляет хранилище метрик с использованием файловой системы.
 type FileStorage struct {
 	mem      *MemStorage
+	history  *MemHistory
 	consumer Consumer
 	producer Producer
+	wal      WAL
+	seq      uint64
+	pending  int
 	cfg      config.ServerConfig
-	mx       sync.Mutex
+	// now задаёт время записи истории; nil, если история не записывается.
+	now func() time.Time
+	mx  sync.Mutex
 }
 
 // NewFileStorage создаёт и возвращает новое файловое хранилище метрик.
@@ -39,6 +59,7 @@ func NewFileStorage(cfg config.ServerConfig, consumer Consumer, producer Produce
 	mem := NewMemStorage()
 	fs := &FileStorage{
 		mem:      mem,
+		history:  NewMemHistory(),
 		consumer: consumer,
 		producer: producer,
 		cfg:      cfg,
@@ -46,45 +67,49 @@ func NewFileStorage(cfg config.ServerConfig, consumer Consumer, producer Produce
 	return fs
 }
 
+// SetWAL подключает журнал упреждающей записи.
+// С журналом каждое изменение дописывается в него до подтверждения, а снимок в файле
+// периодически перезаписывается с последующей очисткой журнала.
+func (fs *FileStorage) SetWAL(wal WAL) {
+	fs.wal = wal
+}
+
+// setHistory включает запись истории значений метрик. FileStorage хранит историю сам и сохраняет её
+// вместе с обновлением метрик одной записью, поэтому history должен быть самим FileStorage.
+func (fs *FileStorage) setHistory(_ HistoryStore, now func() time.Time) {
+	fs.now = now
+}
+
 // Init инициализирует файловое хранилище, выполняя восстановление и настройку синхронизации.
 func (fs *FileStorage) Init(ctx context.Context) error {
 	if fs.cfg.Restore {
-		fs.Restore()
+		fs.Restore(ctx)
+	}
+	if fs.wal != nil {
+		if !fs.cfg.Restore {
+			// Записи прошлого запуска не должны примениться к новому снимку.
+			if err := fs.wal.Truncate(); err != nil {
+				return err
+			}
+		}
+		if err := fs.wal.Open(ctx); err != nil {
+			return err
+		}
 	}
-	fs.RunSync()
+	fs.RunSync(ctx)
 	fs.WaitShutDown(ctx)
 
 	return nil
 }
 
-// SetGauge устанавливает значение метрики типа gauge и сохраняет изменения в файл при необходимости.
+// SetGauge устанавливает значение метрики типа gauge и сохраняет изменение.
 func (fs *FileStorage) SetGauge(ctx context.Context, key string, value float64) error {
-	err := fs.mem.SetGauge(ctx, key, value)
-	if err != nil {
-		return err
-	}
-	if fs.cfg.StoreInterval == 0 {
-		err := fs.uploadToFile(ctx)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return fs.write(ctx, &file.Update{Op: file.OpMetrics, Gauge: map[string]float64{key: value}})
 }
 
-// AddCounter увеличивает значение метрики типа counter и сохраняет изменения в файл при необходимости.
+// AddCounter увеличивает значение метрики типа counter и сохраняет изменение.
 func (fs *FileStorage) AddCounter(ctx context.Context, key string, value int64) error {
-	err := fs.mem.AddCounter(ctx, key, value)
-	if err != nil {
-		return err
-	}
-	if fs.cfg.StoreInterval == 0 {
-		err := fs.uploadToFile(ctx)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return fs.write(ctx, &file.Update{Op: file.OpMetrics, Counter: map[string]int64{key: value}})
 }
 
 // GetCounter возвращает текущее значение метрики типа counter.
@@ -102,11 +127,125 @@ func (fs *FileStorage) GetMetrics(ctx context.Context) (map[string]int64, map[st
 	return fs.mem.GetMetrics(ctx)
 }
 
-// SetMetrics сохраняет переданные метрики в памяти.
+// SetMetrics сохраняет переданные метрики и записывает изменение.
 func (fs *FileStorage) SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
-	return fs.mem.SetMetrics(ctx, counter, gauge)
+	return fs.write(ctx, &file.Update{Op: file.OpMetrics, Counter: counter, Gauge: gauge})
+}
+
+// AddHistogram добавляет наблюдения к метрике типа histogram и сохраняет изменение.
+func (fs *FileStorage) AddHistogram(ctx context.Context, key string, value metrics.HistogramValue) error {
+	return fs.write(ctx, &file.Update{Op: file.OpMetrics, Histogram: map[string]metrics.HistogramValue{key: value}})
+}
+
+// SetSummary устанавливает значение метрики типа summary и сохраняет изменение.
+func (fs *FileStorage) SetSummary(ctx context.Context, key string, value metrics.SummaryValue) error {
+	return fs.write(ctx, &file.Update{Op: file.OpMetrics, Summary: map[string]metrics.SummaryValue{key: value}})
 }
 
+// GetHistogram возвращает текущее значение метрики типа histogram.
+func (fs *FileStorage) GetHistogram(ctx context.Context, key string) (metrics.HistogramValue, error) {
+	return fs.mem.GetHistogram(ctx, key)
+}
+
+// GetSummary возвращает текущее значение метрики типа summary.
+func (fs *FileStorage) GetSummary(ctx context.Context, key string) (metrics.SummaryValue, error) {
+	return fs.mem.GetSummary(ctx, key)
+}
+
+// GetDistributions возвращает все сохранённые метрики типа histogram и summary.
+func (fs *FileStorage) GetDistributions(ctx context.Context) (map[string]metrics.HistogramValue, map[string]metrics.SummaryValue, error) {
+	return fs.mem.GetDistributions(ctx)
+}
+
+// SetDistributions сохраняет переданные метрики типа histogram и summary и записывает изменение.
+func (fs *FileStorage) SetDistributions(ctx context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error {
+	return fs.write(ctx, &file.Update{Op: file.OpMetrics, Histogram: histogram, Summary: summary})
+}
+
+// AddSamples сохраняет значения метрик в историю и записывает изменение.
+func (fs *FileStorage) AddSamples(ctx context.Context, ts time.Time, counter map[string]int64, gauge map[string]float64) error {
+	return fs.write(ctx, &file.Update{Op: file.OpSamples, Counter: counter, Gauge: gauge, Timestamp: ts})
+}
+
+// QueryRange возвращает значения временного ряда из истории в интервале [from, to].
+func (fs *FileStorage) QueryRange(ctx context.Context, mType string, key string, from, to time.Time) ([]metrics.Sample, error) {
+	return fs.history.QueryRange(ctx, mType, key, from, to)
+}
+
+// DeleteBefore удаляет из истории значения, записанные раньше ts, и записывает изменение.
+func (fs *FileStorage) DeleteBefore(ctx context.Context, ts time.Time) error {
+	return fs.write(ctx, &file.Update{Op: file.OpDeleteBefore, Timestamp: ts})
+}
+
+// write применяет изменение к памяти и сохраняет его.
+// С журналом изменение дописывается в него, без журнала при StoreInterval == 0 перезаписывается снимок.
+func (fs *FileStorage) write(ctx context.Context, update *file.Update) error {
+	if fs.wal == nil {
+		if err := fs.apply(ctx, update, fs.now); err != nil {
+			return err
+		}
+		if fs.cfg.StoreInterval == 0 {
+			return fs.uploadToFile(ctx)
+		}
+		return nil
+	}
+
+	fs.mx.Lock()
+	if err := fs.apply(ctx, update, fs.now); err != nil {
+		fs.mx.Unlock()
+		return err
+	}
+	fs.seq++
+	update.Seq = fs.seq
+	if err := fs.wal.Append(update); err != nil {
+		fs.mx.Unlock()
+		return err
+	}
+	fs.pending++
+	compact := fs.cfg.StoreInterval == 0 && fs.pending >= walCompactThreshold
+	fs.mx.Unlock()
+
+	if compact {
+		return fs.uploadToFile(ctx)
+	}
+	return nil
+}
+
+// apply применяет запись журнала к хранилищу в памяти.
+// Значения метрик записываются в историю на момент update.Timestamp, а если он не задан - на момент now,
+// который сохраняется в update.Timestamp. Если now равен nil, запись без Timestamp в историю не попадает.
+func (fs *FileStorage) apply(ctx context.Context, update *file.Update, now func() time.Time) error {
+	switch update.Op {
+	case file.OpMetrics:
+		if err := fs.mem.SetDistributions(ctx, update.Histogram, update.Summary); err != nil {
+			return err
+		}
+		if len(update.Counter) == 0 && len(update.Gauge) == 0 {
+			return nil
+		}
+		var history HistoryStore
+		ts := func() time.Time { return update.Timestamp }
+		if !update.Timestamp.IsZero() {
+			history = fs.history
+		} else if now != nil {
+			history = fs.history
+			ts = func() time.Time {
+				update.Timestamp = now()
+				return update.Timestamp
+			}
+		}
+		fs.mem.setMetrics(ctx, update.Counter, update.Gauge, history, ts)
+		return nil
+	case file.OpSamples:
+		return fs.history.AddSamples(ctx, update.Timestamp, update.Counter, update.Gauge)
+	case file.OpDeleteBefore:
+		return fs.history.DeleteBefore(ctx, update.Timestamp)
+	default:
+		return fmt.Errorf("unknown wal operation %q", update.Op)
+	}
+}
+
+// uploadToFile атомарно записывает снимок хранилища в файл и очищает журнал.
 func (fs *FileStorage) uploadToFile(ctx context.Context) error {
 	fs.mx.Lock()
 	defer fs.mx.Unlock()
@@ -114,14 +253,25 @@ func (fs *FileStorage) uploadToFile(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	histogram, summary, err := fs.GetDistributions(ctx)
+	if err != nil {
+		return err
+	}
+
+	counterHistory, gaugeHistory := fs.history.snapshot()
 
 	event := &file.Event{
-		Gauge:     gauge,
-		Counter:   counter,
-		Timestamp: time.Now().Unix(),
+		Gauge:          gauge,
+		Counter:        counter,
+		Histogram:      histogram,
+		Summary:        summary,
+		Timestamp:      time.Now().Unix(),
+		GaugeHistory:   gaugeHistory,
+		CounterHistory: counterHistory,
+		WALSeq:         fs.seq,
 	}
 
-	err = fs.producer.Open()
+	err = fs.producer.Open(ctx)
 	if err != nil {
 		return err
 	}
@@ -135,27 +285,44 @@ func (fs *FileStorage) uploadToFile(ctx context.Context) error {
 	err = fs.producer.WriteEvent(event)
 	if err != nil {
 		fmt.Println("Error writing event:", err)
+		return nil
+	}
+	if fs.wal != nil {
+		// Записи с номером не больше WALSeq уже в снимке, поэтому сбой до очистки не приведёт к повторному применению.
+		if err = fs.wal.Truncate(); err != nil {
+			return err
+		}
+		fs.pending = 0
 	}
 	return nil
 }
 
-// RunSync запускает периодическую синхронизацию метрик с файлом.
-func (fs *FileStorage) RunSync() {
+// RunSync запускает периодическую синхронизацию метрик с файлом до завершения ctx.
+func (fs *FileStorage) RunSync(ctx context.Context) {
 	if fs.cfg.StoreInterval == 0 {
 		return
 	}
 	go func() {
-		time.Sleep(fs.cfg.StoreInterval)
-		err := fs.uploadToFile(context.Background())
-		if err != nil {
-			log.Println("Error uploading file:", err)
+		ticker := time.NewTicker(fs.cfg.StoreInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := fs.uploadToFile(ctx)
+				if err != nil {
+					log.Println("Error uploading file:", err)
+				}
+			}
 		}
 	}()
 }
 
 // Restore восстанавливает метрики из файла при запуске сервера.
-func (fs *FileStorage) Restore() {
-	err := fs.consumer.Open()
+// Если подключён журнал, поверх снимка применяются записи, не вошедшие в него.
+func (fs *FileStorage) Restore(ctx context.Context) {
+	err := fs.consumer.Open(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -170,6 +337,31 @@ func (fs *FileStorage) Restore() {
 	if data != nil {
 		fs.mem.gauge = data.Gauge
 		fs.mem.counter = data.Counter
+		if data.Histogram != nil {
+			fs.mem.histogram = data.Histogram
+		}
+		if data.Summary != nil {
+			fs.mem.summary = data.Summary
+		}
+		fs.history.restore(data.CounterHistory, data.GaugeHistory)
+		fs.seq = data.WALSeq
+	}
+	if fs.wal == nil {
+		return
+	}
+	err = fs.wal.Replay(func(update *file.Update) error {
+		if update.Seq <= fs.seq {
+			return nil
+		}
+		if err := fs.apply(ctx, update, nil); err != nil {
+			return err
+		}
+		fs.seq = update.Seq
+		fs.pending++
+		return nil
+	})
+	if err != nil {
+		panic(err)
 	}
 }
 
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"log"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/moonicy/gometrics/internal/config"
 	"github.com/moonicy/gometrics/internal/file"
+	"github.com/moonicy/gometrics/internal/metrics"
 )
 
 type MockConsumer struct {
@@ -17,7 +20,7 @@ type MockConsumer struct {
 	Closed bool
 }
 
-func (m *MockConsumer) Open() error {
+func (m *MockConsumer) Open(_ context.Context) error {
 	m.Opened = true
 	return nil
 }
@@ -44,7 +47,7 @@ type MockProducer struct {
 	WriteFn func(event *file.Event) error
 }
 
-func (m *MockProducer) Open() error {
+func (m *MockProducer) Open(_ context.Context) error {
 	if m.FailOn == "Open" {
 		return errors.New("open error")
 	}
@@ -85,7 +88,7 @@ func TestNewFileStorage(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := NewFileStorage(cfg, mockConsumer, mockProducer)
 
-	if fs.cfg != cfg {
+	if !reflect.DeepEqual(fs.cfg, cfg) {
 		t.Errorf("Expected cfg to be %+v, got %+v", cfg, fs.cfg)
 	}
 	if fs.consumer != mockConsumer {
@@ -108,6 +111,7 @@ func TestFileStorage_SetGauge(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      cfg,
@@ -149,6 +153,7 @@ func TestFileStorage_AddCounter(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      cfg,
@@ -188,6 +193,7 @@ func TestFileStorage_GetCounter(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 	}
@@ -208,6 +214,7 @@ func TestFileStorage_GetGauge(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 	}
@@ -229,6 +236,7 @@ func TestFileStorage_GetMetrics(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 	}
@@ -252,6 +260,7 @@ func TestFileStorage_SetMetrics(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      config.ServerConfig{},
@@ -295,6 +304,7 @@ func TestFileStorage_Init_Restore(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      cfg,
@@ -314,8 +324,11 @@ func TestFileStorage_Init_Restore(t *testing.T) {
 }
 
 func TestFileStorage_RunSync(t *testing.T) {
+	ctxt, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	cfg := config.ServerConfig{
-		StoreInterval: 1,
+		StoreInterval: 100 * time.Millisecond,
 	}
 	mockMem := NewMemStorage()
 	mockMem.gauge["cpu"] = 0.90
@@ -324,17 +337,22 @@ func TestFileStorage_RunSync(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      cfg,
 	}
 
-	fs.RunSync()
+	fs.RunSync(ctxt)
 
-	time.Sleep(2 * time.Second)
+	time.Sleep(350 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
 
-	if len(mockProducer.Events) != 1 {
-		t.Errorf("Expected 1 event to be written, got %d", len(mockProducer.Events))
+	fs.mx.Lock()
+	defer fs.mx.Unlock()
+	if len(mockProducer.Events) < 2 {
+		t.Fatalf("Expected periodic sync to write several events, got %d", len(mockProducer.Events))
 	}
 
 	event := mockProducer.Events[0]
@@ -359,6 +377,7 @@ func TestFileStorage_uploadToFile_ErrorOnProducerOpen(t *testing.T) {
 	}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      cfg,
@@ -383,6 +402,7 @@ func TestFileStorage_WaitShutDown(t *testing.T) {
 	mockProducer := &MockProducer{}
 	fs := &FileStorage{
 		mem:      mockMem,
+		history:  NewMemHistory(),
 		consumer: mockConsumer,
 		producer: mockProducer,
 		cfg:      cfg,
@@ -411,3 +431,283 @@ func TestFileStorage_WaitShutDown(t *testing.T) {
 		t.Errorf("Expected counter 'errors' to be 25, got %v", event.Counter["errors"])
 	}
 }
+
+func TestFileStorage_AddHistogram(t *testing.T) {
+	cfg := config.ServerConfig{
+		StoreInterval: 0,
+	}
+	mockProducer := &MockProducer{}
+	fs := &FileStorage{
+		mem:      NewMemStorage(),
+		history:  NewMemHistory(),
+		consumer: &MockConsumer{},
+		producer: mockProducer,
+		cfg:      cfg,
+	}
+
+	value := metrics.HistogramValue{Bounds: []float64{1}, Counts: []uint64{0, 1}, Sum: 3, Count: 1}
+	err := fs.AddHistogram(ctx, "latency", value)
+	if err != nil {
+		t.Fatalf("AddHistogram returned error: %v", err)
+	}
+	err = fs.SetSummary(ctx, "duration", metrics.SummaryValue{Sum: 1, Count: 1})
+	if err != nil {
+		t.Fatalf("SetSummary returned error: %v", err)
+	}
+
+	if len(mockProducer.Events) != 2 {
+		t.Fatalf("Expected 2 events to be written, got %d", len(mockProducer.Events))
+	}
+	event := mockProducer.Events[1]
+	if event.Histogram["latency"].Count != 1 {
+		t.Errorf("Expected histogram 'latency' count to be 1, got %v", event.Histogram["latency"].Count)
+	}
+	if event.Summary["duration"].Sum != 1 {
+		t.Errorf("Expected summary 'duration' sum to be 1, got %v", event.Summary["duration"].Sum)
+	}
+}
+
+func TestFileStorage_RestoreDistributions(t *testing.T) {
+	mockConsumer := &MockConsumer{
+		Events: []*file.Event{
+			{
+				Gauge:     map[string]float64{"cpu": 0.5},
+				Counter:   map[string]int64{"requests": 1},
+				Histogram: map[string]metrics.HistogramValue{"latency": {Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1}},
+				Summary:   map[string]metrics.SummaryValue{"duration": {Sum: 2, Count: 1}},
+			},
+		},
+	}
+	fs := &FileStorage{
+		mem:      NewMemStorage(),
+		history:  NewMemHistory(),
+		consumer: mockConsumer,
+		producer: &MockProducer{},
+	}
+
+	fs.Restore(ctx)
+
+	histogram, err := fs.GetHistogram(ctx, "latency")
+	if err != nil {
+		t.Fatalf("GetHistogram returned error: %v", err)
+	}
+	if histogram.Count != 1 {
+		t.Errorf("Expected histogram 'latency' count to be 1, got %v", histogram.Count)
+	}
+	summary, err := fs.GetSummary(ctx, "duration")
+	if err != nil {
+		t.Fatalf("GetSummary returned error: %v", err)
+	}
+	if summary.Sum != 2 {
+		t.Errorf("Expected summary 'duration' sum to be 2, got %v", summary.Sum)
+	}
+}
+
+func TestFileStorage_History(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockProducer := &MockProducer{}
+	fs := NewFileStorage(config.ServerConfig{StoreInterval: 0}, &MockConsumer{}, mockProducer)
+
+	err := fs.AddSamples(ctx, ts, map[string]int64{"requests": 3}, map[string]float64{"cpu": 0.5})
+	if err != nil {
+		t.Fatalf("AddSamples returned error: %v", err)
+	}
+	if len(mockProducer.Events) != 1 {
+		t.Fatalf("Expected 1 event to be written, got %d", len(mockProducer.Events))
+	}
+	event := mockProducer.Events[0]
+	if len(event.GaugeHistory["cpu"]) != 1 || len(event.CounterHistory["requests"]) != 1 {
+		t.Errorf("Expected history to be written, got %+v %+v", event.GaugeHistory, event.CounterHistory)
+	}
+
+	restored := NewFileStorage(config.ServerConfig{}, &MockConsumer{Events: []*file.Event{event}}, &MockProducer{})
+	restored.Restore(ctx)
+
+	samples, err := restored.QueryRange(ctx, metrics.Gauge, "cpu", ts, ts)
+	if err != nil {
+		t.Fatalf("QueryRange returned error: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Value != 0.5 {
+		t.Errorf("Expected restored sample 0.5, got %v", samples)
+	}
+}
+
+func TestFileStorage_HistoryStorage_SingleWrite(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockProducer := &MockProducer{}
+	fs := NewFileStorage(config.ServerConfig{StoreInterval: 0}, &MockConsumer{}, mockProducer)
+	hs := NewHistoryStorage(fs, fs, time.Hour)
+	hs.now = func() time.Time { return ts }
+
+	if err := hs.AddCounter(ctx, "requests", 3); err != nil {
+		t.Fatalf("AddCounter returned error: %v", err)
+	}
+	if err := hs.SetMetrics(ctx, map[string]int64{"requests": 2}, map[string]float64{"cpu": 0.5}); err != nil {
+		t.Fatalf("SetMetrics returned error: %v", err)
+	}
+	if len(mockProducer.Events) != 2 {
+		t.Fatalf("Expected one snapshot per update, got %d", len(mockProducer.Events))
+	}
+	event := mockProducer.Events[1]
+	if h := event.CounterHistory["requests"]; len(h) != 2 || h[0].Value != 3 || h[1].Value != 5 {
+		t.Errorf("Expected counter history [3 5] in snapshot, got %v", h)
+	}
+	if h := event.GaugeHistory["cpu"]; len(h) != 1 || h[0].Value != 0.5 {
+		t.Errorf("Expected gauge history [0.5] in snapshot, got %v", h)
+	}
+}
+
+func TestFileStorage_WAL_RestoresHistory(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := config.ServerConfig{Restore: true, StoreInterval: time.Hour}
+
+	fs := newWALFileStorage(dir, cfg)
+	NewHistoryStorage(fs, fs, time.Hour).now = func() time.Time { return ts }
+	if err := fs.Init(context.Background()); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+	if err := fs.AddCounter(ctx, "requests", 5); err != nil {
+		t.Fatalf("AddCounter returned error: %v", err)
+	}
+
+	// Значение и его история восстанавливаются из одной записи журнала.
+	restored := newWALFileStorage(dir, cfg)
+	if err := restored.Init(context.Background()); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+	if restored.seq != 1 {
+		t.Errorf("Expected one journal record, got %d", restored.seq)
+	}
+	samples, err := restored.QueryRange(ctx, metrics.Counter, "requests", ts, ts)
+	if err != nil {
+		t.Fatalf("QueryRange returned error: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Value != 5 {
+		t.Errorf("Expected restored sample 5, got %v", samples)
+	}
+}
+
+func newWALFileStorage(dir string, cfg config.ServerConfig) *FileStorage {
+	path := filepath.Join(dir, "metrics.json")
+	fs := NewFileStorage(cfg, file.NewConsumer(path), file.NewProducer(path))
+	fs.SetWAL(file.NewWAL(path + ".wal"))
+	return fs
+}
+
+func TestFileStorage_WAL_RestoreAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := config.ServerConfig{Restore: true, StoreInterval: time.Hour}
+
+	fs := newWALFileStorage(dir, cfg)
+	if err := fs.Init(context.Background()); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+	if err := fs.AddCounter(ctx, "requests", 5); err != nil {
+		t.Fatalf("AddCounter returned error: %v", err)
+	}
+	if err := fs.SetGauge(ctx, "cpu", 0.5); err != nil {
+		t.Fatalf("SetGauge returned error: %v", err)
+	}
+	if err := fs.uploadToFile(ctx); err != nil {
+		t.Fatalf("uploadToFile returned error: %v", err)
+	}
+	if err := fs.AddCounter(ctx, "requests", 2); err != nil {
+		t.Fatalf("AddCounter returned error: %v", err)
+	}
+	if err := fs.AddSamples(ctx, ts, nil, map[string]float64{"cpu": 0.7}); err != nil {
+		t.Fatalf("AddSamples returned error: %v", err)
+	}
+
+	// Хранилище не завершает работу штатно: последние изменения есть только в журнале.
+	restored := newWALFileStorage(dir, cfg)
+	if err := restored.Init(context.Background()); err != nil {
+		t.Fatalf("Init returned error: %v", err)
+	}
+
+	if val, _ := restored.GetCounter(ctx, "requests"); val != 7 {
+		t.Errorf("Expected counter 'requests' to be 7, got %v", val)
+	}
+	if val, _ := restored.GetGauge(ctx, "cpu"); val != 0.5 {
+		t.Errorf("Expected gauge 'cpu' to be 0.5, got %v", val)
+	}
+	samples, err := restored.QueryRange(ctx, metrics.Gauge, "cpu", ts, ts)
+	if err != nil {
+		t.Fatalf("QueryRange returned error: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Value != 0.7 {
+		t.Errorf("Expected restored sample 0.7, got %v", samples)
+	}
+	if restored.seq != 4 {
+		t.Errorf("Expected sequence to continue from 4, got %d", restored.seq)
+	}
+}
+
+func TestFileStorage_WAL_SkipsCompactedRecords(t *testing.T) {
+	dir := t.TempDir()
+	wal := file.NewWAL(filepath.Join(dir, "metrics.wal"))
+	if err := wal.Open(ctx); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	for seq := uint64(1); seq <= 3; seq++ {
+		if err := wal.Append(&file.Update{Seq: seq, Op: file.OpMetrics, Counter: map[string]int64{"requests": 1}}); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// Снимок записан, но журнал не успели очистить до сбоя.
+	snapshot := &file.Event{
+		Gauge:   map[string]float64{},
+		Counter: map[string]int64{"requests": 2},
+		WALSeq:  2,
+	}
+	fs := NewFileStorage(config.ServerConfig{}, &MockConsumer{Events: []*file.Event{snapshot}}, &MockProducer{})
+	fs.SetWAL(wal)
+	fs.Restore(ctx)
+
+	if val, _ := fs.GetCounter(ctx, "requests"); val != 3 {
+		t.Errorf("Expected counter 'requests' to be 3, got %v", val)
+	}
+}
+
+func TestFileStorage_WAL_CompactsOnThreshold(t *testing.T) {
+	dir := t.TempDir()
+	mockProducer := &MockProducer{}
+	fs := NewFileStorage(config.ServerConfig{StoreInterval: 0}, &MockConsumer{}, mockProducer)
+	wal := file.NewWAL(filepath.Join(dir, "metrics.wal"))
+	fs.SetWAL(wal)
+	if err := wal.Open(ctx); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer wal.Close()
+
+	for i := 0; i < walCompactThreshold-1; i++ {
+		if err := fs.AddCounter(ctx, "requests", 1); err != nil {
+			t.Fatalf("AddCounter returned error: %v", err)
+		}
+	}
+	if len(mockProducer.Events) != 0 {
+		t.Fatalf("Expected no snapshot before threshold, got %d", len(mockProducer.Events))
+	}
+	if err := fs.AddCounter(ctx, "requests", 1); err != nil {
+		t.Fatalf("AddCounter returned error: %v", err)
+	}
+	if len(mockProducer.Events) != 1 {
+		t.Fatalf("Expected snapshot after threshold, got %d", len(mockProducer.Events))
+	}
+	if got := mockProducer.Events[0].WALSeq; got != walCompactThreshold {
+		t.Errorf("Expected snapshot WALSeq %d, got %d", walCompactThreshold, got)
+	}
+
+	records := 0
+	if err := wal.Replay(func(*file.Update) error { records++; return nil }); err != nil {
+		t.Fatalf("Replay returned error: %v", err)
+	}
+	if records != 0 {
+		t.Errorf("Expected WAL to be truncated, got %d records", records)
+	}
+}
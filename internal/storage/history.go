@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// HistoryStore определяет интерфейс для хранения истории значений метрик типа counter и gauge.
+type HistoryStore interface {
+	// AddSamples сохраняет значения метрик на момент времени ts.
+	AddSamples(ctx context.Context, ts time.Time, counter map[string]int64, gauge map[string]float64) error
+	// QueryRange возвращает значения временного ряда в интервале [from, to], упорядоченные по времени.
+	QueryRange(ctx context.Context, mType string, key string, from, to time.Time) ([]metrics.Sample, error)
+	// DeleteBefore удаляет значения, записанные раньше ts.
+	DeleteBefore(ctx context.Context, ts time.Time) error
+}
+
+// MemHistory представляет хранилище истории значений метрик в памяти.
+type MemHistory struct {
+	counter map[string][]metrics.Sample
+	gauge   map[string][]metrics.Sample
+	mx      sync.Mutex
+}
+
+// NewMemHistory создаёт и возвращает новое хранилище истории в памяти.
+func NewMemHistory() *MemHistory {
+	return &MemHistory{
+		counter: make(map[string][]metrics.Sample),
+		gauge:   make(map[string][]metrics.Sample),
+	}
+}
+
+// AddSamples сохраняет значения метрик на момент времени ts.
+func (mh *MemHistory) AddSamples(_ context.Context, ts time.Time, counter map[string]int64, gauge map[string]float64) error {
+	mh.mx.Lock()
+	defer mh.mx.Unlock()
+	for k, v := range counter {
+		mh.counter[k] = insertSample(mh.counter[k], metrics.Sample{Timestamp: ts, Value: float64(v)})
+	}
+	for k, v := range gauge {
+		mh.gauge[k] = insertSample(mh.gauge[k], metrics.Sample{Timestamp: ts, Value: v})
+	}
+	return nil
+}
+
+// QueryRange возвращает значения временного ряда в интервале [from, to], упорядоченные по времени.
+func (mh *MemHistory) QueryRange(_ context.Context, mType string, key string, from, to time.Time) ([]metrics.Sample, error) {
+	mh.mx.Lock()
+	defer mh.mx.Unlock()
+	var samples []metrics.Sample
+	switch mType {
+	case metrics.Counter:
+		samples = mh.counter[key]
+	case metrics.Gauge:
+		samples = mh.gauge[key]
+	default:
+		return nil, metrics.ErrUnknownMetric
+	}
+	start := sort.Search(len(samples), func(i int) bool { return !samples[i].Timestamp.Before(from) })
+	end := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp.After(to) })
+	result := make([]metrics.Sample, 0, max(end-start, 0))
+	if start < end {
+		result = append(result, samples[start:end]...)
+	}
+	return result, nil
+}
+
+// DeleteBefore удаляет значения, записанные раньше ts.
+func (mh *MemHistory) DeleteBefore(_ context.Context, ts time.Time) error {
+	mh.mx.Lock()
+	defer mh.mx.Unlock()
+	pruneSamples(mh.counter, ts)
+	pruneSamples(mh.gauge, ts)
+	return nil
+}
+
+func (mh *MemHistory) snapshot() (counter map[string][]metrics.Sample, gauge map[string][]metrics.Sample) {
+	mh.mx.Lock()
+	defer mh.mx.Unlock()
+	return cloneSamples(mh.counter), cloneSamples(mh.gauge)
+}
+
+func (mh *MemHistory) restore(counter map[string][]metrics.Sample, gauge map[string][]metrics.Sample) {
+	mh.mx.Lock()
+	defer mh.mx.Unlock()
+	if counter != nil {
+		mh.counter = counter
+	}
+	if gauge != nil {
+		mh.gauge = gauge
+	}
+}
+
+// insertSample добавляет значение, сохраняя упорядоченность по времени.
+func insertSample(samples []metrics.Sample, s metrics.Sample) []metrics.Sample {
+	if len(samples) == 0 || !s.Timestamp.Before(samples[len(samples)-1].Timestamp) {
+		return append(samples, s)
+	}
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp.After(s.Timestamp) })
+	samples = append(samples, metrics.Sample{})
+	copy(samples[i+1:], samples[i:])
+	samples[i] = s
+	return samples
+}
+
+func pruneSamples(series map[string][]metrics.Sample, ts time.Time) {
+	for k, samples := range series {
+		i := sort.Search(len(samples), func(i int) bool { return !samples[i].Timestamp.Before(ts) })
+		if i == len(samples) {
+			delete(series, k)
+			continue
+		}
+		series[k] = append([]metrics.Sample(nil), samples[i:]...)
+	}
+}
+
+func cloneSamples(series map[string][]metrics.Sample) map[string][]metrics.Sample {
+	result := make(map[string][]metrics.Sample, len(series))
+	for k, samples := range series {
+		result[k] = append([]metrics.Sample(nil), samples...)
+	}
+	return result
+}
+
+type initable interface {
+	Init(ctx context.Context) error
+}
+
+// MetricStorage определяет интерфейс хранилища метрик, к которому HistoryStorage добавляет историю значений.
+// Историю записывает само хранилище в своём пути записи, поэтому интерфейс реализуют только хранилища этого пакета.
+type MetricStorage interface {
+	Init(ctx context.Context) error
+	SetGauge(ctx context.Context, key string, value float64) error
+	AddCounter(ctx context.Context, key string, value int64) error
+	GetCounter(ctx context.Context, key string) (value int64, err error)
+	GetGauge(ctx context.Context, key string) (value float64, err error)
+	GetMetrics(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
+	SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
+	AddHistogram(ctx context.Context, key string, value metrics.HistogramValue) error
+	SetSummary(ctx context.Context, key string, value metrics.SummaryValue) error
+	GetHistogram(ctx context.Context, key string) (value metrics.HistogramValue, err error)
+	GetSummary(ctx context.Context, key string) (value metrics.SummaryValue, err error)
+	GetDistributions(ctx context.Context) (histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue, err error)
+	SetDistributions(ctx context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error
+	// setHistory включает запись истории: после каждого обновления counter и gauge их новые значения
+	// на момент now записываются в history под той же блокировкой или в той же транзакции, что и обновление.
+	setHistory(history HistoryStore, now func() time.Time)
+}
+
+// HistoryStorage дополняет хранилище метрик записью истории значений counter и gauge.
+// После каждого обновления хранилище метрик записывает в историю новое значение метрики,
+// значения старше retention периодически удаляются.
+type HistoryStorage struct {
+	MetricStorage
+	history   HistoryStore
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewHistoryStorage создаёт хранилище, записывающее историю значений метрик storage в history.
+func NewHistoryStorage(storage MetricStorage, history HistoryStore, retention time.Duration) *HistoryStorage {
+	hs := &HistoryStorage{
+		MetricStorage: storage,
+		history:       history,
+		retention:     retention,
+		now:           time.Now,
+	}
+	storage.setHistory(history, func() time.Time { return hs.now().UTC() })
+	return hs
+}
+
+// Init инициализирует хранилище метрик и хранилище истории и запускает удаление устаревших значений.
+func (hs *HistoryStorage) Init(ctx context.Context) error {
+	if err := hs.MetricStorage.Init(ctx); err != nil {
+		return err
+	}
+	// FileStorage сам хранит историю и уже инициализирован выше.
+	if in, ok := hs.history.(initable); ok && any(hs.history) != any(hs.MetricStorage) {
+		if err := in.Init(ctx); err != nil {
+			return err
+		}
+	}
+	hs.RunRetention(ctx)
+	return nil
+}
+
+// QueryRange возвращает значения временного ряда в интервале [from, to].
+func (hs *HistoryStorage) QueryRange(ctx context.Context, mType string, key string, from, to time.Time) ([]metrics.Sample, error) {
+	return hs.history.QueryRange(ctx, mType, key, from, to)
+}
+
+// ApplyCounterFunc вычисляет функцию cf над историей значений counter mn в интервале, заканчивающемся текущим моментом.
+// Если counter не найден, возвращает ErrNotFound.
+func (hs *HistoryStorage) ApplyCounterFunc(ctx context.Context, mn metrics.MetricName, cf metrics.CounterFunc) (metrics.FuncResult, error) {
+	if err := cf.Validate(); err != nil {
+		return metrics.FuncResult{}, err
+	}
+	key := mn.Key()
+	if _, err := hs.MetricStorage.GetCounter(ctx, key); err != nil {
+		return metrics.FuncResult{}, err
+	}
+	to := hs.now().UTC()
+	from := cf.From(to)
+	if from.After(to) {
+		return metrics.FuncResult{}, metrics.ErrWrongFunc
+	}
+	samples, err := hs.history.QueryRange(ctx, metrics.Counter, key, from, to)
+	if err != nil {
+		return metrics.FuncResult{}, err
+	}
+	return metrics.FuncResult{
+		MetricName:  mn,
+		CounterFunc: cf,
+		Value:       cf.Apply(samples),
+		From:        from,
+		To:          to,
+	}, nil
+}
+
+// RunRetention запускает периодическое удаление значений старше retention до завершения ctx.
+func (hs *HistoryStorage) RunRetention(ctx context.Context) {
+	if hs.retention <= 0 {
+		return
+	}
+	interval := min(hs.retention, time.Minute)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := hs.history.DeleteBefore(ctx, hs.now().Add(-hs.retention)); err != nil {
+					log.Println("Error deleting history:", err)
+				}
+			}
+		}
+	}()
+}
+
+// recordSamples записывает в history значения метрик на момент ts.
+// Ошибка записи истории не отменяет уже выполненное обновление метрик.
+func recordSamples(ctx context.Context, history HistoryStore, ts time.Time, counter map[string]int64, gauge map[string]float64) {
+	if len(counter) == 0 && len(gauge) == 0 {
+		return
+	}
+	if err := history.AddSamples(ctx, ts, counter, gauge); err != nil {
+		log.Println("Error writing history:", err)
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// DBHistory представляет хранилище истории значений метрик в базе данных.
+// Таблица metric_samples создаётся миграциями схемы при инициализации DBStorage.
+type DBHistory struct {
+	db DB
+}
+
+// NewDBHistory создаёт и возвращает новое хранилище истории в базе данных.
+func NewDBHistory(db DB) *DBHistory {
+	return &DBHistory{db: db}
+}
+
+// AddSamples сохраняет значения метрик на момент времени ts одним запросом с массивами значений.
+func (dh *DBHistory) AddSamples(ctx context.Context, ts time.Time, counter map[string]int64, gauge map[string]float64) error {
+	if len(counter) == 0 && len(gauge) == 0 {
+		return nil
+	}
+	size := len(counter) + len(gauge)
+	types := make([]string, 0, size)
+	names := make([]string, 0, size)
+	labels := make([]string, 0, size)
+	values := make([]float64, 0, size)
+
+	add := func(mType, key string, value float64) error {
+		name, lbs, err := splitKey(key)
+		if err != nil {
+			return err
+		}
+		types = append(types, mType)
+		names = append(names, name)
+		labels = append(labels, lbs)
+		values = append(values, value)
+		return nil
+	}
+	for _, key := range sortedKeys(counter) {
+		if err := add(metrics.Counter, key, float64(counter[key])); err != nil {
+			return err
+		}
+	}
+	for _, key := range sortedKeys(gauge) {
+		if err := add(metrics.Gauge, key, gauge[key]); err != nil {
+			return err
+		}
+	}
+
+	_, err := dh.db.ExecContext(ctx, `INSERT INTO metric_samples (type, name, labels, ts, value)
+		SELECT type, name, labels::jsonb, $5::timestamptz, value
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::double precision[]) AS t(type, name, labels, value)`,
+		types, names, labels, values, ts)
+	return err
+}
+
+// QueryRange возвращает значения временного ряда в интервале [from, to], упорядоченные по времени.
+func (dh *DBHistory) QueryRange(ctx context.Context, mType string, key string, from, to time.Time) ([]metrics.Sample, error) {
+	name, labels, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := dh.db.QueryContext(ctx, `SELECT ts, value FROM metric_samples
+		WHERE type = $1 AND name = $2 AND labels = $3::jsonb AND ts >= $4 AND ts <= $5 ORDER BY ts`,
+		mType, name, labels, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := make([]metrics.Sample, 0)
+	for rows.Next() {
+		var s metrics.Sample
+		if err = rows.Scan(&s.Timestamp, &s.Value); err != nil {
+			return nil, err
+		}
+		s.Timestamp = s.Timestamp.UTC()
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// DeleteBefore удаляет значения, записанные раньше ts.
+func (dh *DBHistory) DeleteBefore(ctx context.Context, ts time.Time) error {
+	_, err := dh.db.ExecContext(ctx, `DELETE FROM metric_samples WHERE ts < $1`, ts)
+	return err
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// Тест AddSamples: запись значений counter и gauge одним запросом
+func TestDBHistory_AddSamples(t *testing.T) {
+	db, mock := newArrayMock(t)
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("INSERT INTO metric_samples .* unnest").
+		WithArgs([]string{metrics.Counter, metrics.Gauge}, []string{"requests", "cpu"}, []string{"{}", `{"host":"a"}`}, []float64{3, 0.5}, ts).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+
+	err := NewDBHistory(db).AddSamples(context.Background(), ts,
+		map[string]int64{"requests": 3},
+		map[string]float64{metrics.SeriesKey("cpu", map[string]string{"host": "a"}): 0.5})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест AddSamples: пустой набор значений не выполняет запрос
+func TestDBHistory_AddSamples_Empty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = NewDBHistory(db).AddSamples(context.Background(), time.Now(), nil, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест QueryRange: выборка временного ряда за интервал
+func TestDBHistory_QueryRange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Minute)
+	rows := sqlmock.NewRows([]string{"ts", "value"}).
+		AddRow(from, 1.0).
+		AddRow(from.Add(10*time.Second), 2.0)
+	mock.ExpectQuery("SELECT ts, value FROM metric_samples").
+		WithArgs(metrics.Gauge, "cpu", "{}", from, to).
+		WillReturnRows(rows)
+
+	samples, err := NewDBHistory(db).QueryRange(context.Background(), metrics.Gauge, "cpu", from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []metrics.Sample{
+		{Timestamp: from, Value: 1},
+		{Timestamp: from.Add(10 * time.Second), Value: 2},
+	}, samples)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Тест DeleteBefore: удаление устаревших значений
+func TestDBHistory_DeleteBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM metric_samples").WithArgs(ts).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	err = NewDBHistory(db).DeleteBefore(context.Background(), ts)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
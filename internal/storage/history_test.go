@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+func TestMemHistory_QueryRange(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mh := NewMemHistory()
+
+	require.NoError(t, mh.AddSamples(ctx, base.Add(20*time.Second), nil, map[string]float64{"cpu": 3}))
+	require.NoError(t, mh.AddSamples(ctx, base, map[string]int64{"requests": 1}, map[string]float64{"cpu": 1}))
+	require.NoError(t, mh.AddSamples(ctx, base.Add(10*time.Second), nil, map[string]float64{"cpu": 2}))
+
+	samples, err := mh.QueryRange(ctx, metrics.Gauge, "cpu", base, base.Add(10*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Sample{
+		{Timestamp: base, Value: 1},
+		{Timestamp: base.Add(10 * time.Second), Value: 2},
+	}, samples)
+
+	samples, err = mh.QueryRange(ctx, metrics.Counter, "requests", base, base)
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Sample{{Timestamp: base, Value: 1}}, samples)
+
+	samples, err = mh.QueryRange(ctx, metrics.Gauge, "missing", base, base.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, samples)
+
+	_, err = mh.QueryRange(ctx, metrics.Histogram, "cpu", base, base)
+	assert.ErrorIs(t, err, metrics.ErrUnknownMetric)
+}
+
+func TestMemHistory_DeleteBefore(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mh := NewMemHistory()
+	require.NoError(t, mh.AddSamples(ctx, base, map[string]int64{"requests": 1}, map[string]float64{"cpu": 1}))
+	require.NoError(t, mh.AddSamples(ctx, base.Add(time.Minute), nil, map[string]float64{"cpu": 2}))
+
+	require.NoError(t, mh.DeleteBefore(ctx, base.Add(time.Second)))
+
+	samples, err := mh.QueryRange(ctx, metrics.Gauge, "cpu", base, base.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Sample{{Timestamp: base.Add(time.Minute), Value: 2}}, samples)
+	counter, _ := mh.snapshot()
+	assert.NotContains(t, counter, "requests")
+}
+
+func TestHistoryStorage_RecordsUpdates(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := base
+	history := NewMemHistory()
+	hs := NewHistoryStorage(NewMemStorage(), history, time.Hour)
+	hs.now = func() time.Time { return now }
+
+	require.NoError(t, hs.AddCounter(ctx, "requests", 2))
+	now = now.Add(time.Second)
+	require.NoError(t, hs.SetMetrics(ctx, map[string]int64{"requests": 3}, map[string]float64{"cpu": 0.5}))
+	now = now.Add(time.Second)
+	require.NoError(t, hs.SetGauge(ctx, "cpu", 0.7))
+
+	samples, err := hs.QueryRange(ctx, metrics.Counter, "requests", base, now)
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Sample{
+		{Timestamp: base, Value: 2},
+		{Timestamp: base.Add(time.Second), Value: 5},
+	}, samples)
+
+	samples, err = hs.QueryRange(ctx, metrics.Gauge, "cpu", base, now)
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.Sample{
+		{Timestamp: base.Add(time.Second), Value: 0.5},
+		{Timestamp: base.Add(2 * time.Second), Value: 0.7},
+	}, samples)
+
+	value, err := hs.GetGauge(ctx, "cpu")
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, value)
+}
+
+func TestHistoryStorage_ConcurrentCounter(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) MetricStorage{
+		"memory": func(*testing.T) MetricStorage { return NewMemStorage() },
+		"segment": func(t *testing.T) MetricStorage {
+			ss, _ := newTestSegmentStorage(t, t.TempDir())
+			return ss
+		},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			history := NewMemHistory()
+			hs := NewHistoryStorage(backend(t), history, time.Hour)
+			var tick atomic.Int64
+			base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			hs.now = func() time.Time { return base.Add(time.Duration(tick.Add(1))) }
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 25; j++ {
+						assert.NoError(t, hs.AddCounter(ctx, "requests", 1))
+					}
+				}()
+			}
+			wg.Wait()
+
+			// Каждое обновление записано один раз, значения возрастают вместе со временем записи.
+			samples, err := hs.QueryRange(ctx, metrics.Counter, "requests", base, base.Add(time.Hour))
+			require.NoError(t, err)
+			require.Len(t, samples, 200)
+			for i, s := range samples {
+				assert.Equal(t, float64(i+1), s.Value)
+			}
+		})
+	}
+}
+
+func TestHistoryStorage_Init(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	history := NewMemHistory()
+	hs := NewHistoryStorage(NewMemStorage(), history, time.Millisecond)
+	hs.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	require.NoError(t, history.AddSamples(ctx, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), nil, map[string]float64{"cpu": 1}))
+
+	require.NoError(t, hs.Init(ctx))
+
+	assert.Eventually(t, func() bool {
+		_, gauge := history.snapshot()
+		return len(gauge) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestHistoryStorage_ApplyCounterFunc(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := base
+	hs := NewHistoryStorage(NewMemStorage(), NewMemHistory(), time.Hour)
+	hs.now = func() time.Time { return now }
+
+	// 10 -> 30 -> сброс до 5 -> 15 за 30 секунд: прирост 20 + 5 + 10 = 35.
+	require.NoError(t, hs.AddCounter(ctx, "requests", 10))
+	now = now.Add(10 * time.Second)
+	require.NoError(t, hs.AddCounter(ctx, "requests", 20))
+	now = now.Add(10 * time.Second)
+	require.NoError(t, hs.history.AddSamples(ctx, now, map[string]int64{"requests": 5}, nil))
+	now = now.Add(10 * time.Second)
+	require.NoError(t, hs.history.AddSamples(ctx, now, map[string]int64{"requests": 15}, nil))
+
+	mn := metrics.MetricName{ID: "requests", MType: metrics.Counter}
+	result, err := hs.ApplyCounterFunc(ctx, mn, metrics.CounterFunc{Func: metrics.FuncIncrease, Window: "1m"})
+	require.NoError(t, err)
+	assert.Equal(t, 35.0, result.Value)
+	assert.Equal(t, now.Add(-time.Minute), result.From)
+	assert.Equal(t, now, result.To)
+
+	result, err = hs.ApplyCounterFunc(ctx, mn, metrics.CounterFunc{Func: metrics.FuncRate, Window: "1m"})
+	require.NoError(t, err)
+	assert.InDelta(t, 35.0/30, result.Value, 1e-9)
+
+	since := base.Add(15 * time.Second)
+	result, err = hs.ApplyCounterFunc(ctx, mn, metrics.CounterFunc{Func: metrics.FuncIncrease, Since: &since})
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, result.Value)
+
+	_, err = hs.ApplyCounterFunc(ctx, metrics.MetricName{ID: "missing", MType: metrics.Counter}, metrics.CounterFunc{Func: metrics.FuncRate, Window: "1m"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = hs.ApplyCounterFunc(ctx, mn, metrics.CounterFunc{Func: metrics.FuncRate})
+	assert.ErrorIs(t, err, metrics.ErrWrongFunc)
+}
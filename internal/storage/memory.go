@@ -3,20 +3,29 @@ package storage
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
 )
 
 // MemStorage представляет хранилище метрик в памяти.
 type MemStorage struct {
-	gauge   map[string]float64
-	counter map[string]int64
-	mx      sync.Mutex
+	gauge     map[string]float64
+	counter   map[string]int64
+	histogram map[string]metrics.HistogramValue
+	summary   map[string]metrics.SummaryValue
+	history   HistoryStore
+	now       func() time.Time
+	mx        sync.Mutex
 }
 
 // NewMemStorage создаёт и возвращает новое хранилище метрик в памяти.
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		gauge:   make(map[string]float64),
-		counter: make(map[string]int64),
+		gauge:     make(map[string]float64),
+		counter:   make(map[string]int64),
+		histogram: make(map[string]metrics.HistogramValue),
+		summary:   make(map[string]metrics.SummaryValue),
 	}
 }
 
@@ -25,20 +34,20 @@ func (ms *MemStorage) Init(_ context.Context) error {
 	return nil
 }
 
+// setHistory включает запись истории значений метрик в history.
+func (ms *MemStorage) setHistory(history HistoryStore, now func() time.Time) {
+	ms.history = history
+	ms.now = now
+}
+
 // SetGauge устанавливает значение метрики типа gauge с заданным именем и значением.
-func (ms *MemStorage) SetGauge(_ context.Context, key string, value float64) error {
-	ms.mx.Lock()
-	defer ms.mx.Unlock()
-	ms.gauge[key] = value
-	return nil
+func (ms *MemStorage) SetGauge(ctx context.Context, key string, value float64) error {
+	return ms.SetMetrics(ctx, nil, map[string]float64{key: value})
 }
 
 // AddCounter увеличивает значение метрики типа counter с заданным именем на указанное значение.
-func (ms *MemStorage) AddCounter(_ context.Context, key string, value int64) error {
-	ms.mx.Lock()
-	defer ms.mx.Unlock()
-	ms.counter[key] += value
-	return nil
+func (ms *MemStorage) AddCounter(ctx context.Context, key string, value int64) error {
+	return ms.SetMetrics(ctx, map[string]int64{key: value}, nil)
 }
 
 // GetCounter возвращает текущее значение метрики типа counter с заданным именем.
@@ -79,14 +88,122 @@ func (ms *MemStorage) GetMetrics(_ context.Context) (counter map[string]int64, g
 }
 
 // SetMetrics устанавливает переданные метрики в хранилище.
-func (ms *MemStorage) SetMetrics(_ context.Context, counter map[string]int64, gauge map[string]float64) error {
+// Если включена запись истории, новые значения записываются в неё под той же блокировкой.
+func (ms *MemStorage) SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	ms.setMetrics(ctx, counter, gauge, ms.history, ms.now)
+	return nil
+}
+
+// setMetrics устанавливает переданные метрики и, если задана history, записывает в неё новые значения
+// на момент now. Время берётся под блокировкой, чтобы история counter не нарушала порядок обновлений.
+func (ms *MemStorage) setMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64, history HistoryStore, now func() time.Time) {
 	ms.mx.Lock()
 	defer ms.mx.Unlock()
 	for k, v := range gauge {
 		ms.gauge[k] = v
 	}
+	var current map[string]int64
+	if history != nil {
+		current = make(map[string]int64, len(counter))
+	}
 	for k, v := range counter {
 		ms.counter[k] += v
+		if current != nil {
+			current[k] = ms.counter[k]
+		}
+	}
+	if history != nil {
+		recordSamples(ctx, history, now(), current, gauge)
+	}
+}
+
+// AddHistogram добавляет наблюдения к метрике типа histogram с заданным именем.
+// Если границы корзин не совпадают с сохранёнными, возвращает metrics.ErrWrongValue.
+func (ms *MemStorage) AddHistogram(_ context.Context, key string, value metrics.HistogramValue) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	return ms.addHistogram(key, value)
+}
+
+func (ms *MemStorage) addHistogram(key string, value metrics.HistogramValue) error {
+	current, ok := ms.histogram[key]
+	if !ok {
+		ms.histogram[key] = value.Clone()
+		return nil
+	}
+	current = current.Clone()
+	if err := current.Merge(value); err != nil {
+		return err
+	}
+	ms.histogram[key] = current
+	return nil
+}
+
+// SetSummary устанавливает значение метрики типа summary с заданным именем.
+func (ms *MemStorage) SetSummary(_ context.Context, key string, value metrics.SummaryValue) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.summary[key] = value.Clone()
+	return nil
+}
+
+// GetHistogram возвращает текущее значение метрики типа histogram с заданным именем.
+func (ms *MemStorage) GetHistogram(_ context.Context, key string) (metrics.HistogramValue, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	value, ok := ms.histogram[key]
+	if !ok {
+		return metrics.HistogramValue{}, ErrNotFound
+	}
+	return value.Clone(), nil
+}
+
+// GetSummary возвращает текущее значение метрики типа summary с заданным именем.
+func (ms *MemStorage) GetSummary(_ context.Context, key string) (metrics.SummaryValue, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	value, ok := ms.summary[key]
+	if !ok {
+		return metrics.SummaryValue{}, ErrNotFound
+	}
+	return value.Clone(), nil
+}
+
+// GetDistributions возвращает все сохранённые метрики типа histogram и summary.
+func (ms *MemStorage) GetDistributions(_ context.Context) (map[string]metrics.HistogramValue, map[string]metrics.SummaryValue, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	histogram := make(map[string]metrics.HistogramValue, len(ms.histogram))
+	summary := make(map[string]metrics.SummaryValue, len(ms.summary))
+	for k, v := range ms.histogram {
+		histogram[k] = v.Clone()
+	}
+	for k, v := range ms.summary {
+		summary[k] = v.Clone()
+	}
+	return histogram, summary, nil
+}
+
+// SetDistributions сохраняет переданные метрики типа histogram и summary.
+// Гистограммы с несовпадающими границами корзин не сохраняются, а метод возвращает metrics.ErrWrongValue.
+func (ms *MemStorage) SetDistributions(_ context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	for k, v := range histogram {
+		if current, ok := ms.histogram[k]; ok {
+			current = current.Clone()
+			if err := current.Merge(v); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range histogram {
+		if err := ms.addHistogram(k, v); err != nil {
+			return err
+		}
+	}
+	for k, v := range summary {
+		ms.summary[k] = v.Clone()
 	}
 	return nil
 }
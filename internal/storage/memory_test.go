@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/moonicy/gometrics/internal/agent"
+	"github.com/moonicy/gometrics/internal/metrics"
 )
 
 var ctx = context.Background()
@@ -189,3 +190,72 @@ func TestMemStorage_Init(t *testing.T) {
 	err := ms.Init(ctx)
 	assert.NoError(t, err)
 }
+
+func TestMemStorage_AddHistogram(t *testing.T) {
+	ms := NewMemStorage()
+
+	err := ms.AddHistogram(ctx, "latency", metrics.HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 0}, Sum: 0.5, Count: 1})
+	assert.NoError(t, err)
+	err = ms.AddHistogram(ctx, "latency", metrics.HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{0, 1, 1}, Sum: 4.5, Count: 2})
+	assert.NoError(t, err)
+
+	got, err := ms.GetHistogram(ctx, "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, metrics.HistogramValue{Bounds: []float64{1, 2}, Counts: []uint64{1, 1, 1}, Sum: 5, Count: 3}, got)
+
+	err = ms.AddHistogram(ctx, "latency", metrics.HistogramValue{Bounds: []float64{5}, Counts: []uint64{1, 0}, Sum: 1, Count: 1})
+	assert.ErrorIs(t, err, metrics.ErrWrongValue)
+
+	got, err = ms.GetHistogram(ctx, "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), got.Count)
+
+	_, err = ms.GetHistogram(ctx, "nonexistentHistogram")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemStorage_SetSummary(t *testing.T) {
+	ms := NewMemStorage()
+
+	value := metrics.SummaryValue{Quantiles: []metrics.Quantile{{Quantile: 0.5, Value: 1}}, Sum: 3, Count: 2}
+	err := ms.SetSummary(ctx, "duration", value)
+	assert.NoError(t, err)
+
+	got, err := ms.GetSummary(ctx, "duration")
+	assert.NoError(t, err)
+	assert.Equal(t, value, got)
+
+	_, err = ms.GetSummary(ctx, "nonexistentSummary")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemStorage_SetDistributions(t *testing.T) {
+	ms := NewMemStorage()
+
+	histogram := map[string]metrics.HistogramValue{
+		"latency": {Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+	}
+	summary := map[string]metrics.SummaryValue{
+		"duration": {Quantiles: []metrics.Quantile{{Quantile: 0.9, Value: 2}}, Sum: 2, Count: 1},
+	}
+
+	err := ms.SetDistributions(ctx, histogram, summary)
+	assert.NoError(t, err)
+	err = ms.SetDistributions(ctx, histogram, nil)
+	assert.NoError(t, err)
+
+	gotHistogram, gotSummary, err := ms.GetDistributions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), gotHistogram["latency"].Count)
+	assert.Equal(t, []uint64{2, 0}, gotHistogram["latency"].Counts)
+	assert.Equal(t, summary["duration"], gotSummary["duration"])
+
+	err = ms.SetDistributions(ctx, map[string]metrics.HistogramValue{
+		"other":   {Bounds: []float64{1}, Counts: []uint64{1, 0}, Count: 1},
+		"latency": {Bounds: []float64{2}, Counts: []uint64{1, 0}, Count: 1},
+	}, nil)
+	assert.ErrorIs(t, err, metrics.ErrWrongValue)
+
+	_, err = ms.GetHistogram(ctx, "other")
+	assert.ErrorIs(t, err, ErrNotFound, "batch with invalid histogram must not be partially applied")
+}
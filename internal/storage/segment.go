@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/segment"
+)
+
+// SegmentStore определяет интерфейс встроенного хранилища ключ-значение.
+type SegmentStore interface {
+	Open() error
+	Get(key string) ([]byte, error)
+	PutBatch(entries map[string][]byte) error
+	Keys(prefix string) []string
+	Close() error
+}
+
+// SegmentStorage представляет хранилище метрик во встроенном хранилище ключ-значение на диске.
+// Каждое изменение сохраняется на диск до подтверждения, в памяти хранится только индекс ключей.
+type SegmentStorage struct {
+	store   SegmentStore
+	history HistoryStore
+	now     func() time.Time
+	mx      sync.Mutex
+}
+
+// NewSegmentStorage создаёт и возвращает новое хранилище метрик поверх store.
+func NewSegmentStorage(store SegmentStore) *SegmentStorage {
+	return &SegmentStorage{store: store}
+}
+
+// Init открывает хранилище, восстанавливая данные после аварийного завершения, и закрывает его при завершении ctx.
+func (ss *SegmentStorage) Init(ctx context.Context) error {
+	if err := ss.store.Open(); err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ss.mx.Lock()
+		defer ss.mx.Unlock()
+		if err := ss.store.Close(); err != nil {
+			log.Println("Error closing segment store:", err)
+		}
+	}()
+	return nil
+}
+
+// setHistory включает запись истории значений метрик в history.
+func (ss *SegmentStorage) setHistory(history HistoryStore, now func() time.Time) {
+	ss.history = history
+	ss.now = now
+}
+
+// SetGauge устанавливает значение метрики типа gauge с заданным именем.
+func (ss *SegmentStorage) SetGauge(ctx context.Context, key string, value float64) error {
+	return ss.SetMetrics(ctx, nil, map[string]float64{key: value})
+}
+
+// AddCounter увеличивает значение метрики типа counter с заданным именем на указанное значение.
+func (ss *SegmentStorage) AddCounter(ctx context.Context, key string, value int64) error {
+	return ss.SetMetrics(ctx, map[string]int64{key: value}, nil)
+}
+
+// GetCounter возвращает значение метрики типа counter с заданным именем.
+func (ss *SegmentStorage) GetCounter(_ context.Context, key string) (int64, error) {
+	return ss.counter(key)
+}
+
+// GetGauge возвращает значение метрики типа gauge с заданным именем.
+func (ss *SegmentStorage) GetGauge(_ context.Context, key string) (float64, error) {
+	data, err := ss.get(metrics.Gauge, key)
+	if err != nil {
+		return 0, err
+	}
+	return decodeGauge(data), nil
+}
+
+// GetMetrics возвращает все метрики типа counter и gauge.
+func (ss *SegmentStorage) GetMetrics(_ context.Context) (map[string]int64, map[string]float64, error) {
+	counter := make(map[string]int64)
+	gauge := make(map[string]float64)
+	err := ss.scan(metrics.Counter, func(name string, data []byte) error {
+		counter[name] = decodeCounter(data)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ss.scan(metrics.Gauge, func(name string, data []byte) error {
+		gauge[name] = decodeGauge(data)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return counter, gauge, nil
+}
+
+// SetMetrics сохраняет переданные метрики одной записью: значения gauge заменяются, counter увеличиваются.
+// Если включена запись истории, новые значения записываются в неё под той же блокировкой.
+func (ss *SegmentStorage) SetMetrics(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+	entries := make(map[string][]byte, len(counter)+len(gauge))
+	for k, v := range gauge {
+		entries[segmentKey(metrics.Gauge, k)] = encodeGauge(v)
+	}
+	updated := make(map[string]int64, len(counter))
+	for k, v := range counter {
+		current, err := ss.counter(k)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		updated[k] = current + v
+		entries[segmentKey(metrics.Counter, k)] = encodeCounter(updated[k])
+	}
+	if err := ss.store.PutBatch(entries); err != nil {
+		return err
+	}
+	if ss.history != nil {
+		recordSamples(ctx, ss.history, ss.now(), updated, gauge)
+	}
+	return nil
+}
+
+// AddHistogram добавляет наблюдения к метрике типа histogram с заданным именем.
+// Если границы корзин не совпадают с сохранёнными, возвращает metrics.ErrWrongValue.
+func (ss *SegmentStorage) AddHistogram(ctx context.Context, key string, value metrics.HistogramValue) error {
+	return ss.SetDistributions(ctx, map[string]metrics.HistogramValue{key: value}, nil)
+}
+
+// SetSummary устанавливает значение метрики типа summary с заданным именем.
+func (ss *SegmentStorage) SetSummary(ctx context.Context, key string, value metrics.SummaryValue) error {
+	return ss.SetDistributions(ctx, nil, map[string]metrics.SummaryValue{key: value})
+}
+
+// GetHistogram возвращает значение метрики типа histogram с заданным именем.
+func (ss *SegmentStorage) GetHistogram(_ context.Context, key string) (metrics.HistogramValue, error) {
+	var value metrics.HistogramValue
+	data, err := ss.get(metrics.Histogram, key)
+	if err != nil {
+		return value, err
+	}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+// GetSummary возвращает значение метрики типа summary с заданным именем.
+func (ss *SegmentStorage) GetSummary(_ context.Context, key string) (metrics.SummaryValue, error) {
+	var value metrics.SummaryValue
+	data, err := ss.get(metrics.Summary, key)
+	if err != nil {
+		return value, err
+	}
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+// GetDistributions возвращает все метрики типа histogram и summary.
+func (ss *SegmentStorage) GetDistributions(_ context.Context) (map[string]metrics.HistogramValue, map[string]metrics.SummaryValue, error) {
+	histogram := make(map[string]metrics.HistogramValue)
+	summary := make(map[string]metrics.SummaryValue)
+	err := ss.scan(metrics.Histogram, func(name string, data []byte) error {
+		var value metrics.HistogramValue
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		histogram[name] = value
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ss.scan(metrics.Summary, func(name string, data []byte) error {
+		var value metrics.SummaryValue
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		summary[name] = value
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return histogram, summary, nil
+}
+
+// SetDistributions сохраняет переданные метрики типа histogram и summary одной записью.
+// Гистограммы с несовпадающими границами корзин не сохраняются, а метод возвращает metrics.ErrWrongValue.
+func (ss *SegmentStorage) SetDistributions(ctx context.Context, histogram map[string]metrics.HistogramValue, summary map[string]metrics.SummaryValue) error {
+	ss.mx.Lock()
+	defer ss.mx.Unlock()
+	entries := make(map[string][]byte, len(histogram)+len(summary))
+	for k, v := range histogram {
+		current, err := ss.GetHistogram(ctx, k)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			current = v.Clone()
+		case err != nil:
+			return err
+		default:
+			if err = current.Merge(v); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		entries[segmentKey(metrics.Histogram, k)] = data
+	}
+	for k, v := range summary {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		entries[segmentKey(metrics.Summary, k)] = data
+	}
+	return ss.store.PutBatch(entries)
+}
+
+func (ss *SegmentStorage) counter(key string) (int64, error) {
+	data, err := ss.get(metrics.Counter, key)
+	if err != nil {
+		return 0, err
+	}
+	return decodeCounter(data), nil
+}
+
+func (ss *SegmentStorage) get(mType string, key string) ([]byte, error) {
+	data, err := ss.store.Get(segmentKey(mType, key))
+	if errors.Is(err, segment.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// scan передаёт в fn имя и значение каждой метрики типа mType.
+func (ss *SegmentStorage) scan(mType string, fn func(name string, data []byte) error) error {
+	prefix := segmentKey(mType, "")
+	for _, key := range ss.store.Keys(prefix) {
+		data, err := ss.store.Get(key)
+		if errors.Is(err, segment.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(strings.TrimPrefix(key, prefix), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func segmentKey(mType string, key string) string {
+	return mType + "/" + key
+}
+
+func encodeGauge(value float64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, math.Float64bits(value))
+}
+
+func decodeGauge(data []byte) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(data))
+}
+
+func encodeCounter(value int64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(value))
+}
+
+func decodeCounter(data []byte) int64 {
+	return int64(binary.LittleEndian.Uint64(data))
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/segment"
+)
+
+func newTestSegmentStorage(t *testing.T, dir string) (*SegmentStorage, context.CancelFunc) {
+	t.Helper()
+	ctxt, cancel := context.WithCancel(context.Background())
+	ss := NewSegmentStorage(segment.NewStore(dir, 0))
+	require.NoError(t, ss.Init(ctxt))
+	t.Cleanup(cancel)
+	return ss, cancel
+}
+
+func TestSegmentStorage_Metrics(t *testing.T) {
+	ss, _ := newTestSegmentStorage(t, t.TempDir())
+
+	_, err := ss.GetCounter(ctx, "requests")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = ss.GetGauge(ctx, "cpu")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, ss.AddCounter(ctx, "requests", 2))
+	require.NoError(t, ss.AddCounter(ctx, "requests", 3))
+	require.NoError(t, ss.SetGauge(ctx, "cpu", 0.5))
+	require.NoError(t, ss.SetMetrics(ctx, map[string]int64{"requests": 5, "errors": 1}, map[string]float64{"cpu": -1.25}))
+
+	counter, gauge, err := ss.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"requests": 10, "errors": 1}, counter)
+	assert.Equal(t, map[string]float64{"cpu": -1.25}, gauge)
+}
+
+func TestSegmentStorage_Distributions(t *testing.T) {
+	ss, _ := newTestSegmentStorage(t, t.TempDir())
+
+	h := metrics.NewHistogramValue([]float64{1, 10})
+	h.Observe(5)
+	require.NoError(t, ss.AddHistogram(ctx, "latency", h))
+	require.NoError(t, ss.AddHistogram(ctx, "latency", h))
+	require.NoError(t, ss.SetSummary(ctx, "duration", metrics.SummaryValue{Sum: 2, Count: 1}))
+
+	got, err := ss.GetHistogram(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), got.Count)
+	assert.Equal(t, []uint64{0, 2, 0}, got.Counts)
+
+	err = ss.AddHistogram(ctx, "latency", metrics.NewHistogramValue([]float64{2}))
+	assert.ErrorIs(t, err, metrics.ErrWrongValue)
+
+	histogram, summary, err := ss.GetDistributions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), histogram["latency"].Count)
+	assert.Equal(t, 2.0, summary["duration"].Sum)
+
+	_, err = ss.GetSummary(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSegmentStorage_Restart(t *testing.T) {
+	dir := t.TempDir()
+	ss, cancel := newTestSegmentStorage(t, dir)
+	require.NoError(t, ss.AddCounter(ctx, "requests", 7))
+	require.NoError(t, ss.SetGauge(ctx, "cpu", 0.75))
+
+	// Хранилище не закрывается перед повторным открытием: каждая запись уже на диске.
+	restarted, _ := newTestSegmentStorage(t, dir)
+	value, err := restarted.GetCounter(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), value)
+	gauge, err := restarted.GetGauge(ctx, "cpu")
+	require.NoError(t, err)
+	assert.Equal(t, 0.75, gauge)
+	cancel()
+}
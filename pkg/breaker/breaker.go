@@ -0,0 +1,186 @@
+// Package breaker предоставляет автоматический выключатель (circuit breaker), который перестаёт
+// вызывать недоступный сервис после серии ошибок и периодически проверяет его восстановление.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen возвращается Breaker.Execute, когда выключатель разомкнут и вызов не выполняется.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State - состояние выключателя.
+type State int
+
+// Состояния выключателя.
+const (
+	Closed   State = iota // Closed - вызовы выполняются, ошибки подсчитываются.
+	Open                  // Open - вызовы отклоняются до окончания паузы.
+	HalfOpen              // HalfOpen - выполняется пробный вызов, по его итогу выключатель замыкается или размыкается.
+)
+
+// String возвращает название состояния.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings задаёт параметры выключателя.
+type Settings struct {
+	// FailureThreshold - число ошибок подряд, после которого выключатель размыкается; значения меньше 1 означают 1.
+	FailureThreshold int
+	// SuccessThreshold - число успешных пробных вызовов подряд, после которого выключатель замыкается;
+	// значения меньше 1 означают 1.
+	SuccessThreshold int
+	// Cooldown - пауза после размыкания, по истечении которой разрешается пробный вызов.
+	Cooldown time.Duration
+	// MaxCooldown - предел паузы: после каждого неудачного пробного вызова пауза удваивается до MaxCooldown.
+	// Если MaxCooldown не больше Cooldown, пауза не растёт.
+	MaxCooldown time.Duration
+}
+
+// Breaker - автоматический выключатель. Безопасен для одновременного использования.
+type Breaker struct {
+	settings      Settings
+	now           func() time.Time
+	onStateChange func(from, to State)
+
+	mx        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	cooldown  time.Duration
+	openUntil time.Time
+	probing   bool
+	// generation увеличивается при каждой смене состояния.
+	generation uint64
+}
+
+// New создаёт и возвращает замкнутый выключатель с заданными параметрами.
+func New(settings Settings) *Breaker {
+	if settings.FailureThreshold < 1 {
+		settings.FailureThreshold = 1
+	}
+	if settings.SuccessThreshold < 1 {
+		settings.SuccessThreshold = 1
+	}
+	return &Breaker{settings: settings, now: time.Now, cooldown: settings.Cooldown}
+}
+
+// SetOnStateChange задаёт функцию, вызываемую при каждой смене состояния.
+// Функция вызывается под блокировкой выключателя и не должна обращаться к нему.
+func (b *Breaker) SetOnStateChange(fn func(from, to State)) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.onStateChange = fn
+}
+
+// State возвращает текущее состояние выключателя. Разомкнутый выключатель, пауза которого истекла,
+// сообщает состояние HalfOpen.
+func (b *Breaker) State() State {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.state == Open && !b.now().Before(b.openUntil) {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Execute выполняет fn, если выключатель это разрешает, и учитывает результат.
+// Разомкнутый выключатель возвращает ErrOpen, не вызывая fn. После паузы выполняется один пробный
+// вызов; остальные вызовы до его завершения также получают ErrOpen.
+func (b *Breaker) Execute(fn func() error) error {
+	generation, ok := b.allow()
+	if !ok {
+		return ErrOpen
+	}
+	err := fn()
+	b.record(generation, err == nil)
+	return err
+}
+
+// allow сообщает, разрешён ли вызов, и возвращает поколение состояния, в котором он начат.
+func (b *Breaker) allow() (uint64, bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	switch b.state {
+	case Closed:
+		return b.generation, true
+	case Open:
+		if b.now().Before(b.openUntil) {
+			return 0, false
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return b.generation, true
+	default:
+		if b.probing {
+			return 0, false
+		}
+		b.probing = true
+		return b.generation, true
+	}
+}
+
+// record учитывает результат вызова. Результаты вызовов, начатых до смены состояния, не учитываются.
+func (b *Breaker) record(generation uint64, success bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case Closed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.open()
+		}
+	case HalfOpen:
+		b.probing = false
+		if !success {
+			b.cooldown = min(b.cooldown*2, max(b.settings.MaxCooldown, b.settings.Cooldown))
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.SuccessThreshold {
+			b.failures = 0
+			b.successes = 0
+			b.cooldown = b.settings.Cooldown
+			b.setState(Closed)
+		}
+	}
+}
+
+func (b *Breaker) open() {
+	b.failures = 0
+	b.successes = 0
+	b.openUntil = b.now().Add(b.cooldown)
+	b.setState(Open)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	if b.onStateChange != nil {
+		b.onStateChange(from, state)
+	}
+}
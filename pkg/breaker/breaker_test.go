@@ -0,0 +1,123 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errFail = errors.New("server is not available")
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(settings Settings) (*Breaker, *clock, *[]string) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := New(settings)
+	b.now = c.now
+	var transitions []string
+	b.SetOnStateChange(func(from, to State) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+	return b, c, &transitions
+}
+
+func fail() error { return errFail }
+
+func succeed() error { return nil }
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b, _, transitions := newTestBreaker(Settings{FailureThreshold: 3, Cooldown: time.Second})
+
+	assert.ErrorIs(t, b.Execute(fail), errFail)
+	assert.NoError(t, b.Execute(succeed), "success resets the failure count")
+	assert.ErrorIs(t, b.Execute(fail), errFail)
+	assert.ErrorIs(t, b.Execute(fail), errFail)
+	assert.Equal(t, Closed, b.State())
+	assert.ErrorIs(t, b.Execute(fail), errFail)
+	assert.Equal(t, Open, b.State())
+
+	called := false
+	err := b.Execute(func() error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.False(t, called)
+	assert.Equal(t, []string{"closed->open"}, *transitions)
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	b, c, transitions := newTestBreaker(Settings{FailureThreshold: 1, Cooldown: time.Second})
+
+	require.ErrorIs(t, b.Execute(fail), errFail)
+	c.advance(time.Second)
+	assert.Equal(t, HalfOpen, b.State())
+
+	// Пока выполняется пробный вызов, остальные вызовы отклоняются.
+	err := b.Execute(func() error {
+		assert.ErrorIs(t, b.Execute(succeed), ErrOpen)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Closed, b.State())
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, *transitions)
+}
+
+func TestBreaker_CooldownBackoff(t *testing.T) {
+	b, c, _ := newTestBreaker(Settings{FailureThreshold: 1, Cooldown: time.Second, MaxCooldown: 3 * time.Second})
+
+	require.ErrorIs(t, b.Execute(fail), errFail)
+	for _, cooldown := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		c.advance(cooldown - time.Millisecond)
+		assert.ErrorIs(t, b.Execute(succeed), ErrOpen, "cooldown %v has not passed", cooldown)
+		c.advance(time.Millisecond)
+		assert.ErrorIs(t, b.Execute(fail), errFail, "probe after %v", cooldown)
+		assert.Equal(t, Open, b.State())
+	}
+
+	// После замыкания пауза возвращается к исходной.
+	c.advance(3 * time.Second)
+	require.NoError(t, b.Execute(succeed))
+	require.ErrorIs(t, b.Execute(fail), errFail)
+	c.advance(time.Second)
+	assert.Equal(t, HalfOpen, b.State())
+}
+
+func TestBreaker_SuccessThreshold(t *testing.T) {
+	b, c, _ := newTestBreaker(Settings{FailureThreshold: 1, SuccessThreshold: 2, Cooldown: time.Second})
+
+	require.ErrorIs(t, b.Execute(fail), errFail)
+	c.advance(time.Second)
+	require.NoError(t, b.Execute(succeed))
+	assert.Equal(t, HalfOpen, b.State())
+	require.NoError(t, b.Execute(succeed))
+	assert.Equal(t, Closed, b.State())
+}
+
+func TestBreaker_IgnoresStaleResults(t *testing.T) {
+	b, _, _ := newTestBreaker(Settings{FailureThreshold: 1, Cooldown: time.Second})
+
+	// Вызов начат в замкнутом состоянии и завершился после размыкания другим вызовом.
+	err := b.Execute(func() error {
+		require.ErrorIs(t, b.Execute(fail), errFail)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Open, b.State())
+}
+
+func TestState_String(t *testing.T) {
+	assert.Equal(t, "closed", Closed.String())
+	assert.Equal(t, "open", Open.String())
+	assert.Equal(t, "half-open", HalfOpen.String())
+	assert.Equal(t, "unknown", State(7).String())
+}
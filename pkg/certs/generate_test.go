@@ -0,0 +1,62 @@
+package certs
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthority_Issue(t *testing.T) {
+	ca, err := NewAuthority("gometrics CA", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ca.Cert.IsCA)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	server, err := ca.IssueServer("server", []string{"localhost", "127.0.0.1"}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, server.Cert.DNSNames)
+	assert.Len(t, server.Cert.IPAddresses, 1)
+	_, err = server.Cert.Verify(x509.VerifyOptions{
+		DNSName:   "localhost",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	assert.NoError(t, err)
+
+	agent, err := ca.IssueClient("agent", time.Hour)
+	require.NoError(t, err)
+	_, err = agent.Cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err)
+	_, err = agent.Cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	assert.Error(t, err)
+}
+
+func TestWriteFiles(t *testing.T) {
+	ca, err := NewAuthority("gometrics CA", time.Hour)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "ca.pem")
+	keyFile := filepath.Join(dir, "ca-key.pem")
+
+	require.NoError(t, WriteFiles(ca.Cert, ca.Key, certFile, keyFile))
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	pool, err := loadCertPool(certFile)
+	require.NoError(t, err)
+	assert.NotNil(t, pool)
+}
@@ -0,0 +1,69 @@
+// Package certs предоставляет загрузку настроек TLS и выпуск сертификатов локального удостоверяющего центра.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoCertificates возвращается, если в файле удостоверяющего центра нет ни одного сертификата.
+var ErrNoCertificates = errors.New("no certificates found")
+
+// ServerTLSConfig возвращает настройки TLS сервера с сертификатом certFile и ключом keyFile.
+// Если задан caFile, сервер требует от клиента сертификат, подписанный этим удостоверяющим центром (mTLS).
+func ServerTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientTLSConfig возвращает настройки TLS клиента.
+// Если задан caFile, сертификат сервера проверяется этим удостоверяющим центром, иначе - системными.
+// Если заданы certFile и keyFile, клиент предъявляет серверу свой сертификат.
+func ClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%w in %s", ErrNoCertificates, caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,91 @@
+package certs
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePKI выпускает удостоверяющий центр, сертификаты сервера и агента и возвращает каталог с файлами.
+func writePKI(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	ca, err := NewAuthority("gometrics CA", time.Hour)
+	require.NoError(t, err)
+	server, err := ca.IssueServer("server", []string{"127.0.0.1"}, time.Hour)
+	require.NoError(t, err)
+	agent, err := ca.IssueClient("agent", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, WriteFiles(ca.Cert, ca.Key, filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")))
+	require.NoError(t, WriteFiles(server.Cert, server.Key, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")))
+	require.NoError(t, WriteFiles(agent.Cert, agent.Key, filepath.Join(dir, "agent.pem"), filepath.Join(dir, "agent-key.pem")))
+	return dir
+}
+
+func newTLSServer(t *testing.T, cfg *tls.Config) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(cfg *tls.Config, url string) error {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ServerTLSConfig(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing-key.pem"), "")
+	assert.Error(t, err)
+
+	dir = writePKI(t)
+	file := func(name string) string { return filepath.Join(dir, name) }
+
+	t.Run("tls", func(t *testing.T) {
+		serverCfg, err := ServerTLSConfig(file("server.pem"), file("server-key.pem"), "")
+		require.NoError(t, err)
+		srv := newTLSServer(t, serverCfg)
+
+		clientCfg, err := ClientTLSConfig("", "", file("ca.pem"))
+		require.NoError(t, err)
+		assert.NoError(t, get(clientCfg, srv.URL))
+
+		assert.Error(t, get(&tls.Config{MinVersion: tls.VersionTLS12}, srv.URL), "server must not be trusted without CA")
+	})
+
+	t.Run("mtls", func(t *testing.T) {
+		serverCfg, err := ServerTLSConfig(file("server.pem"), file("server-key.pem"), file("ca.pem"))
+		require.NoError(t, err)
+		srv := newTLSServer(t, serverCfg)
+
+		clientCfg, err := ClientTLSConfig(file("agent.pem"), file("agent-key.pem"), file("ca.pem"))
+		require.NoError(t, err)
+		assert.NoError(t, get(clientCfg, srv.URL))
+
+		noCert, err := ClientTLSConfig("", "", file("ca.pem"))
+		require.NoError(t, err)
+		assert.Error(t, get(noCert, srv.URL), "client without certificate must be rejected")
+	})
+
+	t.Run("bad ca", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(file("bad.pem"), []byte("not a certificate"), 0o644))
+		_, err := ClientTLSConfig("", "", file("bad.pem"))
+		assert.ErrorIs(t, err, ErrNoCertificates)
+	})
+}
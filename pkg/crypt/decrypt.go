@@ -31,6 +31,11 @@ func Decrypt(privateKeyPath string, encryptedData []byte) ([]byte, error) {
 		return nil, errors.New("failed to parse private key")
 	}
 
+	return decryptSegments(privateKey, encryptedData)
+}
+
+// decryptSegments расшифровывает данные, посегментно зашифрованные RSA-PKCS1v15 функцией Encrypt.
+func decryptSegments(privateKey *rsa.PrivateKey, encryptedData []byte) ([]byte, error) {
 	var decryptedBuffer bytes.Buffer
 	segmentSize := privateKey.Size()
 
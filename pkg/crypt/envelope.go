@@ -0,0 +1,119 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Заголовок запроса, которым клиент сообщает формат шифрования тела, и его значения.
+const (
+	EncryptionHeader = "X-Encryption"
+	// EncryptionLegacy - посегментное шифрование RSA-PKCS1v15 функцией Encrypt; используется, если заголовок не задан.
+	EncryptionLegacy = "rsa-pkcs1v15"
+	// EncryptionEnvelope - конверт EncryptEnvelope: ключ AES-256-GCM, зашифрованный RSA-OAEP, и шифротекст.
+	EncryptionEnvelope = "rsa-oaep-aes256gcm"
+)
+
+// EnvelopeV1 - текущая версия формата конверта.
+const EnvelopeV1 byte = 1
+
+// Ошибки разбора конверта.
+var (
+	ErrMalformedEnvelope   = errors.New("malformed envelope")
+	ErrUnsupportedEnvelope = errors.New("unsupported envelope version")
+)
+
+const (
+	envelopeHeaderSize = 3 // версия и длина зашифрованного ключа
+	aesKeySize         = 32
+)
+
+// EncryptEnvelope шифрует данные случайным ключом AES-256-GCM и оборачивает ключ открытым ключом RSA-OAEP (SHA-256).
+// Открытый ключ перечитывается из файла publicKeyPath при его изменении.
+// Формат конверта версии 1:
+//
+//	версия (1 байт) | длина ключа N (2 байта, big-endian) | ключ (N байт) | nonce (12 байт) | шифротекст с тегом
+//
+// Версия и зашифрованный ключ защищены тегом GCM как дополнительные данные.
+func EncryptEnvelope(publicKeyPath string, data []byte) ([]byte, error) {
+	envelope, _, err := publicKeyFileFor(publicKeyPath).Encrypt(data)
+	return envelope, err
+}
+
+// DecryptEnvelope расшифровывает конверт, созданный EncryptEnvelope, закрытым ключом RSA из файла privateKeyPath.
+func DecryptEnvelope(privateKeyPath string, envelope []byte) ([]byte, error) {
+	privateKey, err := loadPrivateKey(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return openEnvelope(privateKey, envelope)
+}
+
+func sealEnvelope(publicKey *rsa.PublicKey, data []byte) ([]byte, error) {
+	key := make([]byte, aesKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(wrappedKey)+gcm.NonceSize()+len(data)+gcm.Overhead())
+	out[0] = EnvelopeV1
+	binary.BigEndian.PutUint16(out[1:envelopeHeaderSize], uint16(len(wrappedKey)))
+	out = append(out, wrappedKey...)
+	aad := append([]byte(nil), out...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, aad), nil
+}
+
+func openEnvelope(privateKey *rsa.PrivateKey, envelope []byte) ([]byte, error) {
+	if len(envelope) < envelopeHeaderSize {
+		return nil, ErrMalformedEnvelope
+	}
+	if envelope[0] != EnvelopeV1 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEnvelope, envelope[0])
+	}
+	keyEnd := envelopeHeaderSize + int(binary.BigEndian.Uint16(envelope[1:envelopeHeaderSize]))
+	if len(envelope) < keyEnd {
+		return nil, ErrMalformedEnvelope
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, envelope[envelopeHeaderSize:keyEnd], nil)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope) < keyEnd+gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrMalformedEnvelope
+	}
+	nonce := envelope[keyEnd : keyEnd+gcm.NonceSize()]
+	return gcm.Open(nil, nonce, envelope[keyEnd+gcm.NonceSize():], envelope[:keyEnd])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,124 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair записывает пару ключей RSA во временный каталог и возвращает пути до открытого и закрытого ключа.
+func writeKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	publicPath := filepath.Join(dir, "public.pem")
+	privatePath := filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}), 0o644))
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), 0o600))
+	return publicPath, privatePath
+}
+
+func TestEnvelope(t *testing.T) {
+	publicPath, privatePath := writeKeyPair(t)
+	data := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1}`), 10000)
+
+	envelope, err := EncryptEnvelope(publicPath, data)
+	require.NoError(t, err)
+	assert.Equal(t, EnvelopeV1, envelope[0])
+	assert.Less(t, len(envelope), len(data)+512, "envelope overhead must not depend on data size")
+
+	decrypted, err := DecryptEnvelope(privatePath, envelope)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+
+	empty, err := EncryptEnvelope(publicPath, nil)
+	require.NoError(t, err)
+	decrypted, err = DecryptEnvelope(privatePath, empty)
+	require.NoError(t, err)
+	assert.Empty(t, decrypted)
+}
+
+func TestDecryptEnvelope_Errors(t *testing.T) {
+	publicPath, privatePath := writeKeyPair(t)
+	_, otherPrivatePath := writeKeyPair(t)
+	envelope, err := EncryptEnvelope(publicPath, []byte("test data"))
+	require.NoError(t, err)
+
+	tampered := append([]byte(nil), envelope...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	wrongVersion := append([]byte(nil), envelope...)
+	wrongVersion[0] = 2
+
+	tests := []struct {
+		name     string
+		keyPath  string
+		envelope []byte
+		wantErr  error
+	}{
+		{name: "empty", keyPath: privatePath, envelope: nil, wantErr: ErrMalformedEnvelope},
+		{name: "truncated key", keyPath: privatePath, envelope: envelope[:10], wantErr: ErrMalformedEnvelope},
+		{name: "unsupported version", keyPath: privatePath, envelope: wrongVersion, wantErr: ErrUnsupportedEnvelope},
+		{name: "tampered ciphertext", keyPath: privatePath, envelope: tampered},
+		{name: "other key", keyPath: otherPrivatePath, envelope: envelope},
+		{name: "missing key", keyPath: filepath.Join(t.TempDir(), "missing.pem"), envelope: envelope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptEnvelope(tt.keyPath, tt.envelope)
+			assert.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptEnvelope_InvalidKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, os.WriteFile(path, []byte("INVALID PEM DATA"), 0o644))
+
+	_, err := EncryptEnvelope(path, []byte("test data"))
+	assert.Error(t, err)
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	require.NoError(b, err)
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(b, err)
+	publicPath := filepath.Join(b.TempDir(), "public.pem")
+	require.NoError(b, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}), 0o644))
+	data := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1}`), 1000)
+
+	b.Run("legacy", func(b *testing.B) {
+		publicKeyPEM = nil
+		for i := 0; i < b.N; i++ {
+			if _, err := Encrypt(publicPath, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("envelope", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := EncryptEnvelope(publicPath, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
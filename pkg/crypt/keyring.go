@@ -0,0 +1,145 @@
+package crypt
+
+import (
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Keyring - набор закрытых ключей RSA, которыми сервер расшифровывает запросы агентов.
+// Ключи выбираются по идентификатору из заголовка X-Key-ID, что позволяет одновременно
+// принимать запросы, зашифрованные старым и новым ключом, на время ротации.
+type Keyring struct {
+	paths []string
+
+	mx   sync.RWMutex
+	keys []*rsa.PrivateKey
+	ids  map[string]*rsa.PrivateKey
+}
+
+// NewKeyring создаёт и возвращает новый пустой Keyring для файлов и каталогов paths.
+// Ключи загружаются методом Reload; из каталога загружаются все файлы *.pem.
+func NewKeyring(paths ...string) *Keyring {
+	return &Keyring{paths: paths, ids: make(map[string]*rsa.PrivateKey)}
+}
+
+// Reload перечитывает все ключи. Если хотя бы один ключ не удаётся загрузить,
+// возвращается ошибка, а ранее загруженный набор ключей сохраняется.
+func (kr *Keyring) Reload() error {
+	var keys []*rsa.PrivateKey
+	ids := make(map[string]*rsa.PrivateKey)
+	for _, path := range kr.paths {
+		loaded, err := loadPrivateKeys(path)
+		if err != nil {
+			return err
+		}
+		for _, key := range loaded {
+			id := KeyID(&key.PublicKey)
+			if _, ok := ids[id]; ok {
+				continue
+			}
+			ids[id] = key
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no private keys found in %v", kr.paths)
+	}
+
+	kr.mx.Lock()
+	defer kr.mx.Unlock()
+	kr.keys, kr.ids = keys, ids
+	return nil
+}
+
+// KeyIDs возвращает идентификаторы загруженных ключей в порядке загрузки.
+func (kr *Keyring) KeyIDs() []string {
+	kr.mx.RLock()
+	defer kr.mx.RUnlock()
+	ids := make([]string, 0, len(kr.keys))
+	for _, key := range kr.keys {
+		ids = append(ids, KeyID(&key.PublicKey))
+	}
+	return ids
+}
+
+// Empty сообщает, что ни один ключ не загружен.
+func (kr *Keyring) Empty() bool {
+	kr.mx.RLock()
+	defer kr.mx.RUnlock()
+	return len(kr.keys) == 0
+}
+
+// DecryptEnvelope расшифровывает конверт ключом с идентификатором keyID.
+// Если идентификатор не задан, конверт по очереди расшифровывается всеми ключами.
+func (kr *Keyring) DecryptEnvelope(keyID string, envelope []byte) ([]byte, error) {
+	return kr.decrypt(keyID, envelope, openEnvelope)
+}
+
+// DecryptLegacy расшифровывает данные, посегментно зашифрованные RSA-PKCS1v15, ключом с идентификатором keyID.
+// Если идентификатор не задан, данные по очереди расшифровываются всеми ключами.
+func (kr *Keyring) DecryptLegacy(keyID string, data []byte) ([]byte, error) {
+	return kr.decrypt(keyID, data, decryptSegments)
+}
+
+func (kr *Keyring) decrypt(keyID string, data []byte, decrypt func(*rsa.PrivateKey, []byte) ([]byte, error)) ([]byte, error) {
+	kr.mx.RLock()
+	defer kr.mx.RUnlock()
+	if keyID != "" {
+		key, ok := kr.ids[keyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+		}
+		return decrypt(key, data)
+	}
+	err := errors.New("no private keys loaded")
+	for _, key := range kr.keys {
+		var decrypted []byte
+		if decrypted, err = decrypt(key, data); err == nil {
+			return decrypted, nil
+		}
+	}
+	return nil, err
+}
+
+// loadPrivateKeys загружает закрытый ключ из файла path или все закрытые ключи из файлов *.pem каталога path.
+// Файлы каталога с другими блоками PEM, например открытые ключи, пропускаются.
+func loadPrivateKeys(path string) ([]*rsa.PrivateKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		key, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*rsa.PrivateKey{key}, nil
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var keys []*rsa.PrivateKey
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if block, _ := pem.Decode(data); block == nil || block.Type != "RSA PRIVATE KEY" {
+			continue
+		}
+		key, err := loadPrivateKey(file)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,126 @@
+package crypt
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeyIDHeader - заголовок запроса с идентификатором ключа, которым зашифровано тело.
+const KeyIDHeader = "X-Key-ID"
+
+// ErrUnknownKey возвращается, если ключ с идентификатором из запроса не загружен.
+var ErrUnknownKey = errors.New("unknown key id")
+
+// KeyID возвращает идентификатор ключа: первые 8 байт SHA-256 от открытого ключа в формате PKIX в виде hex-строки.
+func KeyID(publicKey *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PublicKeyFile - открытый ключ RSA, который перечитывается из файла при изменении файла.
+// Позволяет агенту начать шифровать новым ключом без перезапуска.
+type PublicKeyFile struct {
+	path string
+
+	mx      sync.Mutex
+	modTime time.Time
+	size    int64
+	key     *rsa.PublicKey
+	id      string
+}
+
+// NewPublicKeyFile создаёт и возвращает новый PublicKeyFile для файла path. Файл читается при первом использовании.
+func NewPublicKeyFile(path string) *PublicKeyFile {
+	return &PublicKeyFile{path: path}
+}
+
+// Key возвращает текущий открытый ключ и его идентификатор, перечитывая файл, если он изменился.
+// Если изменённый файл не удаётся разобрать, возвращается ошибка, а ранее загруженный ключ сохраняется.
+func (pf *PublicKeyFile) Key() (*rsa.PublicKey, string, error) {
+	pf.mx.Lock()
+	defer pf.mx.Unlock()
+	info, err := os.Stat(pf.path)
+	if err != nil {
+		return nil, "", err
+	}
+	if pf.key != nil && info.ModTime().Equal(pf.modTime) && info.Size() == pf.size {
+		return pf.key, pf.id, nil
+	}
+	key, err := loadPublicKey(pf.path)
+	if err != nil {
+		return nil, "", err
+	}
+	pf.key, pf.id = key, KeyID(key)
+	pf.modTime, pf.size = info.ModTime(), info.Size()
+	return pf.key, pf.id, nil
+}
+
+// Encrypt шифрует данные в конверт текущим ключом и возвращает конверт и идентификатор ключа.
+func (pf *PublicKeyFile) Encrypt(data []byte) ([]byte, string, error) {
+	key, id, err := pf.Key()
+	if err != nil {
+		return nil, "", err
+	}
+	envelope, err := sealEnvelope(key, data)
+	return envelope, id, err
+}
+
+// publicKeyFiles хранит PublicKeyFile по пути до файла для EncryptEnvelope.
+var publicKeyFiles sync.Map
+
+func publicKeyFileFor(path string) *PublicKeyFile {
+	pf, _ := publicKeyFiles.LoadOrStore(path, NewPublicKeyFile(path))
+	return pf.(*PublicKeyFile)
+}
+
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not RSA public key")
+	}
+	return publicKey, nil
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path, "RSA PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s", path)
+	}
+	return privateKey, nil
+}
+
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("failed to decode PEM block containing %s in %s", blockType, path)
+	}
+	return block, nil
+}
@@ -0,0 +1,56 @@
+package crypt
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyID(t *testing.T) {
+	publicPath, privatePath := writeKeyPair(t)
+	publicKey, err := loadPublicKey(publicPath)
+	require.NoError(t, err)
+	privateKey, err := loadPrivateKey(privatePath)
+	require.NoError(t, err)
+
+	id := KeyID(publicKey)
+	assert.Len(t, id, 16)
+	assert.Equal(t, id, KeyID(&privateKey.PublicKey))
+
+	otherPublicPath, _ := writeKeyPair(t)
+	otherKey, err := loadPublicKey(otherPublicPath)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, KeyID(otherKey))
+}
+
+func TestPublicKeyFile_Reload(t *testing.T) {
+	publicPath, privatePath := writeKeyPair(t)
+	newPublicPath, newPrivatePath := writeKeyPair(t)
+	pf := NewPublicKeyFile(publicPath)
+
+	envelope, id, err := pf.Encrypt([]byte("old key"))
+	require.NoError(t, err)
+	data, err := DecryptEnvelope(privatePath, envelope)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old key"), data)
+
+	newKey, err := os.ReadFile(newPublicPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(publicPath, newKey, 0o644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(publicPath, later, later))
+
+	envelope, newID, err := pf.Encrypt([]byte("new key"))
+	require.NoError(t, err)
+	assert.NotEqual(t, id, newID)
+	data, err = DecryptEnvelope(newPrivatePath, envelope)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new key"), data)
+
+	require.NoError(t, os.WriteFile(publicPath, []byte("broken"), 0o644))
+	_, _, err = pf.Key()
+	assert.Error(t, err)
+}
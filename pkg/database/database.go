@@ -1,10 +1,18 @@
-// Package database предоставляет обертку над sql.DB с возможностью повторных попыток при ошибках соединения.
+// Package database предоставляет обертку над sql.DB с возможностью повторных попыток при ошибках соединения
+// и направлением запросов чтения на реплику.
 package database
 
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -14,19 +22,92 @@ import (
 	"github.com/moonicy/gometrics/pkg/retry"
 )
 
+// replicaRetryInterval - время, в течение которого чтение идёт в основную БД после ошибки соединения с репликой.
+const replicaRetryInterval = 30 * time.Second
+
 // RetryableDB представляет базу данных с возможностью повторных попыток при ошибках соединения.
+// Если задана реплика, запросы ReadQueryContext и ReadQueryRowContext выполняются на ней.
 type RetryableDB struct {
-	db  *sql.DB
-	log *zap.SugaredLogger
+	db      *sql.DB
+	replica *sql.DB
+	log     *zap.SugaredLogger
+	retry   retry.Policy
+	// replicaDownUntil хранит время в наносекундах, до которого реплика считается недоступной.
+	replicaDownUntil atomic.Int64
 }
 
 // NewDatabase создает новое соединение с базой данных и возвращает RetryableDB, функцию для закрытия соединения и ошибку, если она произошла.
+// Параметры пула соединений и statement_timeout берутся из конфигурации и применяются к основной БД и к реплике.
 func NewDatabase(logger *zap.SugaredLogger, cfg config.ServerConfig) (*RetryableDB, func() error, error) {
-	db, err := sql.Open("pgx", cfg.DatabaseDsn)
+	db, err := open(cfg.DatabaseDsn, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	rdb := &RetryableDB{db: db, log: logger, retry: defaultRetryPolicy(logger)}
+	if cfg.DatabaseReplicaDsn == "" {
+		return rdb, db.Close, nil
+	}
+	replica, err := open(cfg.DatabaseReplicaDsn, cfg)
 	if err != nil {
+		_ = db.Close()
 		return nil, nil, err
 	}
-	return &RetryableDB{db: db, log: logger}, db.Close, nil
+	rdb.replica = replica
+	return rdb, func() error {
+		return errors.Join(db.Close(), replica.Close())
+	}, nil
+}
+
+// SetRetryPolicy задаёт политику повторных попыток при ошибках соединения.
+// Запросы выполняются с контекстом вызова, поэтому AttemptTimeout политики не должен быть задан.
+func (db *RetryableDB) SetRetryPolicy(policy retry.Policy) {
+	db.retry = policy
+}
+
+// defaultRetryPolicy возвращает политику по умолчанию, которая выводит в лог каждый повтор.
+func defaultRetryPolicy(logger *zap.SugaredLogger) retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.OnAttempt = func(a retry.Attempt) {
+		if a.Err != nil && a.Delay > 0 {
+			logger.Warnw("retrying database query", "attempt", a.Number, "delay", a.Delay, "error", a.Err)
+		}
+	}
+	return policy
+}
+
+func open(dsn string, cfg config.ServerConfig) (*sql.DB, error) {
+	if cfg.DatabaseStatementTimeout > 0 {
+		dsn = withRuntimeParam(dsn, "statement_timeout", strconv.FormatInt(cfg.DatabaseStatementTimeout.Milliseconds(), 10))
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseMaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.DatabaseMaxOpenConns)
+	}
+	if cfg.DatabaseMaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.DatabaseMaxIdleConns)
+	}
+	if cfg.DatabaseConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.DatabaseConnMaxLifetime)
+	}
+	return db, nil
+}
+
+// withRuntimeParam добавляет в строку подключения параметр сессии Postgres.
+// Поддерживаются строки в виде URL и в виде пар ключ=значение.
+func withRuntimeParam(dsn string, key string, value string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			q.Set(key, value)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return strings.TrimSpace(dsn + " " + key + "=" + value)
 }
 
 // Ping проверяет соединение с базой данных.
@@ -37,25 +118,9 @@ func (db *RetryableDB) Ping() error {
 // ExecContext выполняет SQL-запрос без возвращения строк и поддерживает повторные попытки при ошибках соединения.
 func (db *RetryableDB) ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
 	db.log.Info("opening database")
-	err = retry.RetryHandle(func() error {
+	err = db.retry.Do(ctx, func(ctx context.Context) error {
 		result, err = db.db.ExecContext(ctx, query, args...)
-		if err == nil {
-			return nil
-		}
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgerrcode.IsConnectionException(pgErr.Code) {
-				db.log.Warnw("database connection error", "error", err)
-				return retry.NewRetryableError(pgErr.Message)
-			}
-		}
-		var connErr *pgconn.ConnectError
-		if errors.As(err, &connErr) {
-			db.log.Warnw("database connection error", "error", err)
-			return retry.NewRetryableError(err.Error())
-		}
-		db.log.Errorw("database retry error", "error", err)
-		return err
+		return db.classify(err)
 	})
 	if err != nil {
 		db.log.Errorw("database error", "error", err)
@@ -68,26 +133,9 @@ func (db *RetryableDB) ExecContext(ctx context.Context, query string, args ...an
 // QueryContext выполняет SQL-запрос и возвращает несколько строк результата, поддерживая повторные попытки при ошибках соединения.
 func (db *RetryableDB) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
 	db.log.Info("opening database")
-	err = retry.RetryHandle(func() error {
+	err = db.retry.Do(ctx, func(ctx context.Context) error {
 		rows, err = db.db.QueryContext(ctx, query, args...)
-		if err == nil {
-			return nil
-		}
-		if rows.Err() != nil {
-			err = rows.Err()
-		}
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsConnectionException(pgErr.Code) {
-			db.log.Warnw("database connection error", "error", err)
-			return retry.NewRetryableError(pgErr.Message)
-		}
-		var connErr *pgconn.ConnectError
-		if errors.As(err, &connErr) {
-			db.log.Warnw("database connection error", "error", err)
-			return retry.NewRetryableError(err.Error())
-		}
-		db.log.Errorw("database retry error", "error", err)
-		return err
+		return db.classify(err)
 	})
 	if err != nil {
 		db.log.Errorw("database error", "error", err)
@@ -99,23 +147,10 @@ func (db *RetryableDB) QueryContext(ctx context.Context, query string, args ...a
 
 // QueryRowContext выполняет SQL-запрос и возвращает одну строку результата, поддерживая повторные попытки при ошибках соединения.
 func (db *RetryableDB) QueryRowContext(ctx context.Context, query string, args ...any) (row *sql.Row) {
-	var err error
 	db.log.Info("opening database")
-	err = retry.RetryHandle(func() error {
+	err := db.retry.Do(ctx, func(ctx context.Context) error {
 		row = db.db.QueryRowContext(ctx, query, args...)
-		err = row.Err()
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsConnectionException(pgErr.Code) {
-			db.log.Warnw("database connection error", "error", err)
-			return retry.NewRetryableError(pgErr.Message)
-		}
-		var connErr *pgconn.ConnectError
-		if errors.As(err, &connErr) {
-			db.log.Warnw("database connection error", "error", err)
-			return retry.NewRetryableError(err.Error())
-		}
-		db.log.Errorw("database retry error", "error", err)
-		return err
+		return db.classify(row.Err())
 	})
 	if err != nil {
 		db.log.Errorw("database error", "error", err)
@@ -125,7 +160,83 @@ func (db *RetryableDB) QueryRowContext(ctx context.Context, query string, args .
 	return row
 }
 
+// classify помечает ошибки соединения с БД как повторяемые.
+func (db *RetryableDB) classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if isConnectionError(err) {
+		db.log.Warnw("database connection error", "error", err)
+		return retry.NewRetryableError(err.Error())
+	}
+	db.log.Errorw("database retry error", "error", err)
+	return err
+}
+
 // Begin начинает новую транзакцию и возвращает объект sql.Tx.
 func (db *RetryableDB) Begin() (tx *sql.Tx, err error) {
 	return db.db.Begin()
 }
+
+// BeginTx начинает новую транзакцию с контекстом ctx и параметрами opts и возвращает объект sql.Tx.
+func (db *RetryableDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	return db.db.BeginTx(ctx, opts)
+}
+
+// ReadQueryContext выполняет запрос чтения на реплике и возвращает несколько строк результата.
+// Если реплика не задана или недоступна, запрос выполняется на основной БД.
+func (db *RetryableDB) ReadQueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if replica := db.readReplica(); replica != nil {
+		rows, err := replica.QueryContext(ctx, query, args...)
+		if err == nil || ctx.Err() != nil || !isConnectionError(err) {
+			return rows, err
+		}
+		db.markReplicaDown(err)
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// ReadQueryRowContext выполняет запрос чтения на реплике и возвращает одну строку результата.
+// Если реплика не задана или недоступна, запрос выполняется на основной БД.
+func (db *RetryableDB) ReadQueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if replica := db.readReplica(); replica != nil {
+		row := replica.QueryRowContext(ctx, query, args...)
+		if err := row.Err(); err == nil || ctx.Err() != nil || !isConnectionError(err) {
+			return row
+		}
+		db.markReplicaDown(row.Err())
+	}
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// readReplica возвращает реплику, если она задана и не была недавно признана недоступной.
+func (db *RetryableDB) readReplica() *sql.DB {
+	if db.replica == nil || time.Now().UnixNano() < db.replicaDownUntil.Load() {
+		return nil
+	}
+	return db.replica
+}
+
+func (db *RetryableDB) markReplicaDown(err error) {
+	db.log.Warnw("read replica is unavailable, reading from primary", "error", err, "retry_in", replicaRetryInterval)
+	db.replicaDownUntil.Store(time.Now().Add(replicaRetryInterval).UnixNano())
+}
+
+// isConnectionError сообщает, вызвана ли ошибка недоступностью сервера БД.
+// Отмена запроса и истечение его контекста ошибкой соединения не считаются: медленный запрос
+// не должен отключать реплику.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code)
+	}
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr)
+}
@@ -4,13 +4,21 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"log"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap/zaptest"
 
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/pkg/retry"
+
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
@@ -96,6 +104,53 @@ func TestRetryableDB_ExecContext_NonRetryableError(t *testing.T) {
 	}
 }
 
+func TestRetryableDB_ExecContext_RetryConnectionError(t *testing.T) {
+	retryableDB, mock, closeFunc := setupMockDB(t)
+	defer closeFunc()
+	var attempts int
+	retryableDB.SetRetryPolicy(retry.Policy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		OnAttempt:       func(retry.Attempt) { attempts++ },
+	})
+
+	connErr := &pgconn.PgError{Code: pgerrcode.ConnectionFailure, Message: "connection failure"}
+	mock.ExpectExec("DELETE FROM gauge").WillReturnError(connErr)
+	mock.ExpectExec("DELETE FROM gauge").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := retryableDB.ExecContext(context.Background(), "DELETE FROM gauge"); err != nil {
+		t.Fatalf("Ожидали успешный повтор, получили ошибку: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Ожидали 2 попытки, получили %d", attempts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания не были выполнены: %v", err)
+	}
+}
+
+func TestRetryableDB_ExecContext_CancelStopsRetry(t *testing.T) {
+	retryableDB, mock, closeFunc := setupMockDB(t)
+	defer closeFunc()
+	ctx, cancel := context.WithCancel(context.Background())
+	retryableDB.SetRetryPolicy(retry.Policy{
+		MaxAttempts:     3,
+		InitialInterval: time.Hour,
+		OnAttempt:       func(retry.Attempt) { cancel() },
+	})
+
+	mock.ExpectExec("DELETE FROM gauge").WillReturnError(&pgconn.PgError{Code: pgerrcode.ConnectionFailure})
+
+	start := time.Now()
+	_, err := retryableDB.ExecContext(ctx, "DELETE FROM gauge")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Ожидали context.Canceled, получили %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Ожидали прерывание ожидания повтора, прошло %v", elapsed)
+	}
+}
+
 func TestRetryableDB_QueryContext_Success(t *testing.T) {
 	retryableDB, mock, closeFunc := setupMockDB(t)
 	defer closeFunc()
@@ -224,3 +279,172 @@ func TestRetryableDB_Begin_Error(t *testing.T) {
 		t.Errorf("Ожидания не были выполнены: %v", err)
 	}
 }
+
+func setupReplicaMockDB(t *testing.T) (*RetryableDB, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	retryableDB, primaryMock, closeFunc := setupMockDB(t)
+	t.Cleanup(closeFunc)
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка при создании sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = replica.Close() })
+	retryableDB.replica = replica
+	return retryableDB, primaryMock, replicaMock
+}
+
+func TestRetryableDB_ReadQueryContext_Replica(t *testing.T) {
+	retryableDB, primaryMock, replicaMock := setupReplicaMockDB(t)
+
+	replicaMock.ExpectQuery("SELECT name, value FROM gauge").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("cpu", 0.5))
+
+	rows, err := retryableDB.ReadQueryContext(context.Background(), "SELECT name, value FROM gauge")
+	if err != nil {
+		t.Fatalf("Ожидали успешный ReadQueryContext, получили ошибку: %v", err)
+	}
+	_ = rows.Close()
+
+	if err = replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания реплики не были выполнены: %v", err)
+	}
+	if err = primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания основной БД не были выполнены: %v", err)
+	}
+}
+
+func TestRetryableDB_ReadQueryContext_FallbackToPrimary(t *testing.T) {
+	retryableDB, primaryMock, replicaMock := setupReplicaMockDB(t)
+
+	connErr := &pgconn.PgError{Code: pgerrcode.ConnectionFailure, Message: "replica is down"}
+	replicaMock.ExpectQuery("SELECT name, value FROM gauge").WillReturnError(connErr)
+	primaryMock.ExpectQuery("SELECT name, value FROM gauge").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("cpu", 0.5))
+	// Пока реплика считается недоступной, чтение сразу идёт в основную БД.
+	primaryMock.ExpectQuery("SELECT value FROM gauge").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(0.5))
+
+	rows, err := retryableDB.ReadQueryContext(context.Background(), "SELECT name, value FROM gauge")
+	if err != nil {
+		t.Fatalf("Ожидали чтение из основной БД, получили ошибку: %v", err)
+	}
+	_ = rows.Close()
+
+	var value float64
+	if err = retryableDB.ReadQueryRowContext(context.Background(), "SELECT value FROM gauge").Scan(&value); err != nil {
+		t.Fatalf("Ожидали чтение из основной БД, получили ошибку: %v", err)
+	}
+
+	if err = replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания реплики не были выполнены: %v", err)
+	}
+	if err = primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания основной БД не были выполнены: %v", err)
+	}
+}
+
+func TestRetryableDB_ReadQueryRowContext_QueryErrorNotFallback(t *testing.T) {
+	retryableDB, primaryMock, replicaMock := setupReplicaMockDB(t)
+
+	queryErr := errors.New("syntax error")
+	replicaMock.ExpectQuery("SELECT value FROM gauge").WillReturnError(queryErr)
+
+	row := retryableDB.ReadQueryRowContext(context.Background(), "SELECT value FROM gauge")
+	if !errors.Is(row.Err(), queryErr) {
+		t.Errorf("Ожидали ошибку запроса реплики, получили: %v", row.Err())
+	}
+	if retryableDB.readReplica() == nil {
+		t.Errorf("Ошибка запроса не должна отключать реплику")
+	}
+
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания реплики не были выполнены: %v", err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания основной БД не были выполнены: %v", err)
+	}
+}
+
+func TestRetryableDB_ReadQueryContext_DeadlineNotFallback(t *testing.T) {
+	retryableDB, primaryMock, replicaMock := setupReplicaMockDB(t)
+
+	replicaMock.ExpectQuery("SELECT name, value FROM gauge").WillReturnError(&net.OpError{Op: "read", Err: context.DeadlineExceeded})
+
+	_, err := retryableDB.ReadQueryContext(context.Background(), "SELECT name, value FROM gauge")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Ожидали ошибку истечения времени запроса, получили: %v", err)
+	}
+	if retryableDB.readReplica() == nil {
+		t.Errorf("Истечение времени запроса не должно отключать реплику")
+	}
+
+	if err = replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания реплики не были выполнены: %v", err)
+	}
+	if err = primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Ожидания основной БД не были выполнены: %v", err)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection failure", err: &pgconn.PgError{Code: pgerrcode.ConnectionFailure}, want: true},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "bad connection", err: driver.ErrBadConn, want: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "deadline exceeded", err: context.DeadlineExceeded},
+		{name: "network timeout", err: &net.OpError{Op: "read", Err: context.DeadlineExceeded}},
+		{name: "query error", err: errors.New("syntax error")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.want {
+				t.Errorf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDatabase_Pool(t *testing.T) {
+	cfg := config.ServerConfig{
+		DatabaseDsn:              "postgres://user@localhost:5432/metrics",
+		DatabaseReplicaDsn:       "host=replica user=user dbname=metrics",
+		DatabaseMaxOpenConns:     7,
+		DatabaseMaxIdleConns:     3,
+		DatabaseConnMaxLifetime:  time.Minute,
+		DatabaseStatementTimeout: 2 * time.Second,
+	}
+	db, closeFn, err := NewDatabase(zaptest.NewLogger(t).Sugar(), cfg)
+	if err != nil {
+		t.Fatalf("Ожидали успешный NewDatabase, получили ошибку: %v", err)
+	}
+	defer func() { _ = closeFn() }()
+
+	if db.replica == nil {
+		t.Fatalf("Ожидали подключение к реплике")
+	}
+	for _, pool := range []*sql.DB{db.db, db.replica} {
+		if got := pool.Stats().MaxOpenConnections; got != 7 {
+			t.Errorf("Ожидали MaxOpenConnections 7, получили %d", got)
+		}
+	}
+}
+
+func TestWithRuntimeParam(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{dsn: "postgres://user@localhost:5432/metrics?sslmode=disable", want: "postgres://user@localhost:5432/metrics?sslmode=disable&statement_timeout=2000"},
+		{dsn: "host=localhost dbname=metrics", want: "host=localhost dbname=metrics statement_timeout=2000"},
+		{dsn: "", want: "statement_timeout=2000"},
+	}
+	for _, tt := range tests {
+		if got := withRuntimeParam(tt.dsn, "statement_timeout", "2000"); got != tt.want {
+			t.Errorf("withRuntimeParam(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Package instrument позволяет сервисам на Go отправлять собственные метрики на сервер метрик.
+//
+// Registry создаёт метрики Counter, Gauge и Histogram, накапливает их значения в памяти
+// и периодически отправляет на сервер по HTTP или gRPC тем же способом, что и агент:
+// со сжатием gzip, подписью HashSHA256 и шифрованием публичным ключом.
+//
+//	reg, err := instrument.New(instrument.Config{Address: "http://localhost:8080", Labels: map[string]string{"service": "api"}})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer reg.Close(context.Background())
+//
+//	reg.Counter("requests", map[string]string{"code": "200"}).Inc()
+//	reg.Gauge("queue_size", nil).Set(12)
+//	reg.Histogram("latency", nil, instrument.DefaultBounds).Observe(0.042)
+package instrument
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moonicy/gometrics/internal/client"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// DefaultFlushInterval - частота отправки метрик по умолчанию.
+const DefaultFlushInterval = 10 * time.Second
+
+// Config задаёт параметры подключения к серверу и отправки метрик.
+type Config struct {
+	// Address - адрес сервера: адрес HTTP-сервера, например "http://localhost:8080",
+	// или адрес gRPC-сервера, например "localhost:3200", если GRPC равен true.
+	// Для HTTP адрес без схемы дополняется как у агента: "localhost:8080" означает "http://localhost:8080".
+	Address string
+	// GRPC включает отправку метрик по gRPC вместо HTTP.
+	GRPC bool
+	// HashKey - ключ подписи запросов.
+	HashKey string
+	// CryptoKey - путь до файла с публичным ключом для шифрования запросов; используется только для HTTP.
+	CryptoKey string
+	// TLS - настройки TLS; для HTTP адрес должен начинаться с "https://".
+	TLS *tls.Config
+	// Labels - метки, которые добавляются ко всем метрикам. Метки самой метрики имеют приоритет.
+	Labels map[string]string
+	// FlushInterval - частота отправки метрик; если не задана, используется DefaultFlushInterval.
+	FlushInterval time.Duration
+}
+
+// exporter отправляет метрики на сервер.
+type exporter interface {
+	SendMetrics(ctx context.Context, batch []metrics.Metric) error
+}
+
+// Registry хранит метрики сервиса и периодически отправляет их на сервер.
+// Безопасен для одновременного использования.
+type Registry struct {
+	exporter exporter
+	closer   io.Closer
+	labels   map[string]string
+
+	mx         sync.Mutex
+	counters   map[string]*Counter
+	gauges     map[string]*Gauge
+	histograms map[string]*Histogram
+
+	// flushMx не даёт двум отправкам выполняться одновременно.
+	flushMx   sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New создаёт Registry и запускает периодическую отправку метрик.
+// Отправку нужно завершить вызовом Close, чтобы не потерять последние значения.
+func New(cfg Config) (*Registry, error) {
+	var (
+		exp    exporter
+		closer io.Closer
+	)
+	if cfg.GRPC {
+		c, err := client.NewGRPCClient(cfg.Address, cfg.HashKey, cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		exp, closer = c, c
+	} else {
+		c := client.NewClient(config.ParseURI(cfg.Address), cfg.HashKey, cfg.CryptoKey)
+		if cfg.TLS != nil {
+			c.SetTLSConfig(cfg.TLS)
+		}
+		exp = c
+	}
+	r := newRegistry(exp, cfg.Labels)
+	r.closer = closer
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	r.start(interval)
+	return r, nil
+}
+
+func newRegistry(exp exporter, labels map[string]string) *Registry {
+	return &Registry{
+		exporter:   exp,
+		labels:     labels,
+		counters:   make(map[string]*Counter),
+		gauges:     make(map[string]*Gauge),
+		histograms: make(map[string]*Histogram),
+	}
+}
+
+// Counter возвращает метрику типа counter с именем id и метками labels, создавая её при первом вызове.
+// Некорректное имя или метки вызывают панику.
+func (r *Registry) Counter(id string, labels map[string]string) *Counter {
+	name, key := r.metricName(id, metrics.Counter, labels)
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	c, ok := r.counters[key]
+	if !ok {
+		c = &Counter{name: name}
+		r.counters[key] = c
+	}
+	return c
+}
+
+// Gauge возвращает метрику типа gauge с именем id и метками labels, создавая её при первом вызове.
+// Метрика отправляется, только если ей установлено значение. Некорректное имя или метки вызывают панику.
+func (r *Registry) Gauge(id string, labels map[string]string) *Gauge {
+	name, key := r.metricName(id, metrics.Gauge, labels)
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	g, ok := r.gauges[key]
+	if !ok {
+		g = &Gauge{name: name}
+		r.gauges[key] = g
+	}
+	return g
+}
+
+// Histogram возвращает метрику типа histogram с именем id и метками labels, создавая её при первом вызове
+// с границами корзин bounds; при повторных вызовах bounds не учитываются. Если bounds не заданы,
+// используются DefaultBounds. Некорректное имя, метки или границы вызывают панику.
+func (r *Registry) Histogram(id string, labels map[string]string, bounds []float64) *Histogram {
+	name, key := r.metricName(id, metrics.Histogram, labels)
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	h, ok := r.histograms[key]
+	if !ok {
+		if len(bounds) == 0 {
+			bounds = DefaultBounds
+		}
+		value := metrics.NewHistogramValue(bounds)
+		if err := value.Validate(); err != nil {
+			panic(fmt.Sprintf("instrument: histogram %s: invalid bounds %v", id, bounds))
+		}
+		h = &Histogram{name: name, value: value}
+		r.histograms[key] = h
+	}
+	return h
+}
+
+// metricName добавляет к меткам метрики общие метки и возвращает её имя и ключ временного ряда.
+func (r *Registry) metricName(id, mType string, labels map[string]string) (metrics.MetricName, string) {
+	merged := make(map[string]string, len(r.labels)+len(labels))
+	for k, v := range r.labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		merged = nil
+	}
+	name := metrics.MetricName{ID: id, MType: mType, Labels: merged}
+	if err := name.Validate(); err != nil {
+		panic(fmt.Sprintf("instrument: metric %s: %v", id, err))
+	}
+	return name, metrics.SeriesKey(id, merged)
+}
+
+// Flush отправляет на сервер накопленные значения метрик. Если отправить не удалось,
+// приращения счётчиков и наблюдения гистограмм сохраняются до следующей отправки.
+func (r *Registry) Flush(ctx context.Context) error {
+	r.flushMx.Lock()
+	defer r.flushMx.Unlock()
+
+	batch, restore := r.collect()
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := r.exporter.SendMetrics(ctx, batch); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+// collect забирает накопленные значения метрик и возвращает их вместе с функцией,
+// которая возвращает их обратно в метрики.
+func (r *Registry) collect() ([]metrics.Metric, func()) {
+	r.mx.Lock()
+	counters := sortedValues(r.counters)
+	gauges := sortedValues(r.gauges)
+	histograms := sortedValues(r.histograms)
+	r.mx.Unlock()
+
+	var (
+		batch    []metrics.Metric
+		restores []func()
+	)
+	for _, c := range counters {
+		delta := c.delta.Swap(0)
+		if delta == 0 {
+			continue
+		}
+		batch = append(batch, metrics.Metric{MetricName: c.name, Delta: &delta})
+		restores = append(restores, func() { c.delta.Add(delta) })
+	}
+	for _, g := range gauges {
+		if v, ok := g.value(); ok {
+			batch = append(batch, metrics.Metric{MetricName: g.name, Value: &v})
+		}
+	}
+	for _, h := range histograms {
+		v, ok := h.take()
+		if !ok {
+			continue
+		}
+		batch = append(batch, metrics.Metric{MetricName: h.name, Histogram: &v})
+		restores = append(restores, func() { h.restore(v) })
+	}
+	return batch, func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+func sortedValues[T any](m map[string]*T) []*T {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]*T, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+	return values
+}
+
+// start запускает отправку метрик с частотой interval.
+func (r *Registry) start(interval time.Duration) {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := r.Flush(ctx); err != nil {
+					log.Printf("Flush metrics: %v", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Close останавливает периодическую отправку, отправляет накопленные значения и закрывает соединение
+// с сервером. Повторные вызовы ничего не делают.
+func (r *Registry) Close(ctx context.Context) error {
+	var err error
+	r.closeOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+			<-r.done
+		}
+		err = r.Flush(ctx)
+		if r.closer != nil {
+			err = errors.Join(err, r.closer.Close())
+		}
+	})
+	return err
+}
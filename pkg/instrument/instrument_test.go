@@ -0,0 +1,209 @@
+package instrument
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/moonicy/gometrics/internal/client"
+	"github.com/moonicy/gometrics/internal/config"
+	"github.com/moonicy/gometrics/internal/handlers"
+	"github.com/moonicy/gometrics/internal/metrics"
+	"github.com/moonicy/gometrics/internal/storage"
+	"github.com/moonicy/gometrics/pkg/crypt"
+	"github.com/moonicy/gometrics/pkg/gzip"
+	sign "github.com/moonicy/gometrics/pkg/hash"
+)
+
+type fakeExporter struct {
+	mx      sync.Mutex
+	batches [][]metrics.Metric
+	err     error
+}
+
+func (e *fakeExporter) SendMetrics(_ context.Context, batch []metrics.Metric) error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	e.batches = append(e.batches, batch)
+	return nil
+}
+
+func (e *fakeExporter) last(t *testing.T) []metrics.Metric {
+	t.Helper()
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	require.NotEmpty(t, e.batches)
+	return e.batches[len(e.batches)-1]
+}
+
+func TestRegistry_Flush(t *testing.T) {
+	exp := &fakeExporter{}
+	r := newRegistry(exp, map[string]string{"service": "api", "env": "prod"})
+
+	r.Counter("requests", map[string]string{"code": "200"}).Inc()
+	r.Counter("requests", map[string]string{"code": "200"}).Add(2)
+	r.Counter("errors", nil)
+	r.Gauge("queue", map[string]string{"env": "dev"}).Set(7)
+	r.Gauge("unset", nil)
+	r.Histogram("latency", nil, []float64{0.1, 1}).Observe(0.3)
+
+	require.NoError(t, r.Flush(context.Background()))
+	batch := exp.last(t)
+	require.Len(t, batch, 3, "zero counters and unset gauges are not sent")
+
+	assert.Equal(t, metrics.MetricName{
+		ID: "requests", MType: metrics.Counter, Labels: map[string]string{"service": "api", "env": "prod", "code": "200"},
+	}, batch[0].MetricName)
+	assert.Equal(t, int64(3), *batch[0].Delta)
+	assert.Equal(t, map[string]string{"service": "api", "env": "dev"}, batch[1].Labels, "metric labels win")
+	assert.Equal(t, 7.0, *batch[1].Value)
+	assert.Equal(t, []uint64{0, 1, 0}, batch[2].Histogram.Counts)
+
+	require.NoError(t, r.Flush(context.Background()))
+	batch = exp.last(t)
+	require.Len(t, batch, 1, "only the gauge is sent again")
+	assert.Equal(t, "queue", batch[0].ID)
+}
+
+func TestRegistry_FlushFailure(t *testing.T) {
+	exp := &fakeExporter{err: errors.New("server is not available")}
+	r := newRegistry(exp, nil)
+	c := r.Counter("requests", nil)
+	h := r.Histogram("latency", nil, nil)
+
+	c.Inc()
+	h.Observe(1)
+	assert.Error(t, r.Flush(context.Background()))
+
+	c.Inc()
+	h.Observe(2)
+	exp.err = nil
+	require.NoError(t, r.Flush(context.Background()))
+	batch := exp.last(t)
+	require.Len(t, batch, 2)
+	assert.Equal(t, int64(2), *batch[0].Delta, "unsent increments are kept")
+	assert.Equal(t, uint64(2), batch[1].Histogram.Count, "unsent observations are kept")
+	assert.Equal(t, DefaultBounds, batch[1].Histogram.Bounds)
+}
+
+func TestRegistry_InvalidMetric(t *testing.T) {
+	r := newRegistry(&fakeExporter{}, nil)
+	assert.Panics(t, func() { r.Counter("", nil) })
+	assert.Panics(t, func() { r.Gauge("cpu{core=\"0\"}", nil) })
+	assert.Panics(t, func() { r.Histogram("latency", nil, []float64{1, 1}) })
+}
+
+func TestNew_HTTP(t *testing.T) {
+	received := make(chan []metrics.Metric, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/updates/", r.URL.Path)
+		body, err := gzip.NewCompressReader(r.Body)
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, sign.CalcHash(data, "secret"), r.Header.Get("HashSHA256"))
+		var batch []metrics.Metric
+		require.NoError(t, jsoniter.Unmarshal(data, &batch))
+		received <- batch
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r, err := New(Config{Address: server.URL, HashKey: "secret", FlushInterval: time.Hour})
+	require.NoError(t, err)
+	r.Counter("requests", nil).Inc()
+	require.NoError(t, r.Close(context.Background()))
+	require.NoError(t, r.Close(context.Background()))
+
+	select {
+	case batch := <-received:
+		require.Len(t, batch, 1)
+		assert.Equal(t, "requests", batch[0].ID)
+		assert.Equal(t, int64(1), *batch[0].Delta)
+	default:
+		t.Fatal("metrics are not flushed on close")
+	}
+}
+
+func TestNew_PeriodicFlush(t *testing.T) {
+	exp := &fakeExporter{}
+	r := newRegistry(exp, nil)
+	r.Gauge("up", nil).Set(1)
+	r.start(10 * time.Millisecond)
+	defer r.Close(context.Background())
+
+	assert.Eventually(t, func() bool {
+		exp.mx.Lock()
+		defer exp.mx.Unlock()
+		return len(exp.batches) >= 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestNew_ServerRoundTrip(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	publicPath := filepath.Join(dir, "public.pem")
+	privatePath := filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}), 0o644))
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), 0o600))
+	keyring := crypt.NewKeyring(privatePath)
+	require.NoError(t, keyring.Reload())
+
+	memStorage := storage.NewMemStorage()
+	logger := zap.NewNop().Sugar()
+	router := handlers.NewRoute(handlers.NewMetricsHandler(memStorage, nil, logger), logger, config.ServerConfig{HashKey: "secret"}, keyring)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	r, err := New(Config{Address: server.URL, HashKey: "secret", CryptoKey: publicPath, Labels: map[string]string{"service": "api"}})
+	require.NoError(t, err)
+	r.Counter("requests", nil).Add(3)
+	r.Gauge("queue", nil).Set(12)
+	r.Histogram("latency", nil, []float64{0.1, 1}).Observe(0.3)
+	require.NoError(t, r.Close(context.Background()))
+
+	ctx := context.Background()
+	labels := map[string]string{"service": "api"}
+	counter, err := memStorage.GetCounter(ctx, metrics.SeriesKey("requests", labels))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), counter)
+	gauge, err := memStorage.GetGauge(ctx, metrics.SeriesKey("queue", labels))
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, gauge)
+	hist, err := memStorage.GetHistogram(ctx, metrics.SeriesKey("latency", labels))
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 0}, hist.Counts)
+}
+
+func TestNew_GRPC(t *testing.T) {
+	r, err := New(Config{Address: "localhost:3200", GRPC: true})
+	require.NoError(t, err)
+	_, ok := r.exporter.(*client.GRPCClient)
+	assert.True(t, ok)
+	assert.NoError(t, r.Close(context.Background()), "nothing to flush, connection is closed")
+}
@@ -0,0 +1,102 @@
+package instrument
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+// DefaultBounds - границы корзин гистограммы по умолчанию, подходящие для длительностей в секундах.
+var DefaultBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Counter - метрика типа counter. На сервер отправляется приращение с предыдущей отправки.
+// Безопасна для одновременного использования.
+type Counter struct {
+	name  metrics.MetricName
+	delta atomic.Int64
+}
+
+// Inc увеличивает счётчик на 1.
+func (c *Counter) Inc() {
+	c.delta.Add(1)
+}
+
+// Add увеличивает счётчик на n. Отрицательные значения игнорируются: счётчик не уменьшается.
+func (c *Counter) Add(n int64) {
+	if n > 0 {
+		c.delta.Add(n)
+	}
+}
+
+// Gauge - метрика типа gauge. На сервер отправляется последнее установленное значение.
+// Безопасна для одновременного использования.
+type Gauge struct {
+	name metrics.MetricName
+	bits atomic.Uint64
+	set  atomic.Bool
+}
+
+// Set устанавливает значение метрики.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+	g.set.Store(true)
+}
+
+// Add прибавляет к значению метрики delta, например для учёта числа выполняющихся запросов.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			g.set.Store(true)
+			return
+		}
+	}
+}
+
+// value возвращает значение метрики; ok равно false, если значение не установлено или не является числом.
+func (g *Gauge) value() (v float64, ok bool) {
+	v = math.Float64frombits(g.bits.Load())
+	return v, g.set.Load() && !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// Histogram - метрика типа histogram. На сервер отправляются наблюдения с предыдущей отправки,
+// сервер добавляет их к сохранённой гистограмме. Безопасна для одновременного использования.
+type Histogram struct {
+	name metrics.MetricName
+
+	mx    sync.Mutex
+	value metrics.HistogramValue
+}
+
+// Observe добавляет наблюдение v. Значения NaN игнорируются.
+func (h *Histogram) Observe(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.value.Observe(v)
+}
+
+// take возвращает наблюдения с предыдущей отправки и начинает новую гистограмму.
+// Если наблюдений не было, ok равно false.
+func (h *Histogram) take() (v metrics.HistogramValue, ok bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.value.Count == 0 {
+		return metrics.HistogramValue{}, false
+	}
+	v = h.value
+	h.value = metrics.NewHistogramValue(v.Bounds)
+	return v, true
+}
+
+// restore возвращает в гистограмму наблюдения, которые не удалось отправить.
+func (h *Histogram) restore(v metrics.HistogramValue) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	// Границы корзин у взятой и новой гистограмм совпадают, поэтому ошибки быть не может.
+	_ = h.value.Merge(v)
+}
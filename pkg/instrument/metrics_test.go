@@ -0,0 +1,71 @@
+package instrument
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/moonicy/gometrics/internal/metrics"
+)
+
+func TestCounter(t *testing.T) {
+	var c Counter
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	c.Add(5)
+	c.Add(-3)
+	assert.Equal(t, int64(1005), c.delta.Load())
+}
+
+func TestGauge(t *testing.T) {
+	var g Gauge
+	_, ok := g.value()
+	assert.False(t, ok, "gauge without value is not sent")
+
+	g.Add(2)
+	g.Add(1.5)
+	v, ok := g.value()
+	require.True(t, ok)
+	assert.Equal(t, 3.5, v)
+
+	g.Set(-1)
+	v, _ = g.value()
+	assert.Equal(t, -1.0, v)
+
+	g.Set(math.NaN())
+	_, ok = g.value()
+	assert.False(t, ok)
+}
+
+func TestHistogram_TakeRestore(t *testing.T) {
+	h := Histogram{value: metrics.NewHistogramValue([]float64{1, 10})}
+	_, ok := h.take()
+	assert.False(t, ok)
+
+	h.Observe(0.5)
+	h.Observe(5)
+	h.Observe(math.NaN())
+	v, ok := h.take()
+	require.True(t, ok)
+	assert.Equal(t, []uint64{1, 1, 0}, v.Counts)
+	assert.Equal(t, 5.5, v.Sum)
+
+	h.Observe(50)
+	h.restore(v)
+	v, ok = h.take()
+	require.True(t, ok)
+	assert.Equal(t, []uint64{1, 1, 1}, v.Counts)
+	assert.Equal(t, uint64(3), v.Count)
+}
@@ -0,0 +1,58 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ClientUnaryInterceptor возвращает клиентский перехватчик, который добавляет к запросу
+// IP-адрес клиента в метаданных x-real-ip и подпись запроса в метаданных hashsha256.
+// Пустые ip и key не добавляются.
+func ClientUnaryInterceptor(key, ip string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if ip != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RealIPKey, ip)
+		}
+		if key != "" {
+			hash, err := calcHash(req, key)
+			if err != nil {
+				return err
+			}
+			ctx = metadata.AppendToOutgoingContext(ctx, HashKey, hash)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// ClientStreamInterceptor возвращает клиентский перехватчик потоков, который добавляет IP-адрес клиента
+// в метаданных x-real-ip и подписывает каждое отправляемое сообщение с полем signature ключом key.
+// Пустые ip и key не добавляются.
+func ClientStreamInterceptor(key, ip string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if ip != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RealIPKey, ip)
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil || key == "" {
+			return cs, err
+		}
+		return &signedClientStream{ClientStream: cs, key: key}, nil
+	}
+}
+
+// signedClientStream подписывает отправляемые сообщения потока.
+type signedClientStream struct {
+	grpc.ClientStream
+	key string
+}
+
+// SendMsg отправляет подписанную копию сообщения m.
+func (s *signedClientStream) SendMsg(m any) error {
+	signed, err := signMessage(m, s.key)
+	if err != nil {
+		return err
+	}
+	return s.ClientStream.SendMsg(signed)
+}
@@ -0,0 +1,87 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/moonicy/gometrics/pkg/middlewares"
+)
+
+// RealIPKey - ключ метаданных с IP-адресом клиента, аналог заголовка X-Real-IP.
+const RealIPKey = "x-real-ip"
+
+// IPCheckUnaryInterceptor возвращает перехватчик, который пропускает только запросы из доверенной подсети.
+// Адрес клиента берётся из метаданных x-real-ip, а при их отсутствии - из адреса соединения.
+// Если заданы methods, проверяются только вызовы этих методов (полные имена вида /proto.Metrics/UpdateMetrics),
+// иначе - все вызовы. Если подсеть не задана, проверка не выполняется.
+func IPCheckUnaryInterceptor(trustedSubnet string, methods ...string) grpc.UnaryServerInterceptor {
+	checked := checkedMethods(methods)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if checked(info.FullMethod) {
+			if err := checkIP(ctx, trustedSubnet); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// IPCheckStreamInterceptor возвращает потоковый вариант IPCheckUnaryInterceptor.
+func IPCheckStreamInterceptor(trustedSubnet string, methods ...string) grpc.StreamServerInterceptor {
+	checked := checkedMethods(methods)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if checked(info.FullMethod) {
+			if err := checkIP(ss.Context(), trustedSubnet); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// checkedMethods возвращает функцию, которая сообщает, нужно ли проверять вызов метода.
+func checkedMethods(methods []string) func(fullMethod string) bool {
+	if len(methods) == 0 {
+		return func(string) bool { return true }
+	}
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return func(fullMethod string) bool { return set[fullMethod] }
+}
+
+func checkIP(ctx context.Context, trustedSubnet string) error {
+	if trustedSubnet == "" {
+		return nil
+	}
+	ip := clientIP(ctx)
+	if ip == "" || !middlewares.IPInTrustedSubnet(ip, trustedSubnet) {
+		return status.Errorf(codes.PermissionDenied, "ip %q is not in trusted subnet", ip)
+	}
+	return nil
+}
+
+// clientIP возвращает IP-адрес клиента из метаданных x-real-ip или из адреса соединения.
+func clientIP(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RealIPKey); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
@@ -0,0 +1,72 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestIPCheckUnaryInterceptor(t *testing.T) {
+	withPeer := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}})
+	}
+	withRealIP := func(ctx context.Context, ip string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(RealIPKey, ip))
+	}
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		trustedSubnet string
+		want          codes.Code
+	}{
+		{name: "no subnet", ctx: context.Background(), want: codes.OK},
+		{name: "real ip in subnet", ctx: withRealIP(context.Background(), "192.168.1.5"), trustedSubnet: "192.168.1.0/24", want: codes.OK},
+		{name: "real ip not in subnet", ctx: withRealIP(withPeer("192.168.1.5"), "10.0.0.1"), trustedSubnet: "192.168.1.0/24", want: codes.PermissionDenied},
+		{name: "peer in subnet", ctx: withPeer("192.168.1.7"), trustedSubnet: "192.168.1.0/24", want: codes.OK},
+		{name: "peer not in subnet", ctx: withPeer("10.0.0.1"), trustedSubnet: "192.168.1.0/24", want: codes.PermissionDenied},
+		{name: "unknown ip", ctx: context.Background(), trustedSubnet: "192.168.1.0/24", want: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			_, err := IPCheckUnaryInterceptor(tt.trustedSubnet)(tt.ctx, "req", &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+				called = true
+				return nil, nil
+			})
+			assert.Equal(t, tt.want, status.Code(err))
+			assert.Equal(t, tt.want == codes.OK, called)
+		})
+	}
+}
+
+func TestIPCheckStreamInterceptor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RealIPKey, "10.0.0.1"))
+	interceptor := IPCheckStreamInterceptor("192.168.1.0/24")
+
+	err := interceptor(nil, &testServerStream{ctx: ctx}, &grpc.StreamServerInfo{}, func(any, grpc.ServerStream) error {
+		t.Error("handler must not be called")
+		return nil
+	})
+
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestIPCheckUnaryInterceptor_Methods(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RealIPKey, "10.0.0.1"))
+	interceptor := IPCheckUnaryInterceptor("192.168.1.0/24", "/proto.Metrics/UpdateMetrics")
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+
+	_, err := interceptor(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/proto.Metrics/UpdateMetrics"}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = interceptor(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/proto.Metrics/GetMetric"}, handler)
+	assert.NoError(t, err, "reads must not be checked")
+}
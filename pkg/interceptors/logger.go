@@ -0,0 +1,44 @@
+// Package interceptors предоставляет перехватчики gRPC, повторяющие цепочку middleware HTTP-сервера:
+// логирование, проверку подписи HashSHA256, проверку доверенной подсети и восстановление после паники.
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// LoggingUnaryInterceptor возвращает перехватчик, который логирует метод, длительность обработки и код ответа.
+func LoggingUnaryInterceptor(sugar *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		sugar.Infoln(
+			"method", info.FullMethod,
+			"duration", time.Since(start),
+			"code", status.Code(err),
+		)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor возвращает перехватчик, который логирует метод, длительность потока и код завершения.
+func LoggingStreamInterceptor(sugar *zap.SugaredLogger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		err := handler(srv, ss)
+
+		sugar.Infoln(
+			"method", info.FullMethod,
+			"duration", time.Since(start),
+			"code", status.Code(err),
+		)
+		return err
+	}
+}
@@ -0,0 +1,58 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoggingUnaryInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	interceptor := LoggingUnaryInterceptor(zap.New(core).Sugar())
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/GetMetric"}
+
+	resp, err := interceptor(context.Background(), "req", info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	if assert.Equal(t, 1, logs.Len()) {
+		msg := logs.All()[0].Message
+		assert.Contains(t, msg, "/metrics.Metrics/GetMetric")
+		assert.Contains(t, msg, "NotFound")
+	}
+}
+
+func TestLoggingStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	interceptor := LoggingStreamInterceptor(zap.New(core).Sugar())
+	info := &grpc.StreamServerInfo{FullMethod: "/metrics.Metrics/WatchMetrics"}
+
+	err := interceptor(nil, &testServerStream{ctx: context.Background()}, info, func(any, grpc.ServerStream) error {
+		return nil
+	})
+
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, logs.Len()) {
+		msg := logs.All()[0].Message
+		assert.Contains(t, msg, "/metrics.Metrics/WatchMetrics")
+		assert.Contains(t, msg, "OK")
+	}
+}
+
+// testServerStream - поток сервера с заданным контекстом.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
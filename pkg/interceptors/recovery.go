@@ -0,0 +1,37 @@
+package interceptors
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnaryInterceptor возвращает перехватчик, который восстанавливается после паники в обработчике,
+// логирует её и возвращает клиенту статус Internal.
+func RecoveryUnaryInterceptor(sugar *zap.SugaredLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				sugar.Errorw("Panic in gRPC handler", "method", info.FullMethod, "panic", r, zap.StackSkip("stack", 2))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor возвращает потоковый вариант RecoveryUnaryInterceptor.
+func RecoveryStreamInterceptor(sugar *zap.SugaredLogger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				sugar.Errorw("Panic in gRPC handler", "method", info.FullMethod, "panic", r, zap.StackSkip("stack", 2))
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,47 @@
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	interceptor := RecoveryUnaryInterceptor(zap.New(core).Sugar())
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	_, err := interceptor(context.Background(), "req", info, func(context.Context, any) (any, error) {
+		panic("boom")
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	if assert.Equal(t, 1, logs.Len()) {
+		assert.Equal(t, "boom", logs.All()[0].ContextMap()["panic"])
+	}
+
+	resp, err := interceptor(context.Background(), "req", info, func(context.Context, any) (any, error) {
+		return "resp", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "resp", resp)
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	interceptor := RecoveryStreamInterceptor(zap.New(core).Sugar())
+	info := &grpc.StreamServerInfo{FullMethod: "/metrics.Metrics/StreamMetrics"}
+
+	err := interceptor(nil, &testServerStream{ctx: context.Background()}, info, func(any, grpc.ServerStream) error {
+		panic("boom")
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, 1, logs.Len())
+}
@@ -0,0 +1,152 @@
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	sign "github.com/moonicy/gometrics/pkg/hash"
+)
+
+// HashKey - ключ метаданных с подписью сообщения, аналог заголовка HashSHA256.
+const HashKey = "hashsha256"
+
+// SignCheckUnaryInterceptor возвращает перехватчик, который проверяет подпись запроса и подписывает ответ.
+// Подпись вычисляется функцией hash.CalcHash от детерминированного protobuf-представления сообщения.
+// Как и SignCheckMiddleware, пропускает запросы без подписи, а при несовпадении возвращает статус Unauthenticated.
+// Подпись ответа передаётся в заголовке hashsha256. Если ключ не задан, проверка не выполняется.
+func SignCheckUnaryInterceptor(key string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if key == "" {
+			return handler(ctx, req)
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(HashKey); len(values) > 0 && values[0] != "" {
+				hash, err := calcHash(req, key)
+				if err != nil {
+					return nil, status.Errorf(codes.Internal, "sign request: %v", err)
+				}
+				if values[0] != hash {
+					return nil, status.Error(codes.Unauthenticated, "invalid request signature")
+				}
+			}
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if hash, err := calcHash(resp, key); err == nil {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(HashKey, hash))
+		}
+		return resp, nil
+	}
+}
+
+// SignatureField - имя строкового поля, в котором передаётся подпись сообщения потока.
+// Метаданные передаются один раз при открытии потока, поэтому каждое сообщение подписывается в этом поле.
+const SignatureField = "signature"
+
+// SignCheckStreamInterceptor возвращает потоковый вариант SignCheckUnaryInterceptor. Каждое принятое сообщение
+// с полем signature должно быть подписано: сообщение без подписи или с неверной подписью завершает поток
+// статусом Unauthenticated. Сообщения без такого поля не проверяются. Если ключ не задан, проверка не выполняется.
+func SignCheckStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if key == "" {
+			return handler(srv, ss)
+		}
+		return handler(srv, &signedServerStream{ServerStream: ss, key: key})
+	}
+}
+
+// signedServerStream проверяет подписи принимаемых сообщений.
+type signedServerStream struct {
+	grpc.ServerStream
+	key string
+}
+
+// RecvMsg принимает сообщение и проверяет его подпись.
+func (s *signedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return verifyMessage(m, s.key)
+}
+
+// signatureField возвращает поле подписи сообщения или nil, если его нет.
+func signatureField(m proto.Message) protoreflect.FieldDescriptor {
+	fd := m.ProtoReflect().Descriptor().Fields().ByName(SignatureField)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return nil
+	}
+	return fd
+}
+
+// messageHash вычисляет подпись сообщения с пустым полем подписи fd.
+func messageHash(m proto.Message, fd protoreflect.FieldDescriptor, key string) (string, error) {
+	unsigned := proto.Clone(m)
+	unsigned.ProtoReflect().Clear(fd)
+	return calcHash(unsigned, key)
+}
+
+// signMessage возвращает копию сообщения msg с подписью в поле signature.
+// Сообщения без поля подписи возвращаются без изменений.
+func signMessage(msg any, key string) (any, error) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return msg, nil
+	}
+	fd := signatureField(m)
+	if fd == nil {
+		return msg, nil
+	}
+	hash, err := messageHash(m, fd, key)
+	if err != nil {
+		return nil, err
+	}
+	signed := proto.Clone(m)
+	signed.ProtoReflect().Set(fd, protoreflect.ValueOfString(hash))
+	return signed, nil
+}
+
+// verifyMessage проверяет подпись в поле signature сообщения msg.
+func verifyMessage(msg any, key string) error {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", msg)
+	}
+	fd := signatureField(m)
+	if fd == nil {
+		return nil
+	}
+	signature := m.ProtoReflect().Get(fd).String()
+	if signature == "" {
+		return status.Error(codes.Unauthenticated, "missing message signature")
+	}
+	hash, err := messageHash(m, fd, key)
+	if err != nil {
+		return status.Errorf(codes.Internal, "sign message: %v", err)
+	}
+	if signature != hash {
+		return status.Error(codes.Unauthenticated, "invalid message signature")
+	}
+	return nil
+}
+
+// calcHash вычисляет подпись protobuf-сообщения.
+func calcHash(msg any, key string) (string, error) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return "", status.Errorf(codes.Internal, "unexpected message type %T", msg)
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return sign.CalcHash(body, key), nil
+}
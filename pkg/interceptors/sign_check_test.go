@@ -0,0 +1,168 @@
+package interceptors
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/moonicy/gometrics/proto"
+)
+
+func TestSignCheckUnaryInterceptor(t *testing.T) {
+	req := &pb.GetMetricRequest{Id: "Alloc", Type: "gauge", Labels: map[string]string{"host": "a", "env": "prod"}}
+	hash, err := calcHash(req, "secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  string
+		hash string
+		want codes.Code
+	}{
+		{name: "no key", hash: "wrong", want: codes.OK},
+		{name: "valid signature", key: "secret", hash: hash, want: codes.OK},
+		{name: "no signature", key: "secret", want: codes.OK},
+		{name: "invalid signature", key: "secret", hash: "wrong", want: codes.Unauthenticated},
+		{name: "other key", key: "other", hash: hash, want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.hash != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(HashKey, tt.hash))
+			}
+			called := false
+			_, err := SignCheckUnaryInterceptor(tt.key)(ctx, req, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+				called = true
+				return &pb.GetMetricResponse{}, nil
+			})
+			assert.Equal(t, tt.want, status.Code(err))
+			assert.Equal(t, tt.want == codes.OK, called)
+		})
+	}
+}
+
+// testMetricsServer проверяет метаданные, переданные клиентским перехватчиком.
+type testMetricsServer struct {
+	pb.UnimplementedMetricsServer
+	ip chan string
+}
+
+func (s *testMetricsServer) GetMetric(ctx context.Context, _ *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+	s.ip <- clientIP(ctx)
+	return &pb.GetMetricResponse{Metric: &pb.Metric{Value: &pb.Metric_Gauge{Gauge: &pb.Gauge{Id: "Alloc", Value: 1}}}}, nil
+}
+
+func (s *testMetricsServer) StreamMetrics(stream pb.Metrics_StreamMetricsServer) error {
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return stream.SendAndClose(&pb.UpdateMetricsResponse{})
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *testMetricsServer) WatchMetrics(_ *pb.WatchMetricsRequest, stream pb.Metrics_WatchMetricsServer) error {
+	s.ip <- clientIP(stream.Context())
+	return nil
+}
+
+func TestClientInterceptors(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(IPCheckUnaryInterceptor("192.168.1.0/24"), SignCheckUnaryInterceptor("secret")),
+		grpc.ChainStreamInterceptor(IPCheckStreamInterceptor("192.168.1.0/24"), SignCheckStreamInterceptor("secret")),
+	)
+	srv := &testMetricsServer{ip: make(chan string, 1)}
+	pb.RegisterMetricsServer(server, srv)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	dial := func(key, ip string) pb.MetricsClient {
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(ClientUnaryInterceptor(key, ip)),
+			grpc.WithStreamInterceptor(ClientStreamInterceptor(key, ip)),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return pb.NewMetricsClient(conn)
+	}
+	ctx := context.Background()
+	req := &pb.GetMetricRequest{Id: "Alloc", Type: "gauge", Labels: map[string]string{"host": "a"}}
+
+	var header metadata.MD
+	resp, err := dial("secret", "192.168.1.5").GetMetric(ctx, req, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.5", <-srv.ip)
+	respHash, err := calcHash(resp, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, []string{respHash}, header.Get(HashKey))
+
+	_, err = dial("other", "192.168.1.5").GetMetric(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = dial("secret", "10.0.0.1").GetMetric(ctx, req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := dial("", "192.168.1.5").WatchMetrics(ctx, &pb.WatchMetricsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "192.168.1.5", <-srv.ip)
+
+	update := &pb.UpdateMetricsRequest{Gauges: []*pb.Gauge{{Id: "Alloc", Value: 1}}}
+	for _, tt := range []struct {
+		key  string
+		want codes.Code
+	}{
+		{key: "secret", want: codes.OK},
+		{key: "", want: codes.Unauthenticated},
+		{key: "other", want: codes.Unauthenticated},
+	} {
+		updates, err := dial(tt.key, "192.168.1.5").StreamMetrics(ctx)
+		require.NoError(t, err)
+		for range 2 {
+			// Сервер может завершить поток после первого сообщения, тогда Send возвращает io.EOF.
+			if err = updates.Send(update); err != nil {
+				break
+			}
+		}
+		_, err = updates.CloseAndRecv()
+		assert.Equal(t, tt.want, status.Code(err), "key %q", tt.key)
+	}
+	assert.Empty(t, update.Signature, "client must sign a copy of the message")
+}
+
+func TestSignMessage(t *testing.T) {
+	req := &pb.UpdateMetricsRequest{Counters: []*pb.Counter{{Id: "PollCount", Delta: 5}}}
+	signed, err := signMessage(req, "secret")
+	require.NoError(t, err)
+	assert.NotEmpty(t, signed.(*pb.UpdateMetricsRequest).Signature)
+	assert.NoError(t, verifyMessage(signed, "secret"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(verifyMessage(signed, "other")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(verifyMessage(req, "secret")))
+
+	signed.(*pb.UpdateMetricsRequest).Counters[0].Delta = 6
+	assert.Equal(t, codes.Unauthenticated, status.Code(verifyMessage(signed, "secret")))
+
+	watch := &pb.WatchMetricsRequest{}
+	unchanged, err := signMessage(watch, "secret")
+	require.NoError(t, err)
+	assert.Same(t, watch, unchanged)
+	assert.NoError(t, verifyMessage(watch, "secret"))
+}
@@ -8,11 +8,22 @@ import (
 	"net/http"
 )
 
-// CryptMiddleware .
-func CryptMiddleware(publicKeyPath, privateKeyPath string) func(http.Handler) http.Handler {
+// CryptMiddleware возвращает middleware, который расшифровывает тело запроса ключом из keyring
+// и, если задан publicKeyPath, шифрует ответ открытым ключом из этого файла.
+// Формат тела запроса выбирается по заголовку X-Encryption: конверт RSA-OAEP + AES-GCM для значения
+// rsa-oaep-aes256gcm, иначе - посегментное RSA-PKCS1v15, которое отправляют агенты предыдущих версий.
+// Ключ выбирается по идентификатору из заголовка X-Key-ID, без него перебираются все ключи.
+// Если в keyring нет ни одного ключа, тело запроса без заголовка X-Encryption передаётся без изменений.
+// Ответ шифруется только в конверт: формат и идентификатор ключа передаются в заголовках X-Encryption и X-Key-ID.
+func CryptMiddleware(publicKeyPath string, keyring *crypt.Keyring) func(http.Handler) http.Handler {
+	var responseKey *crypt.PublicKeyFile
+	if publicKeyPath != "" {
+		responseKey = crypt.NewPublicKeyFile(publicKeyPath)
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Body != nil {
+			encryption := r.Header.Get(crypt.EncryptionHeader)
+			if r.Body != nil && (encryption != "" || !keyring.Empty()) {
 				bodyBytes, err := io.ReadAll(r.Body)
 				if err != nil {
 					http.Error(w, "Failed to read request body", http.StatusInternalServerError)
@@ -20,7 +31,16 @@ func CryptMiddleware(publicKeyPath, privateKeyPath string) func(http.Handler) ht
 				}
 
 				if len(bodyBytes) != 0 {
-					decryptedBody, err := crypt.Decrypt(privateKeyPath, bodyBytes)
+					decrypt := keyring.DecryptLegacy
+					switch encryption {
+					case "", crypt.EncryptionLegacy:
+					case crypt.EncryptionEnvelope:
+						decrypt = keyring.DecryptEnvelope
+					default:
+						http.Error(w, "Unsupported encryption", http.StatusUnsupportedMediaType)
+						return
+					}
+					decryptedBody, err := decrypt(r.Header.Get(crypt.KeyIDHeader), bodyBytes)
 					if err != nil {
 						http.Error(w, "Failed to decrypt request", http.StatusInternalServerError)
 						return
@@ -30,24 +50,48 @@ func CryptMiddleware(publicKeyPath, privateKeyPath string) func(http.Handler) ht
 				}
 			}
 
-			responseRecorder := &ResponseRecorder{ResponseWriter: w, body: &bytes.Buffer{}}
-			next.ServeHTTP(responseRecorder, r)
+			if responseKey == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			buffered := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(buffered, r)
 
-			encryptedResponse, err := crypt.Encrypt(publicKeyPath, responseRecorder.body.Bytes())
+			encryptedResponse, keyID, err := responseKey.Encrypt(buffered.body.Bytes())
 			if err != nil {
 				http.Error(w, "Failed to encrypt response", http.StatusInternalServerError)
 				return
 			}
 
+			w.Header().Del("Content-Length")
 			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set(crypt.EncryptionHeader, crypt.EncryptionEnvelope)
+			w.Header().Set(crypt.KeyIDHeader, keyID)
+			w.WriteHeader(buffered.status)
 			_, err = w.Write(encryptedResponse)
 			if err != nil {
-				log.Fatal(err)
+				log.Printf("Failed to write response: %v", err)
 			}
 		})
 	}
 }
 
+// bufferedResponse накапливает код и тело ответа, чтобы зашифровать тело целиком до отправки клиенту.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bufferedResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *bufferedResponse) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
 // ResponseRecorder для перехвата данных ответа
 type ResponseRecorder struct {
 	http.ResponseWriter
@@ -6,6 +6,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"github.com/moonicy/gometrics/pkg/crypt"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
@@ -118,7 +119,7 @@ func TestCryptMiddleware_DecryptionError(t *testing.T) {
 		t.Error("Handler should not be called")
 	})
 
-	middleware := CryptMiddleware(publicKeyPath, privateKeyPath)
+	middleware := CryptMiddleware(publicKeyPath, newKeyring(t, privateKeyPath))
 	server := httptest.NewServer(middleware(handler))
 	defer server.Close()
 
@@ -143,7 +144,7 @@ func TestCryptMiddleware_ReadBodyError(t *testing.T) {
 		t.Error("Handler should not be called")
 	})
 
-	middleware := CryptMiddleware(publicKeyPath, privateKeyPath)
+	middleware := CryptMiddleware(publicKeyPath, newKeyring(t, privateKeyPath))
 	server := httptest.NewServer(middleware(handler))
 	defer server.Close()
 
@@ -164,3 +165,147 @@ type BrokenReader struct{}
 func (br *BrokenReader) Read(p []byte) (int, error) {
 	return 0, io.ErrUnexpectedEOF
 }
+
+func TestCryptMiddleware_Envelope(t *testing.T) {
+	publicKeyPath, privateKeyPath, cleanup, err := generateRSAKeys()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	var body []byte
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err = io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(CryptMiddleware(publicKeyPath, newKeyring(t, privateKeyPath))(handler))
+	defer server.Close()
+
+	data := bytes.Repeat([]byte("metrics payload "), 1000)
+	envelope, err := crypt.EncryptEnvelope(publicKeyPath, data)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(envelope))
+	assert.NoError(t, err)
+	req.Header.Set(crypt.EncryptionHeader, crypt.EncryptionEnvelope)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, data, body)
+}
+
+func TestCryptMiddleware_UnsupportedEncryption(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not be called")
+	})
+	server := httptest.NewServer(CryptMiddleware("", crypt.NewKeyring())(handler))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte("data")))
+	assert.NoError(t, err)
+	req.Header.Set(crypt.EncryptionHeader, "rot13")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func newKeyring(t *testing.T, privateKeyPath string) *crypt.Keyring {
+	t.Helper()
+	keyring := crypt.NewKeyring(privateKeyPath)
+	assert.NoError(t, keyring.Reload())
+	return keyring
+}
+
+func TestCryptMiddleware_KeyRotation(t *testing.T) {
+	oldPublic, oldPrivate, cleanupOld, err := generateRSAKeys()
+	assert.NoError(t, err)
+	defer cleanupOld()
+	newPublic, newPrivate, cleanupNew, err := generateRSAKeys()
+	assert.NoError(t, err)
+	defer cleanupNew()
+
+	keyring := crypt.NewKeyring(oldPrivate, newPrivate)
+	assert.NoError(t, keyring.Reload())
+	var body []byte
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err = io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(CryptMiddleware(newPublic, keyring)(handler))
+	defer server.Close()
+
+	for _, publicKeyPath := range []string{oldPublic, newPublic} {
+		envelope, keyID, err := crypt.NewPublicKeyFile(publicKeyPath).Encrypt([]byte("payload"))
+		assert.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(envelope))
+		assert.NoError(t, err)
+		req.Header.Set(crypt.EncryptionHeader, crypt.EncryptionEnvelope)
+		req.Header.Set(crypt.KeyIDHeader, keyID)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []byte("payload"), body)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte{crypt.EnvelopeV1}))
+	assert.NoError(t, err)
+	req.Header.Set(crypt.EncryptionHeader, crypt.EncryptionEnvelope)
+	req.Header.Set(crypt.KeyIDHeader, "unknown")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestCryptMiddleware_EncryptResponse(t *testing.T) {
+	publicKeyPath, privateKeyPath, cleanup, err := generateRSAKeys()
+	assert.NoError(t, err)
+	defer cleanup()
+
+	keyring := newKeyring(t, privateKeyPath)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("response payload"))
+	})
+	server := httptest.NewServer(CryptMiddleware(publicKeyPath, keyring)(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, crypt.EncryptionEnvelope, resp.Header.Get(crypt.EncryptionHeader))
+	decrypted, err := keyring.DecryptEnvelope(resp.Header.Get(crypt.KeyIDHeader), body)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("response payload"), decrypted)
+}
+
+func TestCryptMiddleware_PlainResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("response payload"))
+	})
+	server := httptest.NewServer(CryptMiddleware("", crypt.NewKeyring())(handler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(crypt.EncryptionHeader))
+	assert.Equal(t, "response payload", string(body))
+}
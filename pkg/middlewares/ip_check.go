@@ -28,8 +28,8 @@ func IPCheckMiddleware(trustedSubnet string) func(http.Handler) http.Handler {
 					res.WriteHeader(http.StatusForbidden)
 					return
 				}
-				handler.ServeHTTP(res, req)
 			}
+			handler.ServeHTTP(res, req)
 		})
 	}
 }
@@ -284,6 +284,12 @@ func TestIPCheckMiddleware(t *testing.T) {
 			xRealIP:       "",
 			wantStatus:    http.StatusForbidden,
 		},
+		{
+			name:          "No trusted subnet",
+			trustedSubnet: "",
+			xRealIP:       "",
+			wantStatus:    http.StatusOK,
+		},
 		{
 			name:          "Invalid trusted subnet",
 			trustedSubnet: "invalid_subnet",
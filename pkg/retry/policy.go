@@ -0,0 +1,129 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrExhausted возвращается Policy.Do, когда попытки закончились, а ошибка осталась повторяемой.
+var ErrExhausted = errors.New("retry attempts exhausted")
+
+// Attempt описывает завершённую попытку и передаётся в Policy.OnAttempt.
+type Attempt struct {
+	Number  int           // Number - номер попытки, начиная с 1.
+	Err     error         // Err - ошибка попытки или nil при успехе.
+	Delay   time.Duration // Delay - пауза перед следующей попыткой; 0, если повторов больше не будет.
+	Elapsed time.Duration // Elapsed - время с начала первой попытки.
+}
+
+// Policy задаёт правила повторных попыток: число попыток, экспоненциальную паузу со случайным
+// разбросом и ограничение общего времени. Повторяются только ошибки RetryableError.
+// Нулевое значение Policy выполняет одну попытку без повторов.
+type Policy struct {
+	// MaxAttempts - максимальное число попыток, включая первую.
+	MaxAttempts int
+	// InitialInterval - пауза перед первым повтором.
+	InitialInterval time.Duration
+	// MaxInterval - максимальная пауза между попытками; 0 снимает ограничение.
+	MaxInterval time.Duration
+	// Multiplier - множитель паузы после каждого повтора; значения меньше 1 означают постоянную паузу.
+	Multiplier float64
+	// Jitter - доля случайного отклонения паузы от 0 до 1: пауза выбирается из interval*(1±Jitter).
+	Jitter float64
+	// MaxElapsedTime - максимальное время от начала первой попытки; 0 снимает ограничение.
+	// Повтор не начинается, если пауза перед ним выйдет за этот предел.
+	MaxElapsedTime time.Duration
+	// AttemptTimeout - время на одну попытку; 0 снимает ограничение.
+	// Контекст попытки отменяется после её завершения, поэтому его нельзя использовать для результатов,
+	// которые читаются позже, например для sql.Rows.
+	AttemptTimeout time.Duration
+	// OnAttempt вызывается после каждой попытки, если задана.
+	OnAttempt func(attempt Attempt)
+}
+
+// DefaultPolicy возвращает политику по умолчанию: до 4 попыток с паузами около 1, 2 и 4 секунд
+// и не более 15 секунд в сумме.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     4,
+		InitialInterval: time.Second,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+		MaxElapsedTime:  15 * time.Second,
+	}
+}
+
+// Do выполняет функцию do и повторяет её, пока она возвращает RetryableError и политика допускает повтор.
+// Ошибка, не являющаяся RetryableError, возвращается сразу. Если попытки исчерпаны, возвращает ошибку,
+// оборачивающую ErrExhausted и последнюю ошибку. Если ctx завершается во время паузы, ожидание прерывается
+// и возвращается ошибка, оборачивающая ctx.Err() и последнюю ошибку.
+func (p Policy) Do(ctx context.Context, do func(ctx context.Context) error) error {
+	start := time.Now()
+	interval := p.InitialInterval
+	for number := 1; ; number++ {
+		err := p.attempt(ctx, do)
+		var re *RetryableError
+		retryable := err != nil && errors.As(err, &re)
+
+		var delay time.Duration
+		exhausted := retryable && number >= p.MaxAttempts
+		if retryable && !exhausted {
+			delay = p.jitter(interval)
+			interval = p.next(interval)
+			if p.MaxElapsedTime > 0 && time.Since(start)+delay > p.MaxElapsedTime {
+				exhausted = true
+				delay = 0
+			}
+		}
+		if p.OnAttempt != nil {
+			p.OnAttempt(Attempt{Number: number, Err: err, Delay: delay, Elapsed: time.Since(start)})
+		}
+		if !retryable {
+			return err
+		}
+		if exhausted {
+			return fmt.Errorf("%w after %d attempts: %w", ErrExhausted, number, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("retry cancelled: %w", errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, do func(ctx context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return do(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	return do(attemptCtx)
+}
+
+// jitter возвращает паузу со случайным отклонением от interval в пределах доли Jitter.
+func (p Policy) jitter(interval time.Duration) time.Duration {
+	if p.Jitter <= 0 || interval <= 0 {
+		return interval
+	}
+	jitter := min(p.Jitter, 1)
+	return time.Duration(float64(interval) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// next возвращает паузу перед следующим повтором.
+func (p Policy) next(interval time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		interval = time.Duration(float64(interval) * p.Multiplier)
+	}
+	if p.MaxInterval > 0 && interval > p.MaxInterval {
+		interval = p.MaxInterval
+	}
+	return interval
+}
@@ -0,0 +1,168 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testPolicy() Policy {
+	return Policy{
+		MaxAttempts:     4,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     4 * time.Millisecond,
+		Multiplier:      2,
+	}
+}
+
+func TestPolicy_Do_SuccessAfterRetries(t *testing.T) {
+	var attempts []Attempt
+	p := testPolicy()
+	p.OnAttempt = func(a Attempt) { attempts = append(attempts, a) }
+
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return NewRetryableError("temporary error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error after retries, got %v", err)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 observed attempts, got %d", len(attempts))
+	}
+	wantDelays := []time.Duration{time.Millisecond, 2 * time.Millisecond, 0}
+	for i, a := range attempts {
+		if a.Number != i+1 {
+			t.Errorf("Attempt %d: expected number %d, got %d", i, i+1, a.Number)
+		}
+		if a.Delay != wantDelays[i] {
+			t.Errorf("Attempt %d: expected delay %v, got %v", i, wantDelays[i], a.Delay)
+		}
+	}
+	if attempts[2].Err != nil {
+		t.Errorf("Expected last attempt to succeed, got %v", attempts[2].Err)
+	}
+}
+
+func TestPolicy_Do_NonRetryableError(t *testing.T) {
+	errExpected := errors.New("non-retryable error")
+	calls := 0
+	err := testPolicy().Do(context.Background(), func(context.Context) error {
+		calls++
+		return errExpected
+	})
+	if err != errExpected {
+		t.Errorf("Expected error %v, got %v", errExpected, err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestPolicy_Do_Exhausted(t *testing.T) {
+	calls := 0
+	err := testPolicy().Do(context.Background(), func(context.Context) error {
+		calls++
+		return NewRetryableError("temporary error")
+	})
+	if !errors.Is(err, ErrExhausted) {
+		t.Errorf("Expected ErrExhausted, got %v", err)
+	}
+	var re *RetryableError
+	if !errors.As(err, &re) {
+		t.Errorf("Expected last RetryableError to be wrapped, got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 attempts, got %d", calls)
+	}
+}
+
+func TestPolicy_Do_ZeroValue(t *testing.T) {
+	calls := 0
+	err := Policy{}.Do(context.Background(), func(context.Context) error {
+		calls++
+		return NewRetryableError("temporary error")
+	})
+	if !errors.Is(err, ErrExhausted) {
+		t.Errorf("Expected ErrExhausted, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestPolicy_Do_CancelDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{MaxAttempts: 4, InitialInterval: time.Hour}
+	p.OnAttempt = func(Attempt) { cancel() }
+
+	start := time.Now()
+	err := p.Do(ctx, func(context.Context) error {
+		return NewRetryableError("temporary error")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected cancellation to interrupt the backoff, took %v", elapsed)
+	}
+}
+
+func TestPolicy_Do_MaxElapsedTime(t *testing.T) {
+	p := Policy{MaxAttempts: 100, InitialInterval: 10 * time.Millisecond, MaxElapsedTime: 25 * time.Millisecond}
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return NewRetryableError("temporary error")
+	})
+	if !errors.Is(err, ErrExhausted) {
+		t.Errorf("Expected ErrExhausted, got %v", err)
+	}
+	if calls < 2 || calls > 3 {
+		t.Errorf("Expected 2 or 3 attempts within max elapsed time, got %d", calls)
+	}
+}
+
+func TestPolicy_Do_AttemptTimeout(t *testing.T) {
+	p := Policy{MaxAttempts: 2, AttemptTimeout: time.Millisecond}
+	calls := 0
+	err := p.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return NewRetryableError(ctx.Err().Error())
+	})
+	if !errors.Is(err, ErrExhausted) {
+		t.Errorf("Expected ErrExhausted, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestPolicy_Jitter(t *testing.T) {
+	p := Policy{Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := p.jitter(100 * time.Millisecond)
+		if d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("Expected jittered delay within [50ms, 150ms], got %v", d)
+		}
+	}
+}
+
+func TestPolicy_Next(t *testing.T) {
+	p := Policy{Multiplier: 3, MaxInterval: 5 * time.Second}
+	if got := p.next(time.Second); got != 3*time.Second {
+		t.Errorf("Expected 3s, got %v", got)
+	}
+	if got := p.next(3 * time.Second); got != 5*time.Second {
+		t.Errorf("Expected interval capped at 5s, got %v", got)
+	}
+	if got := (Policy{}).next(time.Second); got != time.Second {
+		t.Errorf("Expected constant interval without multiplier, got %v", got)
+	}
+}
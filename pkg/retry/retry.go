@@ -1,9 +1,9 @@
+// Package retry предоставляет повторное выполнение операций при временных ошибках.
 package retry
 
 import (
 	"errors"
 	"fmt"
-	"log"
 	"time"
 )
 
@@ -11,11 +11,12 @@ import (
 // Он повторяет попытки с увеличивающимися интервалами ожидания.
 // Если ошибка не является RetryableError, она возвращается немедленно.
 // Если после всех попыток ошибка не устранена, возвращает ошибку с сообщением о тайм-ауте.
+//
+// Deprecated: RetryHandle не учитывает отмену контекста; используйте Policy.Do.
 func RetryHandle(do func() error) error {
 	var err error
 	for _, waitTime := range []time.Duration{0, 1, 3, 5} {
 		time.Sleep(waitTime * time.Second)
-		log.Println("wt: ", waitTime)
 		err = do()
 		if err == nil {
 			return nil